@@ -0,0 +1,435 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/chromedp/cdproto/target"
+
+	"github.com/lightpanda-io/gomcp/pkg/browser"
+)
+
+const (
+	sessionsReapInterval = time.Minute
+	tabsSweepInterval    = time.Minute
+)
+
+// admin restricts the handler to the clients connected from the local host.
+// The admin endpoints expose the sessions ids which must stay private.
+func admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if unixConn(req) {
+			next(w, req)
+			return
+		}
+
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			slog.Debug("admin access denied", slog.String("addr", req.RemoteAddr))
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		next(w, req)
+	}
+}
+
+func handleSessionsList(sessions *Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(sessions.List()); err != nil {
+			slog.Error("sessions list encode", slog.Any("err", err))
+		}
+	}
+}
+
+func handleSessionsKill(sessions *Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var id SessionId
+		if err := id.Set(req.PathValue("id")); err != nil {
+			http.Error(w, "bad id", http.StatusBadRequest)
+			return
+		}
+
+		if !sessions.Kill(id) {
+			http.Error(w, "id not found", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type TabsPruneResponse struct {
+	Pruned int `json:"pruned"`
+}
+
+func handleTabsPrune(srv *MCPServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(TabsPruneResponse{
+			Pruned: srv.tabs.Prune(),
+		})
+		if err != nil {
+			slog.Error("tabs prune encode", slog.Any("err", err))
+		}
+	}
+}
+
+func handleTabsList(srv *MCPServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(srv.tabs.List()); err != nil {
+			slog.Error("tabs list encode", slog.Any("err", err))
+		}
+	}
+}
+
+func handleTabsStats(srv *MCPServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(srv.tabs.Stats()); err != nil {
+			slog.Error("tabs stats encode", slog.Any("err", err))
+		}
+	}
+}
+
+type TabsClearResponse struct {
+	Closed int `json:"closed"`
+}
+
+func handleTabsClear(srv *MCPServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(TabsClearResponse{
+			Closed: srv.tabs.Clear(),
+		})
+		if err != nil {
+			slog.Error("tabs clear encode", slog.Any("err", err))
+		}
+	}
+}
+
+func handleTabsClose(srv *MCPServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.PathValue("id")
+		if id == "" {
+			http.Error(w, "bad id", http.StatusBadRequest)
+			return
+		}
+
+		if err := srv.tabs.CloseTarget(target.ID(id)); err != nil {
+			slog.Error("tabs close", slog.String("id", id), slog.Any("err", err))
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func handleCDPForget(srv *MCPServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		host := req.PathValue("host")
+		if host == "" {
+			http.Error(w, "bad host", http.StatusBadRequest)
+			return
+		}
+
+		if !srv.endpoints.Forget(host) {
+			http.Error(w, "host not found", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func handleCDPDrain(srv *MCPServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		host := browser.Host(req.PathValue("host"))
+		if host == "" {
+			http.Error(w, "bad host", http.StatusBadRequest)
+			return
+		}
+
+		err := srv.tabs.Drain(host, func(err error) {
+			if err != nil {
+				slog.Error("cdp drain", slog.String("host", host), slog.Any("err", err))
+				return
+			}
+			srv.endpoints.Forget(host)
+			slog.Info("cdp drained", slog.String("host", host))
+		})
+		if errors.Is(err, ErrDraining) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// runsessions executes the sessions admin command against the running sse
+// server.
+func runsessions(ctx context.Context, api apiEndpoint, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing sessions command: list|kill <id>")
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return errors.New("usage: sessions list")
+		}
+
+		resp, err := adminreq(ctx, http.MethodGet, api, "/sessions")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		var infos []SessionInfo
+		if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+			return fmt.Errorf("decode sessions: %w", err)
+		}
+
+		for _, info := range infos {
+			fmt.Fprintf(out, "%s\tcreated %s\tlast used %s",
+				info.Id,
+				info.CreatedAt.Format(time.RFC3339),
+				info.LastUsedAt.Format(time.RFC3339),
+			)
+			if h := info.History; h != nil {
+				fmt.Fprintf(out, "\thistory %d pages %d bytes", h.Pages, h.Bytes)
+				if h.MaxBytes > 0 {
+					fmt.Fprintf(out, " quota %d bytes evicted %d", h.MaxBytes, h.Evicted)
+				}
+				if h.QuotaExceeded > 0 {
+					fmt.Fprintf(out, "\tquota exceeded %d", h.QuotaExceeded)
+				}
+			}
+			fmt.Fprintln(out)
+		}
+
+		return nil
+	case "kill":
+		if len(args) != 2 {
+			return errors.New("usage: sessions kill <id>")
+		}
+
+		resp, err := adminreq(ctx, http.MethodDelete, api, "/sessions/"+args[1])
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		return nil
+	}
+
+	return fmt.Errorf("invalid sessions command: %s", args[0])
+}
+
+// runtabs executes the tabs admin command against the running sse server.
+func runtabs(ctx context.Context, api apiEndpoint, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing tabs command: list|stats|clear|close <id>|prune")
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return errors.New("usage: tabs list")
+		}
+
+		var infos []TabInfo
+		if err := admindecode(ctx, http.MethodGet, api, "/tabs", &infos); err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", info.Id, info.Host, info.State, info.Pin)
+		}
+
+		return nil
+	case "stats":
+		if len(args) != 1 {
+			return errors.New("usage: tabs stats")
+		}
+
+		var stats map[string]TabsStats
+		if err := admindecode(ctx, http.MethodGet, api, "/tabs/stats", &stats); err != nil {
+			return err
+		}
+
+		for host, st := range stats {
+			health := "healthy"
+			if !st.Healthy {
+				health = "unhealthy"
+			}
+			if st.Draining {
+				health = "draining"
+			}
+			fmt.Fprintf(out, "%s\t%s\tidle %d\tchecked out %d\tpinned %d\ttotal %d\tmax %d\tpolicy %s\n",
+				host, health, st.Idle, st.CheckedOut, st.Pinned, st.Total, st.Max, st.Policy,
+			)
+			fmt.Fprintf(out, "%s\tcheckouts %d\tcreated %d\twaits %d\twait max %s\ttimeouts %d\trejected %d\tpeak %d\n",
+				host, st.Checkouts, st.Created, st.Waits, st.WaitMax.Round(time.Millisecond), st.Timeouts, st.Rejected, st.Peak,
+			)
+			for _, h := range st.Hints() {
+				fmt.Fprintf(out, "%s\thint: %s\n", host, h)
+			}
+		}
+
+		return nil
+	case "clear":
+		if len(args) != 1 {
+			return errors.New("usage: tabs clear")
+		}
+
+		var res TabsClearResponse
+		if err := admindecode(ctx, http.MethodDelete, api, "/tabs", &res); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "%d tabs closed\n", res.Closed)
+
+		return nil
+	case "close":
+		if len(args) != 2 {
+			return errors.New("usage: tabs close <id>")
+		}
+
+		resp, err := adminreq(ctx, http.MethodPost, api, "/tabs/"+args[1]+"/close")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		return nil
+	case "prune":
+		if len(args) != 1 {
+			return errors.New("usage: tabs prune")
+		}
+
+		var res TabsPruneResponse
+		if err := admindecode(ctx, http.MethodPost, api, "/tabs/prune", &res); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "%d tabs pruned\n", res.Pruned)
+
+		return nil
+	}
+
+	return fmt.Errorf("invalid tabs command: %s", args[0])
+}
+
+// runcdp executes the cdp admin command against the running sse server.
+func runcdp(ctx context.Context, api apiEndpoint, args []string, _ io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing cdp command: forget|drain <host>")
+	}
+
+	switch args[0] {
+	case "forget":
+		if len(args) != 2 {
+			return errors.New("usage: cdp forget <host>")
+		}
+
+		resp, err := adminreq(ctx, http.MethodDelete, api, "/cdp/"+url.PathEscape(args[1]))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		return nil
+	case "drain":
+		if len(args) != 2 {
+			return errors.New("usage: cdp drain <endpoint>")
+		}
+
+		resp, err := adminreq(ctx, http.MethodPost, api, "/cdp/"+url.PathEscape(browser.Host(args[1]))+"/drain")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		return nil
+	}
+
+	return fmt.Errorf("invalid cdp command: %s", args[0])
+}
+
+// adminreq sends a request to the admin api and checks the response status.
+func adminreq(ctx context.Context, method string, api apiEndpoint, path string) (*http.Response, error) {
+	cli, base, err := apiClient(api)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, base+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create http req: %w", err)
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do req: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("bad status code: %d %s", resp.StatusCode, b)
+	}
+
+	return resp, nil
+}
+
+// admindecode sends a request to the admin api and decodes the JSON response
+// into v.
+func admindecode(ctx context.Context, method string, api apiEndpoint, path string, v any) error {
+	resp, err := adminreq(ctx, method, api, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
@@ -15,54 +15,109 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/sse"
+
+	"github.com/lightpanda-io/gomcp/mcp"
 )
 
 // runapi starts http API server.
-// Cancelling ctx will shutdown the http server gracefully.
-func runapi(ctx context.Context, addr string, mcpsrv *MCPServer) error {
-	sessions := NewSessions()
+// Cancelling ctx will shutdown the http server gracefully: the new sessions
+// and requests are refused, the running tool calls are given up to drain to
+// finish, then the sessions are closed and their tabs checked in.
+func runapi(ctx context.Context, addr string, mode FileMode, tlsopts TLSOptions, sessions *Sessions, mcpsrv *MCPServer, drain time.Duration) error {
+	tlscfg, err := tlsopts.Config()
+	if err != nil {
+		return err
+	}
 
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /ack", func(_ http.ResponseWriter, _ *http.Request) {})
+	mux.HandleFunc("GET /metrics", handleMetrics(mcpsrv))
+	mux.HandleFunc("GET /healthz", handleHealth(mcpsrv, false))
+	mux.HandleFunc("GET /readyz", handleHealth(mcpsrv, true))
 
-	mux.HandleFunc("GET /sse", cors(handleSSE(ctx, sessions, mcpsrv)))
-	mux.HandleFunc("POST /messages", cors(handleMessage(ctx, sessions, mcpsrv)))
+	if mcpsrv.OAuth != nil {
+		mux.HandleFunc("GET "+OAuthMetadataPath, cors(handleOAuthMetadata(mcpsrv.OAuth)))
+		mux.HandleFunc("GET "+OAuthMetadataPath+"/", cors(handleOAuthMetadata(mcpsrv.OAuth)))
+	}
+	mux.HandleFunc("GET /sse", cors(mcpsrv.authenticate(handleSSE(ctx, sessions, mcpsrv))))
+	mux.HandleFunc("POST /messages", cors(mcpsrv.authenticate(handleMessage(ctx, sessions, mcpsrv))))
 	mux.HandleFunc("OPTIONS /messages", cors(handleMessage(ctx, sessions, mcpsrv)))
 
+	mux.HandleFunc("GET /sessions", admin(handleSessionsList(sessions)))
+	mux.HandleFunc("DELETE /sessions/{id}", admin(handleSessionsKill(sessions)))
+	mux.HandleFunc("GET /tabs", admin(handleTabsList(mcpsrv)))
+	mux.HandleFunc("DELETE /tabs", admin(handleTabsClear(mcpsrv)))
+	mux.HandleFunc("GET /tabs/stats", admin(handleTabsStats(mcpsrv)))
+	mux.HandleFunc("GET /pipeline/stats", admin(handlePipelineStats(mcpsrv)))
+	mux.HandleFunc("POST /tabs/prune", admin(handleTabsPrune(mcpsrv)))
+	mux.HandleFunc("POST /tabs/{id}/close", admin(handleTabsClose(mcpsrv)))
+	mux.HandleFunc("DELETE /cdp/{host}", admin(handleCDPForget(mcpsrv)))
+	mux.HandleFunc("POST /cdp/{host}/drain", admin(handleCDPDrain(mcpsrv)))
+	mux.HandleFunc("GET /replica/state", replicaAuth(mcpsrv.ReplicaToken, handleReplicaState(mcpsrv, sessions)))
+
+	// close the expired sessions in background.
+	go sessions.Reap(ctx, sessionsReapInterval)
+
+	ln, err := listen(addr, mode)
+	if err != nil {
+		return fmt.Errorf("api server: %w", err)
+	}
+
 	srv := &http.Server{
-		Addr:    addr,
 		Handler: mux,
+		// the requests outlive ctx to be drained.
 		BaseContext: func(net.Listener) context.Context {
-			return ctx
+			return context.WithoutCancel(ctx)
 		},
+		ConnContext: connContext,
+		TLSConfig:   tlscfg,
 	}
 
 	// shutdown api server on context cancelation
 	go func(ctx context.Context, srv *http.Server) {
 		<-ctx.Done()
-		slog.Debug("api server shutting down")
+		slog.Info("api server draining", slog.Duration("timeout", drain))
+		sessions.Drain()
+
 		// we use context.Background() here b/c ctx is already canceled.
-		if err := srv.Shutdown(context.Background()); err != nil {
-			// context cancellation error is ignored.
-			if !errors.Is(err, context.Canceled) {
-				slog.Error("server shutdown", slog.String("err", err.Error()))
-			}
+		dctx, cancel := context.WithTimeout(context.Background(), drain)
+		defer cancel()
+		if err := mcpsrv.Drain(dctx); err != nil {
+			slog.Error("drain tool calls", slog.String("err", err.Error()))
+		}
+		sessions.CloseAll()
+
+		sctx, cancel := context.WithTimeout(context.Background(), apiShutdownTimeout)
+		defer cancel()
+		slog.Debug("api server shutting down")
+		if err := srv.Shutdown(sctx); err != nil {
+			slog.Error("server shutdown", slog.String("err", err.Error()))
+			// drop the connections still open.
+			srv.Close()
 		}
 	}(ctx, srv)
 
-	slog.Info("server listening", slog.String("addr", addr))
+	slog.Info("server listening", slog.String("addr", addr), slog.Bool("tls", tlscfg != nil))
 
-	// ListenAndServe always returns a non-nil error.
-	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+	// the certificates are in the TLS config.
+	if tlscfg != nil {
+		err = srv.ServeTLS(ln, "", "")
+	} else {
+		err = srv.Serve(ln)
+	}
+	// Serve always returns a non-nil error.
+	if err != http.ErrServerClosed {
 		return fmt.Errorf("api server: %w", err)
 	}
 	slog.Info("api server shutdown")
@@ -74,13 +129,14 @@ func cors(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("access-control-allow-credentials", "true")
 		w.Header().Set("access-control-allow-origin", "*")
+		w.Header().Set("access-control-expose-headers", SessionIdHeader)
 
 		w.Header().Set("Cache-Control", "no-cache")
 		w.Header().Set("Connection", "keep-alive")
 
 		if req.Method == http.MethodOptions {
 			w.Header().Set("access-control-allow-methods", "GET,POST")
-			w.Header().Set("access-control-allow-headers", "content-type,Accept,Authorization")
+			w.Header().Set("access-control-allow-headers", "content-type,Accept,Authorization,"+SessionIdHeader)
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
@@ -93,20 +149,74 @@ func handleSSE(_ context.Context, sessions *Sessions, srv *MCPServer) http.Handl
 	return func(w http.ResponseWriter, req *http.Request) {
 		ctx := req.Context()
 
-		w.Header().Set("Content-Type", "text/event-stream")
+		if sessions.Draining() {
+			http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+			return
+		}
 
-		s := NewSession()
+		// a client re-attaches to a session persisted by the previous run
+		// with its id.
+		var (
+			s       *Session
+			rec     SessionRecord
+			resumed bool
+		)
+		if v := cmp.Or(req.Header.Get(SessionIdHeader), req.URL.Query().Get("id")); v != "" {
+			var id SessionId
+			if err := id.Set(v); err != nil {
+				http.Error(w, "bad id", http.StatusBadRequest)
+				return
+			}
+			tok := ""
+			if t := tokenOf(ctx); t != nil {
+				tok = t.Name
+			}
+			if s, rec, resumed = sessions.Resume(id, tok); !resumed {
+				slog.Debug("unknown session id", slog.Any("id", id))
+				http.Error(w, "session not found", http.StatusNotFound)
+				return
+			}
+		} else {
+			s = sessions.New()
+		}
 		defer s.Close()
-
-		sessions.Add(s)
 		defer sessions.Remove(s.id)
 
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set(SessionIdHeader, s.id.String())
+
 		slog.Debug("connect sse", slog.Any("id", s.id))
 		defer slog.Debug("disconnect sse", slog.Any("id", s.id))
 
 		// create the mcpconn
 		mcpconn := srv.NewConn()
 		defer mcpconn.Close()
+		if srv.IsolateSessions {
+			if err := mcpconn.Isolate(ctx); err != nil {
+				slog.Error("isolate session", slog.Any("id", s.id), slog.Any("err", err))
+				http.Error(w, "browser context unavailable", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		mcpconn.session = s.id.String()
+		mcpconn.token = tokenOf(ctx)
+		s.SetHistory(mcpconn.history)
+		s.SetToken(mcpconn.token)
+
+		if resumed {
+			slog.Debug("resume session", slog.Any("id", s.id))
+			sessions.resume(ctx, rec, mcpconn)
+		}
+		// the record is kept on shutdown for the client to resume the
+		// session, it is removed when the session ends.
+		sessions.persist(ctx, s, mcpconn)
+		defer func() {
+			if sessions.Draining() {
+				sessions.persist(context.WithoutCancel(ctx), s, mcpconn)
+				return
+			}
+			sessions.Store.Remove(s.id.String())
+		}()
 
 		f, ok := w.(http.Flusher)
 		if !ok {
@@ -141,6 +251,15 @@ func handleSSE(_ context.Context, sessions *Sessions, srv *MCPServer) http.Handl
 					slog.Error("handle req", slog.Any("err", err))
 					return
 				}
+				// the tool calls change the cookies and the last usage
+				// time of the session.
+				switch rreq.(type) {
+				case mcp.InitializeRequest, mcp.ToolsCallRequest:
+					sessions.persist(ctx, s, mcpconn)
+				}
+			case <-s.Done():
+				slog.Debug("session closed", slog.Any("id", s.id))
+				return
 			case <-req.Context().Done():
 				return
 			case <-ctx.Done():
@@ -167,6 +286,11 @@ func handleMessage(_ context.Context, sessions *Sessions, srv *MCPServer) http.H
 			http.Error(w, "id not found", http.StatusBadRequest)
 			return
 		}
+		if !s.Owned(tokenOf(req.Context())) {
+			slog.Debug("session of another token", slog.Any("id", id))
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
 
 		mcpreq, err := srv.Decode(req.Body)
 		if err != nil {
@@ -182,7 +306,25 @@ func handleMessage(_ context.Context, sessions *Sessions, srv *MCPServer) http.H
 			return
 		}
 
-		s.Requests() <- mcpreq
+		if sessions.Draining() {
+			http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+			return
+		}
+
+		s.Touch()
+
+		select {
+		case s.Requests() <- mcpreq:
+		case <-s.Done():
+			http.Error(w, "session closed", http.StatusGone)
+			return
+		default:
+			// the session's queue is full, the client must slow down.
+			slog.Debug("session queue full", slog.Any("id", id))
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
 
 		w.WriteHeader(http.StatusAccepted)
 	}
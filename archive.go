@@ -0,0 +1,126 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+const ArchiveDefaultTimeout = 2 * time.Minute
+
+// FormatArchive returns the text of the archived page.
+func FormatArchive(a fetch.Archive) string {
+	s := fmt.Sprintf("The page has been archived.\nURL: %s\nFormat: %s\nPath: %s\nSize: %d bytes\n", a.URL, a.Format, a.Path, a.Size)
+	if a.Format == fetch.ArchiveDir {
+		s += fmt.Sprintf("Assets: %d saved, %d missing\n", a.Assets, a.Missing)
+	}
+
+	return s
+}
+
+// runarchive saves the page as rendered in a MHTML file or a directory and
+// prints its path.
+func runarchive(ctx context.Context, mcpsrv *MCPServer, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("archive", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var (
+		out     = flags.String("out", "page", "path of the archive, the .mhtml extension is added to the MHTML file without extension")
+		asjson  = flags.Bool("json", false, "print the result in JSON")
+		timeout = flags.Duration("timeout", ArchiveDefaultTimeout, "timeout of the page load and capture")
+	)
+
+	format := fetch.ArchiveMHTML
+	flags.Var(&format, "format", "format of the archive: mhtml for a single file captured by the browser, dir for a directory of the rendered HTML and its assets")
+
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: archive [options] <url>\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("bad arguments")
+	}
+	u := flags.Arg(0)
+
+	path := *out
+	if format == fetch.ArchiveMHTML && filepath.Ext(path) == "" {
+		path += ".mhtml"
+	}
+
+	if err := mcpsrv.checkURL(ctx, u); err != nil {
+		return err
+	}
+
+	t, err := mcpsrv.tabs.Checkout()
+	if err != nil {
+		return fmt.Errorf("browser connect: %w", err)
+	}
+	defer mcpsrv.tabs.Checkin(t)
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	tctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	release, err := mcpsrv.Limiter.Wait(ctx, u)
+	if err != nil {
+		return err
+	}
+	err = chromedp.Run(tctx, chromedp.Navigate(u))
+	release()
+	if err != nil {
+		mcpsrv.diagnose(t.ctx, nil, "archive", err.Error())
+		return navigateError(u, err)
+	}
+
+	a, err := fetch.ArchivePage(tctx, path, format)
+	if err != nil {
+		mcpsrv.diagnose(t.ctx, nil, "archive", err.Error())
+		return err
+	}
+	// the navigation may have been redirected to a blocked url.
+	if err := mcpsrv.checkURL(ctx, a.URL); err != nil {
+		return err
+	}
+
+	if *asjson {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(a); err != nil {
+			return fmt.Errorf("json encode: %w", err)
+		}
+		return nil
+	}
+
+	_, err = io.WriteString(stdout, FormatArchive(a))
+	return err
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Kinds of the audit events.
+const (
+	AuditToolCall   = "tool_call"
+	AuditNavigation = "navigation"
+)
+
+// AuditEvent is a line of the audit log.
+type AuditEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	// Session of the MCP client, empty for the stdio server and the
+	// commands.
+	Session string `json:"session,omitempty"`
+	// Name of the API token of the MCP client, if any.
+	Token string `json:"token,omitempty"`
+	Tool  string `json:"tool,omitempty"`
+	URL   string `json:"url,omitempty"`
+	// Duration in milliseconds.
+	Duration int64 `json:"duration_ms"`
+	// Size of the tool result or bytes received by the browser during the
+	// navigation.
+	Bytes int64 `json:"bytes"`
+	// ok or the class of the error.
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AuditLog appends the tool calls and the navigations to a JSON lines file.
+// A nil AuditLog discards the events.
+type AuditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenAuditLog opens the audit log file in append mode, it is created if
+// needed.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("audit log: %w", err)
+	}
+
+	return &AuditLog{f: f}, nil
+}
+
+// Close closes the file.
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.f.Close()
+}
+
+// Log appends the event, the failures are logged.
+func (a *AuditLog) Log(ev AuditEvent) {
+	if a == nil {
+		return
+	}
+
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		slog.Error("audit log", slog.Any("err", err))
+		return
+	}
+
+	// a line is written at once to keep the file parsable.
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.f.Write(append(b, '\n')); err != nil {
+		slog.Error("audit log", slog.Any("err", err))
+	}
+}
+
+// toolCall logs the call of the tool name started at start, size is the
+// size of its result. token is the name of the client's API token.
+func (a *AuditLog) toolCall(session, token, name string, start time.Time, size int, err error) {
+	ev := AuditEvent{
+		Time:     start,
+		Event:    AuditToolCall,
+		Session:  session,
+		Token:    token,
+		Tool:     name,
+		Duration: time.Since(start).Milliseconds(),
+		Bytes:    int64(size),
+	}
+	ev.Outcome, ev.Error = outcome(err)
+	a.Log(ev)
+}
+
+// navigation logs the navigation to the url started at start, received is the
+// number of bytes received by the tab.
+func (a *AuditLog) navigation(session, u string, start time.Time, received int64, err error) {
+	ev := AuditEvent{
+		Time:     start,
+		Event:    AuditNavigation,
+		Session:  session,
+		URL:      u,
+		Duration: time.Since(start).Milliseconds(),
+		Bytes:    received,
+	}
+	ev.Outcome, ev.Error = outcome(err)
+	a.Log(ev)
+}
+
+// outcome returns the outcome and the message of the error.
+func outcome(err error) (string, string) {
+	if err == nil {
+		return "ok", ""
+	}
+
+	return classify(err).Name, err.Error()
+}
+
+type auditSessionKey struct{}
+
+// withSession returns a context giving the session of the tool call to the
+// audited navigations.
+func withSession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, auditSessionKey{}, session)
+}
+
+// sessionOf returns the session of the tool call of ctx, if any.
+func sessionOf(ctx context.Context) string {
+	s, _ := ctx.Value(auditSessionKey{}).(string)
+	return s
+}
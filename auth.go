@@ -0,0 +1,267 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/lightpanda-io/gomcp/mcp"
+)
+
+// Scope is a class of tools granted to an API token.
+type Scope string
+
+const (
+	// Load and read the pages: navigation, search and extraction.
+	ScopeRead Scope = "read"
+	// Interact with the pages: clicks, typing, uploads, logins and the
+	// browse and recipes steps.
+	ScopeBrowse Scope = "browse"
+	// Evaluate the selectors and the scripts given by the clients in the
+	// pages: extract_structured and the browse extracts with fields.
+	ScopeEval Scope = "eval"
+)
+
+// toolScopes are the scopes required by the built-in tools. The saved
+// searches tools require read, the recipes and the unknown tools browse.
+var toolScopes = map[string]Scope{
+	"goto":               ScopeRead,
+	"search":             ScopeRead,
+	"search_batch":       ScopeRead,
+	"research":           ScopeRead,
+	"lookup":             ScopeRead,
+	"hostinfo":           ScopeRead,
+	"head":               ScopeRead,
+	"get_feed":           ScopeRead,
+	"diff_page":          ScopeRead,
+	"click":              ScopeBrowse,
+	"type":               ScopeBrowse,
+	"press_key":          ScopeBrowse,
+	"hover":              ScopeBrowse,
+	"scroll_to":          ScopeBrowse,
+	"drag_and_drop":      ScopeBrowse,
+	"set_file_input":     ScopeBrowse,
+	"download":           ScopeBrowse,
+	"markdown":           ScopeRead,
+	"dom":                ScopeRead,
+	"links":              ScopeRead,
+	"extract_structured": ScopeEval,
+	"browse":             ScopeBrowse,
+	"get_history_page":   ScopeRead,
+	"fetch_page_chunk":   ScopeRead,
+	"pin_tab":            ScopeBrowse,
+	"unpin_tab":          ScopeBrowse,
+	"list_pages":         ScopeRead,
+	"switch_page":        ScopeBrowse,
+	"login":              ScopeBrowse,
+	"set_env":            ScopeBrowse,
+	"over":               ScopeRead,
+}
+
+// toolScope returns the scope required by the tool name.
+func (s *MCPServer) toolScope(name string) Scope {
+	if sc, ok := toolScopes[name]; ok {
+		return sc
+	}
+	if _, ok := s.Searches.ByTool(name); ok {
+		return ScopeRead
+	}
+
+	return ScopeBrowse
+}
+
+// APIToken authenticates the MCP clients of the HTTP server.
+type APIToken struct {
+	Name   string
+	Scopes []Scope
+	secret string
+}
+
+// Allows returns true if the token is granted the scope.
+func (t *APIToken) Allows(sc Scope) bool {
+	return slices.Contains(t.Scopes, sc)
+}
+
+// APITokens are the tokens accepted by the HTTP server, without token the
+// clients aren't authenticated.
+// It implements flag.Value, the flag can be repeated.
+type APITokens []*APIToken
+
+var InvalidAPIToken = errors.New("invalid token, the format is name:scope[,scope]:secret with the read, browse or eval scopes, a $VAR secret is read from the environment")
+
+// String doesn't give the secrets.
+func (t *APITokens) String() string {
+	s := make([]string, 0, len(*t))
+	for _, tok := range *t {
+		scopes := make([]string, 0, len(tok.Scopes))
+		for _, sc := range tok.Scopes {
+			scopes = append(scopes, string(sc))
+		}
+		s = append(s, tok.Name+":"+strings.Join(scopes, ","))
+	}
+
+	return strings.Join(s, " ")
+}
+
+func (t *APITokens) Set(v string) error {
+	parts := strings.SplitN(v, ":", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return InvalidAPIToken
+	}
+
+	tok := &APIToken{Name: parts[0], secret: parts[2]}
+	if name, ok := strings.CutPrefix(tok.secret, "$"); ok {
+		tok.secret = os.Getenv(name)
+	}
+	if tok.secret == "" {
+		return InvalidAPIToken
+	}
+
+	for _, sc := range strings.Split(parts[1], ",") {
+		switch sc := Scope(strings.TrimSpace(sc)); sc {
+		case ScopeRead, ScopeBrowse, ScopeEval:
+			tok.Scopes = append(tok.Scopes, sc)
+		default:
+			return InvalidAPIToken
+		}
+	}
+
+	for _, o := range *t {
+		if o.Name == tok.Name {
+			return fmt.Errorf("duplicate token %s", tok.Name)
+		}
+	}
+	*t = append(*t, tok)
+
+	return nil
+}
+
+// Authenticate returns the token of the request's bearer authorization.
+func (t APITokens) Authenticate(req *http.Request) (*APIToken, bool) {
+	got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || got == "" {
+		return nil, false
+	}
+
+	// all the tokens are compared to not leak which one matches.
+	var found *APIToken
+	for _, tok := range t {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(tok.secret)) == 1 {
+			found = tok
+		}
+	}
+
+	return found, found != nil
+}
+
+type apiTokenKey struct{}
+
+// tokenOf returns the token authenticating the request of ctx, if any.
+func tokenOf(ctx context.Context) *APIToken {
+	t, _ := ctx.Value(apiTokenKey{}).(*APIToken)
+	return t
+}
+
+// authenticate restricts the handler to the clients giving one of the tokens
+// or an access token accepted by OAuth, the token is given to the handler in
+// the request's context. Without tokens nor OAuth, the clients aren't
+// authenticated.
+func (s *MCPServer) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	if len(s.Tokens) == 0 && s.OAuth == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		tok, ok := s.Tokens.Authenticate(req)
+		var err error
+		if !ok && s.OAuth != nil {
+			tok, err = s.OAuth.Authenticate(req)
+			ok = err == nil
+		}
+		if !ok {
+			slog.Debug("unauthenticated client", slog.String("addr", req.RemoteAddr), slog.Any("err", err))
+			if s.OAuth != nil {
+				s.OAuth.challenge(w, err)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gomcp"`)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, req.WithContext(context.WithValue(req.Context(), apiTokenKey{}, tok)))
+	}
+}
+
+// ScopeError is returned when the token of the connection isn't granted the
+// scope required by a tool.
+type ScopeError struct {
+	Tool  string
+	Scope Scope
+	Token string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("forbidden: tool %s requires the %s scope, not granted to the token %s", e.Tool, e.Scope, e.Token)
+}
+
+// authorize returns a ScopeError if the token of the connection isn't granted
+// the scope of the tool name. The connections without token are allowed.
+func (c *MCPConn) authorize(name string) error {
+	if c.token == nil {
+		return nil
+	}
+
+	return c.authorizeScope(name, c.srv.toolScope(name))
+}
+
+// authorizeScope returns an error if the token of the connection isn't
+// granted the scope sc required by the tool name.
+func (c *MCPConn) authorizeScope(name string, sc Scope) error {
+	if c.token != nil && !c.token.Allows(sc) {
+		return &ScopeError{Tool: name, Scope: sc, Token: c.token.Name}
+	}
+
+	return nil
+}
+
+// allowedTools returns the tools granted to the token of the connection.
+func (c *MCPConn) allowedTools(tools []mcp.Tool) []mcp.Tool {
+	if c.token == nil {
+		return tools
+	}
+
+	return slices.DeleteFunc(tools, func(t mcp.Tool) bool {
+		return c.authorize(t.Name) != nil
+	})
+}
+
+// tokenName returns the name of the token of the connection, empty without
+// token.
+func (c *MCPConn) tokenName() string {
+	if c.token == nil {
+		return ""
+	}
+
+	return c.token.Name
+}
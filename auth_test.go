@@ -0,0 +1,152 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/lightpanda-io/gomcp/mcp"
+)
+
+func TestToolScope(t *testing.T) {
+	s := &MCPServer{
+		Searches: SavedSearches{"news": {Tool: "search_news"}},
+		Recipes:  Recipes{"prices": {Tool: "get_prices"}},
+	}
+
+	for _, tc := range []struct {
+		tool string
+		want Scope
+	}{
+		{"goto", ScopeRead},
+		{"markdown", ScopeRead},
+		{"get_history_page", ScopeRead},
+		{"click", ScopeBrowse},
+		{"login", ScopeBrowse},
+		{"set_env", ScopeBrowse},
+		{"browse", ScopeBrowse},
+		{"extract_structured", ScopeEval},
+		{"search_news", ScopeRead},
+		{"get_prices", ScopeBrowse},
+		{"unknown", ScopeBrowse},
+	} {
+		t.Run(tc.tool, func(t *testing.T) {
+			if got := s.toolScope(tc.tool); got != tc.want {
+				t.Errorf("toolScope(%s) = %s, want %s", tc.tool, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestToolScopesListed checks that every built-in tool has its scope.
+func TestToolScopesListed(t *testing.T) {
+	s := &MCPServer{}
+	for _, tool := range s.ListTools() {
+		if _, ok := toolScopes[tool.Name]; !ok {
+			t.Errorf("tool %s has no scope", tool.Name)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		scopes []Scope
+		tool   string
+		args   string
+		denied Scope
+	}{
+		{name: "read goto", scopes: []Scope{ScopeRead}, tool: "goto"},
+		{name: "read click", scopes: []Scope{ScopeRead}, tool: "click", denied: ScopeBrowse},
+		{name: "read extract", scopes: []Scope{ScopeRead}, tool: "extract_structured", denied: ScopeEval},
+		{name: "eval extract", scopes: []Scope{ScopeEval}, tool: "extract_structured"},
+		{
+			name:   "browse fields",
+			scopes: []Scope{ScopeRead, ScopeBrowse},
+			tool:   "browse",
+			args:   `{"actions":[{"action":"extract","fields":{"price":{"selector":".price"}}}]}`,
+			denied: ScopeEval,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &MCPServer{}
+			conn := s.NewConn()
+			defer conn.Close()
+			conn.token = &APIToken{Name: "agent", Scopes: tc.scopes}
+
+			err := conn.authorize(tc.tool)
+			if err == nil && tc.args != "" {
+				var req mcp.ToolsCallRequest
+				req.Params.Name = tc.tool
+				req.Params.Arguments = json.RawMessage(tc.args)
+				_, err = s.CallTool(context.Background(), conn, req)
+			}
+
+			var serr *ScopeError
+			switch {
+			case tc.denied == "" && err != nil:
+				t.Errorf("authorize(%s) = %v, want allowed", tc.tool, err)
+			case tc.denied != "" && (!errors.As(err, &serr) || serr.Scope != tc.denied):
+				t.Errorf("authorize(%s) = %v, want the %s scope denied", tc.tool, err, tc.denied)
+			}
+		})
+	}
+}
+
+func TestAPITokensSet(t *testing.T) {
+	t.Setenv("TEST_TOKEN_SECRET", "s3cret")
+
+	for _, tc := range []struct {
+		v      string
+		scopes []Scope
+		secret string
+		err    bool
+	}{
+		{v: "agent:read:abc", scopes: []Scope{ScopeRead}, secret: "abc"},
+		{v: "agent:read,browse,eval:abc", scopes: []Scope{ScopeRead, ScopeBrowse, ScopeEval}, secret: "abc"},
+		{v: "agent:read:a:b", scopes: []Scope{ScopeRead}, secret: "a:b"},
+		{v: "agent:read:$TEST_TOKEN_SECRET", scopes: []Scope{ScopeRead}, secret: "s3cret"},
+		{v: "agent:read:$TEST_TOKEN_MISSING", err: true},
+		{v: "agent:admin:abc", err: true},
+		{v: ":read:abc", err: true},
+		{v: "agent:read", err: true},
+	} {
+		t.Run(tc.v, func(t *testing.T) {
+			var toks APITokens
+			err := toks.Set(tc.v)
+			if tc.err {
+				if err == nil {
+					t.Errorf("Set(%s) succeeded, want an error", tc.v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Set(%s) = %v", tc.v, err)
+			}
+			tok := toks[0]
+			if tok.secret != tc.secret || len(tok.Scopes) != len(tc.scopes) {
+				t.Fatalf("Set(%s) = %+v", tc.v, tok)
+			}
+			for _, sc := range tc.scopes {
+				if !tok.Allows(sc) {
+					t.Errorf("Set(%s) doesn't allow %s", tc.v, sc)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/lightpanda-io/gomcp/pkg/search"
+)
+
+const (
+	SearchBatchMaxQueries = 10
+	// Number of queries run at the same time, each one uses its own tab.
+	SearchBatchConcurrency = 4
+)
+
+// SearchBatchResult groups the results of one query of a batch.
+type SearchBatchResult struct {
+	Query   search.Query
+	Results []search.Result
+	Err     error
+}
+
+var ErrNoQuery = errors.New("no query")
+
+// SearchBatch runs the queries concurrently, each one in a dedicated tab of
+// the pool. The results are returned in the order of the queries, a failing
+// query doesn't stop the others.
+func (s *MCPServer) SearchBatch(ctx context.Context, queries []search.Query) ([]SearchBatchResult, error) {
+	if len(queries) == 0 {
+		return nil, ErrNoQuery
+	}
+	if len(queries) > SearchBatchMaxQueries {
+		return nil, fmt.Errorf("too many queries: %d, max %d", len(queries), SearchBatchMaxQueries)
+	}
+
+	res := make([]SearchBatchResult, len(queries))
+	sem := make(chan struct{}, SearchBatchConcurrency)
+
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				res[i] = SearchBatchResult{Query: q, Err: ctx.Err()}
+				return
+			}
+
+			r, _, err := s.SearchPage(ctx, q)
+			res[i] = SearchBatchResult{Query: q, Results: r, Err: err}
+		}()
+	}
+	wg.Wait()
+
+	return res, nil
+}
+
+// FormatSearchBatch returns the results grouped by query.
+func FormatSearchBatch(res []SearchBatchResult) string {
+	var b strings.Builder
+	for i, r := range res {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "## %s\n\n", r.Query.Text)
+		if r.Err != nil {
+			fmt.Fprintf(&b, "Error: %s\n", r.Err)
+			continue
+		}
+		b.WriteString(search.Format(r.Results))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// readQueries returns the non empty lines of the file path, - reads stdin.
+func readQueries(path string, stdin io.Reader) ([]string, error) {
+	in := stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open queries: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var queries []string
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if q := strings.TrimSpace(scanner.Text()); q != "" {
+			queries = append(queries, q)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read queries: %w", err)
+	}
+
+	return queries, nil
+}
@@ -0,0 +1,230 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// BlocklistFiles is the list of the blocklist files.
+// It implements flag.Value, the flag can be repeated.
+type BlocklistFiles []string
+
+func (f *BlocklistFiles) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *BlocklistFiles) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// BlockedURLError is returned when a navigation targets a blocked url. Its
+// message is a JSON document giving the blocked_reason to the agent.
+type BlockedURLError struct {
+	URL    string `json:"url"`
+	Reason string `json:"blocked_reason"`
+	List   string `json:"list"`
+}
+
+func (e *BlockedURLError) Error() string {
+	b, err := json.Marshal(struct {
+		Error string `json:"error"`
+		*BlockedURLError
+	}{"url blocked", e})
+	if err != nil {
+		return fmt.Sprintf("url blocked: %s: %s", e.URL, e.Reason)
+	}
+
+	return string(b)
+}
+
+type blockEntry struct {
+	reason string
+	list   string
+}
+
+// Blocklist screens the urls before the navigations with local lists of
+// malware and phishing hosts and urls.
+type Blocklist struct {
+	hosts map[string]blockEntry
+	urls  map[string]blockEntry
+}
+
+// LoadBlocklist reads the files, in hosts format, one domain per line or
+// URLhaus dumps, in CSV or one url per line. It returns nil without files.
+func LoadBlocklist(files []string) (*Blocklist, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	bl := &Blocklist{
+		hosts: make(map[string]blockEntry),
+		urls:  make(map[string]blockEntry),
+	}
+	for _, path := range files {
+		if err := bl.load(path); err != nil {
+			return nil, fmt.Errorf("blocklist %s: %w", path, err)
+		}
+	}
+
+	slog.Debug("blocklist loaded", slog.Int("hosts", len(bl.hosts)), slog.Int("urls", len(bl.urls)))
+
+	return bl, nil
+}
+
+// localHosts are the loopback and local entries heading the hosts files.
+var localHosts = []string{
+	"localhost", "localhost.localdomain", "local", "broadcasthost", "0.0.0.0",
+}
+
+// localHost returns true if the entry of a hosts file is a local one, not a
+// listed host, the ip6-* names included.
+func localHost(host string) bool {
+	return slices.Contains(localHosts, host) || strings.HasPrefix(host, "ip6-")
+}
+
+func (bl *Blocklist) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	list := filepath.Base(path)
+
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// URLhaus CSV: id,dateadded,url,url_status,last_online,threat,...
+		if strings.HasPrefix(line, `"`) {
+			r := csv.NewReader(strings.NewReader(line))
+			rec, err := r.Read()
+			if err != nil {
+				continue
+			}
+			if len(rec) > 5 {
+				bl.urls[normurl(rec[2])] = blockEntry{reason: rec[5], list: list}
+			}
+			continue
+		}
+
+		if strings.Contains(line, "://") {
+			bl.urls[normurl(line)] = blockEntry{reason: "listed url", list: list}
+			continue
+		}
+
+		// hosts format: 0.0.0.0 example.com, or a host per line.
+		fields := strings.Fields(line)
+		hosts := fields[1:]
+		if len(fields) == 1 {
+			hosts = fields
+		}
+		for _, host := range hosts {
+			if strings.HasPrefix(host, "#") {
+				break
+			}
+			host = strings.ToLower(strings.TrimSuffix(host, "."))
+			if host == "" || localHost(host) {
+				continue
+			}
+			bl.hosts[host] = blockEntry{reason: "listed host", list: list}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	return nil
+}
+
+// normurl removes the fragment and the trailing slash of the url.
+func normurl(u string) string {
+	u, _, _ = strings.Cut(strings.TrimSpace(u), "#")
+	return strings.TrimSuffix(u, "/")
+}
+
+// Check returns a BlockedURLError if the url or one of its parent domains is
+// listed.
+func (bl *Blocklist) Check(u string) error {
+	if bl == nil {
+		return nil
+	}
+
+	if e, ok := bl.urls[normurl(u)]; ok {
+		return &BlockedURLError{URL: u, Reason: e.reason, List: e.list}
+	}
+
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil
+	}
+	host := strings.ToLower(pu.Hostname())
+	for host != "" {
+		if e, ok := bl.hosts[host]; ok {
+			return &BlockedURLError{URL: u, Reason: e.reason + " " + host, List: e.list}
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			break
+		}
+		host = parent
+	}
+
+	return nil
+}
+
+// checkLocation checks the url of the current page and the address it was
+// loaded from, the navigation may have been redirected to a blocked url or to
+// a private network. The blocked page is unloaded.
+func (c *MCPConn) checkLocation(ctx context.Context) error {
+	if c.srv.Blocklist == nil && c.srv.AllowPrivateNetworks {
+		return nil
+	}
+
+	var location string
+	if err := c.run(ctx, chromedp.Location(&location)); err != nil {
+		return fmt.Errorf("page location: %w", err)
+	}
+
+	err := c.srv.checkURL(ctx, location)
+	if err == nil {
+		err = c.srv.checkResponse(location, c.tab.lastResponse())
+	}
+	if err != nil {
+		if nerr := c.run(ctx, chromedp.Navigate("about:blank")); nerr != nil {
+			slog.Debug("unload blocked page", slog.Any("err", nerr))
+		}
+	}
+
+	return err
+}
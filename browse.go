@@ -0,0 +1,163 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+const (
+	BrowseDefaultTimeout = 30 * time.Second
+	BrowseMaxTimeout     = 2 * time.Minute
+	BrowseMaxActions     = 20
+)
+
+// A step executed by the browse tool.
+type BrowseAction struct {
+	// navigate, wait, click, type, set_file_input or extract.
+	Action   string `json:"action"`
+	URL      string `json:"url,omitempty"`
+	Selector string `json:"selector,omitempty"`
+	// Text typed by type.
+	Text string `json:"text,omitempty"`
+	// Wait duration in milliseconds, used when no selector is given.
+	Duration int `json:"duration,omitempty"`
+	// Files selected by set_file_input, relative to the upload directory.
+	Files []string `json:"files,omitempty"`
+	// Fields extracted in JSON by extract instead of the markdown.
+	Fields fetch.Fields `json:"fields,omitempty"`
+}
+
+var ErrNoAction = errors.New("no action")
+
+// Browse executes the actions in sequence within the timeout and returns the
+// extracted contents. It stops at the first failing action.
+func (c *MCPConn) Browse(ctx context.Context, actions []BrowseAction, timeout time.Duration) (string, error) {
+	if len(actions) == 0 {
+		return "", ErrNoAction
+	}
+	if len(actions) > BrowseMaxActions {
+		return "", fmt.Errorf("too many actions: %d, max %d", len(actions), BrowseMaxActions)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var b strings.Builder
+	for i, a := range actions {
+		res, err := c.browse(ctx, a)
+		if err != nil {
+			c.srv.diagnose(c.cdpctx, &c.env, "browse", fmt.Sprintf("action %d %s: %s", i+1, a.Action, err))
+			return "", fmt.Errorf("action %d %s: %w", i+1, a.Action, err)
+		}
+
+		fmt.Fprintf(&b, "[%d] %s\n", i+1, a.Action)
+		if res != "" {
+			b.WriteString(res)
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String(), nil
+}
+
+// browse executes one action.
+func (c *MCPConn) browse(ctx context.Context, a BrowseAction) (string, error) {
+	if a.Action == "navigate" {
+		if a.URL == "" {
+			return "", errors.New("no url")
+		}
+		if err := c.srv.checkURL(ctx, a.URL); err != nil {
+			return "", err
+		}
+		if err := c.connect(ctx); err != nil {
+			return "", fmt.Errorf("browser connect: %w", err)
+		}
+		if err := c.navigate(ctx, a.URL); err != nil {
+			return "", navigateError(a.URL, err)
+		}
+		return "", c.checkLocation(ctx)
+	}
+
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, navigate first")
+	}
+
+	ctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	switch a.Action {
+	case "wait":
+		if a.Selector == "" {
+			d := time.Duration(a.Duration) * time.Millisecond
+			return "", chromedp.Run(ctx, chromedp.Sleep(d))
+		}
+		expr, by := fetch.Query(a.Selector)
+		return "", chromedp.Run(ctx, chromedp.WaitVisible(expr, by))
+	case "click":
+		if a.Selector == "" {
+			return "", errors.New("no selector")
+		}
+		expr, by := fetch.Query(a.Selector)
+		return "", chromedp.Run(ctx, chromedp.Click(expr, by, chromedp.NodeVisible))
+	case "type":
+		if a.Selector == "" {
+			return "", errors.New("no selector")
+		}
+		expr, by := fetch.Query(a.Selector)
+		return "", chromedp.Run(ctx, chromedp.SendKeys(expr, a.Text, by, chromedp.NodeVisible))
+	case "set_file_input":
+		if a.Selector == "" {
+			return "", errors.New("no selector")
+		}
+		return "", c.srv.setFileInput(ctx, a.Selector, a.Files)
+	case "extract":
+		if len(a.Fields) > 0 {
+			data, err := fetch.Structured(ctx, a.Fields)
+			if err != nil {
+				return "", err
+			}
+			b, err := json.MarshalIndent(data, "", "  ")
+			if err != nil {
+				return "", fmt.Errorf("json encode: %w", err)
+			}
+			return c.screen(ctx, string(b))
+		}
+
+		sel := a.Selector
+		if sel == "" {
+			sel = "html"
+		}
+		content, err := fetch.Markdown(ctx, sel, c.srv.Boilerplate)
+		if err != nil {
+			return "", err
+		}
+		if content, err = c.screen(ctx, content); err != nil {
+			return "", err
+		}
+		return c.guard(ctx, content, false), nil
+	}
+
+	return "", fmt.Errorf("invalid action: %s", a.Action)
+}
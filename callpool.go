@@ -0,0 +1,180 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+const MaxQueuedCallsDefault = 16
+
+var (
+	// ErrCallQueueFull is returned when a connection queues too many calls.
+	ErrCallQueueFull = errors.New("too many queued calls")
+	// ErrCallPoolClosed is returned when a call is queued on a closed
+	// connection.
+	ErrCallPoolClosed = errors.New("connection closed")
+	// ErrDuplicateCall is returned when a call reuses the id of a pending
+	// one.
+	ErrDuplicateCall = errors.New("duplicate request id")
+	// ErrCallCancelled is the cause of the context of a call cancelled by the
+	// client.
+	ErrCallCancelled = errors.New("call cancelled by the client")
+)
+
+// call is a tool call queued in a callPool.
+type call struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	run    func(context.Context)
+}
+
+// callPool runs the tool calls of a connection with a fixed number of
+// workers. The calls complete in any order, their responses are matched to
+// the requests by their JSON-RPC id.
+type callPool struct {
+	mu sync.Mutex
+	// queued and running calls per request id.
+	pending map[int]*call
+	queue   chan *call
+	// maximum number of pending calls.
+	size   int
+	wg     sync.WaitGroup
+	closed bool
+	// serializes the messages sent by the workers.
+	sendmu sync.Mutex
+}
+
+// newCallPool starts workers running the calls, at most queued calls wait
+// for a worker.
+func newCallPool(workers, queued int) *callPool {
+	workers = max(1, workers)
+	p := &callPool{
+		pending: make(map[int]*call),
+		size:    workers + max(0, queued),
+	}
+	p.queue = make(chan *call, p.size)
+	for range workers {
+		p.wg.Add(1)
+		go p.work()
+	}
+
+	return p
+}
+
+func (p *callPool) work() {
+	defer p.wg.Done()
+	for c := range p.queue {
+		c.run(c.ctx)
+	}
+}
+
+// Push queues the call of request id, run is called by a worker with a
+// context cancelled with ctx, by Cancel or by Close. done is called once the
+// call ended or was dropped.
+func (p *callPool) Push(ctx context.Context, id int, run func(context.Context), done func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrCallPoolClosed
+	}
+	if _, ok := p.pending[id]; ok {
+		return ErrDuplicateCall
+	}
+	if len(p.pending) >= p.size {
+		return ErrCallQueueFull
+	}
+
+	c := &call{}
+	c.ctx, c.cancel = context.WithCancelCause(ctx)
+	c.run = func(ctx context.Context) {
+		defer done()
+		defer p.remove(id, c)
+		if ctx.Err() != nil {
+			// cancelled while queued.
+			slog.Debug("call dropped", slog.Int("id", id), slog.Any("cause", context.Cause(ctx)))
+			return
+		}
+		run(ctx)
+	}
+
+	// the queue holds all the pending calls, it never blocks.
+	p.queue <- c
+	p.pending[id] = c
+
+	return nil
+}
+
+func (p *callPool) remove(id int, c *call) {
+	c.cancel(nil)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending[id] == c {
+		delete(p.pending, id)
+	}
+}
+
+// Cancel cancels the queued or running call of request id. It returns false
+// if the call isn't pending.
+func (p *callPool) Cancel(id int) bool {
+	p.mu.Lock()
+	c, ok := p.pending[id]
+	p.mu.Unlock()
+	if ok {
+		c.cancel(ErrCallCancelled)
+	}
+
+	return ok
+}
+
+// Send returns send serialized with the messages of the workers, the
+// messages aren't sent once the pool is closed.
+func (p *callPool) Send(send SendFn) SendFn {
+	return func(event string, data any) error {
+		p.sendmu.Lock()
+		defer p.sendmu.Unlock()
+
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			return nil
+		}
+
+		return send(event, data)
+	}
+}
+
+// Close cancels the pending calls and waits for the workers to stop.
+func (p *callPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, c := range p.pending {
+		c.cancel(context.Canceled)
+	}
+	close(p.queue)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
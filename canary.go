@@ -0,0 +1,227 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+const CanaryDefaultTimeout = 30 * time.Second
+
+// CanaryCheck asserts the content extracted from a known url.
+type CanaryCheck struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// Selector of the extracted element, the whole page by default.
+	Selector string `yaml:"selector"`
+	// Expected final HTTP status, 0 accepts any status below 400.
+	Status int64 `yaml:"status"`
+	// Substring of the page title.
+	Title string `yaml:"title"`
+	// Substrings required in the markdown.
+	Contains []string `yaml:"contains"`
+	// Substrings forbidden in the markdown.
+	Absent []string `yaml:"absent"`
+	// Regular expressions matching the markdown.
+	Matches []string `yaml:"matches"`
+	// Minimum length of the markdown.
+	MinLength int `yaml:"min_length"`
+}
+
+// CanarySuite is a set of checks run before rolling a new browser or
+// extraction build.
+type CanarySuite struct {
+	// Timeout of each check.
+	Timeout time.Duration `yaml:"timeout"`
+	Checks  []CanaryCheck `yaml:"checks"`
+}
+
+// LoadCanarySuite reads the suite file.
+func LoadCanarySuite(path string) (CanarySuite, error) {
+	var s CanarySuite
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("read suite: %w", err)
+	}
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		return s, fmt.Errorf("decode suite %s: %w", path, err)
+	}
+
+	for i, c := range s.Checks {
+		if c.URL == "" {
+			return s, fmt.Errorf("check %d: missing url", i+1)
+		}
+		if c.Name == "" {
+			s.Checks[i].Name = c.URL
+		}
+		for _, m := range c.Matches {
+			if _, err := regexp.Compile(m); err != nil {
+				return s, fmt.Errorf("check %s: %w", s.Checks[i].Name, err)
+			}
+		}
+	}
+	if s.Timeout == 0 {
+		s.Timeout = CanaryDefaultTimeout
+	}
+
+	return s, nil
+}
+
+// assert returns the failed assertions of the check on the fetched page.
+func (c CanaryCheck) assert(res fetch.Result) []string {
+	var fails []string
+
+	switch {
+	case c.Status != 0 && res.Status != c.Status:
+		fails = append(fails, fmt.Sprintf("status %d, want %d", res.Status, c.Status))
+	case c.Status == 0 && res.Status >= 400:
+		fails = append(fails, fmt.Sprintf("status %d", res.Status))
+	}
+	if c.Title != "" && !strings.Contains(res.Title, c.Title) {
+		fails = append(fails, fmt.Sprintf("title %q doesn't contain %q", res.Title, c.Title))
+	}
+	for _, s := range c.Contains {
+		if !strings.Contains(res.Markdown, s) {
+			fails = append(fails, fmt.Sprintf("missing %q", s))
+		}
+	}
+	for _, s := range c.Absent {
+		if strings.Contains(res.Markdown, s) {
+			fails = append(fails, fmt.Sprintf("unexpected %q", s))
+		}
+	}
+	for _, m := range c.Matches {
+		if !regexp.MustCompile(m).MatchString(res.Markdown) {
+			fails = append(fails, fmt.Sprintf("no match for /%s/", m))
+		}
+	}
+	if len(res.Markdown) < c.MinLength {
+		fails = append(fails, fmt.Sprintf("length %d, want at least %d", len(res.Markdown), c.MinLength))
+	}
+
+	return fails
+}
+
+// Canary runs the check in a tab of the server and returns its failed
+// assertions.
+func (s *MCPServer) Canary(ctx context.Context, c CanaryCheck, timeout time.Duration) ([]string, error) {
+	_, _, err := s.FetchPage(ctx, PageJob{
+		Op:  "canary",
+		URL: c.URL,
+		Options: fetch.Options{
+			Selector:       c.Selector,
+			Timeout:        timeout,
+			Clicks:         s.Clicks,
+			ClickWait:      s.ClickWait,
+			DismissConsent: s.DismissConsent,
+			Boilerplate:    s.Boilerplate,
+		},
+		// the failures are diagnosed by the pipeline while the page is
+		// loaded.
+		Emit: func(res fetch.Result) error {
+			if fails := c.assert(res); len(fails) > 0 {
+				return &canaryFailure{fails}
+			}
+			return nil
+		},
+	})
+
+	var ferr *canaryFailure
+	if errors.As(err, &ferr) {
+		return ferr.fails, nil
+	}
+
+	return nil, err
+}
+
+// canaryFailure is the error of the assertions of a check.
+type canaryFailure struct {
+	fails []string
+}
+
+func (e *canaryFailure) Error() string {
+	return strings.Join(e.fails, "; ")
+}
+
+// ErrCanaryFailed is returned when a check of the suite fails.
+var ErrCanaryFailed = errors.New("canary checks failed")
+
+// runcanary runs the canary suite and reports the regressions.
+func runcanary(ctx context.Context, mcpsrv *MCPServer, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("canary", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	suite := flags.String("suite", "", "yaml file of the checks")
+
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: canary --suite <file>\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *suite == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return errors.New("bad arguments")
+	}
+
+	s, err := LoadCanarySuite(*suite)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, c := range s.Checks {
+		start := time.Now()
+		fails, err := mcpsrv.Canary(ctx, c, s.Timeout)
+		if err != nil {
+			fails = []string{err.Error()}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if len(fails) == 0 {
+			fmt.Fprintf(stdout, "PASS\t%s\t%s\n", c.Name, time.Since(start).Round(time.Millisecond))
+			continue
+		}
+
+		failed++
+		fmt.Fprintf(stdout, "FAIL\t%s\t%s\n", c.Name, time.Since(start).Round(time.Millisecond))
+		for _, f := range fails {
+			fmt.Fprintf(stdout, "\t%s\n", f)
+		}
+	}
+
+	fmt.Fprintf(stdout, "%d checks, %d failed\n", len(s.Checks), failed)
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d", ErrCanaryFailed, failed, len(s.Checks))
+	}
+
+	return nil
+}
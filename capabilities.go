@@ -0,0 +1,215 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lightpanda-io/gomcp/mcp"
+)
+
+// Capability is a resource a tool requires from the deployment.
+type Capability string
+
+const (
+	// Drive a browser tab.
+	CapBrowser Capability = "browser"
+	// Send requests without browser, e.g. DNS, whois or HTTP.
+	CapNetwork Capability = "network"
+	// Read the local files.
+	CapFileRead Capability = "file_read"
+	// Write the local files.
+	CapFileWrite Capability = "file_write"
+	// Keep a state beyond the connection.
+	CapPersist Capability = "persist"
+)
+
+// toolCapabilities are the capabilities required by the built-in tools. The
+// saved searches tools require a browser.
+var toolCapabilities = map[string][]Capability{
+	"goto":               {CapBrowser},
+	"search":             {CapBrowser},
+	"search_batch":       {CapBrowser},
+	"research":           {CapBrowser},
+	"lookup":             {CapBrowser, CapNetwork},
+	"hostinfo":           {CapNetwork},
+	"head":               {CapNetwork},
+	"get_feed":           {CapNetwork},
+	"diff_page":          {CapBrowser, CapFileWrite, CapPersist},
+	"click":              {CapBrowser},
+	"type":               {CapBrowser},
+	"press_key":          {CapBrowser},
+	"hover":              {CapBrowser},
+	"scroll_to":          {CapBrowser},
+	"drag_and_drop":      {CapBrowser},
+	"set_file_input":     {CapBrowser, CapFileRead},
+	"download":           {CapBrowser, CapFileWrite},
+	"markdown":           {CapBrowser},
+	"dom":                {CapBrowser},
+	"links":              {CapBrowser},
+	"extract_structured": {CapBrowser},
+	"browse":             {CapBrowser},
+	"get_history_page":   {},
+	"fetch_page_chunk":   {},
+	"pin_tab":            {CapBrowser, CapPersist},
+	"unpin_tab":          {CapBrowser, CapPersist},
+	"list_pages":         {CapBrowser},
+	"switch_page":        {CapBrowser},
+	"login":              {CapBrowser, CapFileRead},
+	"set_env":            {},
+	"over":               {},
+}
+
+// ToolManifest describes a tool of the deployment.
+type ToolManifest struct {
+	mcp.Tool
+	Capabilities []Capability `json:"capabilities"`
+	Limits       struct {
+		TimeoutMS int64 `json:"timeoutMs,omitempty"`
+		MaxBytes  int   `json:"maxBytes,omitempty"`
+	} `json:"limits"`
+	// Reason why the tool is listed but refuses the calls, empty if it is
+	// enabled.
+	Disabled string `json:"disabled,omitempty"`
+}
+
+// Manifest describes the tools, the limits and the policies of the
+// deployment, for the orchestration layers.
+type Manifest struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Limits  struct {
+		MaxConcurrentCalls int `json:"maxConcurrentCalls"`
+		MaxQueuedCalls     int `json:"maxQueuedCalls"`
+		MaxPinnedTabs      int `json:"maxPinnedTabs"`
+		MaxResponseText    int `json:"maxResponseText,omitempty"`
+		MaxContent         int `json:"maxContent,omitempty"`
+		ChunkSize          int `json:"chunkSize,omitempty"`
+		HistorySize        int `json:"historySize"`
+		HistoryMaxBytes    int `json:"historyMaxBytes,omitempty"`
+		Retries            int `json:"retries"`
+	} `json:"limits"`
+	Policies struct {
+		Sanitize          bool            `json:"sanitize"`
+		Injections        InjectionPolicy `json:"injections"`
+		ContentFilter     bool            `json:"contentFilter"`
+		Blocklist         bool            `json:"blocklist"`
+		FailOnErrorStatus bool            `json:"failOnErrorStatus"`
+		Screenshots       string          `json:"screenshots"`
+	} `json:"policies"`
+	Tools []ToolManifest `json:"tools"`
+}
+
+// Manifest returns the manifest of the server.
+func (s *MCPServer) Manifest() Manifest {
+	var m Manifest
+	m.Name = s.Name
+	m.Version = s.Version
+
+	m.Limits.MaxConcurrentCalls = s.MaxConcurrentCalls
+	m.Limits.MaxQueuedCalls = s.MaxQueuedCalls
+	m.Limits.MaxPinnedTabs = s.MaxPinnedTabs
+	m.Limits.MaxResponseText = s.MaxResponseText
+	m.Limits.MaxContent = s.MaxContent
+	m.Limits.ChunkSize = s.ChunkSize
+	m.Limits.HistorySize = s.HistorySize
+	m.Limits.HistoryMaxBytes = s.HistoryMaxBytes
+	m.Limits.Retries = s.Retries
+
+	m.Policies.Sanitize = s.Sanitize
+	m.Policies.Injections = s.Injections
+	m.Policies.ContentFilter = s.Filter != nil
+	m.Policies.Blocklist = s.Blocklist != nil
+	m.Policies.FailOnErrorStatus = s.FailOnErrorStatus
+	m.Policies.Screenshots = s.Screenshots.String()
+
+	for _, t := range s.ListTools() {
+		tm := ToolManifest{Tool: t}
+
+		caps, ok := toolCapabilities[t.Name]
+		if !ok {
+			caps = []Capability{CapBrowser}
+		}
+		tm.Capabilities = append([]Capability{}, caps...)
+
+		l := s.Limits.Get(t.Name)
+		tm.Limits.TimeoutMS = l.Timeout.Milliseconds()
+		tm.Limits.MaxBytes = l.MaxBytes
+
+		if t.Name == "set_file_input" && s.UploadDir == "" {
+			tm.Disabled = "no upload directory, see --upload-dir"
+		}
+		if t.Name == "login" && s.Vault == nil {
+			tm.Disabled = "no credential vault, set MCP_VAULT_PASSPHRASE"
+		}
+
+		m.Tools = append(m.Tools, tm)
+	}
+
+	return m
+}
+
+// runcapabilities prints the manifest of the deployment configured by the
+// options.
+func runcapabilities(srv *MCPServer, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("capabilities", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	asjson := flags.Bool("json", false, "print the manifest in JSON with the tools schemas")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	m := srv.Manifest()
+
+	if *asjson {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(m); err != nil {
+			return fmt.Errorf("json encode: %w", err)
+		}
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "%s %s\n", m.Name, m.Version)
+	fmt.Fprintf(stdout, "limits\tconcurrent calls %d\tqueued calls %d\tpinned tabs %d\tretries %d\n",
+		m.Limits.MaxConcurrentCalls, m.Limits.MaxQueuedCalls, m.Limits.MaxPinnedTabs, m.Limits.Retries)
+	fmt.Fprintf(stdout, "policies\tsanitize %t\tinjections %s\tcontent filter %t\tblocklist %t\n",
+		m.Policies.Sanitize, m.Policies.Injections, m.Policies.ContentFilter, m.Policies.Blocklist)
+	for _, t := range m.Tools {
+		caps := make([]string, 0, len(t.Capabilities))
+		for _, c := range t.Capabilities {
+			caps = append(caps, string(c))
+		}
+		if len(caps) == 0 {
+			caps = append(caps, "-")
+		}
+		line := fmt.Sprintf("%s\t%s", t.Name, strings.Join(caps, ","))
+		if t.Limits.TimeoutMS > 0 || t.Limits.MaxBytes > 0 {
+			line += fmt.Sprintf("\ttimeout %dms\tmax %d bytes", t.Limits.TimeoutMS, t.Limits.MaxBytes)
+		}
+		if t.Disabled != "" {
+			line += "\tdisabled: " + t.Disabled
+		}
+		fmt.Fprintln(stdout, line)
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+const (
+	// ChallengePassWait is the time given to the challenges passing by
+	// themselves, e.g. the JavaScript checks of Cloudflare.
+	ChallengePassWait = 5 * time.Second
+
+	challengeSolverTimeout = 2 * time.Minute
+)
+
+// ErrBotChallenge is matched by the errors of the pages answered with a bot
+// challenge or a CAPTCHA.
+var ErrBotChallenge = errors.New("bot challenge")
+
+// BotChallengeError is returned when a navigation ends on a bot challenge
+// instead of the page.
+type BotChallengeError struct {
+	fetch.Challenge
+	// Path of the screenshot of the challenge, empty without debug
+	// artifacts.
+	Screenshot string
+}
+
+func (e *BotChallengeError) Error() string {
+	msg := fmt.Sprintf("navigate %s: bot challenge: %s", e.URL, e.Provider)
+	if e.Screenshot != "" {
+		msg += ", screenshot " + e.Screenshot
+	}
+
+	return msg
+}
+
+func (e *BotChallengeError) Is(target error) bool {
+	return target == ErrBotChallenge
+}
+
+// ChallengeSolver hands the bot challenges to an external solver, e.g. a
+// CAPTCHA solving service or a human operator, returning the cookies passing
+// the challenge.
+type ChallengeSolver struct {
+	URL string
+}
+
+// Solve sends the challenge and the user agent of the browser to the solver
+// and returns the cookies to set.
+func (cs *ChallengeSolver) Solve(ctx context.Context, ch fetch.Challenge, ua string) ([]SessionCookie, error) {
+	ctx, cancel := context.WithTimeout(ctx, challengeSolverTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(struct {
+		fetch.Challenge
+		UserAgent string `json:"userAgent"`
+	}{ch, ua})
+	if err != nil {
+		return nil, fmt.Errorf("encode: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cs.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create http req: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do req: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status code: %d", resp.StatusCode)
+	}
+
+	var res struct {
+		Solved  bool            `json:"solved"`
+		Reason  string          `json:"reason"`
+		Cookies []SessionCookie `json:"cookies"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if !res.Solved {
+		return nil, fmt.Errorf("not solved: %s", res.Reason)
+	}
+
+	return res.Cookies, nil
+}
+
+// challenge returns a BotChallengeError if the page loaded in the tab of
+// tctx is a bot challenge. With solve, the challenge is given to the
+// server's solver first, if any: once solved, its cookies are set in the
+// browser and challenge returns true, the page must be loaded again.
+func (s *MCPServer) challenge(tctx context.Context, env *SessionEnv, solve bool) (bool, error) {
+	if !s.DetectChallenges {
+		return false, nil
+	}
+
+	ch, err := fetch.DetectChallenge(tctx, nil, ChallengePassWait)
+	if err != nil {
+		// the page may have navigated meanwhile.
+		slog.Debug("challenge", slog.Any("err", err))
+		return false, nil
+	}
+	if ch == nil {
+		return false, nil
+	}
+	slog.Debug("challenge", slog.String("provider", ch.Provider), slog.String("url", env.Redact(ch.URL)))
+
+	if solve && s.ChallengeSolver != nil {
+		if err := s.solve(tctx, *ch); err != nil {
+			slog.Warn("challenge solver", slog.String("provider", ch.Provider), slog.String("url", env.Redact(ch.URL)), slog.Any("err", env.RedactError(err)))
+		} else {
+			return true, nil
+		}
+	}
+
+	cerr := &BotChallengeError{Challenge: *ch}
+	cerr.Screenshot = s.diagnose(tctx, env, "challenge", cerr.Error())
+
+	return false, cerr
+}
+
+// solve hands the challenge to the server's solver and sets the returned
+// cookies in the browser of the tab tctx.
+func (s *MCPServer) solve(tctx context.Context, ch fetch.Challenge) error {
+	var ua string
+	if err := chromedp.Run(tctx, chromedp.Evaluate("navigator.userAgent", &ua)); err != nil {
+		return fmt.Errorf("user agent: %w", err)
+	}
+
+	jar, err := s.ChallengeSolver.Solve(tctx, ch, ua)
+	if err != nil {
+		return err
+	}
+	if len(jar) == 0 {
+		return nil
+	}
+
+	if err := chromedp.Run(tctx, network.SetCookies(cookieParams(jar))); err != nil {
+		return fmt.Errorf("set cookies: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+// Default size of the chunks of the large contents returned by the tools.
+const ChunkDefaultSize = 100 << 10
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrCursorExpired = errors.New("cursor expired, another content has been paginated since, get the content again")
+)
+
+// pagedContent is the last content returned in chunks by a connection.
+type pagedContent struct {
+	// incremented for each paginated content.
+	id      int
+	content string
+}
+
+// paginate returns the content, or its first chunk followed by the cursor of
+// the next one if it exceeds the server's chunk size.
+func (c *MCPConn) paginate(content string) string {
+	if c.srv.ChunkSize <= 0 || len(content) <= c.srv.ChunkSize {
+		return content
+	}
+
+	c.paged = pagedContent{id: c.paged.id + 1, content: content}
+
+	return c.chunk(0)
+}
+
+// chunk returns the chunk of the paginated content starting at offset,
+// followed by the cursor of the next one.
+func (c *MCPConn) chunk(offset int) string {
+	content := c.paged.content
+	part, next := fetch.Chunk(content, offset, c.srv.ChunkSize)
+
+	if next >= len(content) {
+		return fmt.Sprintf("%s\n\n[last chunk, bytes %d-%d of %d]", part, offset, offset+len(part), len(content))
+	}
+
+	return fmt.Sprintf("%s\n\n[chunk, bytes %d-%d of %d, call fetch_page_chunk with the cursor \"%d:%d\" to get the next one]",
+		part, offset, offset+len(part), len(content), c.paged.id, next)
+}
+
+// GetChunk returns the chunk of the paginated content at the cursor given by
+// the previous chunk.
+func (c *MCPConn) GetChunk(cursor string) (string, error) {
+	sid, soff, ok := strings.Cut(cursor, ":")
+	id, err := strconv.Atoi(sid)
+	if !ok || err != nil {
+		return "", ErrInvalidCursor
+	}
+	offset, err := strconv.Atoi(soff)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+
+	if id != c.paged.id || c.paged.content == "" {
+		return "", ErrCursorExpired
+	}
+	if offset < 0 || offset >= len(c.paged.content) {
+		return "", ErrInvalidCursor
+	}
+
+	return c.chunk(offset), nil
+}
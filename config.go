@@ -0,0 +1,244 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configFile = "config.yaml"
+
+// Config gives the default values of the command line options. The keys are
+// the options names, the repeatable options accept a list of values.
+// The precedence is env < config < flags.
+type Config map[string][]string
+
+// configfile returns the default path of the config file.
+func configfile() string {
+	dir, err := configdir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, configFile)
+}
+
+// LoadConfig reads the YAML config file. A missing file returns an empty
+// config.
+func LoadConfig(path string) (Config, error) {
+	c := Config{}
+	if path == "" {
+		return c, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("decode config %s: %w", path, err)
+	}
+
+	for k, v := range raw {
+		// the profiles are loaded by LoadProfiles.
+		if k == profilesKey {
+			continue
+		}
+		switch v := v.(type) {
+		case nil:
+		case []any:
+			for _, vv := range v {
+				c[k] = append(c[k], fmt.Sprint(vv))
+			}
+		case map[string]any:
+			return nil, fmt.Errorf("config %s: invalid value for %s", path, k)
+		default:
+			c[k] = []string{fmt.Sprint(v)}
+		}
+	}
+
+	return c, nil
+}
+
+// Apply sets the options of the config which are not given on the command
+// line. It returns the names of the options set.
+func (c Config) Apply(flags *flag.FlagSet) ([]string, error) {
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	var applied []string
+	for _, name := range c.names() {
+		if flags.Lookup(name) == nil {
+			return nil, fmt.Errorf("config: unknown option %s", name)
+		}
+		if given[name] {
+			continue
+		}
+
+		for _, v := range c[name] {
+			if err := flags.Set(name, v); err != nil {
+				return nil, fmt.Errorf("config: option %s: %w", name, err)
+			}
+		}
+		applied = append(applied, name)
+	}
+
+	return applied, nil
+}
+
+// names returns the sorted options names.
+func (c Config) names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// save writes the config into the YAML file path, keeping its profiles.
+func (c Config) save(path string) error {
+	raw := make(map[string]any, len(c)+1)
+	if b, err := os.ReadFile(path); err == nil {
+		var old map[string]any
+		if err := yaml.Unmarshal(b, &old); err == nil && old[profilesKey] != nil {
+			raw[profilesKey] = old[profilesKey]
+		}
+	}
+	for k, v := range c {
+		if len(v) == 1 {
+			raw[k] = v[0]
+			continue
+		}
+		raw[k] = v
+	}
+
+	b, err := yaml.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+		return fmt.Errorf("create dir: %w", err)
+	}
+
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+
+	return nil
+}
+
+// runconfig executes the config command. applied gives the options set by
+// the config file.
+func runconfig(path string, flags *flag.FlagSet, applied []string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing config command: show|set <name> <value>|init")
+	}
+
+	switch args[0] {
+	case "show":
+		if len(args) != 1 {
+			return errors.New("usage: config show")
+		}
+
+		fmt.Fprintf(out, "# %s\n", path)
+		flags.VisitAll(func(f *flag.Flag) {
+			src := "default"
+			// the options set by the config are visited too.
+			flags.Visit(func(ff *flag.Flag) {
+				if ff.Name == f.Name {
+					src = "flag"
+				}
+			})
+			if slices.Contains(applied, f.Name) {
+				src = "config"
+			}
+			fmt.Fprintf(out, "%s: %s\t# %s\n", f.Name, f.Value.String(), src)
+		})
+
+		return nil
+	case "set":
+		if len(args) != 3 {
+			return errors.New("usage: config set <name> <value>")
+		}
+
+		name, value := args[1], args[2]
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown option %s", name)
+		}
+		// check the value.
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("option %s: %w", name, err)
+		}
+
+		c, err := LoadConfig(path)
+		if err != nil {
+			return err
+		}
+		c[name] = []string{value}
+
+		return c.save(path)
+	case "init":
+		if len(args) != 1 {
+			return errors.New("usage: config init")
+		}
+
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("config %s already exists", path)
+		}
+
+		var b strings.Builder
+		b.WriteString("# gomcp configuration.\n")
+		b.WriteString("# The keys are the command line options names, the repeatable options\n")
+		b.WriteString("# accept a list. The precedence is env < config < flags.\n")
+		flags.VisitAll(func(f *flag.Flag) {
+			if f.Name == "config" {
+				return
+			}
+			fmt.Fprintf(&b, "\n# %s\n# %s: %q\n", f.Usage, f.Name, f.DefValue)
+		})
+
+		if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+			return fmt.Errorf("create dir: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+			return fmt.Errorf("write config: %w", err)
+		}
+
+		fmt.Fprintf(out, "%s created\n", path)
+
+		return nil
+	}
+
+	return fmt.Errorf("invalid config command: %s", args[0])
+}
@@ -0,0 +1,224 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	daemonSocket = "daemon.sock"
+
+	daemonDialTimeout = 100 * time.Millisecond
+)
+
+// daemonCommands are the commands forwarded to the daemon.
+var daemonCommands = []string{"search", "fetch"}
+
+// daemonLocalFlags are the options of the forwarded commands reading or
+// writing local files or streaming their output, the commands using them run
+// locally.
+var daemonLocalFlags = []string{"queries", "warc", "images-dir", "images", "out", "jsonl", "state", "resume"}
+
+// daemonsocket returns the default path of the daemon socket.
+func daemonsocket() string {
+	dir, err := configdir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, daemonSocket)
+}
+
+// DaemonRequest is a command run by the daemon.
+type DaemonRequest struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+}
+
+// DaemonResponse is the output of a command run by the daemon.
+type DaemonResponse struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	Error  string `json:"error,omitempty"`
+	// Name of the error class, for the exit code.
+	Class string `json:"class,omitempty"`
+}
+
+// RemoteError is the failure of a command run by the daemon.
+type RemoteError struct {
+	Class ErrorClass
+	Msg   string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Msg
+}
+
+// rundaemon serves the search and fetch commands on the unix socket path
+// until ctx is done. The browser connection, the tab pool and the caches
+// stay warm between the commands.
+func rundaemon(ctx context.Context, mcpsrv *MCPServer, path string) error {
+	if path == "" {
+		return errors.New("no daemon socket")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("daemon socket: %w", err)
+	}
+
+	// only the user can run the commands.
+	ln, err := listenUnix(path, 0600)
+	if errors.Is(err, ErrSocketInUse) {
+		return fmt.Errorf("daemon already running on %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("daemon listen: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /run", handleDaemonRun(mcpsrv))
+
+	srv := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), apiShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(sctx); err != nil {
+			slog.Error("daemon shutdown", slog.Any("err", err))
+			srv.Close()
+		}
+	}()
+
+	slog.Info("daemon listening", slog.String("socket", path))
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("daemon serve: %w", err)
+	}
+
+	return nil
+}
+
+func handleDaemonRun(srv *MCPServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var dr DaemonRequest
+		if err := json.NewDecoder(req.Body).Decode(&dr); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+
+		var stdout, stderr bytes.Buffer
+		var err error
+		switch dr.Command {
+		case "search":
+			err = runsearch(req.Context(), srv, dr.Args, strings.NewReader(""), &stdout, &stderr)
+		case "fetch":
+			err = runfetch(req.Context(), srv, dr.Args, &stdout, &stderr)
+		default:
+			http.Error(w, "unknown command", http.StatusBadRequest)
+			return
+		}
+		slog.Debug("daemon command", slog.String("command", dr.Command), slog.Any("err", err))
+
+		resp := DaemonResponse{Stdout: stdout.String(), Stderr: stderr.String()}
+		if err != nil {
+			resp.Error, resp.Class = err.Error(), classify(err).Name
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			slog.Error("daemon response encode", slog.Any("err", err))
+		}
+	}
+}
+
+// forward runs the command in the daemon listening on the socket path. It
+// returns false if the command must run locally: no daemon is running, the
+// command isn't served by the daemon or it uses local files.
+func forward(ctx context.Context, path, cmd string, args []string, stdout, stderr io.Writer) (bool, error) {
+	if path == "" || !slices.Contains(daemonCommands, cmd) {
+		return false, nil
+	}
+	for _, a := range args {
+		name, _, _ := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if strings.HasPrefix(a, "-") && slices.Contains(daemonLocalFlags, name) {
+			return false, nil
+		}
+	}
+
+	conn, err := net.DialTimeout("unix", path, daemonDialTimeout)
+	if err != nil {
+		return false, nil
+	}
+	conn.Close()
+
+	body, err := json.Marshal(DaemonRequest{Command: cmd, Args: args})
+	if err != nil {
+		return true, fmt.Errorf("json encode: %w", err)
+	}
+
+	cli := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://daemon/run", bytes.NewReader(body))
+	if err != nil {
+		return true, fmt.Errorf("create http req: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	slog.Debug("forward to the daemon", slog.String("command", cmd), slog.String("socket", path))
+	resp, err := cli.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return true, fmt.Errorf("daemon: bad status code: %d", resp.StatusCode)
+	}
+
+	var dr DaemonResponse
+	if err := json.NewDecoder(resp.Body).Decode(&dr); err != nil {
+		return true, fmt.Errorf("daemon: decode response: %w", err)
+	}
+
+	if _, err := io.WriteString(stderr, dr.Stderr); err != nil {
+		return true, err
+	}
+	if _, err := io.WriteString(stdout, dr.Stdout); err != nil {
+		return true, err
+	}
+	if dr.Error != "" {
+		return true, &RemoteError{Class: errorClass(dr.Class), Msg: dr.Error}
+	}
+
+	return true, nil
+}
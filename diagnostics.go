@@ -0,0 +1,88 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+const diagnoseTimeout = 10 * time.Second
+
+// diagnose captures a screenshot, the DOM and the url of the page loaded in
+// the tab ctx into the debug artifacts directory, if any. op names the failed
+// operation and detail gives the failure, the values of env are redacted from
+// the url and the detail. It returns the path of the screenshot, empty if it
+// wasn't captured.
+// The artifacts are captured even if the operation's context is done.
+func (s *MCPServer) diagnose(ctx context.Context, env *SessionEnv, op, detail string) string {
+	if s.DebugArtifacts == "" || ctx == nil {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, diagnoseTimeout)
+	defer cancel()
+
+	now := time.Now()
+	base := filepath.Join(s.DebugArtifacts, now.Format("20060102-150405.000")+"-"+op)
+
+	if err := os.MkdirAll(s.DebugArtifacts, 0775); err != nil {
+		slog.Error("debug artifacts", slog.Any("err", err))
+		return ""
+	}
+
+	var location, html string
+	var png []byte
+	var screenshot string
+	// the failures are reported in the summary, the page may be partly
+	// loaded.
+	var errs []string
+	if err := chromedp.Run(ctx, chromedp.Location(&location)); err != nil {
+		errs = append(errs, "location: "+err.Error())
+	}
+	if err := chromedp.Run(ctx, chromedp.OuterHTML("html", &html, chromedp.ByQuery)); err != nil {
+		errs = append(errs, "dom: "+err.Error())
+	} else if err := os.WriteFile(base+".html", []byte(html), 0644); err != nil {
+		errs = append(errs, "write dom: "+err.Error())
+	}
+	if err := chromedp.Run(ctx, chromedp.FullScreenshot(&png, 100)); err != nil {
+		errs = append(errs, "screenshot: "+err.Error())
+	} else if err := os.WriteFile(base+".png", png, 0644); err != nil {
+		errs = append(errs, "write screenshot: "+err.Error())
+	} else {
+		screenshot = base + ".png"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Operation: %s\nFailure: %s\nURL: %s\nAt: %s\n", op, env.Redact(detail), env.Redact(location), now.Format(time.RFC3339))
+	for _, e := range errs {
+		fmt.Fprintf(&b, "Capture error: %s\n", e)
+	}
+	if err := os.WriteFile(base+".txt", []byte(b.String()), 0644); err != nil {
+		slog.Error("debug artifacts", slog.Any("err", err))
+		return screenshot
+	}
+
+	slog.Debug("debug artifacts", slog.String("op", op), slog.String("path", base))
+
+	return screenshot
+}
@@ -25,6 +25,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 func cleanup(_ context.Context) error {
@@ -149,17 +150,24 @@ func configdir() (string, error) {
 
 var ErrNoBrowser = errors.New("no browser")
 
-// runbrowser returns a command to run the local browser.
-func newbrowser(ctx context.Context) (*exec.Cmd, error) {
-	// get the dir
-	dir, err := configdir()
-	if err != nil {
-		return nil, fmt.Errorf("get config dir: %w", err)
+// Port of the CDP server of the browser launched locally.
+const localCDPPort = "9222"
+
+// newbrowser returns the command starting the browser bin, the downloaded
+// Lightpanda browser if bin is empty. Chrome and Chromium binaries are
+// started headless.
+func newbrowser(ctx context.Context, bin string) (*exec.Cmd, error) {
+	if bin == "" {
+		// get the dir
+		dir, err := configdir()
+		if err != nil {
+			return nil, fmt.Errorf("get config dir: %w", err)
+		}
+		bin = binfilename(dir)
 	}
-	bin := binfilename(dir)
 
 	// ensure the file exists
-	_, err = os.Stat(bin)
+	_, err := os.Stat(bin)
 	if errors.Is(err, os.ErrNotExist) {
 		return nil, ErrNoBrowser
 	}
@@ -167,9 +175,47 @@ func newbrowser(ctx context.Context) (*exec.Cmd, error) {
 		return nil, fmt.Errorf("browser bin: %w", err)
 	}
 
-	cmd := exec.CommandContext(ctx, bin, "--port", "9222", "--timeout", "180")
+	args := []string{"--port", localCDPPort, "--timeout", "180"}
+	if strings.Contains(strings.ToLower(filepath.Base(bin)), "chrom") {
+		dir, err := os.MkdirTemp("", "gomcp-chrome")
+		if err != nil {
+			return nil, fmt.Errorf("chrome data dir: %w", err)
+		}
+		args = []string{
+			"--headless=new",
+			"--remote-debugging-port=" + localCDPPort,
+			"--no-first-run",
+			"--user-data-dir=" + dir,
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, bin, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	return cmd, nil
 }
+
+// startbrowser runs the browser bin until ctx is done. The returned function
+// waits for the browser process to end.
+func startbrowser(ctx context.Context, bin string) (func(), error) {
+	browser, err := newbrowser(ctx, bin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := browser.Start(); err != nil {
+		return nil, fmt.Errorf("start browser: %w", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		if err := browser.Wait(); err != nil {
+			slog.Error("run browser", slog.Any("err", err))
+		}
+		// The browser is ended, notify to stop waiting.
+		close(done)
+	}()
+
+	return func() { <-done }, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DrainDefaultTimeout = 30 * time.Second
+
+	// time given to the connections to close after the drain.
+	apiShutdownTimeout = 5 * time.Second
+)
+
+// inflight counts the running tool calls to drain them on shutdown.
+type inflight struct {
+	sync.Mutex
+	wg       sync.WaitGroup
+	draining bool
+}
+
+// start records a new call, it returns false once the calls are drained.
+func (f *inflight) start() bool {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.draining {
+		return false
+	}
+	f.wg.Add(1)
+
+	return true
+}
+
+// done records the end of a call recorded by start.
+func (f *inflight) done() {
+	f.wg.Done()
+}
+
+// closed returns true once the calls are drained.
+func (f *inflight) closed() bool {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.draining
+}
+
+// Drain refuses the new tool calls and waits for the running ones until ctx is
+// done.
+func (s *MCPServer) Drain(ctx context.Context) error {
+	s.calls.Lock()
+	s.calls.draining = true
+	s.calls.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.calls.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("tool calls still running: %w", ctx.Err())
+	}
+}
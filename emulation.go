@@ -0,0 +1,397 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/device"
+)
+
+// Viewport is the size of the emulated screen.
+// It implements flag.Value with the format WIDTHxHEIGHT.
+type Viewport struct {
+	Width  int64
+	Height int64
+}
+
+var InvalidViewport = errors.New("invalid viewport, the format is WIDTHxHEIGHT")
+
+func (v *Viewport) String() string {
+	if v.Width == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%dx%d", v.Width, v.Height)
+}
+
+func (v *Viewport) Set(s string) error {
+	w, h, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok {
+		return InvalidViewport
+	}
+
+	width, err := strconv.ParseInt(strings.TrimSpace(w), 10, 64)
+	if err != nil || width <= 0 {
+		return InvalidViewport
+	}
+	height, err := strconv.ParseInt(strings.TrimSpace(h), 10, 64)
+	if err != nil || height <= 0 {
+		return InvalidViewport
+	}
+
+	v.Width, v.Height = width, height
+	return nil
+}
+
+// Geolocation is the emulated position.
+// It implements flag.Value with the format LATITUDE,LONGITUDE.
+type Geolocation struct {
+	Lat float64
+	Lon float64
+	// the position is given, 0,0 is a valid one.
+	ok bool
+}
+
+var InvalidGeolocation = errors.New("invalid geolocation, the format is LATITUDE,LONGITUDE")
+
+func (g *Geolocation) String() string {
+	if !g.ok {
+		return ""
+	}
+
+	return fmt.Sprintf("%g,%g", g.Lat, g.Lon)
+}
+
+func (g *Geolocation) Set(s string) error {
+	lat, lon, ok := strings.Cut(s, ",")
+	if !ok {
+		return InvalidGeolocation
+	}
+
+	var err error
+	if g.Lat, err = strconv.ParseFloat(strings.TrimSpace(lat), 64); err != nil || g.Lat < -90 || g.Lat > 90 {
+		return InvalidGeolocation
+	}
+	if g.Lon, err = strconv.ParseFloat(strings.TrimSpace(lon), 64); err != nil || g.Lon < -180 || g.Lon > 180 {
+		return InvalidGeolocation
+	}
+
+	g.ok = true
+	return nil
+}
+
+// Color scheme preferred by the pages.
+type ColorScheme string
+
+const (
+	ColorSchemeDark  ColorScheme = "dark"
+	ColorSchemeLight ColorScheme = "light"
+)
+
+var InvalidColorScheme = errors.New("invalid color scheme")
+
+func (c ColorScheme) String() string {
+	return string(c)
+}
+
+func (c *ColorScheme) Set(v string) error {
+	switch ColorScheme(v) {
+	case ColorSchemeDark, ColorSchemeLight:
+		*c = ColorScheme(v)
+		return nil
+	}
+
+	return InvalidColorScheme
+}
+
+var ErrUnknownDevice = errors.New("unknown device")
+
+// lookupDevice returns the device named name, e.g. "iPhone 14" or
+// "Pixel 5 landscape". The case is ignored.
+func lookupDevice(name string) (device.Info, error) {
+	for d := device.Reset + 1; d <= device.MotoG4landscape; d++ {
+		if strings.EqualFold(d.String(), name) {
+			return d.Device(), nil
+		}
+	}
+
+	return device.Info{}, fmt.Errorf("%w: %s", ErrUnknownDevice, name)
+}
+
+// Emulation describes the screen, the region and the media features emulated
+// before the navigations. The viewport, mobile and dpr options override the device ones.
+type Emulation struct {
+	// Name of a known device.
+	Device   string
+	Viewport Viewport
+	Mobile   bool
+	// Device pixel ratio.
+	DPR float64
+
+	// Language of the pages, e.g. fr-FR.
+	Lang string
+	// IANA timezone, e.g. Europe/Paris.
+	Timezone    string
+	Geolocation Geolocation
+
+	// Media features of the themed renderings.
+	ColorScheme   ColorScheme
+	ReducedMotion bool
+
+	// Hide the automation markers and the headless user agent, see
+	// stealthJS.
+	Stealth bool
+	// Fingerprints randomized in stealth mode.
+	Noise StealthNoise
+}
+
+// The screen emulated when only some options are given.
+var (
+	desktopScreen = device.Info{Width: 1280, Height: 800, Scale: 1}
+	mobileScreen  = device.Info{Width: 390, Height: 844, Scale: 3, Mobile: true, Touch: true}
+)
+
+// IsZero returns true if nothing is emulated.
+func (e Emulation) IsZero() bool {
+	return e == Emulation{}
+}
+
+// screen returns true if a screen is emulated.
+func (e Emulation) screen() bool {
+	return e.Device != "" || e.Viewport.Width > 0 || e.Mobile || e.DPR > 0
+}
+
+// Validate checks the device name and the timezone.
+func (e Emulation) Validate() error {
+	if !e.Stealth && e.Noise != (StealthNoise{}) {
+		return errors.New("the stealth noise requires --stealth")
+	}
+	if e.Device != "" {
+		if _, err := lookupDevice(e.Device); err != nil {
+			return err
+		}
+	}
+	if e.Timezone != "" {
+		if _, err := time.LoadLocation(e.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Action returns the CDP actions emulating the screen, the region and the
+// media features.
+func (e Emulation) Action() (chromedp.Action, error) {
+	var tasks chromedp.Tasks
+
+	var ua string
+	if e.screen() {
+		d := desktopScreen
+		if e.Mobile {
+			d = mobileScreen
+		}
+		if e.Device != "" {
+			var err error
+			if d, err = lookupDevice(e.Device); err != nil {
+				return nil, err
+			}
+		}
+
+		if e.Viewport.Width > 0 {
+			d.Width, d.Height = e.Viewport.Width, e.Viewport.Height
+		}
+		if e.DPR > 0 {
+			d.Scale = e.DPR
+		}
+		if e.Mobile {
+			d.Mobile, d.Touch = true, true
+		}
+
+		ua = d.UserAgent
+		tasks = append(tasks, chromedp.Emulate(d))
+	}
+
+	if e.Stealth {
+		// the stealth user agent gives the language.
+		tasks = append(tasks, stealthUserAgentAction(ua, e.Lang))
+	} else if e.Lang != "" {
+		tasks = append(tasks,
+			// the language is given with the user agent, the browser's one
+			// is kept without device.
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				ua := ua
+				if ua == "" {
+					_, _, _, agent, _, err := browser.GetVersion().Do(ctx)
+					if err != nil {
+						return fmt.Errorf("user agent: %w", err)
+					}
+					ua = agent
+				}
+				return emulation.SetUserAgentOverride(ua).WithAcceptLanguage(e.Lang).Do(ctx)
+			}),
+		)
+	}
+	if e.Lang != "" {
+		tasks = append(tasks, emulation.SetLocaleOverride().WithLocale(strings.ReplaceAll(e.Lang, "-", "_")))
+	}
+	if e.Timezone != "" {
+		tasks = append(tasks, emulation.SetTimezoneOverride(e.Timezone))
+	}
+	if e.Geolocation.ok {
+		tasks = append(tasks,
+			// the pages can read the position without prompt.
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				c := chromedp.FromContext(ctx)
+				perms := []browser.PermissionType{browser.PermissionTypeGeolocation}
+				return browser.GrantPermissions(perms).Do(cdp.WithExecutor(ctx, c.Browser))
+			}),
+			emulation.SetGeolocationOverride().
+				WithLatitude(e.Geolocation.Lat).
+				WithLongitude(e.Geolocation.Lon).
+				WithAccuracy(1),
+		)
+	}
+
+	var features []*emulation.MediaFeature
+	if e.ColorScheme != "" {
+		features = append(features, &emulation.MediaFeature{Name: "prefers-color-scheme", Value: string(e.ColorScheme)})
+	}
+	if e.ReducedMotion {
+		features = append(features, &emulation.MediaFeature{Name: "prefers-reduced-motion", Value: "reduce"})
+	}
+	if len(features) > 0 {
+		tasks = append(tasks, emulation.SetEmulatedMedia().WithFeatures(features))
+	}
+
+	return tasks, nil
+}
+
+// resetEmulation returns the CDP actions restoring the browser's screen,
+// region and media features.
+func resetEmulation() chromedp.Action {
+	return chromedp.Tasks{
+		chromedp.EmulateReset(),
+		emulation.SetLocaleOverride(),
+		emulation.SetTimezoneOverride(""),
+		emulation.ClearGeolocationOverride(),
+		emulation.SetEmulatedMedia(),
+	}
+}
+
+// emulate applies the emulation to the tab t before a navigation.
+func emulate(ctx context.Context, t *tab, e Emulation) error {
+	if e.IsZero() {
+		return nil
+	}
+
+	action, err := e.Action()
+	if err != nil {
+		return err
+	}
+
+	if err := chromedp.Run(ctx, action); err != nil {
+		return fmt.Errorf("emulate: %w", err)
+	}
+
+	t.mu.Lock()
+	t.emulated = true
+	t.mu.Unlock()
+
+	if e.Stealth {
+		return t.stealth(ctx, e)
+	}
+
+	return nil
+}
+
+// emulationArgs are the emulation options of the tools.
+type emulationArgs struct {
+	Viewport      string  `json:"viewport"`
+	Mobile        *bool   `json:"mobile"`
+	Device        string  `json:"device"`
+	DPR           float64 `json:"dpr"`
+	Lang          string  `json:"lang"`
+	Timezone      string  `json:"timezone"`
+	Geolocation   string  `json:"geolocation"`
+	ColorScheme   string  `json:"color_scheme"`
+	ReducedMotion *bool   `json:"reduced_motion"`
+}
+
+// given returns true if an option is given.
+func (a emulationArgs) given() bool {
+	return a.Viewport != "" || a.Mobile != nil || a.Device != "" || a.DPR != 0 ||
+		a.Lang != "" || a.Timezone != "" || a.Geolocation != "" ||
+		a.ColorScheme != "" || a.ReducedMotion != nil
+}
+
+// apply returns the emulation e overridden by the given options.
+func (a emulationArgs) apply(e Emulation) (Emulation, error) {
+	if a.Viewport != "" {
+		if err := e.Viewport.Set(a.Viewport); err != nil {
+			return e, err
+		}
+	}
+	if a.Mobile != nil {
+		e.Mobile = *a.Mobile
+	}
+	if a.Device != "" {
+		e.Device = a.Device
+	}
+	if a.DPR != 0 {
+		e.DPR = a.DPR
+	}
+	if a.Lang != "" {
+		e.Lang = a.Lang
+	}
+	if a.Timezone != "" {
+		e.Timezone = a.Timezone
+	}
+	if a.Geolocation != "" {
+		if err := e.Geolocation.Set(a.Geolocation); err != nil {
+			return e, err
+		}
+	}
+	if a.ColorScheme != "" {
+		if err := e.ColorScheme.Set(a.ColorScheme); err != nil {
+			return e, err
+		}
+	}
+	if a.ReducedMotion != nil {
+		e.ReducedMotion = *a.ReducedMotion
+	}
+
+	return e, e.Validate()
+}
+
+// emulation returns the emulation of the connection, the server's one unless
+// the tools gave other options.
+func (c *MCPConn) emulation() Emulation {
+	if c.emulated != nil {
+		return *c.emulated
+	}
+
+	return c.srv.Emulation
+}
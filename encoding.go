@@ -0,0 +1,77 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// OutputEncoding is the encoding of the commands output, e.g. GBK or
+// Shift_JIS for the legacy consoles. It implements flag.Value with the names
+// of the WHATWG encoding standard.
+type OutputEncoding struct {
+	name string
+	enc  encoding.Encoding
+}
+
+var InvalidOutputEncoding = errors.New("invalid output encoding, e.g. utf-8, gbk, gb18030, big5, shift_jis, euc-jp or euc-kr")
+
+func (e *OutputEncoding) String() string {
+	if e.name == "" {
+		return "utf-8"
+	}
+	return e.name
+}
+
+func (e *OutputEncoding) Set(v string) error {
+	enc, err := htmlindex.Get(strings.TrimSpace(v))
+	if err != nil {
+		return InvalidOutputEncoding
+	}
+
+	e.name, e.enc = strings.ToLower(v), enc
+	return nil
+}
+
+// utf8 returns true if the output isn't transcoded.
+func (e *OutputEncoding) utf8() bool {
+	return e.enc == nil || e.enc == encoding.Nop || e.enc == htmlindexUTF8
+}
+
+var htmlindexUTF8, _ = htmlindex.Get("utf-8")
+
+// Writer returns a writer transcoding to the encoding, the characters missing
+// from the encoding are replaced. The writer must be closed to flush it.
+func (e *OutputEncoding) Writer(w io.Writer) io.WriteCloser {
+	if e.utf8() {
+		return nopCloser{w}
+	}
+
+	return transform.NewWriter(w, encoding.ReplaceUnsupported(e.enc.NewEncoder()))
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
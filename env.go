@@ -0,0 +1,189 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	// Maximum number of variables of a session environment.
+	SessionEnvMaxVars = 32
+	// Maximum size of a variable value.
+	SessionEnvMaxValue = 4096
+)
+
+var (
+	InvalidEnvName = errors.New("invalid env variable name, use letters, digits and _")
+	ErrEnvLimit    = fmt.Errorf("env limit exceeded, max %d variables of %d bytes", SessionEnvMaxVars, SessionEnvMaxValue)
+)
+
+// envRef matches the references to the env variables in the tool arguments.
+var (
+	envRef  = regexp.MustCompile(`\$\{env\.([A-Za-z_][A-Za-z0-9_]*)\}`)
+	envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// SessionEnv is the environment of a session, e.g. the API tokens used by the
+// extractions. The tool arguments reference the variables as ${env.NAME}.
+// The values are never logged and are redacted from the tool results and
+// errors.
+type SessionEnv struct {
+	sync.Mutex
+	vars map[string]string
+}
+
+// Set sets the variables, an empty value removes the variable.
+func (e *SessionEnv) Set(vars map[string]string) error {
+	e.Lock()
+	defer e.Unlock()
+
+	next := make(map[string]string, len(e.vars)+len(vars))
+	for k, v := range e.vars {
+		next[k] = v
+	}
+	for k, v := range vars {
+		if !envName.MatchString(k) {
+			return fmt.Errorf("%w: %s", InvalidEnvName, k)
+		}
+		if len(v) > SessionEnvMaxValue {
+			return fmt.Errorf("%w: %s", ErrEnvLimit, k)
+		}
+		if v == "" {
+			delete(next, k)
+			continue
+		}
+		next[k] = v
+	}
+	if len(next) > SessionEnvMaxVars {
+		return ErrEnvLimit
+	}
+
+	e.vars = next
+	return nil
+}
+
+// Names returns the sorted names of the variables.
+func (e *SessionEnv) Names() []string {
+	e.Lock()
+	defer e.Unlock()
+
+	names := make([]string, 0, len(e.vars))
+	for k := range e.vars {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// LogValue logs the names of the variables only.
+func (e *SessionEnv) LogValue() slog.Value {
+	return slog.AnyValue(e.Names())
+}
+
+// expandJSON replaces the references in the JSON document b by the values of
+// the variables, escaped for the JSON strings.
+func (e *SessionEnv) expandJSON(b []byte) ([]byte, error) {
+	if !envRef.Match(b) {
+		return b, nil
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	var err error
+	res := envRef.ReplaceAllFunc(b, func(ref []byte) []byte {
+		name := string(envRef.FindSubmatch(ref)[1])
+		v, ok := e.vars[name]
+		if !ok {
+			err = fmt.Errorf("unknown env variable %s, set it with set_env", name)
+			return ref
+		}
+		q, _ := json.Marshal(v)
+		// remove the quotes, the reference is inside a string.
+		return q[1 : len(q)-1]
+	})
+
+	return res, err
+}
+
+// Redact replaces the values of the variables in s. A nil env returns s.
+func (e *SessionEnv) Redact(s string) string {
+	if e == nil {
+		return s
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	for k, v := range e.vars {
+		s = strings.ReplaceAll(s, v, "${env."+k+"}")
+	}
+
+	return s
+}
+
+// redactedError hides the env values of the error message, the error is
+// still classified by its cause.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// RedactError returns err with the values of the variables replaced in its
+// message.
+func (e *SessionEnv) RedactError(err error) error {
+	if err == nil || e == nil {
+		return nil
+	}
+
+	msg := e.Redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+
+	return &redactedError{msg: msg, err: err}
+}
+
+type sessionEnvKey struct{}
+
+// withSessionEnv returns a context giving the env of the tool call to the
+// logs and the audited navigations, which redact its values.
+func withSessionEnv(ctx context.Context, e *SessionEnv) context.Context {
+	return context.WithValue(ctx, sessionEnvKey{}, e)
+}
+
+// envOf returns the env of the tool call of ctx, nil if none.
+func envOf(ctx context.Context) *SessionEnv {
+	e, _ := ctx.Value(sessionEnvKey{}).(*SessionEnv)
+	return e
+}
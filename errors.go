@@ -0,0 +1,191 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+	"github.com/lightpanda-io/gomcp/rpc"
+)
+
+// NavigationTimeoutError is returned when a page doesn't load in time.
+type NavigationTimeoutError struct {
+	URL string
+	Err error
+}
+
+func (e *NavigationTimeoutError) Error() string {
+	return fmt.Sprintf("navigate %s: timeout: %s", e.URL, e.Err)
+}
+
+func (e *NavigationTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// DNSError is returned when the host of a page can't be resolved.
+type DNSError struct {
+	URL string
+	Err error
+}
+
+func (e *DNSError) Error() string {
+	return fmt.Sprintf("navigate %s: dns failure: %s", e.URL, e.Err)
+}
+
+func (e *DNSError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatusError is returned when a page answers with an error status.
+type HTTPStatusError struct {
+	URL       string
+	Status    int64
+	Redirects []fetch.Redirect
+}
+
+func (e *HTTPStatusError) Error() string {
+	msg := fmt.Sprintf("navigate %s: http status %d", e.URL, e.Status)
+	if len(e.Redirects) > 0 {
+		msg += ", " + fetch.FormatRedirects(e.Redirects, e.URL, e.Status)
+	}
+
+	return msg
+}
+
+// CDPConnectError is returned when the browser can't be reached.
+type CDPConnectError struct {
+	Host string
+	Err  error
+}
+
+func (e *CDPConnectError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Host, e.Err)
+}
+
+func (e *CDPConnectError) Unwrap() error {
+	return e.Err
+}
+
+// navigateError returns the typed error of the failed navigation to url.
+func navigateError(url string, err error) error {
+	var derr *net.DNSError
+	msg := err.Error()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), strings.Contains(msg, "net::ERR_TIMED_OUT"):
+		return &NavigationTimeoutError{URL: url, Err: err}
+	case errors.As(err, &derr), strings.Contains(msg, "net::ERR_NAME_NOT_RESOLVED"):
+		return &DNSError{URL: url, Err: err}
+	}
+
+	var (
+		cerr  *CDPConnectError
+		cherr *BotChallengeError
+	)
+	if errors.As(err, &cerr) || errors.As(err, &cherr) {
+		return err
+	}
+
+	return fmt.Errorf("navigate %s: %w", url, err)
+}
+
+// ErrorClass describes a class of failures.
+type ErrorClass struct {
+	Name string
+	// Exit code of the process.
+	Exit int
+	// Error code of the MCP responses.
+	Code int
+}
+
+var (
+	ErrorClassUnknown           = ErrorClass{"error", exitFail, rpc.ServerErrorCode}
+	ErrorClassNavigationTimeout = ErrorClass{"navigation_timeout", 3, rpc.NavigationTimeoutCode}
+	ErrorClassDNS               = ErrorClass{"dns_failure", 4, rpc.DNSFailureCode}
+	ErrorClassHTTPStatus        = ErrorClass{"http_status", 5, rpc.HTTPStatusCode}
+	ErrorClassCDPConnect        = ErrorClass{"cdp_connect", 6, rpc.CDPConnectCode}
+	ErrorClassBlocked           = ErrorClass{"blocked", 7, rpc.BlockedCode}
+	ErrorClassLimit             = ErrorClass{"limit_exceeded", 8, rpc.LimitExceededCode}
+	ErrorClassForbidden         = ErrorClass{"forbidden", 9, rpc.ForbiddenCode}
+	ErrorClassBotChallenge      = ErrorClass{"bot_challenge", 10, rpc.BotChallengeCode}
+)
+
+// errorClass returns the class named name, ErrorClassUnknown if it isn't
+// known.
+func errorClass(name string) ErrorClass {
+	for _, c := range []ErrorClass{
+		ErrorClassNavigationTimeout,
+		ErrorClassDNS,
+		ErrorClassHTTPStatus,
+		ErrorClassCDPConnect,
+		ErrorClassBlocked,
+		ErrorClassLimit,
+		ErrorClassForbidden,
+		ErrorClassBotChallenge,
+	} {
+		if c.Name == name {
+			return c
+		}
+	}
+
+	return ErrorClassUnknown
+}
+
+// classify returns the class of the error.
+func classify(err error) ErrorClass {
+	var rerr *RemoteError
+	if errors.As(err, &rerr) {
+		return rerr.Class
+	}
+
+	var (
+		terr  *NavigationTimeoutError
+		derr  *DNSError
+		herr  *HTTPStatusError
+		serr  *fetch.StatusError
+		cerr  *CDPConnectError
+		berr  *BlockedError
+		buerr *BlockedURLError
+		perr  *PrivateNetworkError
+		lerr  *LimitError
+		aerr  *ScopeError
+		lherr *LoginHostError
+		cherr *BotChallengeError
+	)
+	switch {
+	case errors.As(err, &buerr), errors.As(err, &berr), errors.As(err, &perr):
+		return ErrorClassBlocked
+	case errors.As(err, &lerr):
+		return ErrorClassLimit
+	case errors.As(err, &aerr), errors.As(err, &lherr):
+		return ErrorClassForbidden
+	case errors.As(err, &cherr):
+		return ErrorClassBotChallenge
+	case errors.As(err, &cerr):
+		return ErrorClassCDPConnect
+	case errors.As(err, &terr):
+		return ErrorClassNavigationTimeout
+	case errors.As(err, &derr):
+		return ErrorClassDNS
+	case errors.As(err, &herr), errors.As(err, &serr):
+		return ErrorClassHTTPStatus
+	}
+
+	return ErrorClassUnknown
+}
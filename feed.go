@@ -0,0 +1,379 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	FeedDefaultLimit = 20
+	// Maximum size of the entries summary in bytes.
+	FeedMaxSummary = 1000
+
+	feedTimeout = 30 * time.Second
+	// Maximum size of the pages and the feeds read.
+	feedMaxBody = 10 << 20
+)
+
+var ErrNoFeed = errors.New("no feed found")
+
+// feedTypes are the media types of the feeds, in the discovery preference
+// order.
+var feedTypes = []string{"application/atom+xml", "application/rss+xml", "application/rdf+xml", "application/xml", "text/xml"}
+
+// Feed is a RSS or Atom feed with normalized entries.
+type Feed struct {
+	// URL of the feed.
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	// Link of the site.
+	Link    string      `json:"link,omitempty"`
+	Entries []FeedEntry `json:"entries"`
+}
+
+// FeedEntry is an entry of a feed.
+type FeedEntry struct {
+	Title string `json:"title"`
+	Link  string `json:"link"`
+	// Publication date in RFC 3339, the update date if missing.
+	Date string `json:"date,omitempty"`
+	// Summary in markdown, cut at FeedMaxSummary.
+	Summary string `json:"summary,omitempty"`
+}
+
+func (f Feed) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Feed: %s\n", f.URL)
+	if f.Title != "" {
+		fmt.Fprintf(&b, "Title: %s\n", f.Title)
+	}
+	if f.Link != "" {
+		fmt.Fprintf(&b, "Link: %s\n", f.Link)
+	}
+	for i, e := range f.Entries {
+		fmt.Fprintf(&b, "\n%d. %s\n%s\n", i+1, e.Title, e.Link)
+		if e.Date != "" {
+			fmt.Fprintf(&b, "Date: %s\n", e.Date)
+		}
+		if e.Summary != "" {
+			fmt.Fprintf(&b, "%s\n", e.Summary)
+		}
+	}
+
+	return b.String()
+}
+
+// Feed returns the feed of the url, the url of a feed or of a page linking to
+// its feed. It doesn't use the browser: the feeds are much cheaper than the
+// rendered pages. The redirections are checked like the navigations. limit
+// caps the entries, 0 means FeedDefaultLimit.
+func (s *MCPServer) Feed(ctx context.Context, u string, limit int) (Feed, error) {
+	if limit <= 0 {
+		limit = FeedDefaultLimit
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, feedTimeout)
+	defer cancel()
+
+	// the connections to the private networks are refused after the DNS
+	// resolution too.
+	cli := s.httpClient()
+
+	body, final, ctype, err := s.getFeed(ctx, cli, u)
+	if err != nil {
+		return Feed{}, err
+	}
+
+	// a page links to its feed.
+	if !isFeed(ctype, body) {
+		fu, err := discoverFeed(body, final)
+		if err != nil {
+			return Feed{}, err
+		}
+		if body, final, _, err = s.getFeed(ctx, cli, fu); err != nil {
+			return Feed{}, err
+		}
+	}
+
+	f, err := parseFeed(body, final, limit)
+	if err != nil {
+		return Feed{}, fmt.Errorf("feed %s: %w", final, err)
+	}
+
+	return f, nil
+}
+
+// getFeed returns the body, the url after the redirections and the media type
+// of the url.
+func (s *MCPServer) getFeed(ctx context.Context, cli *http.Client, u string) ([]byte, *url.URL, string, error) {
+	if err := s.checkURL(ctx, u); err != nil {
+		return nil, nil, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("create http req: %w", err)
+	}
+	req.Header.Set("User-Agent", lookupUserAgent)
+	req.Header.Set("Accept", strings.Join(feedTypes, ", ")+", text/html;q=0.9, */*;q=0.8")
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		var (
+			berr *BlockedURLError
+			perr *PrivateNetworkError
+		)
+		if errors.As(err, &berr) {
+			return nil, nil, "", berr
+		}
+		if errors.As(err, &perr) {
+			return nil, nil, "", perr
+		}
+		return nil, nil, "", fmt.Errorf("get %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, nil, "", &HTTPStatusError{URL: resp.Request.URL.String(), Status: int64(resp.StatusCode)}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, feedMaxBody))
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("read %s: %w", u, err)
+	}
+	ctype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	return body, resp.Request.URL, ctype, nil
+}
+
+// isFeed returns true if the body is a feed, some servers give a wrong type.
+func isFeed(ctype string, body []byte) bool {
+	if strings.HasSuffix(ctype, "+xml") {
+		return true
+	}
+	if ctype == "text/html" {
+		return false
+	}
+
+	head := bytes.ToLower(body[:min(len(body), 1024)])
+	return bytes.Contains(head, []byte("<rss")) || bytes.Contains(head, []byte("<feed")) || bytes.Contains(head, []byte("<rdf:rdf"))
+}
+
+// discoverFeed returns the url of the feed linked by the page.
+func discoverFeed(body []byte, base *url.URL) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("parse page: %w", err)
+	}
+
+	for _, t := range feedTypes {
+		href, ok := doc.Find(fmt.Sprintf("link[rel~=alternate][type=%q][href]", t)).First().Attr("href")
+		if !ok {
+			continue
+		}
+		fu, err := base.Parse(strings.TrimSpace(href))
+		if err != nil {
+			continue
+		}
+		return fu.String(), nil
+	}
+
+	return "", fmt.Errorf("%w in %s", ErrNoFeed, base)
+}
+
+// xmlFeed decodes the RSS 2.0, RSS 1.0 and Atom feeds.
+type xmlFeed struct {
+	// RSS.
+	Channel struct {
+		Title string    `xml:"title"`
+		Links []xmlLink `xml:"link"`
+		Items []xmlItem `xml:"item"`
+	} `xml:"channel"`
+	// RSS 1.0, the items are outside of the channel.
+	Items []xmlItem `xml:"item"`
+	// Atom.
+	Title   string    `xml:"title"`
+	Links   []xmlLink `xml:"link"`
+	Entries []xmlItem `xml:"entry"`
+}
+
+type xmlLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+type xmlItem struct {
+	Title       string    `xml:"title"`
+	Links       []xmlLink `xml:"link"`
+	GUID        string    `xml:"guid"`
+	PubDate     string    `xml:"pubDate"`
+	Date        string    `xml:"date"`
+	Published   string    `xml:"published"`
+	Updated     string    `xml:"updated"`
+	Description string    `xml:"description"`
+	Summary     string    `xml:"summary"`
+	Content     string    `xml:"content"`
+	Encoded     string    `xml:"encoded"`
+}
+
+// link returns the alternate link, RSS gives it as text and Atom as href.
+func link(links []xmlLink) string {
+	for _, l := range links {
+		if t := strings.TrimSpace(l.Text); t != "" {
+			return t
+		}
+		if l.Href != "" && (l.Rel == "" || l.Rel == "alternate") {
+			return l.Href
+		}
+	}
+
+	return ""
+}
+
+// feedDateLayouts are the date formats of the feeds in the wild.
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"Mon, 02 Jan 06 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// feedDate returns the date in RFC 3339, or as is if its format is unknown.
+func feedDate(v string) string {
+	v = strings.TrimSpace(v)
+	for _, l := range feedDateLayouts {
+		if t, err := time.Parse(l, v); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+
+	return v
+}
+
+func parseFeed(body []byte, base *url.URL, limit int) (Feed, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = charset.NewReaderLabel
+	// the feeds often use the HTML entities.
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+
+	var x xmlFeed
+	if err := dec.Decode(&x); err != nil {
+		return Feed{}, fmt.Errorf("decode: %w", err)
+	}
+
+	f := Feed{
+		URL:   base.String(),
+		Title: strings.TrimSpace(x.Channel.Title + x.Title),
+		Link:  link(append(x.Channel.Links, x.Links...)),
+	}
+
+	items := append(append(x.Channel.Items, x.Items...), x.Entries...)
+	for _, it := range items[:min(len(items), limit)] {
+		e := FeedEntry{
+			Title: strings.TrimSpace(it.Title),
+			Link:  link(it.Links),
+		}
+		if e.Link == "" && strings.HasPrefix(it.GUID, "http") {
+			e.Link = strings.TrimSpace(it.GUID)
+		}
+		if lu, err := base.Parse(e.Link); err == nil {
+			e.Link = lu.String()
+		}
+		for _, d := range []string{it.PubDate, it.Published, it.Date, it.Updated} {
+			if strings.TrimSpace(d) != "" {
+				e.Date = feedDate(d)
+				break
+			}
+		}
+		for _, s := range []string{it.Description, it.Summary, it.Content, it.Encoded} {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+			md, err := fetch.Convert(s)
+			if err != nil {
+				md = s
+			}
+			e.Summary = fetch.Truncate(strings.TrimSpace(md), FeedMaxSummary, fetch.TruncateHead)
+			break
+		}
+		f.Entries = append(f.Entries, e)
+	}
+
+	return f, nil
+}
+
+// runfeed prints the entries of the feed of the url.
+func runfeed(ctx context.Context, mcpsrv *MCPServer, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("feed", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var (
+		limit  = flags.Int("limit", FeedDefaultLimit, "maximum number of entries")
+		asjson = flags.Bool("json", false, "print the feed in JSON")
+	)
+
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: feed [options] <url>\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("bad arguments")
+	}
+
+	f, err := mcpsrv.Feed(ctx, flags.Arg(0), *limit)
+	if err != nil {
+		return err
+	}
+
+	if *asjson {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f); err != nil {
+			return fmt.Errorf("json encode: %w", err)
+		}
+		return nil
+	}
+
+	_, err = io.WriteString(stdout, f.String())
+	return err
+}
@@ -0,0 +1,311 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+	"github.com/lightpanda-io/gomcp/pkg/warc"
+)
+
+const (
+	FetchDefaultTimeout = 60 * time.Second
+
+	salvageTimeout = 10 * time.Second
+)
+
+// ClickSelectors is the list of the selectors clicked before the
+// extractions.
+// It implements flag.Value, the flag can be repeated.
+type ClickSelectors []string
+
+func (c *ClickSelectors) String() string {
+	return strings.Join(*c, ",")
+}
+
+func (c *ClickSelectors) Set(v string) error {
+	*c = append(*c, v)
+	return nil
+}
+
+// BoilerplateSelectors is the list of the CSS selectors of the boilerplate
+// elements added to fetch.Boilerplate.
+// It implements flag.Value, the flag can be repeated.
+type BoilerplateSelectors []string
+
+func (b *BoilerplateSelectors) String() string {
+	return strings.Join(*b, ",")
+}
+
+func (b *BoilerplateSelectors) Set(v string) error {
+	*b = append(*b, v)
+	return nil
+}
+
+// ErrInterrupted is returned when the fetch is interrupted, the partial
+// content is still printed.
+var ErrInterrupted = errors.New("fetch interrupted, the content is partial")
+
+// runfetch prints the content of the pages, or writes them in files with
+// --out. On interrupt, the loading is stopped and the content rendered so far
+// is printed with a partial marker.
+func runfetch(ctx context.Context, mcpsrv *MCPServer, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var (
+		sel     = flags.String("selector", "", "CSS selector, xpath: prefixed XPath expression or shadow: prefixed shadow piercing selector of the element converted in markdown, the whole page by default")
+		asjson  = flags.Bool("json", false, "print the result in JSON with its status, redirects, metadata and stage timings")
+		jsonl   = flags.Bool("jsonl", false, "print a JSON line per page as soon as it is done, with its url, status, title, content and error")
+		timeout = flags.Duration("timeout", FetchDefaultTimeout, "timeout of the page load")
+		imgdir  = flags.String("images-dir", "images", "directory of the images downloaded with --images download")
+		clean   = flags.Bool("sanitize", false, "remove the invisible unicode characters, e.g. zero width spaces and bidi overrides")
+		fmatter = flags.Bool("front-matter", false, "prepend a YAML front matter with the url, title, description, fetch time, word count and language of the page")
+		warcout = flags.String("warc", "", "WARC file receiving the HTTP requests and responses of the page, compressed if it ends with .gz, e.g. out.warc.gz")
+		incog   = flags.Bool("incognito", false, "load the page in a throwaway browser context, sharing no cookie nor cache with the pooled tabs")
+		outdir  = flags.String("out", "", "directory receiving a file per page named with --name-template instead of stdout, the paths of the files are printed")
+	)
+	var (
+		statef = flags.String("state", "", "state file of the fetch of several pages, saved after each page and removed once they are all done, in the config directory by default")
+		resume = flags.Bool("resume", false, "fetch the pages left by the interrupted fetch of the same urls, or of the --state file without urls")
+	)
+	var names NameTemplate
+	names.Set(NameDefaultTemplate) // nolint:errcheck
+	flags.Var(&names, "name-template", "Go template of the file names of --out, relative to the directory, with the .Host, .Path, .Slug, .Title, .Date and .Hash fields of the page")
+	format := fetch.FormatMarkdown
+	flags.Var(&format, "format", "format of the content: markdown, or dom for a simplified DOM with the interactive elements numbered")
+
+	images := fetch.ImagesKeep
+	flags.Var(&images, "images", "images of the markdown: keep the links, download them in --images-dir, inline the small ones as data URIs or strip them")
+
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: fetch [options] <url>...\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 && (!*resume || *statef == "") {
+		flags.Usage()
+		return errors.New("bad arguments")
+	}
+	urls := flags.Args()
+	if *jsonl && (*asjson || *outdir != "") {
+		return errors.New("--jsonl prints the pages on stdout, without --json nor --out")
+	}
+
+	var archive *warc.Writer
+	if *warcout != "" {
+		w, f, err := createWARC(*warcout)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		archive = w
+	}
+
+	if *incog {
+		var (
+			done func()
+			err  error
+		)
+		ctx, done, err = mcpsrv.incognito(ctx)
+		if err != nil {
+			return err
+		}
+		defer done()
+	}
+
+	// the progress of the fetches of several pages is saved to resume them.
+	var st *FetchState
+	if path := *statef; *resume || path != "" || len(urls) > 1 {
+		if path == "" {
+			path = statepath(urls)
+		}
+		if *resume {
+			var err error
+			if st, err = loadFetchState(path); err != nil {
+				return err
+			}
+			if len(urls) > 0 && !slices.Equal(urls, st.URLs) {
+				return fmt.Errorf("state %s: the urls differ", path)
+			}
+			urls = st.Frontier()
+			slog.Debug("resume fetch", slog.String("state", path), slog.Int("done", len(st.Done)), slog.Int("left", len(urls)))
+		} else if path != "" {
+			st = &FetchState{URLs: urls, path: path}
+			if err := st.save(); err != nil {
+				return err
+			}
+		}
+	}
+
+	var files *pageFiles
+	if *outdir != "" {
+		files = newPageFiles(*outdir, &names)
+	}
+
+	// the pages are fetched in order, a failed page doesn't stop the next
+	// ones.
+	var failed int
+	for _, u := range urls {
+		fetchedAt := time.Now()
+		res, timings, err := mcpsrv.FetchPage(ctx, PageJob{
+			Op:  "fetch",
+			URL: u,
+			Options: fetch.Options{
+				Selector:          *sel,
+				Timeout:           *timeout,
+				FailOnErrorStatus: mcpsrv.FailOnErrorStatus,
+				Clicks:            mcpsrv.Clicks,
+				ClickWait:         mcpsrv.ClickWait,
+				DismissConsent:    mcpsrv.DismissConsent,
+				Sites:             mcpsrv.SiteExtractors,
+				Images:            fetch.ImageOptions{Policy: images, Dir: *imgdir},
+				Sanitize:          *clean,
+				MaxContent:        mcpsrv.MaxContent,
+				Truncation:        mcpsrv.Truncation,
+				Boilerplate:       mcpsrv.Boilerplate,
+				Format:            format,
+			},
+			// on interrupt, the content rendered so far is printed.
+			Salvage:   true,
+			Translate: true,
+			WARC:      archive,
+		})
+		if *jsonl {
+			// the failures are printed too.
+			if werr := writeLine(stdout, u, res, err); werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			if len(urls) == 1 || ctx.Err() != nil {
+				return err
+			}
+			if !*jsonl {
+				slog.Error("fetch", slog.String("url", u), slog.Any("err", err))
+			}
+			failed++
+			continue
+		}
+
+		write := func(w io.Writer) error {
+			return writeFetch(w, res, timings, fetchedAt, *asjson, *fmatter)
+		}
+		switch {
+		case *jsonl:
+		case files == nil:
+			err = write(stdout)
+		default:
+			var path string
+			if path, err = files.write(pageName(u, res, fetchedAt), write); err == nil {
+				fmt.Fprintln(stdout, path)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if res.Partial {
+			return ErrInterrupted
+		}
+		if st != nil {
+			if err := st.done(u); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the state is kept to try the failed pages again.
+	if failed > 0 {
+		return fmt.Errorf("%d of %d pages failed", failed, len(urls))
+	}
+	if st != nil {
+		return st.remove()
+	}
+
+	return nil
+}
+
+// writeFetch writes the fetched page res in JSON with its timings, or its
+// content with the optional front matter.
+func writeFetch(w io.Writer, res fetch.Result, timings []StageTiming, fetchedAt time.Time, asjson, fmatter bool) error {
+	if asjson {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		// the timings tell if the slowness comes from the browser, the site
+		// or gomcp.
+		out := struct {
+			fetch.Result
+			Timings []StageTiming `json:"timings"`
+		}{res, timings}
+		if err := enc.Encode(out); err != nil {
+			return fmt.Errorf("json encode: %w", err)
+		}
+
+		return nil
+	}
+
+	if fmatter {
+		fm, err := fetch.FrontMatter(res, fetchedAt)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, fm); err != nil {
+			return err
+		}
+	}
+	if res.Partial {
+		fmt.Fprintln(w, "partial: true")
+	}
+	content := res.Markdown
+	// the salvaged content is in markdown.
+	if res.DOM != "" {
+		content = res.DOM + "\n"
+	}
+	_, err := io.WriteString(w, content)
+
+	return err
+}
+
+// expand dismisses the consent dialogs with --dismiss-consent and clicks the
+// elements of the --click selectors in the current page.
+func (c *MCPConn) expand(ctx context.Context) error {
+	if len(c.srv.Clicks) == 0 && !c.srv.DismissConsent {
+		return nil
+	}
+
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	if c.srv.DismissConsent {
+		if _, err := fetch.DismissConsent(tctx, nil); err != nil {
+			return err
+		}
+	}
+
+	return fetch.Expand(tctx, c.srv.Clicks, c.srv.ClickWait)
+}
@@ -0,0 +1,244 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+const (
+	NameDefaultTemplate = "{{.Host}}/{{.Slug}}.md"
+
+	// maximum length of the slugs.
+	slugMaxLen = 80
+)
+
+// NameTemplate names the files of the fetched pages, see PageName for its
+// fields.
+// It implements flag.Value.
+type NameTemplate struct {
+	src  string
+	tmpl *template.Template
+}
+
+var InvalidNameTemplate = errors.New("invalid name template, e.g. {{.Host}}/{{.Slug}}.md")
+
+func (t *NameTemplate) String() string {
+	return t.src
+}
+
+func (t *NameTemplate) Set(v string) error {
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(v)
+	if err != nil {
+		return fmt.Errorf("%w: %w", InvalidNameTemplate, err)
+	}
+	// the unknown fields fail on execution.
+	if err := tmpl.Execute(io.Discard, PageName{}); err != nil {
+		return fmt.Errorf("%w: %w", InvalidNameTemplate, err)
+	}
+	t.src, t.tmpl = v, tmpl
+
+	return nil
+}
+
+// PageName are the fields of the name templates. They are derived from the
+// requested url, not the redirected one, so the names are deterministic.
+type PageName struct {
+	// Host of the url.
+	Host string
+	// Path of the url, its segments slugified, index for the root.
+	Path string
+	// Slug of the title of the page, of the last segment of the path
+	// without title.
+	Slug string
+	// Title of the page without the path separators.
+	Title string
+	// Fetch date, YYYY-MM-DD.
+	Date string
+	// First 8 bytes of the SHA-256 of the url in hex.
+	Hash string
+}
+
+// pageName returns the fields of the page res fetched from the url u at.
+func pageName(u string, res fetch.Result, at time.Time) PageName {
+	sum := sha256.Sum256([]byte(u))
+	n := PageName{
+		Title: strings.NewReplacer("/", "-", `\`, "-").Replace(strings.TrimSpace(res.Title)),
+		Date:  at.Format(time.DateOnly),
+		Hash:  hex.EncodeToString(sum[:8]),
+	}
+
+	var segs []string
+	if pu, err := url.Parse(u); err == nil {
+		n.Host = strings.ToLower(pu.Hostname())
+		for _, s := range strings.Split(pu.Path, "/") {
+			if s = slug(strings.TrimSuffix(s, path.Ext(s))); s != "" {
+				segs = append(segs, s)
+			}
+		}
+	}
+	if n.Host == "" {
+		n.Host = "unknown"
+	}
+	n.Path = "index"
+	if len(segs) > 0 {
+		n.Path = strings.Join(segs, "/")
+	}
+
+	n.Slug = slug(res.Title)
+	if n.Slug == "" {
+		n.Slug = path.Base(n.Path)
+	}
+
+	return n
+}
+
+// slug returns the letters and digits of s in lower case, the other runes
+// are replaced with dashes.
+func slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			dash = b.Len() > 0
+			continue
+		}
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		if b.Len() >= slugMaxLen {
+			break
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// pageFiles writes the fetched pages in a directory, the names given by a
+// template.
+type pageFiles struct {
+	dir  string
+	tmpl *NameTemplate
+	// count of the pages named per path, the next pages get a suffix.
+	names map[string]int
+}
+
+func newPageFiles(dir string, tmpl *NameTemplate) *pageFiles {
+	return &pageFiles{dir: dir, tmpl: tmpl, names: make(map[string]int)}
+}
+
+// path returns the path of the file of the page n. The pages of a run with
+// the same name are suffixed with -2, -3...
+func (pf *pageFiles) path(n PageName) (string, error) {
+	var b strings.Builder
+	if err := pf.tmpl.tmpl.Execute(&b, n); err != nil {
+		return "", fmt.Errorf("name template: %w", err)
+	}
+	name := filepath.Clean(filepath.FromSlash(b.String()))
+	// the name can't escape the directory.
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("name template: %s is outside the output directory", name)
+	}
+	if name == "." {
+		return "", errors.New("name template: empty name")
+	}
+
+	pf.names[name]++
+	if i := pf.names[name]; i > 1 {
+		ext := filepath.Ext(name)
+		name = strings.TrimSuffix(name, ext) + "-" + strconv.Itoa(i) + ext
+	}
+
+	return filepath.Join(pf.dir, name), nil
+}
+
+// write writes the page n with write and returns the path of its file.
+func (pf *pageFiles) write(n PageName, write func(io.Writer) error) (string, error) {
+	p, err := pf.path(n)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0775); err != nil {
+		return "", fmt.Errorf("create dir: %w", err)
+	}
+
+	f, err := os.Create(p)
+	if err != nil {
+		return "", fmt.Errorf("create file: %w", err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("write %s: %w", p, err)
+	}
+
+	return p, nil
+}
+
+// PageLine is the JSON line of a page fetched with --jsonl, printed as soon
+// as the page is done.
+type PageLine struct {
+	URL    string `json:"url"`
+	Status int64  `json:"status,omitempty"`
+	Title  string `json:"title,omitempty"`
+	// Markdown or simplified DOM of the page.
+	Content string `json:"content,omitempty"`
+	// The loading was interrupted, the content is the one rendered so far.
+	Partial bool `json:"partial,omitempty"`
+	// Failure of the fetch, the content is empty.
+	Error string `json:"error,omitempty"`
+}
+
+// writeLine writes the JSON line of the page res fetched from the url u, or
+// of its failure err.
+func writeLine(w io.Writer, u string, res fetch.Result, err error) error {
+	l := PageLine{URL: u}
+	if err != nil {
+		l.Error = err.Error()
+	} else {
+		l.URL, l.Status, l.Title, l.Partial = res.URL, res.Status, res.Title, res.Partial
+		l.Content = res.Markdown
+		if res.DOM != "" {
+			l.Content = res.DOM
+		}
+	}
+
+	// the encoder ends the line, the readers get it at once.
+	if err := json.NewEncoder(w).Encode(l); err != nil {
+		return fmt.Errorf("json encode: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPageFilesPath(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		tmpl  string
+		pages []PageName
+		want  []string
+		err   bool
+	}{
+		{
+			name:  "host and slug",
+			tmpl:  "{{.Host}}/{{.Slug}}.md",
+			pages: []PageName{{Host: "example.com", Slug: "hello-world"}},
+			want:  []string{"example.com/hello-world.md"},
+		},
+		{
+			name:  "same name",
+			tmpl:  "{{.Host}}.md",
+			pages: []PageName{{Host: "example.com"}, {Host: "example.com"}, {Host: "example.com"}},
+			want:  []string{"example.com.md", "example.com-2.md", "example.com-3.md"},
+		},
+		{
+			name:  "cleaned",
+			tmpl:  "{{.Host}}/./a/../{{.Slug}}.md",
+			pages: []PageName{{Host: "example.com", Slug: "page"}},
+			want:  []string{"example.com/page.md"},
+		},
+		{
+			name:  "parent dir",
+			tmpl:  "../{{.Slug}}.md",
+			pages: []PageName{{Slug: "page"}},
+			err:   true,
+		},
+		{
+			name:  "parent dir in a field",
+			tmpl:  "{{.Title}}.md",
+			pages: []PageName{{Title: "../../etc/passwd"}},
+			err:   true,
+		},
+		{
+			name:  "absolute",
+			tmpl:  "/tmp/{{.Slug}}.md",
+			pages: []PageName{{Slug: "page"}},
+			err:   true,
+		},
+		{
+			name:  "empty",
+			tmpl:  "{{.Slug}}",
+			pages: []PageName{{}},
+			err:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var tmpl NameTemplate
+			if err := tmpl.Set(tc.tmpl); err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			pf := newPageFiles(dir, &tmpl)
+
+			for i, n := range tc.pages {
+				got, err := pf.path(n)
+				if tc.err {
+					if err == nil {
+						t.Errorf("path(%+v) = %s, want an error", n, got)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("path(%+v) = %v", n, err)
+				}
+				if want := filepath.Join(dir, filepath.FromSlash(tc.want[i])); got != want {
+					t.Errorf("path(%+v) = %s, want %s", n, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNameTemplateSet(t *testing.T) {
+	for _, tc := range []struct {
+		tmpl string
+		err  bool
+	}{
+		{tmpl: "{{.Host}}/{{.Path}}.md"},
+		{tmpl: "{{.Date}}-{{.Hash}}.md"},
+		{tmpl: "{{.Unknown}}.md", err: true},
+		{tmpl: "{{.Host", err: true},
+	} {
+		t.Run(tc.tmpl, func(t *testing.T) {
+			var tmpl NameTemplate
+			if err := tmpl.Set(tc.tmpl); (err != nil) != tc.err {
+				t.Errorf("Set(%s) = %v, want error %v", tc.tmpl, err, tc.err)
+			}
+		})
+	}
+}
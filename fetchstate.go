@@ -0,0 +1,116 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const stateDir = "state"
+
+var ErrNoFetchState = errors.New("no fetch state to resume")
+
+// statepath returns the default path of the state of the fetch of the urls,
+// in the user config directory.
+func statepath(urls []string) string {
+	dir, err := configdir()
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(urls, "\n")))
+	return filepath.Join(dir, stateDir, "fetch-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// FetchState is the progress of a fetch of several pages, saved after each
+// page so an interrupted fetch resumes with the pages left.
+type FetchState struct {
+	// All the urls of the fetch, in order.
+	URLs []string `json:"urls"`
+	// Urls of the pages done, the failed ones are tried again on resume.
+	Done []string `json:"done"`
+
+	path string
+}
+
+// loadFetchState reads the state file path, ErrNoFetchState if it doesn't
+// exist.
+func loadFetchState(path string) (*FetchState, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoFetchState, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+
+	st := &FetchState{path: path}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, fmt.Errorf("decode state %s: %w", path, err)
+	}
+
+	return st, nil
+}
+
+// Frontier returns the urls left, in order.
+func (st *FetchState) Frontier() []string {
+	return slices.DeleteFunc(slices.Clone(st.URLs), func(u string) bool {
+		return slices.Contains(st.Done, u)
+	})
+}
+
+// done marks the page u done and saves the state.
+func (st *FetchState) done(u string) error {
+	st.Done = append(st.Done, u)
+	return st.save()
+}
+
+func (st *FetchState) save() error {
+	if err := os.MkdirAll(filepath.Dir(st.path), 0755); err != nil {
+		return fmt.Errorf("state dir: %w", err)
+	}
+
+	b, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("encode state: %w", err)
+	}
+
+	// the rename doesn't leave a partial state.
+	if err := os.WriteFile(st.path+".tmp", b, 0644); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	if err := os.Rename(st.path+".tmp", st.path); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+
+	return nil
+}
+
+// remove deletes the state of the completed fetch.
+func (st *FetchState) remove() error {
+	if err := os.Remove(st.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+const DownloadDefaultTimeout = 5 * time.Minute
+
+// DownloadDefaultDir is the directory of the files downloaded by the tools.
+var DownloadDefaultDir = filepath.Join(os.TempDir(), "gomcp-downloads")
+
+// FormatDownload returns the text of the downloaded file.
+func FormatDownload(d fetch.Download) string {
+	return fmt.Sprintf("The file has been downloaded.\nURL: %s\nPath: %s\nSize: %d bytes\nSHA-256: %s\n", d.URL, d.Path, d.Size, d.SHA256)
+}
+
+// Download saves the file downloaded by the navigation to url, or by the
+// click on the element matching sel in the current page if url is empty.
+func (c *MCPConn) Download(ctx context.Context, url, sel string) (string, error) {
+	if url != "" {
+		if err := c.srv.checkURL(ctx, url); err != nil {
+			return "", err
+		}
+		if err := c.connect(ctx); err != nil {
+			return "", fmt.Errorf("browser connect: %w", err)
+		}
+	} else if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DownloadDefaultTimeout)
+	defer cancel()
+
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	d, err := fetch.DownloadFile(tctx, url, sel, c.srv.DownloadDir)
+	if err != nil {
+		c.srv.diagnose(c.cdpctx, &c.env, "download", err.Error())
+		return "", err
+	}
+	if err := c.srv.checkURL(ctx, d.URL); err != nil {
+		os.Remove(d.Path) // nolint:errcheck
+		return "", err
+	}
+	attachURL(ctx, d.URL)
+
+	return FormatDownload(d), nil
+}
+
+// rundownload saves the file downloaded by the page and prints its path and
+// checksum.
+func rundownload(ctx context.Context, mcpsrv *MCPServer, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("download", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var (
+		out     = flags.String("out", ".", "directory of the downloaded file")
+		asjson  = flags.Bool("json", false, "print the result in JSON")
+		timeout = flags.Duration("timeout", DownloadDefaultTimeout, "timeout of the download")
+	)
+
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: download [options] <url>\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("bad arguments")
+	}
+	u := flags.Arg(0)
+
+	if err := mcpsrv.checkURL(ctx, u); err != nil {
+		return err
+	}
+
+	t, err := mcpsrv.tabs.Checkout()
+	if err != nil {
+		return fmt.Errorf("browser connect: %w", err)
+	}
+	defer mcpsrv.tabs.Checkin(t)
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	tctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	d, err := fetch.DownloadFile(tctx, u, "", *out)
+	if err != nil {
+		mcpsrv.diagnose(t.ctx, nil, "download", err.Error())
+		return err
+	}
+
+	if *asjson {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(d); err != nil {
+			return fmt.Errorf("json encode: %w", err)
+		}
+		return nil
+	}
+
+	_, err = io.WriteString(stdout, FormatDownload(d))
+	return err
+}
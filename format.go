@@ -0,0 +1,264 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/lightpanda-io/gomcp/mcp"
+	"github.com/lightpanda-io/gomcp/pkg/search"
+)
+
+// ToolResult is the result of a tool call before its translation into MCP
+// content.
+type ToolResult struct {
+	Tool string
+	// Text rendering of the result.
+	Text string
+	// Search results of the search tools.
+	Results []search.Result
+	// URL of the page loaded by the tool.
+	URL string
+	// Screenshot of the page and its mime type.
+	Image     []byte
+	ImageType string
+	// Structured data extracted from the page.
+	Data any
+}
+
+// Formatter translates the tool results into MCP content.
+type Formatter interface {
+	Format(ToolResult) mcp.ToolsCallResponse
+}
+
+// FormatterFunc is a function implementing Formatter.
+type FormatterFunc func(ToolResult) mcp.ToolsCallResponse
+
+func (f FormatterFunc) Format(r ToolResult) mcp.ToolsCallResponse {
+	return f(r)
+}
+
+// textContent returns the text of the result and its screenshot, if any.
+func textContent(r ToolResult) []mcp.ToolsCallContent {
+	content := []mcp.ToolsCallContent{{Type: "text", Text: r.Text}}
+	if len(r.Image) > 0 {
+		content = append(content, mcp.ToolsCallContent{
+			Type:     "image",
+			Data:     base64.StdEncoding.EncodeToString(r.Image),
+			MimeType: r.ImageType,
+		})
+	}
+
+	return content
+}
+
+var (
+	// TextFormatter returns the text of the result.
+	TextFormatter = FormatterFunc(func(r ToolResult) mcp.ToolsCallResponse {
+		return mcp.ToolsCallResponse{Content: textContent(r)}
+	})
+
+	// StructuredFormatter adds the search results, the page url and the
+	// extracted data as structured content.
+	StructuredFormatter = FormatterFunc(func(r ToolResult) mcp.ToolsCallResponse {
+		resp := mcp.ToolsCallResponse{Content: textContent(r)}
+		if len(r.Results) > 0 || r.URL != "" || r.Data != nil {
+			resp.StructuredContent = struct {
+				Results []search.Result `json:"results,omitempty"`
+				URL     string          `json:"url,omitempty"`
+				Data    any             `json:"data,omitempty"`
+			}{r.Results, r.URL, r.Data}
+		}
+		return resp
+	})
+
+	// LinksFormatter adds a resource link per search result and for the
+	// page url.
+	LinksFormatter = FormatterFunc(func(r ToolResult) mcp.ToolsCallResponse {
+		resp := mcp.ToolsCallResponse{Content: textContent(r)}
+		for _, res := range r.Results {
+			resp.Content = append(resp.Content, mcp.ToolsCallContent{
+				Type:        "resource_link",
+				URI:         res.URL,
+				Name:        res.Title,
+				Description: res.Snippet,
+				MimeType:    "text/html",
+			})
+		}
+		if r.URL != "" {
+			resp.Content = append(resp.Content, mcp.ToolsCallContent{
+				Type:     "resource_link",
+				URI:      r.URL,
+				Name:     r.URL,
+				MimeType: "text/html",
+			})
+		}
+		return resp
+	})
+)
+
+// Formatters are the formatters usable in the content format rules, by name.
+// Integrators can register their own.
+var Formatters = map[string]Formatter{
+	"text":       TextFormatter,
+	"structured": StructuredFormatter,
+	"links":      LinksFormatter,
+}
+
+// ContentFormat chooses the formatter of the clients matching the name and
+// the version prefix given on initialize.
+type ContentFormat struct {
+	// Client name, empty matches all the clients.
+	Client string
+	// Version prefix, empty matches all the versions.
+	Version   string
+	Formatter string
+}
+
+// ContentFormats is the list of the content format rules, the first matching
+// one applies.
+// It implements flag.Value with the format [client[@version]=]formatter, the
+// flag can be repeated.
+type ContentFormats []ContentFormat
+
+var InvalidContentFormat = errors.New("invalid content format, the format is [client[@version]=]text|structured|links")
+
+func (f *ContentFormats) String() string {
+	s := make([]string, 0, len(*f))
+	for _, cf := range *f {
+		v := cf.Formatter
+		if cf.Client != "" {
+			c := cf.Client
+			if cf.Version != "" {
+				c += "@" + cf.Version
+			}
+			v = c + "=" + v
+		}
+		s = append(s, v)
+	}
+
+	return strings.Join(s, ",")
+}
+
+func (f *ContentFormats) Set(v string) error {
+	var cf ContentFormat
+
+	client, name, ok := strings.Cut(v, "=")
+	if !ok {
+		client, name = "", v
+	}
+	cf.Client, cf.Version, _ = strings.Cut(strings.TrimSpace(client), "@")
+	cf.Formatter = strings.TrimSpace(name)
+
+	if _, ok := Formatters[cf.Formatter]; !ok {
+		return InvalidContentFormat
+	}
+
+	*f = append(*f, cf)
+	return nil
+}
+
+// Formatter returns the formatter of the client, TextFormatter if no rule
+// matches.
+func (f ContentFormats) Formatter(client mcp.Info) Formatter {
+	for _, cf := range f {
+		if cf.Client != "" && !strings.EqualFold(cf.Client, client.Name) {
+			continue
+		}
+		if !strings.HasPrefix(client.Version, cf.Version) {
+			continue
+		}
+		if ft, ok := Formatters[cf.Formatter]; ok {
+			return ft
+		}
+	}
+
+	return TextFormatter
+}
+
+type toolResultKey struct{}
+
+// toolData collects the structured data of a tool call.
+type toolData struct {
+	sync.Mutex
+	results   []search.Result
+	url       string
+	image     []byte
+	imageType string
+	data      any
+}
+
+// withToolData returns a context collecting the structured data of the tool
+// call.
+func withToolData(ctx context.Context) (context.Context, *toolData) {
+	d := &toolData{}
+	return context.WithValue(ctx, toolResultKey{}, d), d
+}
+
+// attachResults records the search results of the tool call of ctx.
+func attachResults(ctx context.Context, results []search.Result) {
+	if d, ok := ctx.Value(toolResultKey{}).(*toolData); ok {
+		d.Lock()
+		d.results = append(d.results, results...)
+		d.Unlock()
+	}
+}
+
+// attachURL records the url of the page loaded by the tool call of ctx.
+func attachURL(ctx context.Context, url string) {
+	if d, ok := ctx.Value(toolResultKey{}).(*toolData); ok {
+		d.Lock()
+		d.url = url
+		d.Unlock()
+	}
+}
+
+// attachImage records the screenshot taken by the tool call of ctx.
+func attachImage(ctx context.Context, b []byte, mimeType string) {
+	if d, ok := ctx.Value(toolResultKey{}).(*toolData); ok {
+		d.Lock()
+		d.image, d.imageType = b, mimeType
+		d.Unlock()
+	}
+}
+
+// attachData records the structured data extracted by the tool call of ctx.
+func attachData(ctx context.Context, data any) {
+	if d, ok := ctx.Value(toolResultKey{}).(*toolData); ok {
+		d.Lock()
+		d.data = data
+		d.Unlock()
+	}
+}
+
+// result returns the tool result of the text with the collected data.
+func (d *toolData) result(tool, text string) ToolResult {
+	d.Lock()
+	defer d.Unlock()
+
+	return ToolResult{
+		Tool:      tool,
+		Text:      text,
+		Results:   d.results,
+		URL:       d.url,
+		Image:     d.image,
+		ImageType: d.imageType,
+		Data:      d.data,
+	}
+}
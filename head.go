@@ -0,0 +1,198 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const headTimeout = 10 * time.Second
+
+// HeadOptions are the options of Head.
+type HeadOptions struct {
+	// Use GET instead of HEAD, the body is discarded. HEAD falls back to GET
+	// if the server doesn't support it.
+	Get bool
+	// Validators of the known version of the resource, the server answers
+	// 304 if it didn't change.
+	IfNoneMatch     string
+	IfModifiedSince string
+}
+
+// HeadResult is the answer to a plain HTTP request.
+type HeadResult struct {
+	// URL after the redirections.
+	URL    string `json:"url"`
+	Method string `json:"method"`
+	Status int    `json:"status"`
+	// The resource didn't change since the given validators.
+	NotModified  bool        `json:"notModified"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"lastModified,omitempty"`
+	Headers      http.Header `json:"headers"`
+}
+
+func (r HeadResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "URL: %s\n", r.URL)
+	fmt.Fprintf(&b, "Status: %d %s\n", r.Status, http.StatusText(r.Status))
+	if r.NotModified {
+		b.WriteString("Not modified: the resource didn't change since the given validators.\n")
+	}
+	if r.ETag != "" {
+		fmt.Fprintf(&b, "ETag: %s\n", r.ETag)
+	}
+	if r.LastModified != "" {
+		fmt.Fprintf(&b, "Last-Modified: %s\n", r.LastModified)
+	}
+
+	b.WriteString("Headers:\n")
+	keys := make([]string, 0, len(r.Headers))
+	for k := range r.Headers {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		for _, v := range r.Headers[k] {
+			fmt.Fprintf(&b, "  %s: %s\n", k, v)
+		}
+	}
+
+	return b.String()
+}
+
+// Head requests the url without browser and returns the status, the headers
+// and the validators of the answer. It cheaply checks the freshness of a page
+// before a full render. The url and its redirections are screened like the
+// navigations, the connections to the private networks are refused.
+func (s *MCPServer) Head(ctx context.Context, u string, opts HeadOptions) (HeadResult, error) {
+	if err := s.checkURL(ctx, u); err != nil {
+		return HeadResult{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, headTimeout)
+	defer cancel()
+
+	// the client refuses the private addresses and checks the redirections.
+	cli := s.httpClient()
+
+	method := http.MethodHead
+	if opts.Get {
+		method = http.MethodGet
+	}
+
+	res, err := head(ctx, cli, method, u, opts)
+	if err != nil {
+		return res, err
+	}
+	if method == http.MethodHead && (res.Status == http.StatusMethodNotAllowed || res.Status == http.StatusNotImplemented) {
+		return head(ctx, cli, http.MethodGet, u, opts)
+	}
+
+	return res, nil
+}
+
+func head(ctx context.Context, cli *http.Client, method, u string, opts HeadOptions) (HeadResult, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return HeadResult{}, fmt.Errorf("create http req: %w", err)
+	}
+	req.Header.Set("User-Agent", lookupUserAgent)
+	if opts.IfNoneMatch != "" {
+		req.Header.Set("If-None-Match", opts.IfNoneMatch)
+	}
+	if opts.IfModifiedSince != "" {
+		req.Header.Set("If-Modified-Since", opts.IfModifiedSince)
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		var (
+			berr *BlockedURLError
+			perr *PrivateNetworkError
+		)
+		if errors.As(err, &berr) {
+			return HeadResult{}, berr
+		}
+		if errors.As(err, &perr) {
+			return HeadResult{}, perr
+		}
+		return HeadResult{}, fmt.Errorf("%s %s: %w", method, u, err)
+	}
+	defer resp.Body.Close()
+	// the body isn't needed, it is drained to reuse the connection.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20)) // nolint:errcheck
+
+	return HeadResult{
+		URL:          resp.Request.URL.String(),
+		Method:       method,
+		Status:       resp.StatusCode,
+		NotModified:  resp.StatusCode == http.StatusNotModified,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Headers:      resp.Header,
+	}, nil
+}
+
+// runhead prints the status, the headers and the validators of the url.
+func runhead(ctx context.Context, srv *MCPServer, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("head", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var (
+		get    = flags.Bool("get", false, "use GET instead of HEAD, the body is discarded")
+		etag   = flags.String("etag", "", "ETag of the known version, sent as If-None-Match")
+		since  = flags.String("since", "", "Last-Modified date of the known version, sent as If-Modified-Since")
+		asjson = flags.Bool("json", false, "print the result in JSON")
+	)
+
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: head [options] <url>\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("bad arguments")
+	}
+
+	res, err := srv.Head(ctx, flags.Arg(0), HeadOptions{Get: *get, IfNoneMatch: *etag, IfModifiedSince: *since})
+	if err != nil {
+		return err
+	}
+
+	if *asjson {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			return fmt.Errorf("json encode: %w", err)
+		}
+		return nil
+	}
+
+	_, err = io.WriteString(stdout, res.String())
+	return err
+}
@@ -0,0 +1,136 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// healthTimeout bounds the checks of a probe.
+const healthTimeout = 5 * time.Second
+
+// HealthReport is the result of the health checks, by name.
+type HealthReport struct {
+	// ok or fail.
+	Status string `json:"status"`
+	// ok or the failure of each check.
+	Checks map[string]string `json:"checks"`
+}
+
+// Healthy returns true if all the checks passed.
+func (r HealthReport) Healthy() bool {
+	return r.Status == "ok"
+}
+
+// Health checks the CDP websocket of the connected browsers. With ready, it
+// checks also a tab can be checked out, the config dir is writable and the
+// server isn't shutting down.
+func (s *MCPServer) Health(ctx context.Context, ready bool) HealthReport {
+	checks := map[string]func(context.Context) error{
+		"cdp": func(context.Context) error { return s.tabs.Ping() },
+	}
+	if ready {
+		checks["tabs"] = s.checkTabs
+		checks["config"] = checkConfigDir
+		checks["drain"] = func(context.Context) error {
+			if s.calls.closed() {
+				return errors.New("shutting down")
+			}
+			return nil
+		}
+	}
+
+	r := HealthReport{Status: "ok", Checks: make(map[string]string, len(checks))}
+	for name, check := range checks {
+		if err := check(ctx); err != nil {
+			slog.Debug("health check", slog.String("check", name), slog.Any("err", err))
+			r.Status = "fail"
+			r.Checks[name] = err.Error()
+			continue
+		}
+		r.Checks[name] = "ok"
+	}
+
+	return r
+}
+
+// checkTabs checks out a tab and gives it back. The checkout may wait for a
+// free tab, it is abandoned when ctx is done.
+func (s *MCPServer) checkTabs(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		t, err := s.tabs.Checkout()
+		if err == nil {
+			s.tabs.Checkin(t)
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("checkout: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("checkout: %w", ctx.Err())
+	}
+}
+
+// checkConfigDir checks a file can be created in the config dir.
+func checkConfigDir(context.Context) error {
+	dir, err := configdir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return fmt.Errorf("config dir: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, ".health-*")
+	if err != nil {
+		return fmt.Errorf("config dir: %w", err)
+	}
+	f.Close()
+
+	return os.Remove(f.Name())
+}
+
+// handleHealth answers 200 if the checks pass and 503 otherwise, with the
+// report.
+func handleHealth(srv *MCPServer, ready bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), healthTimeout)
+		defer cancel()
+
+		r := srv.Health(ctx, ready)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		if !r.Healthy() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(r); err != nil {
+			slog.Error("health encode", slog.Any("err", err))
+		}
+	}
+}
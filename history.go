@@ -0,0 +1,176 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+const HistoryDefaultSize = 10
+
+// A snapshot of a visited page.
+type PageSnapshot struct {
+	URL      string
+	Title    string
+	Markdown string
+	// Language declared by the page.
+	Language string
+	// Texts hidden with CSS, kept to scan the injections.
+	Hidden    []fetch.HiddenText
+	Licenses  []fetch.License
+	VisitedAt time.Time
+}
+
+// String returns the snapshot's metadata followed by its markdown content.
+func (p PageSnapshot) String() string {
+	meta := fmt.Sprintf("URL: %s\nTitle: %s\nVisited at: %s\n",
+		p.URL, p.Title, p.VisitedAt.Format(time.RFC3339),
+	)
+	if s := fetch.FormatLicenses(p.Licenses); s != "" {
+		meta += s + "\n"
+	}
+
+	return meta + "\n" + p.Markdown
+}
+
+// size returns the memory used by the snapshot in bytes.
+func (p PageSnapshot) size() int {
+	n := len(p.URL) + len(p.Title) + len(p.Markdown) + len(p.Language)
+	for _, h := range p.Hidden {
+		n += len(h.Text) + len(h.How)
+	}
+
+	return n
+}
+
+// HistoryStats reports the usage of a history.
+type HistoryStats struct {
+	Pages    int `json:"pages"`
+	Bytes    int `json:"bytes"`
+	MaxBytes int `json:"maxBytes"`
+	// Number of pages evicted to respect the quota.
+	Evicted int `json:"evicted"`
+	// Number of pages not kept because they exceed the quota alone.
+	QuotaExceeded int `json:"quotaExceeded"`
+}
+
+type historyPage struct {
+	PageSnapshot
+	usedAt time.Time
+}
+
+// History keeps the last pages visited, the most recent first.
+type History struct {
+	sync.Mutex
+	size  int
+	pages []historyPage
+
+	bytes    int
+	evicted  int
+	exceeded int
+
+	// Quota of the snapshots in bytes, the least recently used pages are
+	// evicted to respect it. 0 disables it.
+	MaxBytes int
+}
+
+func NewHistory(size int) *History {
+	return &History{size: size}
+}
+
+// Push adds a snapshot on top of the history and drops the oldest one if the
+// history is full. The least recently used snapshots are evicted if the
+// quota is exceeded.
+func (h *History) Push(p PageSnapshot) {
+	if h.size <= 0 {
+		return
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	n := p.size()
+	if h.MaxBytes > 0 && n > h.MaxBytes {
+		h.exceeded++
+		slog.Warn("history quota exceeded", slog.String("url", p.URL), slog.Int("bytes", n), slog.Int("quota", h.MaxBytes))
+		return
+	}
+
+	h.pages = append([]historyPage{{PageSnapshot: p, usedAt: time.Now()}}, h.pages...)
+	h.bytes += n
+	if len(h.pages) > h.size {
+		h.bytes -= h.pages[h.size].size()
+		h.pages = h.pages[:h.size]
+	}
+
+	for h.MaxBytes > 0 && h.bytes > h.MaxBytes {
+		h.evict()
+	}
+}
+
+// evict removes the least recently used snapshot, except the last pushed.
+// The history must be locked.
+func (h *History) evict() {
+	lru := 1
+	for i := 2; i < len(h.pages); i++ {
+		if h.pages[i].usedAt.Before(h.pages[lru].usedAt) {
+			lru = i
+		}
+	}
+
+	h.bytes -= h.pages[lru].size()
+	h.pages = slices.Delete(h.pages, lru, lru+1)
+	h.evicted++
+}
+
+// Get returns the snapshot at index i, 0 being the most recent one.
+func (h *History) Get(i int) (PageSnapshot, bool) {
+	h.Lock()
+	defer h.Unlock()
+
+	if i < 0 || i >= len(h.pages) {
+		return PageSnapshot{}, false
+	}
+	h.pages[i].usedAt = time.Now()
+
+	return h.pages[i].PageSnapshot, true
+}
+
+func (h *History) Len() int {
+	h.Lock()
+	defer h.Unlock()
+
+	return len(h.pages)
+}
+
+// Stats returns the usage of the history.
+func (h *History) Stats() HistoryStats {
+	h.Lock()
+	defer h.Unlock()
+
+	return HistoryStats{
+		Pages:         len(h.pages),
+		Bytes:         h.bytes,
+		MaxBytes:      h.MaxBytes,
+		Evicted:       h.evicted,
+		QuotaExceeded: h.exceeded,
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const hostInfoTimeout = 10 * time.Second
+
+// rdapURL is the bootstrap service redirecting to the RDAP server of the
+// domain's registry. RDAP is the JSON successor of WHOIS.
+const rdapURL = "https://rdap.org/domain/"
+
+// HostInfo returns the DNS records, the registration info and the TLS
+// certificate of the domain. The failing parts are reported in the result.
+// The registry and the host are reached through the server's guard of the
+// private networks.
+func (s *MCPServer) HostInfo(ctx context.Context, domain string) (string, error) {
+	host, err := hostname(domain)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hostInfoTimeout)
+	defer cancel()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", host)
+
+	b.WriteString("\n## DNS\n")
+	writeDNS(ctx, &b, host)
+
+	b.WriteString("\n## Registration\n")
+	if err := writeRDAP(ctx, s.httpClient(), &b, host); err != nil {
+		fmt.Fprintf(&b, "Error: %s\n", err)
+	}
+
+	b.WriteString("\n## TLS certificate\n")
+	if err := writeCert(ctx, s.dialer(), &b, host); err != nil {
+		fmt.Fprintf(&b, "Error: %s\n", err)
+	}
+
+	return b.String(), nil
+}
+
+var ErrNoHost = errors.New("no host")
+
+// hostname returns the host of v, a domain name or an URL.
+func hostname(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if strings.Contains(v, "://") {
+		u, err := url.Parse(v)
+		if err != nil {
+			return "", fmt.Errorf("parse url: %w", err)
+		}
+		v = u.Hostname()
+	}
+	v = strings.TrimSuffix(v, ".")
+	if v == "" {
+		return "", ErrNoHost
+	}
+
+	return strings.ToLower(v), nil
+}
+
+func writeDNS(ctx context.Context, b *strings.Builder, host string) {
+	r := net.DefaultResolver
+
+	if ips, err := r.LookupIPAddr(ctx, host); err == nil {
+		for _, ip := range ips {
+			typ := "A"
+			if ip.IP.To4() == nil {
+				typ = "AAAA"
+			}
+			fmt.Fprintf(b, "- %s: %s\n", typ, ip.String())
+		}
+	} else {
+		fmt.Fprintf(b, "- A/AAAA: error %s\n", err)
+	}
+
+	if cname, err := r.LookupCNAME(ctx, host); err == nil && strings.TrimSuffix(cname, ".") != host {
+		fmt.Fprintf(b, "- CNAME: %s\n", cname)
+	}
+	if mxs, err := r.LookupMX(ctx, host); err == nil {
+		for _, mx := range mxs {
+			fmt.Fprintf(b, "- MX: %s (%d)\n", mx.Host, mx.Pref)
+		}
+	}
+	if nss, err := r.LookupNS(ctx, host); err == nil {
+		for _, ns := range nss {
+			fmt.Fprintf(b, "- NS: %s\n", ns.Host)
+		}
+	}
+	if txts, err := r.LookupTXT(ctx, host); err == nil {
+		for _, txt := range txts {
+			fmt.Fprintf(b, "- TXT: %s\n", txt)
+		}
+	}
+}
+
+type rdapEntity struct {
+	Roles      []string `json:"roles"`
+	VCardArray []any    `json:"vcardArray"`
+}
+
+// name returns the fn property of the entity's vcard.
+func (e rdapEntity) name() string {
+	if len(e.VCardArray) < 2 {
+		return ""
+	}
+	props, ok := e.VCardArray[1].([]any)
+	if !ok {
+		return ""
+	}
+	for _, p := range props {
+		prop, ok := p.([]any)
+		if !ok || len(prop) < 4 || prop[0] != "fn" {
+			continue
+		}
+		if s, ok := prop[3].(string); ok {
+			return s
+		}
+	}
+
+	return ""
+}
+
+func writeRDAP(ctx context.Context, cli *http.Client, b *strings.Builder, host string) error {
+	var d struct {
+		LDHName string   `json:"ldhName"`
+		Status  []string `json:"status"`
+		Events  []struct {
+			Action string `json:"eventAction"`
+			Date   string `json:"eventDate"`
+		} `json:"events"`
+		Entities []rdapEntity `json:"entities"`
+	}
+
+	// the registration is known for the registered domain only, the
+	// subdomains are removed one by one.
+	domain := host
+	for {
+		err := getJSON(ctx, cli, rdapURL+domain, &d)
+		if err == nil {
+			break
+		}
+		_, parent, ok := strings.Cut(domain, ".")
+		if !errors.Is(err, ErrNoEntity) || !ok || !strings.Contains(parent, ".") {
+			return fmt.Errorf("rdap %s: %w", domain, err)
+		}
+		domain = parent
+	}
+
+	fmt.Fprintf(b, "- Domain: %s\n", strings.ToLower(d.LDHName))
+	for _, ev := range d.Events {
+		switch ev.Action {
+		case "registration", "expiration", "last changed":
+			fmt.Fprintf(b, "- %s: %s\n", ev.Action, ev.Date)
+		}
+	}
+	for _, e := range d.Entities {
+		for _, role := range e.Roles {
+			if role == "registrar" {
+				if name := e.name(); name != "" {
+					fmt.Fprintf(b, "- Registrar: %s\n", name)
+				}
+			}
+		}
+	}
+	if len(d.Status) > 0 {
+		fmt.Fprintf(b, "- Status: %s\n", strings.Join(d.Status, ", "))
+	}
+
+	return nil
+}
+
+func writeCert(ctx context.Context, dialer *net.Dialer, b *strings.Builder, host string) error {
+	addr := net.JoinHostPort(host, "443")
+
+	var verr error
+	d := tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		// get the details of the invalid certificates too.
+		var cverr *tls.CertificateVerificationError
+		if !errors.As(err, &cverr) {
+			return fmt.Errorf("tls dial: %w", err)
+		}
+		verr = err
+
+		d.Config.InsecureSkipVerify = true
+		conn, err = d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return fmt.Errorf("tls dial: %w", err)
+		}
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return errors.New("no certificate")
+	}
+	writeCertInfo(b, certs[0])
+
+	if verr != nil {
+		fmt.Fprintf(b, "- Valid: no, %s\n", verr)
+	} else {
+		b.WriteString("- Valid: yes\n")
+	}
+
+	return nil
+}
+
+func writeCertInfo(b *strings.Builder, c *x509.Certificate) {
+	fmt.Fprintf(b, "- Subject: %s\n", c.Subject.String())
+	fmt.Fprintf(b, "- Issuer: %s\n", c.Issuer.String())
+	fmt.Fprintf(b, "- Not before: %s\n", c.NotBefore.Format(time.RFC3339))
+	fmt.Fprintf(b, "- Not after: %s\n", c.NotAfter.Format(time.RFC3339))
+	if len(c.DNSNames) > 0 {
+		fmt.Fprintf(b, "- Names: %s\n", strings.Join(c.DNSNames, ", "))
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/kb"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+const (
+	// Number of mouse moves between the source and the target of a drag.
+	dragSteps = 10
+	// Wait of the intercepted HTML5 drag after the press.
+	dragInterceptWait = 200 * time.Millisecond
+)
+
+var InvalidKey = errors.New("invalid key")
+
+// keyAliases are the common names of the keys differing from their DOM names.
+var keyAliases = map[string]string{
+	"esc":    kb.Escape,
+	"return": kb.Enter,
+	"space":  " ",
+	"del":    kb.Delete,
+	"up":     kb.ArrowUp,
+	"down":   kb.ArrowDown,
+	"left":   kb.ArrowLeft,
+	"right":  kb.ArrowRight,
+}
+
+// keyModifiers are the modifiers of the key combinations.
+var keyModifiers = map[string]input.Modifier{
+	"alt":     input.ModifierAlt,
+	"control": input.ModifierCtrl,
+	"ctrl":    input.ModifierCtrl,
+	"meta":    input.ModifierMeta,
+	"cmd":     input.ModifierMeta,
+	"shift":   input.ModifierShift,
+}
+
+// parseKey returns the key and the modifiers of a key combination, e.g.
+// Enter, ArrowDown, a or Control+Shift+K. The keys are the DOM key names.
+func parseKey(v string) (string, []input.Modifier, error) {
+	parts := strings.Split(v, "+")
+	name := parts[len(parts)-1]
+	// the + key itself.
+	if name == "" && strings.HasSuffix(v, "+") {
+		name = "+"
+		parts = parts[:len(parts)-1]
+	}
+
+	var mods []input.Modifier
+	for _, p := range parts[:len(parts)-1] {
+		m, ok := keyModifiers[strings.ToLower(p)]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: modifier %s", InvalidKey, p)
+		}
+		mods = append(mods, m)
+	}
+
+	if utf8.RuneCountInString(name) == 1 {
+		return name, mods, nil
+	}
+	if k, ok := keyAliases[strings.ToLower(name)]; ok {
+		return k, mods, nil
+	}
+	for r, k := range kb.Keys {
+		if strings.EqualFold(k.Key, name) {
+			return string(r), mods, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("%w: %s", InvalidKey, name)
+}
+
+// center returns the coordinates of the center of the node, scrolled into
+// the viewport.
+func center(ctx context.Context, n *cdp.Node) (float64, float64, error) {
+	if err := dom.ScrollIntoViewIfNeeded().WithNodeID(n.NodeID).Do(ctx); err != nil {
+		return 0, 0, fmt.Errorf("scroll into view: %w", err)
+	}
+
+	quads, err := dom.GetContentQuads().WithNodeID(n.NodeID).Do(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("content quads: %w", err)
+	}
+	if len(quads) == 0 || len(quads[0]) != 8 {
+		return 0, 0, errors.New("element not rendered")
+	}
+
+	var x, y float64
+	for i := 0; i < 8; i += 2 {
+		x += quads[0][i]
+		y += quads[0][i+1]
+	}
+
+	return x / 4, y / 4, nil
+}
+
+// node returns the first visible node matching the selector, it runs in a
+// chromedp action.
+func node(ctx context.Context, sel string) (*cdp.Node, error) {
+	var nodes []*cdp.Node
+	expr, by := fetch.Query(sel)
+	if err := chromedp.Nodes(expr, &nodes, by, chromedp.NodeVisible).Do(ctx); err != nil {
+		return nil, fmt.Errorf("query %s: %w", sel, err)
+	}
+
+	return nodes[0], nil
+}
+
+// PressKey presses the key combination, in the element matching the selector
+// if any, in the focused element otherwise.
+func (c *MCPConn) PressKey(ctx context.Context, key, sel string) (string, error) {
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+
+	k, mods, err := parseKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, interactTimeout)
+	defer cancel()
+
+	var actions []chromedp.Action
+	if sel != "" {
+		expr, by := fetch.Query(sel)
+		actions = append(actions, chromedp.Focus(expr, by, chromedp.NodeVisible))
+	}
+	actions = append(actions, chromedp.KeyEvent(k, chromedp.KeyModifiers(mods...)), chromedp.Sleep(interactSettle))
+	if err := c.run(ctx, actions...); err != nil {
+		return "", fmt.Errorf("press %s: %w", key, err)
+	}
+
+	c.screenshot(ctx)
+
+	return fmt.Sprintf("The key '%s' has been pressed.", key), nil
+}
+
+// Hover moves the mouse over the element matching the selector, e.g. to open
+// a menu or a tooltip.
+func (c *MCPConn) Hover(ctx context.Context, sel string) (string, error) {
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, interactTimeout)
+	defer cancel()
+
+	err := c.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		n, err := node(ctx, sel)
+		if err != nil {
+			return err
+		}
+		x, y, err := center(ctx, n)
+		if err != nil {
+			return err
+		}
+		return chromedp.MouseEvent(input.MouseMoved, x, y).Do(ctx)
+	}), chromedp.Sleep(interactSettle))
+	if err != nil {
+		return "", fmt.Errorf("hover %s: %w", sel, err)
+	}
+
+	c.screenshot(ctx)
+
+	return fmt.Sprintf("The mouse is over '%s'.", sel), nil
+}
+
+// ScrollTo scrolls the element matching the selector into the viewport, or
+// scrolls the page by dy pixels with the mouse wheel without selector, e.g.
+// to load the content of an infinite scroll.
+func (c *MCPConn) ScrollTo(ctx context.Context, sel string, dy float64) (string, error) {
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+	if sel == "" && dy == 0 {
+		return "", errors.New("no selector nor delta")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, interactTimeout)
+	defer cancel()
+
+	var action chromedp.Action
+	res := fmt.Sprintf("The page has been scrolled by %g pixels.", dy)
+	if sel != "" {
+		expr, by := fetch.Query(sel)
+		action = chromedp.ScrollIntoView(expr, by)
+		res = fmt.Sprintf("The element '%s' has been scrolled into view.", sel)
+	} else {
+		action = chromedp.ActionFunc(func(ctx context.Context) error {
+			// the wheel event applies at the center of the viewport.
+			var vp struct{ W, H float64 }
+			if err := chromedp.Evaluate(`({W: window.innerWidth, H: window.innerHeight})`, &vp).Do(ctx); err != nil {
+				return err
+			}
+			return input.DispatchMouseEvent(input.MouseWheel, vp.W/2, vp.H/2).WithDeltaX(0).WithDeltaY(dy).Do(ctx)
+		})
+	}
+
+	if err := c.run(ctx, action, chromedp.Sleep(interactSettle)); err != nil {
+		return "", fmt.Errorf("scroll: %w", err)
+	}
+
+	c.screenshot(ctx)
+
+	return res, nil
+}
+
+// DragAndDrop drags the element matching src and drops it on the element
+// matching dst. The HTML5 drags are intercepted and replayed as drag events,
+// the other ones, e.g. the sliders, follow the mouse moves.
+func (c *MCPConn) DragAndDrop(ctx context.Context, src, dst string) (string, error) {
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, interactTimeout)
+	defer cancel()
+
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	intercepted := make(chan *input.DragData, 1)
+	lctx, stop := context.WithCancel(tctx)
+	defer stop()
+	chromedp.ListenTarget(lctx, func(ev any) {
+		if ev, ok := ev.(*input.EventDragIntercepted); ok {
+			select {
+			case intercepted <- ev.Data:
+			default:
+			}
+		}
+	})
+
+	err := chromedp.Run(tctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		sn, err := node(ctx, src)
+		if err != nil {
+			return err
+		}
+		dn, err := node(ctx, dst)
+		if err != nil {
+			return err
+		}
+		sx, sy, err := center(ctx, sn)
+		if err != nil {
+			return err
+		}
+
+		// the browsers without interception get the mouse events only.
+		if err := input.SetInterceptDrags(true).Do(ctx); err != nil {
+			slog.Debug("intercept drags", slog.Any("err", err))
+		} else {
+			defer input.SetInterceptDrags(false).Do(ctx) // nolint:errcheck
+		}
+
+		if err := chromedp.MouseEvent(input.MouseMoved, sx, sy).Do(ctx); err != nil {
+			return err
+		}
+		if err := chromedp.MouseEvent(input.MousePressed, sx, sy, chromedp.ButtonLeft, chromedp.ClickCount(1)).Do(ctx); err != nil {
+			return err
+		}
+
+		// the target may move when the drag starts.
+		dx, dy, err := center(ctx, dn)
+		if err != nil {
+			return err
+		}
+		for i := 1; i <= dragSteps; i++ {
+			x := sx + (dx-sx)*float64(i)/dragSteps
+			y := sy + (dy-sy)*float64(i)/dragSteps
+			if err := chromedp.MouseEvent(input.MouseMoved, x, y, chromedp.ButtonLeft).Do(ctx); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case data := <-intercepted:
+			for _, typ := range []input.DispatchDragEventType{input.DragEnter, input.DragOver, input.Drop} {
+				if err := input.DispatchDragEvent(typ, dx, dy, data).Do(ctx); err != nil {
+					return err
+				}
+			}
+		case <-time.After(dragInterceptWait):
+		}
+
+		return chromedp.MouseEvent(input.MouseReleased, dx, dy, chromedp.ButtonLeft, chromedp.ClickCount(1)).Do(ctx)
+	}), chromedp.Sleep(interactSettle))
+	if err != nil {
+		return "", fmt.Errorf("drag %s to %s: %w", src, dst, err)
+	}
+
+	c.screenshot(ctx)
+
+	return fmt.Sprintf("The element '%s' has been dropped on '%s'.", src, dst), nil
+}
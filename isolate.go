@@ -0,0 +1,221 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+)
+
+const isolateTimeout = 10 * time.Second
+
+// isolation is the browser context of an isolated store.
+type isolation struct {
+	id cdp.BrowserContextID
+	// browser connection owning the context, the context is disposed when
+	// it closes, even if the server dies.
+	browser *chromedp.Browser
+	cancel  context.CancelFunc
+}
+
+// Isolated returns a new store of the browser opening its tabs in a browser
+// context of their own, with separate cookies, cache and storage. The
+// context is disposed when the store is closed.
+func (ts *targetStore) Isolated(ctx context.Context) (*targetStore, error) {
+	its := newTargetStore(ts.ctx, ts.resolve(ctx), ts.endpoints)
+	its.Headers = ts.Headers
+	its.BlockPrivate = ts.BlockPrivate
+	// the tabs aren't shared, their state is kept until the store closes.
+	its.ResetState = false
+
+	if err := its.isolate(ctx); err != nil {
+		its.Close()
+		return nil, err
+	}
+
+	return its, nil
+}
+
+// isolate creates the browser context of the store, replacing the previous
+// one.
+func (ts *targetStore) isolate(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, isolateTimeout)
+	defer cancel()
+
+	ws := ts.resolve(ctx)
+
+	// the connection lives with the store.
+	bctx, bcancel := context.WithCancel(ts.ctx)
+	b, err := chromedp.NewBrowser(bctx, ws)
+	if err != nil {
+		bcancel()
+		return &CDPConnectError{Host: ts.Host, Err: fmt.Errorf("browser context: %w", err)}
+	}
+	id, err := target.CreateBrowserContext().WithDisposeOnDetach(true).Do(cdp.WithExecutor(ctx, b))
+	if err != nil {
+		bcancel()
+		return &CDPConnectError{Host: ts.Host, Err: fmt.Errorf("create browser context: %w", err)}
+	}
+
+	ts.Lock()
+	prev := ts.isolation
+	ts.isolation = &isolation{id: id, browser: b, cancel: bcancel}
+	ts.Unlock()
+	prev.dispose()
+
+	slog.Debug("browser context created", slog.String("host", ts.Host), slog.String("id", string(id)))
+
+	return nil
+}
+
+// BrowserContext returns the id of the browser context of an isolated store,
+// empty for the default one.
+func (ts *targetStore) BrowserContext() cdp.BrowserContextID {
+	ts.Lock()
+	defer ts.Unlock()
+
+	if ts.isolation == nil {
+		return ""
+	}
+
+	return ts.isolation.id
+}
+
+// Cookies returns the cookies of the browser context of an isolated store.
+func (ts *targetStore) Cookies(ctx context.Context) ([]*network.Cookie, error) {
+	ts.Lock()
+	i := ts.isolation
+	ts.Unlock()
+	if i == nil {
+		return nil, errors.New("no browser context")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, isolateTimeout)
+	defer cancel()
+
+	cookies, err := storage.GetCookies().WithBrowserContextID(i.id).Do(cdp.WithExecutor(ctx, i.browser))
+	if err != nil {
+		return nil, fmt.Errorf("get cookies: %w", err)
+	}
+
+	return cookies, nil
+}
+
+// SetCookies adds the cookies to the browser context of an isolated store.
+func (ts *targetStore) SetCookies(ctx context.Context, cookies []*network.CookieParam) error {
+	ts.Lock()
+	i := ts.isolation
+	ts.Unlock()
+	if i == nil {
+		return errors.New("no browser context")
+	}
+	if len(cookies) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, isolateTimeout)
+	defer cancel()
+
+	if err := storage.SetCookies(cookies).WithBrowserContextID(i.id).Do(cdp.WithExecutor(ctx, i.browser)); err != nil {
+		return fmt.Errorf("set cookies: %w", err)
+	}
+
+	return nil
+}
+
+// contextOptions returns the options of the new tabs of the store.
+// The store must be locked.
+func (ts *targetStore) contextOptions() []chromedp.ContextOption {
+	if ts.isolation == nil {
+		return nil
+	}
+
+	return []chromedp.ContextOption{chromedp.WithExistingBrowserContext(ts.isolation.id)}
+}
+
+// dispose disposes the browser context and closes its connection.
+func (i *isolation) dispose() {
+	if i == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), isolateTimeout)
+	defer cancel()
+	if err := target.DisposeBrowserContext(i.id).Do(cdp.WithExecutor(ctx, i.browser)); err != nil {
+		// the context is disposed with the connection anyway.
+		slog.Debug("dispose browser context", slog.String("id", string(i.id)), slog.Any("err", err))
+	}
+	i.cancel()
+
+	slog.Debug("browser context disposed", slog.String("id", string(i.id)))
+}
+
+// Isolated returns an isolated store of the first browser able to create a
+// browser context.
+func (p *tabPools) Isolated(ctx context.Context) (*targetStore, error) {
+	stores := p.order()
+	if len(stores) == 0 {
+		return nil, errors.New("no browser")
+	}
+
+	var errs []error
+	for _, ts := range stores {
+		its, err := ts.Isolated(ctx)
+		if err == nil {
+			return its, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", ts.Host, err))
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+type isolationKey struct{}
+
+// withIsolation returns a context giving the isolated store of the tool call
+// to the pipelines.
+func withIsolation(ctx context.Context, ts *targetStore) context.Context {
+	return context.WithValue(ctx, isolationKey{}, ts)
+}
+
+// incognito returns a context whose pipelines and connections open their tabs
+// in a throwaway browser context, disposed by done.
+func (s *MCPServer) incognito(ctx context.Context) (context.Context, func(), error) {
+	ts, err := s.tabs.Isolated(ctx)
+	if err != nil {
+		return ctx, nil, fmt.Errorf("incognito: %w", err)
+	}
+
+	return withIsolation(ctx, ts), ts.Close, nil
+}
+
+// checkout returns a tab of the isolated store of ctx, if any, or of the
+// server's tabs.
+func (s *MCPServer) checkout(ctx context.Context) (*tab, error) {
+	if ts, _ := ctx.Value(isolationKey{}).(*targetStore); ts != nil {
+		return ts.Checkout()
+	}
+
+	return s.tabs.Checkout()
+}
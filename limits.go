@@ -0,0 +1,210 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/mcp"
+	"github.com/lightpanda-io/gomcp/pkg/tracing"
+)
+
+// ToolLimit bounds the execution of a tool. A zero value disables the
+// corresponding limit.
+type ToolLimit struct {
+	Timeout  time.Duration `json:"timeout"`
+	MaxBytes int           `json:"maxBytes"`
+}
+
+// The limits name applied to the tools without a specific limit.
+const ToolLimitsDefault = "*"
+
+// ToolLimits maps the tool names to their limits.
+// It implements flag.Value with the format name=timeout/size, e.g.
+// markdown=30s/2MB. The timeout or the size can be omitted.
+type ToolLimits map[string]ToolLimit
+
+var InvalidToolLimit = errors.New("invalid tool limit, the format is name=timeout/size")
+
+func (l ToolLimits) String() string {
+	names := make([]string, 0, len(l))
+	for k := range l {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+
+	s := make([]string, 0, len(names))
+	for _, name := range names {
+		v := l[name]
+		s = append(s, fmt.Sprintf("%s=%s/%d", name, v.Timeout, v.MaxBytes))
+	}
+
+	return strings.Join(s, ",")
+}
+
+func (l ToolLimits) Set(v string) error {
+	name, limits, ok := strings.Cut(v, "=")
+	if !ok || name == "" {
+		return InvalidToolLimit
+	}
+
+	var tl ToolLimit
+
+	timeout, size, _ := strings.Cut(limits, "/")
+	if timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return InvalidToolLimit
+		}
+		tl.Timeout = d
+	}
+	if size != "" {
+		n, err := parseSize(size)
+		if err != nil {
+			return InvalidToolLimit
+		}
+		tl.MaxBytes = n
+	}
+
+	l[name] = tl
+	return nil
+}
+
+// Get returns the limits of the tool, or the default ones.
+func (l ToolLimits) Get(name string) ToolLimit {
+	if tl, ok := l[name]; ok {
+		return tl
+	}
+
+	return l[ToolLimitsDefault]
+}
+
+// ByteSize is a size in bytes.
+// It implements flag.Value with an optional KB, MB or GB suffix.
+type ByteSize int
+
+func (s ByteSize) String() string {
+	return strconv.Itoa(int(s))
+}
+
+func (s *ByteSize) Set(v string) error {
+	n, err := parseSize(v)
+	if err != nil {
+		return err
+	}
+
+	*s = ByteSize(n)
+	return nil
+}
+
+// parseSize parses a size in bytes with an optional KB, MB or GB suffix.
+func parseSize(v string) (int, error) {
+	mult := 1
+	s := strings.ToUpper(strings.TrimSpace(v))
+	for _, u := range []struct {
+		suffix string
+		mult   int
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSuffix(s, u.suffix)
+			mult = u.mult
+			break
+		}
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", v)
+	}
+
+	return n * mult, nil
+}
+
+// LimitError is returned when a tool exceeds one of its limits.
+type LimitError struct {
+	Tool  string
+	Limit string
+	Value string
+	Max   string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("limit exceeded: tool %s %s %s, max %s", e.Tool, e.Limit, e.Value, e.Max)
+}
+
+// callTool calls the tool and enforces its limits.
+func (s *MCPServer) callTool(ctx context.Context, conn *MCPConn, req mcp.ToolsCallRequest) (res string, err error) {
+	name := req.Params.Name
+	l := s.Limits.Get(name)
+
+	ctx, span := s.Tracer.Start(ctx, "tools/call "+name, tracing.String("mcp.tool.name", name))
+	start := time.Now()
+	defer func() {
+		s.telemetry.tool(name, time.Since(start), err)
+		s.Audit.toolCall(conn.session, conn.tokenName(), name, start, len(res), conn.env.RedactError(err))
+		span.End(err)
+	}()
+	ctx = withSession(ctx, conn.session)
+	ctx = withSessionEnv(ctx, &conn.env)
+	if conn.isolated != nil {
+		ctx = withIsolation(ctx, conn.isolated)
+	}
+
+	if err := conn.authorize(name); err != nil {
+		return "", err
+	}
+
+	unlock, err := conn.lockPages(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+	}
+
+	res, err = s.CallTool(ctx, conn, req)
+	if l.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", &LimitError{
+			Tool:  name,
+			Limit: "execution time",
+			Value: time.Since(start).Round(time.Millisecond).String(),
+			Max:   l.Timeout.String(),
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if l.MaxBytes > 0 && len(res) > l.MaxBytes {
+		return "", &LimitError{
+			Tool:  name,
+			Limit: "response size",
+			Value: fmt.Sprintf("%d bytes", len(res)),
+			Max:   fmt.Sprintf("%d bytes", l.MaxBytes),
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	unixPrefix = "unix://"
+	tcpPrefix  = "tcp://"
+
+	ListenDefaultMode = FileMode(0660)
+)
+
+// FileMode is the permissions of a file.
+// It implements flag.Value with the octal notation, e.g. 0600.
+type FileMode os.FileMode
+
+var InvalidFileMode = errors.New("invalid file mode, e.g. 0600 or 0660")
+
+func (m FileMode) String() string {
+	return fmt.Sprintf("%#o", os.FileMode(m).Perm())
+}
+
+func (m *FileMode) Set(v string) error {
+	n, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || n > 0777 {
+		return InvalidFileMode
+	}
+
+	*m = FileMode(n)
+	return nil
+}
+
+// splitListen returns the network and the address of the listen address, a
+// TCP host:port, optionally prefixed with tcp://, or a unix socket path
+// prefixed with unix://.
+func splitListen(addr string) (string, string, error) {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		if path == "" {
+			return "", "", fmt.Errorf("invalid listen address %s: no socket path", addr)
+		}
+		return "unix", path, nil
+	}
+
+	addr = strings.TrimPrefix(addr, tcpPrefix)
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return "", "", fmt.Errorf("invalid listen address %s: %w", addr, err)
+	}
+
+	return "tcp", addr, nil
+}
+
+// listen listens on the address. The unix socket gets the permissions mode,
+// the socket left by a stopped server is replaced.
+func listen(addr string, mode FileMode) (net.Listener, error) {
+	network, address, err := splitListen(addr)
+	if err != nil {
+		return nil, err
+	}
+	if network == "tcp" {
+		return net.Listen(network, address)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(address), 0755); err != nil {
+		return nil, fmt.Errorf("socket dir: %w", err)
+	}
+
+	return listenUnix(address, os.FileMode(mode))
+}
+
+// ErrSocketInUse is returned when a server already listens on the socket.
+var ErrSocketInUse = errors.New("socket already in use")
+
+// listenUnix listens on the unix socket path created with the permissions
+// perm. The socket left by a crashed server is removed, the other files are
+// kept.
+func listenUnix(path string, perm os.FileMode) (net.Listener, error) {
+	fi, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("socket: %w", err)
+	case fi.Mode()&os.ModeSocket == 0:
+		return nil, fmt.Errorf("socket: %s exists and isn't a socket", path)
+	default:
+		if conn, err := net.DialTimeout("unix", path, daemonDialTimeout); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: %s", ErrSocketInUse, path)
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+
+	return bindUnix(path, perm)
+}
+
+type unixConnKey struct{}
+
+// connContext marks the contexts of the requests received on a unix socket.
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.(*net.UnixConn); ok {
+		return context.WithValue(ctx, unixConnKey{}, true)
+	}
+
+	return ctx
+}
+
+// unixConn returns true if the request was received on a unix socket, its
+// access is restricted by the socket permissions.
+func unixConn(req *http.Request) bool {
+	ok, _ := req.Context().Value(unixConnKey{}).(bool)
+	return ok
+}
+
+// apiEndpoint is the address of the API server for the admin commands.
+type apiEndpoint struct {
+	addr string
+	// TLS configuration of the client, nil for plain HTTP.
+	tls *tls.Config
+}
+
+// apiClient returns the client and the base url of the API server listening
+// on the address of api.
+func apiClient(api apiEndpoint) (*http.Client, string, error) {
+	network, address, err := splitListen(api.addr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	scheme := "http"
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if api.tls != nil {
+		scheme = "https"
+		tr.TLSClientConfig = api.tls.Clone()
+		if tr.TLSClientConfig.ServerName == "" {
+			tr.TLSClientConfig.ServerName = serverName(network, address)
+		}
+	}
+	if network == "tcp" {
+		return &http.Client{Transport: tr}, scheme + "://" + address, nil
+	}
+
+	tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}
+
+	return &http.Client{Transport: tr}, scheme + "://gomcp", nil
+}
+
+// serverName returns the name checked in the certificate of the API server,
+// localhost for a unix socket or an unspecified address.
+func serverName(network, address string) string {
+	if network != "tcp" {
+		return "localhost"
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil || host == "" {
+		return "localhost"
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		return "localhost"
+	}
+
+	return host
+}
@@ -0,0 +1,252 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+// loginActions are the actions of the login steps.
+var loginActions = []string{"goto", "click", "type", "press", "wait"}
+
+// loginFormJS marks the visible password input of the page, the username
+// input of its form and its submit button with the data-gomcp-login
+// attribute. A username input alone is marked for the forms asking the
+// password on a next page.
+const loginFormJS = `(() => {
+const visible = (e) => e.getClientRects().length > 0;
+document.querySelectorAll('[data-gomcp-login]').forEach((e) => e.removeAttribute('data-gomcp-login'));
+const password = [...document.querySelectorAll('input[type=password]')].find(visible);
+const scope = (password && password.form) || document;
+const inputs = [...scope.querySelectorAll('input')].filter((e) => visible(e) &&
+	['', 'text', 'email', 'tel'].includes((e.getAttribute('type') || '').toLowerCase()));
+const username = inputs.find((e) => /user|mail|login|account|ident/i.test([e.autocomplete, e.name, e.id].join(' '))) ||
+	(password ? inputs.filter((e) => password.compareDocumentPosition(e) & Node.DOCUMENT_POSITION_PRECEDING).pop() : undefined);
+const form = (password || username || {}).form;
+const submit = [...(form || scope).querySelectorAll('button[type=submit], input[type=submit], button:not([type])')].find(visible);
+const found = {};
+for (const [name, e] of Object.entries({password, username, submit})) {
+	if (!e) continue;
+	e.setAttribute('data-gomcp-login', name);
+	found[name] = true;
+}
+return found;
+})()`
+
+// LoginHostError is returned when the credential of a host would be typed
+// into a page of another host, e.g. a login url given by a prompt injection.
+type LoginHostError struct {
+	// Host of the credential.
+	Host string
+	// Location of the page.
+	URL string
+}
+
+func (e *LoginHostError) Error() string {
+	return fmt.Sprintf("login refused: %s isn't on %s, the credential is only typed into its site", e.URL, e.Host)
+}
+
+// loginHost returns true if the url u is on the host of the credential or one
+// of its subdomains.
+func loginHost(host, u string) bool {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	h := strings.ToLower(strings.TrimSuffix(pu.Hostname(), "."))
+
+	return h != "" && (h == host || strings.HasSuffix(h, "."+host))
+}
+
+// checkLoginHost returns a LoginHostError if the current page isn't on the
+// host of the credential or one of its subdomains.
+func (c *MCPConn) checkLoginHost(ctx context.Context, host string) error {
+	var location string
+	if err := c.run(ctx, chromedp.Location(&location)); err != nil {
+		return fmt.Errorf("page location: %w", err)
+	}
+	if !loginHost(host, location) {
+		return &LoginHostError{Host: host, URL: location}
+	}
+
+	return nil
+}
+
+type loginForm struct {
+	Password bool `json:"password"`
+	Username bool `json:"username"`
+	Submit   bool `json:"submit"`
+}
+
+func loginSelector(name string) string {
+	return fmt.Sprintf("[data-gomcp-login=%s]", name)
+}
+
+// Login logs in the site with its credential of the server's vault. The login
+// page is the url, the credential's one or the current page. The site is the
+// host of the current page by default. The credential is only typed into the
+// pages of its host or of its subdomains.
+// The password is never returned to the agent.
+func (c *MCPConn) Login(ctx context.Context, site, u string) (string, error) {
+	if c.srv.Vault == nil {
+		return "", errors.New("no credential vault, set MCP_VAULT_PASSPHRASE")
+	}
+
+	if site == "" {
+		if c.cdpctx == nil {
+			return "", errors.New("no site nor browser connection, give the site")
+		}
+		var location string
+		if err := c.run(ctx, chromedp.Location(&location)); err != nil {
+			return "", fmt.Errorf("page location: %w", err)
+		}
+		pu, err := url.Parse(location)
+		if err != nil || pu.Hostname() == "" {
+			return "", fmt.Errorf("no site for the page %s", location)
+		}
+		site = pu.Hostname()
+	}
+
+	host, cred, err := c.srv.Vault.Get(site)
+	if err != nil {
+		return "", err
+	}
+	slog.Debug("login", slog.String("site", host), slog.Int("steps", len(cred.Steps)))
+	if u != "" && !loginHost(host, u) {
+		return "", &LoginHostError{Host: host, URL: u}
+	}
+
+	res, err := c.interact(ctx, func() (string, error) {
+		if u == "" {
+			u = cred.LoginURL
+		}
+		if u != "" {
+			if _, err := c.Goto(ctx, u); err != nil {
+				return "", err
+			}
+		} else if c.cdpctx == nil {
+			return "", errors.New("no browser connection, give the url of the login page")
+		}
+
+		if len(cred.Steps) > 0 {
+			if err := c.loginSteps(ctx, host, cred); err != nil {
+				return "", err
+			}
+		} else if err := c.loginForm(ctx, host, cred); err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("The credential of %s has been submitted as %s.", host, cred.Username), nil
+	})
+
+	// the page may echo the password.
+	if cred.Password != "" {
+		res = strings.ReplaceAll(res, cred.Password, "[redacted]")
+		if err != nil {
+			err = &redactedError{msg: strings.ReplaceAll(err.Error(), cred.Password, "[redacted]"), err: err}
+		}
+	}
+
+	return res, err
+}
+
+// loginForm fills the login form of the page and submits it. The username and
+// the password may be asked on two pages.
+func (c *MCPConn) loginForm(ctx context.Context, host string, cred Credential) error {
+	for page := 0; page < 2; page++ {
+		// the first page may have redirected to another site.
+		if err := c.checkLoginHost(ctx, host); err != nil {
+			return err
+		}
+		var form loginForm
+		if err := c.run(ctx, chromedp.Evaluate(loginFormJS, &form)); err != nil {
+			return fmt.Errorf("login form: %w", err)
+		}
+		if !form.Password && !form.Username {
+			return errors.New("no login form in the page")
+		}
+
+		if form.Username {
+			if _, err := c.Type(ctx, loginSelector("username"), cred.Username); err != nil {
+				return err
+			}
+		}
+		if form.Password {
+			if _, err := c.Type(ctx, loginSelector("password"), cred.Password); err != nil {
+				return errors.New("type the password")
+			}
+		}
+
+		var err error
+		if form.Submit {
+			_, err = c.Click(ctx, loginSelector("submit"))
+		} else {
+			_, err = c.PressKey(ctx, "Enter", "")
+		}
+		if err != nil {
+			return fmt.Errorf("submit the login form: %w", err)
+		}
+
+		if form.Password {
+			return nil
+		}
+	}
+
+	return errors.New("no password input in the login form")
+}
+
+// loginSteps runs the steps of a scripted login. The values are typed into the
+// pages of the host only.
+func (c *MCPConn) loginSteps(ctx context.Context, host string, cred Credential) error {
+	for i, st := range cred.Steps {
+		var err error
+		switch st.Action {
+		case "goto":
+			_, err = c.Goto(ctx, cred.expand(st.Value))
+		case "click":
+			_, err = c.Click(ctx, st.Selector)
+		case "type":
+			if err = c.checkLoginHost(ctx, host); err != nil {
+				break
+			}
+			// the error would give the password.
+			if _, err = c.Type(ctx, st.Selector, cred.expand(st.Value)); err != nil {
+				err = fmt.Errorf("type into %s", st.Selector)
+			}
+		case "press":
+			_, err = c.PressKey(ctx, st.Value, st.Selector)
+		case "wait":
+			wctx, cancel := context.WithTimeout(ctx, interactTimeout)
+			expr, by := fetch.Query(st.Selector)
+			err = c.run(wctx, chromedp.WaitVisible(expr, by))
+			cancel()
+		default:
+			err = fmt.Errorf("invalid action %q", st.Action)
+		}
+		if err != nil {
+			return fmt.Errorf("login step %d %s: %w", i+1, st.Action, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,394 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/lightpanda-io/gomcp/pkg/search"
+)
+
+const (
+	lookupUserAgent = "gomcp (https://github.com/lightpanda-io/gomcp)"
+	// Length of the page extract used when the APIs miss.
+	lookupFallbackSize = 1500
+)
+
+// EntityCard is a compact description of an entity.
+type EntityCard struct {
+	Name         string
+	Description  string
+	Summary      string
+	URL          string
+	OfficialSite string
+	ImageURL     string
+	// Facts are the key facts as label and value pairs.
+	Facts [][2]string
+}
+
+func (c EntityCard) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n", c.Name)
+	if c.Description != "" {
+		fmt.Fprintf(&b, "%s\n", c.Description)
+	}
+	if c.Summary != "" {
+		fmt.Fprintf(&b, "\n%s\n", c.Summary)
+	}
+	if len(c.Facts) > 0 || c.OfficialSite != "" || c.ImageURL != "" {
+		b.WriteString("\n")
+	}
+	for _, f := range c.Facts {
+		fmt.Fprintf(&b, "- %s: %s\n", f[0], f[1])
+	}
+	if c.OfficialSite != "" {
+		fmt.Fprintf(&b, "- Official site: %s\n", c.OfficialSite)
+	}
+	if c.ImageURL != "" {
+		fmt.Fprintf(&b, "- Image: %s\n", c.ImageURL)
+	}
+	if c.URL != "" {
+		fmt.Fprintf(&b, "\nSource: %s\n", c.URL)
+	}
+
+	return b.String()
+}
+
+// Wikidata properties returned as key facts.
+var lookupFacts = []struct {
+	id    string
+	label string
+}{
+	{"P31", "Instance of"},
+	{"P17", "Country"},
+	{"P159", "Headquarters"},
+	{"P112", "Founded by"},
+	{"P169", "CEO"},
+	{"P571", "Inception"},
+	{"P569", "Born"},
+	{"P570", "Died"},
+	{"P19", "Place of birth"},
+	{"P106", "Occupation"},
+	{"P1082", "Population"},
+	{"P36", "Capital"},
+}
+
+var (
+	ErrNoEntity = errors.New("no entity found")
+	// ErrInvalidLanguage is returned for a language not formed as a
+	// Wikipedia language code, e.g. en or zh-yue.
+	ErrInvalidLanguage = errors.New("invalid language code")
+)
+
+// wikiLanguage matches the language codes of the Wikipedia hosts.
+var wikiLanguage = regexp.MustCompile(`^[a-z]{2,3}(-[a-z]+)?$`)
+
+// Lookup returns the card of the entity name from Wikipedia and Wikidata. If
+// the APIs don't know the entity, the card is built from the first search
+// result.
+func (c *MCPConn) Lookup(ctx context.Context, name, lang string) (string, error) {
+	if lang == "" {
+		lang = "en"
+	}
+	// the language is the subdomain of the Wikipedia host.
+	if !wikiLanguage.MatchString(lang) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidLanguage, lang)
+	}
+
+	card, err := c.srv.wikiLookup(ctx, name, lang)
+	if err == nil {
+		return card.String(), nil
+	}
+	slog.Debug("wiki lookup", slog.String("name", name), slog.Any("err", err))
+
+	card, err = c.searchLookup(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("lookup %s: %w", name, err)
+	}
+
+	return card.String(), nil
+}
+
+// searchLookup builds the card from the page of the first search result.
+func (c *MCPConn) searchLookup(ctx context.Context, name string) (EntityCard, error) {
+	res, err := c.Search(ctx, search.Query{Text: name})
+	if err != nil {
+		return EntityCard{}, err
+	}
+	if len(res) == 0 {
+		return EntityCard{}, ErrNoEntity
+	}
+
+	first := res[0]
+	if _, err := c.Goto(ctx, first.URL); err != nil {
+		return EntityCard{}, err
+	}
+
+	content, err := c.GetMarkdown(ctx)
+	if err != nil {
+		return EntityCard{}, err
+	}
+	if len(content) > lookupFallbackSize {
+		content = content[:lookupFallbackSize] + "..."
+	}
+
+	return EntityCard{
+		Name:        first.Title,
+		Description: first.Snippet,
+		Summary:     content,
+		URL:         first.URL,
+	}, nil
+}
+
+// wikiLookup builds the card from the Wikipedia summary of the best matching
+// page and its Wikidata item.
+func (s *MCPServer) wikiLookup(ctx context.Context, name, lang string) (EntityCard, error) {
+	wiki := "https://" + lang + ".wikipedia.org"
+
+	var found struct {
+		Query struct {
+			Search []struct {
+				Title string `json:"title"`
+			} `json:"search"`
+		} `json:"query"`
+	}
+	v := url.Values{}
+	v.Set("action", "query")
+	v.Set("list", "search")
+	v.Set("srsearch", name)
+	v.Set("srlimit", "1")
+	v.Set("format", "json")
+	if err := getJSON(ctx, s.httpClient(), wiki+"/w/api.php?"+v.Encode(), &found); err != nil {
+		return EntityCard{}, fmt.Errorf("wikipedia search: %w", err)
+	}
+	if len(found.Query.Search) == 0 {
+		return EntityCard{}, ErrNoEntity
+	}
+	title := found.Query.Search[0].Title
+
+	var summary struct {
+		Title        string `json:"title"`
+		Description  string `json:"description"`
+		Extract      string `json:"extract"`
+		WikibaseItem string `json:"wikibase_item"`
+		Thumbnail    struct {
+			Source string `json:"source"`
+		} `json:"thumbnail"`
+		ContentURLs struct {
+			Desktop struct {
+				Page string `json:"page"`
+			} `json:"desktop"`
+		} `json:"content_urls"`
+	}
+	u := wiki + "/api/rest_v1/page/summary/" + url.PathEscape(strings.ReplaceAll(title, " ", "_"))
+	if err := getJSON(ctx, s.httpClient(), u, &summary); err != nil {
+		return EntityCard{}, fmt.Errorf("wikipedia summary: %w", err)
+	}
+
+	card := EntityCard{
+		Name:        summary.Title,
+		Description: summary.Description,
+		Summary:     summary.Extract,
+		URL:         summary.ContentURLs.Desktop.Page,
+		ImageURL:    summary.Thumbnail.Source,
+	}
+
+	if summary.WikibaseItem != "" {
+		// the facts are optional, the summary is enough for a card.
+		if err := s.wikidataFacts(ctx, summary.WikibaseItem, lang, &card); err != nil {
+			slog.Debug("wikidata facts", slog.String("id", summary.WikibaseItem), slog.Any("err", err))
+		}
+	}
+
+	return card, nil
+}
+
+type wikidataClaim struct {
+	Mainsnak struct {
+		Datavalue struct {
+			Type  string          `json:"type"`
+			Value json.RawMessage `json:"value"`
+		} `json:"datavalue"`
+	} `json:"mainsnak"`
+}
+
+type wikidataEntities struct {
+	Entities map[string]struct {
+		Labels map[string]struct {
+			Value string `json:"value"`
+		} `json:"labels"`
+		Claims map[string][]wikidataClaim `json:"claims"`
+	} `json:"entities"`
+}
+
+// wikidataFacts adds the key facts, the official site and the image of the
+// Wikidata item id to the card.
+func (s *MCPServer) wikidataFacts(ctx context.Context, id, lang string, card *EntityCard) error {
+	var item wikidataEntities
+	v := url.Values{}
+	v.Set("action", "wbgetentities")
+	v.Set("ids", id)
+	v.Set("props", "claims")
+	v.Set("format", "json")
+	if err := getJSON(ctx, s.httpClient(), "https://www.wikidata.org/w/api.php?"+v.Encode(), &item); err != nil {
+		return err
+	}
+
+	claims := item.Entities[id].Claims
+
+	if site := claimString(claims["P856"]); site != "" {
+		card.OfficialSite = site
+	}
+	if img := claimString(claims["P18"]); img != "" {
+		card.ImageURL = "https://commons.wikimedia.org/wiki/Special:FilePath/" + url.PathEscape(img)
+	}
+
+	// collect the values, the entities ids are replaced by their labels
+	// after.
+	type fact struct {
+		label string
+		ids   []string
+		value string
+	}
+	var facts []fact
+	var ids []string
+	for _, lf := range lookupFacts {
+		f := fact{label: lf.label}
+		for _, c := range claims[lf.id] {
+			dv := c.Mainsnak.Datavalue
+			switch dv.Type {
+			case "wikibase-entityid":
+				var e struct {
+					Id string `json:"id"`
+				}
+				if json.Unmarshal(dv.Value, &e) == nil && e.Id != "" {
+					f.ids = append(f.ids, e.Id)
+					ids = append(ids, e.Id)
+				}
+			case "time":
+				var t struct {
+					Time string `json:"time"`
+				}
+				if json.Unmarshal(dv.Value, &t) == nil && f.value == "" {
+					// +2009-11-10T00:00:00Z
+					f.value, _, _ = strings.Cut(strings.TrimPrefix(t.Time, "+"), "T")
+				}
+			case "quantity":
+				var q struct {
+					Amount string `json:"amount"`
+				}
+				if json.Unmarshal(dv.Value, &q) == nil && f.value == "" {
+					f.value = strings.TrimPrefix(q.Amount, "+")
+				}
+			}
+			// keep the first values only.
+			if len(f.ids) >= 3 {
+				break
+			}
+		}
+		if f.value != "" || len(f.ids) > 0 {
+			facts = append(facts, f)
+		}
+	}
+
+	labels := map[string]string{}
+	if len(ids) > 0 {
+		var ents wikidataEntities
+		v := url.Values{}
+		v.Set("action", "wbgetentities")
+		v.Set("ids", strings.Join(ids, "|"))
+		v.Set("props", "labels")
+		v.Set("languages", lang+"|en")
+		v.Set("format", "json")
+		if err := getJSON(ctx, s.httpClient(), "https://www.wikidata.org/w/api.php?"+v.Encode(), &ents); err != nil {
+			return err
+		}
+		for id, e := range ents.Entities {
+			if l, ok := e.Labels[lang]; ok {
+				labels[id] = l.Value
+			} else if l, ok := e.Labels["en"]; ok {
+				labels[id] = l.Value
+			}
+		}
+	}
+
+	for _, f := range facts {
+		value := f.value
+		if len(f.ids) > 0 {
+			names := make([]string, 0, len(f.ids))
+			for _, id := range f.ids {
+				if l, ok := labels[id]; ok {
+					names = append(names, l)
+				}
+			}
+			value = strings.Join(names, ", ")
+		}
+		if value != "" {
+			card.Facts = append(card.Facts, [2]string{f.label, value})
+		}
+	}
+
+	return nil
+}
+
+// claimString returns the first string value of the claims.
+func claimString(claims []wikidataClaim) string {
+	for _, c := range claims {
+		var s string
+		if c.Mainsnak.Datavalue.Type == "string" && json.Unmarshal(c.Mainsnak.Datavalue.Value, &s) == nil {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// getJSON decodes the JSON response of the url requested with cli into v.
+func getJSON(ctx context.Context, cli *http.Client, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("create http req: %w", err)
+	}
+	req.Header.Set("User-Agent", lookupUserAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return fmt.Errorf("do req: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNoEntity
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
@@ -23,9 +23,13 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
+	"time"
 
-	"github.com/chromedp/chromedp"
+	"github.com/lightpanda-io/gomcp/pkg/browser"
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+	"github.com/lightpanda-io/gomcp/pkg/tracing"
 )
 
 const (
@@ -41,7 +45,8 @@ func main() {
 	err := run(ctx, os.Args, os.Stdin, os.Stdout, os.Stderr)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
-		os.Exit(exitFail)
+		// the exit code gives the failure class.
+		os.Exit(classify(err).Exit)
 	}
 
 	os.Exit(exitOK)
@@ -49,6 +54,11 @@ func main() {
 
 const (
 	ApiDefaultAddress = "127.0.0.1:8081"
+
+	SessionIdleTTLDefault = time.Hour
+	MaxPinnedTabsDefault  = 4
+
+	MaxConcurrentCallsDefault = 4
 )
 
 func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
@@ -58,32 +68,242 @@ func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.
 
 	var (
 		verbose = flags.Bool("verbose", false, "enable debug log level")
+		config  = flags.String("config", env("MCP_CONFIG", configfile()), "YAML config file giving the default values of the options")
 		apiaddr = flags.String("api-addr", env("MCP_API_ADDRESS", ApiDefaultAddress), "http api server address")
-		cdp     = flags.String("cdp", os.Getenv("MCP_CDP"), "cdp ws to connect. By default gomcp will run the download Lightpanda browser.")
+		tlscert = flags.String("tls-cert", env("MCP_TLS_CERT", ""), "certificate file of the http server in PEM, enables TLS with --tls-key")
+		tlskey  = flags.String("tls-key", env("MCP_TLS_KEY", ""), "private key file of the http server in PEM")
+		tlsca   = flags.String("tls-client-ca", env("MCP_TLS_CLIENT_CA", ""), "CA file in PEM of the client certificates required by the http server")
+		tlsrca  = flags.String("tls-ca", env("MCP_TLS_CA", ""), "CA file in PEM of the http server certificate for the sessions, tabs and cdp commands, the system CAs by default, enables TLS")
+		tlsccrt = flags.String("tls-client-cert", env("MCP_TLS_CLIENT_CERT", ""), "client certificate file in PEM of the sessions, tabs and cdp commands, for a server requiring one")
+		tlsckey = flags.String("tls-client-key", env("MCP_TLS_CLIENT_KEY", ""), "private key file in PEM of --tls-client-cert")
+		oissuer = flags.String("oauth-issuer", env("MCP_OAUTH_ISSUER", ""), "url of the OAuth 2.1 authorization server issuing the access tokens of the HTTP SSE clients, requires --oauth-resource")
+		oresrc  = flags.String("oauth-resource", env("MCP_OAUTH_RESOURCE", ""), "canonical url of the HTTP SSE server, the audience of the OAuth access tokens, e.g. https://mcp.example.com")
+		listenf = flags.String("listen", env("MCP_LISTEN", ""), "address of the http api and MCP server: host:port, tcp://host:port or unix:///path/to/socket, --api-addr by default")
+		history = flags.Int("history", HistoryDefaultSize, "number of visited pages kept in memory per connection")
+		noindex = flags.Bool("skip-noindex", false, "don't keep the pages with noindex or noarchive robots directives in the history")
+		noboil  = flags.Bool("keep-boilerplate", false, "keep the navigation, footers, cookie banners, share widgets and --boilerplate elements in the extractions")
+		clean   = flags.Bool("sanitize", true, "remove the invisible unicode characters of the tool results, e.g. zero width spaces and bidi overrides hiding prompt injections")
+		failst  = flags.Bool("fail-on-error-status", true, "fail the navigations answered with a 4xx or 5xx status, use false to load the error pages")
+		mobile  = flags.Bool("mobile", false, "load the pages as a mobile device")
+		devname = flags.String("device", "", "name of the emulated device, e.g. \"iPhone 14\" or \"Pixel 5\"")
+		dpr     = flags.Float64("dpr", 0, "device pixel ratio of the emulated screen")
+		lang    = flags.String("lang", "", "language of the pages, e.g. fr-FR")
+		tz      = flags.String("timezone", "", "IANA timezone of the browser, e.g. Europe/Paris")
+		cwait   = flags.Duration("click-wait", fetch.ClickDefaultWait, "wait after the clicks of the --click selectors")
+		sites   = flags.Bool("site-extractors", true, "extract the pages of GitHub, Stack Overflow, Wikipedia, Hacker News and Reddit with their dedicated extractors")
+		consent = flags.Bool("dismiss-consent", false, "close the cookie consent dialogs of the common platforms (OneTrust, Cookiebot, Quantcast...) before the extractions")
+		rmotion = flags.Bool("reduced-motion", false, "emulate the prefers-reduced-motion media feature")
+		stealth = flags.Bool("stealth", false, "hide the automation markers before the navigations: navigator.webdriver, the headless user agent and its client hints, the missing plugins and chrome object")
+		idlettl = flags.Duration("session-idle-ttl", SessionIdleTTLDefault, "close the sse sessions idle for longer, 0 disables it")
+		ttl     = flags.Duration("session-ttl", 0, "close the sse sessions older than the duration, 0 disables it")
+		drain   = flags.Duration("drain-timeout", DrainDefaultTimeout, "time given to the running tool calls to finish on SIGTERM before the sse sessions are closed")
+		pinned  = flags.Int("max-pinned-tabs", MaxPinnedTabsDefault, "maximum number of tabs pinned at the same time")
+		calls   = flags.Int("max-concurrent-calls", MaxConcurrentCallsDefault, "maximum number of tool calls running at the same time per connection, the next ones are queued")
+		qcalls  = flags.Int("max-queued-calls", MaxQueuedCallsDefault, "maximum number of tool calls waiting for a running one per connection, the next ones are refused")
+		sessdir = flags.String("sessions-dir", env("MCP_SESSIONS_DIR", ""), "directory persisting the sse sessions records and the cookies of the isolated sessions, their clients resume them after a restart with the Mcp-Session-Id header, empty disables it")
+		queue   = flags.Int("session-queue", SessionQueueSizeDefault, "maximum number of requests queued per sse session")
+		maxtabs = flags.Int("max-tabs", 0, "maximum number of tabs kept in the pool per browser, when no tab is idle a new one is opened up to the limit then --pool-policy applies, 0 means no limit")
+		ptime   = flags.Duration("pool-timeout", PoolDefaultWaitTimeout, "maximum duration to wait for a free tab with the block pool policy")
+		isolate = flags.Bool("isolate-sessions", false, "open the tabs of each sse session in a browser context of its own, with separate cookies, cache and storage, disposed with the session")
+		reuse   = flags.Bool("reuse-state", false, "keep the cookies, session storage and page of the tabs between the connections")
+		launchb = flags.Bool("launch-browser", false, "launch a local browser if none of the cdp is reachable")
+		private = flags.Bool("allow-private-networks", false, "allow the navigations to the private, loopback, link-local and cloud metadata addresses, refused by default")
+		binpath = flags.String("browser-bin", os.Getenv("MCP_BROWSER_BIN"), "browser binary launched locally, Lightpanda or Chrome. By default the downloaded Lightpanda browser.")
+		cdpttl  = flags.Duration("cdp-cache-ttl", browser.CacheDefaultTTL, "resolve again the cached cdp websocket urls older than the duration, 0 disables it")
+		scache  = flags.Duration("search-cache-ttl", SearchCacheDefaultTTL, "time the results of a search are reused by the identical searches, same normalized text, engine, type and filters, 0 disables the cache")
+		retries = flags.Int("retries", RetriesDefault, "number of retries of a navigation failing with a transient error, e.g. a browser disconnection, a connection reset, a 502, 503 or 504 status or an empty page")
+		backoff = flags.Duration("retry-backoff", RetryDefaultBackoff, "delay before the first retry, doubled on each retry")
+		delay   = flags.Duration("delay", 0, "minimum delay between two navigations to the same host, with up to 50% of jitter")
+		perhost = flags.Int("max-per-host", 0, "maximum number of navigations to the same host at the same time, 0 means no limit")
+		sfile   = flags.String("searches", env("MCP_SEARCHES", searchesfile()), "JSON file of the saved searches")
+		vpath   = flags.String("vault", env("MCP_VAULT", vaultfile()), "file of the sites credentials used by the login tool, encrypted with the MCP_VAULT_PASSPHRASE passphrase")
+		rdir    = flags.String("recipes", env("MCP_RECIPES", recipesdir()), "directory of the YAML recipes, the ones with a tool name are exposed as MCP tools")
+		dsock   = flags.String("daemon-socket", env("MCP_DAEMON_SOCKET", daemonsocket()), "unix socket of the daemon, the search and fetch commands are forwarded to the running daemon, empty to disable")
+		snapdir = flags.String("snapshots", env("MCP_SNAPSHOTS", snapshotsdir()), "directory of the pages snapshots compared by the watch command and the diff_page tool")
+		fdoms   = flags.String("filter-domains", "", "file of the domains whose pages are flagged by the safety filter, one per line")
+		fwords  = flags.String("filter-keywords", "", "file of the keywords flagged by the safety filter, one per line")
+		dldir   = flags.String("download-dir", DownloadDefaultDir, "directory of the files saved by the download tool")
+		updir   = flags.String("upload-dir", "", "directory of the files the set_file_input tool can upload, empty disables the uploads")
+		dbgdir  = flags.String("debug-artifacts", "", "directory receiving a screenshot and the DOM of the page when a navigation or a search fails or returns no result")
+		standby = flags.String("standby-of", env("MCP_STANDBY_OF", ""), "api url of the primary server whose state is replicated, e.g. http://10.0.0.1:8081")
+		rtoken  = flags.String("replica-token", "", "token shared by the primary and standby servers to replicate the state")
+		rtime   = flags.Duration("replica-interval", ReplicaDefaultInterval, "interval of the state replication from the primary server")
+		detectc = flags.Bool("detect-challenges", true, "fail the navigations ending on a bot challenge or a CAPTCHA, e.g. Cloudflare or the DuckDuckGo anomaly page, with a bot_challenge error")
+		solver  = flags.String("challenge-solver", env("MCP_CHALLENGE_SOLVER", ""), "url of an external solver receiving the bot challenges and returning the cookies passing them")
+		fclass  = flags.String("filter-classifier", env("MCP_FILTER_CLASSIFIER", ""), "url of an external classifier screening the pages contents")
+		trto    = flags.String("translate-to", "", "language the pages content of the fetch and research commands and the markdown tool is translated to, e.g. en, requires --translate-endpoint")
+		trurl   = flags.String("translate-endpoint", env("MCP_TRANSLATE_ENDPOINT", ""), "url of a LibreTranslate compatible translate endpoint, e.g. http://localhost:5000/translate")
+		trkey   = flags.String("translate-key", "", "api key of the translate endpoint")
+		audit   = flags.String("audit-log", env("MCP_AUDIT_LOG", ""), "JSON lines file the tool calls and the navigations are appended to, with their session, duration, bytes and outcome")
+		otel    = flags.String("otel-endpoint", env("OTEL_EXPORTER_OTLP_ENDPOINT", ""), "url of an OpenTelemetry collector receiving the traces of the tool calls with OTLP/HTTP JSON, e.g. http://localhost:4318")
 	)
 
+	limits := ToolLimits{}
+	flags.Var(limits, "tool-limit", "execution time and response size limits of a tool as name=timeout/size, e.g. markdown=30s/2MB, use * for all the tools, can be repeated")
+
+	var tokens APITokens
+	flags.Var(&tokens, "token", "bearer token of the HTTP SSE clients as name:scope[,scope]:secret, the scopes are read, browse and eval, a $VAR secret is read from the environment, can be repeated, without token the clients aren't authenticated")
+
+	var cdp CDPEndpoints
+	flags.Var(&cdp, "cdp", "cdp ws to connect, can be repeated or a comma separated list to balance the tabs across several browsers. By default gomcp will run the download Lightpanda browser.")
+
+	balance := BalanceDefaultPolicy
+	flags.Var(&balance, "balance", "choice of the browser for a new tab with several cdp: round-robin or least-loaded")
+
+	headers := HTTPHeaders{}
+	flags.Var(headers, "header", "extra HTTP header sent by the browser tabs as 'Name: value', can be repeated")
+
+	var hquota ByteSize
+	flags.Var(&hquota, "history-quota", "maximum size of the visited pages kept in memory per connection, e.g. 10MB, the least recently used pages are evicted, 0 means no quota")
+
+	var clicks ClickSelectors
+	flags.Var(&clicks, "click", "CSS selector, xpath: prefixed XPath expression or shadow: prefixed shadow piercing selector of the elements clicked after the navigations, before the extraction, e.g. read more or expand buttons, can be repeated")
+
+	var boilerplate BoilerplateSelectors
+	flags.Var(&boilerplate, "boilerplate", "CSS selector of the elements removed before the extractions, added to the built-in navigation, footer, cookie banner and share widget selectors, can be repeated")
+
+	var outenc OutputEncoding
+	flags.Var(&outenc, "output-encoding", "encoding of the commands output, e.g. gbk or shift_jis for the legacy consoles, utf-8 by default")
+
+	var blocklists BlocklistFiles
+	flags.Var(&blocklists, "blocklist", "file of malware or phishing hosts or urls blocked before the navigations, in hosts format or URLhaus dump, can be repeated")
+
+	faction := FilterDefaultAction
+	flags.Var(&faction, "filter-action", "action of the safety filter on the flagged contents: block returns an error, redact replaces the flagged parts")
+
+	injections := InjectionDefaultPolicy
+	flags.Var(&injections, "injections", "handling of the suspected prompt injections of the pages, e.g. ignore previous instructions or texts hidden with CSS: flag warns the agent, strip also removes them, off")
+
+	var viewport Viewport
+	flags.Var(&viewport, "viewport", "size of the emulated screen, e.g. 1280x800")
+
+	var cformats ContentFormats
+	flags.Var(&cformats, "content-format", "formatter of the tool results per client as [client[@version]=]text|structured|links, can be repeated, the first matching rule applies")
+
+	mode := FetchModeBrowser
+	flags.Var(&mode, "mode", "loading of the fetched pages: browser, or http to fetch the static pages without browser, the pages needing JavaScript fall back to the browser")
+
+	lmode := ListenDefaultMode
+	flags.Var(&lmode, "listen-mode", "permissions of the --listen unix socket, e.g. 0600 for the user only")
+
+	shots := ScreenshotDefaultPolicy
+	flags.Var(&shots, "screenshots", "screenshot in the navigation results: auto if the client displays the images, always or never")
+
+	var maxtext ByteSize
+	flags.Var(&maxtext, "max-response-text", "maximum size of the tool results text, e.g. 64KB, 0 truncates them only for the clients with a small context window")
+
+	var maxcontent ByteSize
+	flags.Var(&maxcontent, "max-content-bytes", "maximum size of the pages content, e.g. 32KB, 0 means no limit")
+	maxtokens := flags.Int("max-tokens-estimate", 0, "maximum size of the pages content in tokens, estimated at 4 bytes per token, 0 means no limit")
+	chunksize := ByteSize(ChunkDefaultSize)
+	flags.Var(&chunksize, "chunk-size", "size of the chunks of the pages content returned by the markdown, dom and get_history_page tools, the next chunks are pulled with fetch_page_chunk, 0 disables it")
+	truncation := fetch.TruncateSmart
+	flags.Var(&truncation, "truncate", "truncation of the pages content exceeding the maximum size: smart keeps the title, the headings and the first paragraphs of each section, head keeps the beginning")
+
+	var scheme ColorScheme
+	flags.Var(&scheme, "color-scheme", "emulated prefers-color-scheme media feature: dark or light")
+
+	var geo Geolocation
+	flags.Var(&geo, "geolocation", "position of the browser as latitude,longitude, e.g. 48.8566,2.3522")
+
+	var noise StealthNoise
+	flags.Var(&noise, "stealth-noise", "fingerprints randomized with --stealth, a comma separated list of webgl, reporting a common GPU, and canvas")
+
+	policy := PoolDefaultPolicy
+	flags.Var(&policy, "pool-policy", "behavior when max-tabs is reached: block waits for a free tab, grow opens a surplus tab closed after use, fail returns an error")
+
 	// usage func declaration.
 	exec := args[0]
 	flags.Usage = func() {
-		fmt.Fprintf(stderr, "usage: %s sse|stdio|download|cleanup\n", exec)
+		fmt.Fprintf(stderr, "usage: %s sse|stdio|daemon|search|research|fetch|watch|archive|run|feed|head|canary|download|cleanup|sessions|tabs|cdp|config|auth|capabilities\n", exec)
 		fmt.Fprintf(stderr, "Demo MCP server.\n")
 		fmt.Fprintf(stderr, "\nCommands:\n")
 		fmt.Fprintf(stderr, "\tstdio\t\tstarts the stdio server\n")
 		fmt.Fprintf(stderr, "\tsse\t\tstarts the HTTP SSE MCP server\n")
+		fmt.Fprintf(stderr, "\tdaemon\t\tkeeps the browser connection warm and runs the search and fetch commands forwarded on --daemon-socket\n")
+		fmt.Fprintf(stderr, "\tsearch <text>|--saved <name>|--queries <file>\truns a search, a saved search or a batch of searches\n")
+		fmt.Fprintf(stderr, "\tresearch [--results <n>] [--json] <text>\truns a search and prints the content of the first results\n")
+		fmt.Fprintf(stderr, "\tfetch [--json|--jsonl] [--out dir] [--resume] <url>...\tprints the pages content in markdown or writes them in files, an interrupt prints the content loaded so far\n")
+		fmt.Fprintf(stderr, "\twatch [--interval <d>] [--once] <url>\tchecks the page every interval and prints the diff of its content when it changes\n")
+		fmt.Fprintf(stderr, "\tarchive [--format mhtml|dir] [--out <path>] <url>\tsaves the page as rendered in a MHTML file or a directory with its assets\n")
+		fmt.Fprintf(stderr, "\thead [--etag <v>] [--since <date>] <url>\tprints the status, headers and validators of the url without browser\n")
+		fmt.Fprintf(stderr, "\tfeed [--limit <n>] <url>\tprints the entries of the RSS or Atom feed of the url without browser\n")
+		fmt.Fprintf(stderr, "\tcanary --suite <file>\truns the extraction checks of the suite against the browser\n")
 		fmt.Fprintf(stderr, "\tdownload\tinstalls or updates the Lightpanda browser\n")
+		fmt.Fprintf(stderr, "\tdownload [--out <dir>] <url>\tsaves the file downloaded by the page and prints its path and checksum\n")
+		fmt.Fprintf(stderr, "\trun [--param name=value] <recipe.yaml>\truns the browse steps of the recipe and prints the extracted contents\n")
 		fmt.Fprintf(stderr, "\tcleanup\tremoves the Lightpanda browser\n")
+		fmt.Fprintf(stderr, "\tsessions list|kill <id>\tmanages the sessions of the running HTTP SSE MCP server\n")
+		fmt.Fprintf(stderr, "\ttabs list|stats|clear|close <id>|prune\tmanages the browser tabs of the running HTTP SSE MCP server\n")
+		fmt.Fprintf(stderr, "\tconfig show|set <name> <value>|init\tmanages the config file\n")
+		fmt.Fprintf(stderr, "\tauth add [--username <name>] [--login-url <url>] [--steps <file>] <site>|list|remove <site>\tmanages the sites credentials of the login tool\n")
+		fmt.Fprintf(stderr, "\tcapabilities [--json]\tprints the tools, their schemas, limits and required capabilities of the configured deployment\n")
+		fmt.Fprintf(stderr, "\tcdp forget <host>\tremoves a cached cdp websocket url of the running HTTP SSE MCP server\n")
+		fmt.Fprintf(stderr, "\tcdp drain <endpoint>\tstops giving tabs of the browser, closes them once released and removes the browser of the running HTTP SSE MCP server\n")
 		fmt.Fprintf(stderr, "\nCommand line options:\n")
 		flags.PrintDefaults()
 		fmt.Fprintf(stderr, "\nEnvironment vars:\n")
 		fmt.Fprintf(stderr, "\tMCP_API_ADDRESS\t\tdefault %s\n", ApiDefaultAddress)
 		fmt.Fprintf(stderr, "\tMCP_CDP\n")
+		fmt.Fprintf(stderr, "\tMCP_CONFIG\n")
+		fmt.Fprintf(stderr, "\tMCP_SEARCHES\n")
+		fmt.Fprintf(stderr, "\tMCP_RECIPES\n")
+		fmt.Fprintf(stderr, "\tMCP_DAEMON_SOCKET\n")
+		fmt.Fprintf(stderr, "\tMCP_LISTEN\n")
+		fmt.Fprintf(stderr, "\tMCP_TLS_CERT\n")
+		fmt.Fprintf(stderr, "\tMCP_TLS_KEY\n")
+		fmt.Fprintf(stderr, "\tMCP_TLS_CLIENT_CA\n")
+		fmt.Fprintf(stderr, "\tMCP_TOKEN\n")
+		fmt.Fprintf(stderr, "\tMCP_SESSIONS_DIR\n")
+		fmt.Fprintf(stderr, "\tMCP_OAUTH_ISSUER\n")
+		fmt.Fprintf(stderr, "\tMCP_OAUTH_RESOURCE\n")
+		fmt.Fprintf(stderr, "\tMCP_SNAPSHOTS\n")
+		fmt.Fprintf(stderr, "\tMCP_VAULT\n")
+		fmt.Fprintf(stderr, "\tMCP_VAULT_PASSPHRASE\n")
+		fmt.Fprintf(stderr, "\tMCP_BROWSER_BIN\n")
+		fmt.Fprintf(stderr, "\tMCP_FILTER_CLASSIFIER\n")
+		fmt.Fprintf(stderr, "\tMCP_CHALLENGE_SOLVER\n")
+		fmt.Fprintf(stderr, "\tMCP_TRANSLATE_ENDPOINT\n")
+		fmt.Fprintf(stderr, "\tMCP_TRANSLATE_KEY\n")
+		fmt.Fprintf(stderr, "\tMCP_AUDIT_LOG\n")
+		fmt.Fprintf(stderr, "\tOTEL_EXPORTER_OTLP_ENDPOINT\n")
+		fmt.Fprintf(stderr, "\tMCP_STANDBY_OF\n")
+		fmt.Fprintf(stderr, "\tMCP_REPLICA_TOKEN\n")
 	}
 	if err := flags.Parse(args[1:]); err != nil {
 		return err
 	}
 
+	// the config gives the values of the options missing on the command
+	// line.
+	cfg, err := LoadConfig(*config)
+	if err != nil {
+		return err
+	}
+	applied, err := cfg.Apply(flags)
+	if err != nil {
+		return err
+	}
+
+	if v := os.Getenv("MCP_CDP"); v != "" && len(cdp) == 0 {
+		cdp.Set(v) // nolint:errcheck
+	}
+	if v := os.Getenv("MCP_TOKEN"); v != "" && len(tokens) == 0 {
+		if err := tokens.Set(v); err != nil {
+			return fmt.Errorf("MCP_TOKEN: %w", err)
+		}
+	}
+	// the secrets aren't flag defaults, they would be printed by the usage
+	// and the config commands.
+	if v := os.Getenv("MCP_REPLICA_TOKEN"); v != "" && *rtoken == "" {
+		*rtoken = v
+	}
+	if v := os.Getenv("MCP_TRANSLATE_KEY"); v != "" && *trkey == "" {
+		*trkey = v
+	}
+
 	args = flags.Args()
-	if len(args) != 1 {
+	if len(args) < 1 {
 		flags.Usage()
 		return errors.New("bad arguments")
 	}
@@ -92,22 +312,244 @@ func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.
 		slog.SetLogLoggerLevel(slog.LevelDebug)
 	}
 
+	// the servers speak utf-8 JSON, only the commands output is transcoded.
+	if args[0] != "stdio" && args[0] != "sse" {
+		w := outenc.Writer(stdout)
+		defer w.Close()
+		stdout = w
+	}
+
+	// the pool policy applies when max-tabs is reached only.
+	if *maxtabs == 0 {
+		flags.Visit(func(f *flag.Flag) {
+			if f.Name == "pool-policy" || f.Name == "pool-timeout" {
+				slog.Warn("option ignored without --max-tabs, a new tab is opened when no tab is idle", slog.String("option", f.Name))
+			}
+		})
+	}
+
+	// --listen overrides --api-addr.
+	addr := *apiaddr
+	if *listenf != "" {
+		addr = *listenf
+	}
+
+	// the admin commands speak TLS to a server started with the same
+	// certificate or with --tls-ca.
+	api := apiEndpoint{addr: addr}
+	if *tlscert != "" || *tlsrca != "" || *tlsccrt != "" {
+		topts := TLSClientOptions{CA: *tlsrca, Cert: *tlsccrt, Key: *tlsckey}
+		if api.tls, err = topts.Config(); err != nil {
+			return err
+		}
+	}
+
 	// commands w/o browser.
 	switch args[0] {
 	case "cleanup":
 		return cleanup(ctx)
 	case "download":
-		return download(ctx)
+		// with an url, the file is downloaded by the browser.
+		if len(args) == 1 {
+			return download(ctx)
+		}
+	case "sessions":
+		return runsessions(ctx, api, args[1:], stdout)
+	case "tabs":
+		return runtabs(ctx, api, args[1:], stdout)
+	case "cdp":
+		return runcdp(ctx, api, args[1:], stdout)
+	case "config":
+		return runconfig(*config, flags, applied, args[1:], stdout)
+	case "auth":
+		return runauth(*vpath, args[1:], stdin, stdout, stderr)
+	case "head":
+		bl, err := LoadBlocklist(blocklists)
+		if err != nil {
+			return err
+		}
+		// the url is screened like the navigations.
+		srv := &MCPServer{Blocklist: bl, AllowPrivateNetworks: *private}
+		return runhead(ctx, srv, args[1:], stdout, stderr)
+	}
+
+	// the running daemon has a warm browser connection.
+	if ok, err := forward(ctx, *dsock, args[0], args[1:], stdout, stderr); ok {
+		return err
+	}
+
+	if len(args) != 1 && args[0] != "search" && args[0] != "research" && args[0] != "canary" && args[0] != "fetch" && args[0] != "watch" && args[0] != "archive" && args[0] != "download" && args[0] != "run" && args[0] != "feed" && args[0] != "capabilities" {
+		flags.Usage()
+		return errors.New("bad arguments")
+	}
+
+	searches, err := LoadSavedSearches(*sfile)
+	if err != nil {
+		return err
+	}
+	recipes, err := LoadRecipes(*rdir)
+	if err != nil {
+		return err
+	}
+	profiles, err := LoadProfiles(*config)
+	if err != nil {
+		return err
+	}
+
+	screen := Emulation{
+		Device:      *devname,
+		Viewport:    viewport,
+		Mobile:      *mobile,
+		DPR:         *dpr,
+		Lang:        *lang,
+		Timezone:    *tz,
+		Geolocation: geo,
+		ColorScheme: scheme,
+		// the screenshots are captured without animations.
+		ReducedMotion: *rmotion,
+		Stealth:       *stealth,
+		Noise:         noise,
+	}
+	if err := screen.Validate(); err != nil {
+		return err
+	}
+
+	endpoints := browser.NewEndpoints()
+	endpoints.TTL = *cdpttl
+
+	filter, err := NewContentFilter(*fdoms, *fwords, *fclass, faction)
+	if err != nil {
+		return err
+	}
+
+	blocklist, err := LoadBlocklist(blocklists)
+	if err != nil {
+		return err
+	}
+
+	var translator *Translator
+	if *trto != "" {
+		if *trurl == "" {
+			return errors.New("--translate-to requires --translate-endpoint")
+		}
+		translator = &Translator{Endpoint: *trurl, Target: *trto, APIKey: *trkey}
+	}
+
+	mcpsrv := NewMCPServer("lightpanda go mcp", "1.0.0", endpoints)
+	mcpsrv.HistorySize = *history
+	mcpsrv.HistoryMaxBytes = int(hquota)
+	mcpsrv.SkipNoIndex = *noindex
+	mcpsrv.FailOnErrorStatus = *failst
+	mcpsrv.Sanitize = *clean
+	mcpsrv.Emulation = screen
+	mcpsrv.ContentFormats = cformats
+	mcpsrv.Clicks = clicks
+	if !*noboil {
+		mcpsrv.Boilerplate = append(slices.Clone(fetch.Boilerplate), boilerplate...)
+	}
+	mcpsrv.ClickWait = *cwait
+	mcpsrv.DismissConsent = *consent
+	mcpsrv.SiteExtractors = *sites
+	mcpsrv.Screenshots = shots
+	mcpsrv.Mode = mode
+	mcpsrv.MaxResponseText = int(maxtext)
+	mcpsrv.MaxContent = int(maxcontent)
+	if n := *maxtokens * tokenBytes; n > 0 && (mcpsrv.MaxContent == 0 || n < mcpsrv.MaxContent) {
+		mcpsrv.MaxContent = n
+	}
+	mcpsrv.Truncation = truncation
+	mcpsrv.ChunkSize = int(chunksize)
+	mcpsrv.Filter = filter
+	mcpsrv.DetectChallenges = *detectc
+	if *solver != "" {
+		mcpsrv.ChallengeSolver = &ChallengeSolver{URL: *solver}
+	}
+	mcpsrv.Injections = injections
+	mcpsrv.Blocklist = blocklist
+	mcpsrv.AllowPrivateNetworks = *private
+	mcpsrv.Translator = translator
+	mcpsrv.Snapshots = Snapshots{Dir: *snapdir}
+	if pass := os.Getenv("MCP_VAULT_PASSPHRASE"); pass != "" {
+		mcpsrv.Vault = &Vault{Path: *vpath, Passphrase: pass}
+	}
+	mcpsrv.DebugArtifacts = *dbgdir
+	mcpsrv.DownloadDir = *dldir
+	mcpsrv.UploadDir = *updir
+	mcpsrv.ReplicaToken = *rtoken
+	mcpsrv.ReplicaTLS = api.tls
+	mcpsrv.IsolateSessions = *isolate
+	mcpsrv.Tokens = tokens
+	if *oissuer != "" || *oresrc != "" {
+		if *oissuer == "" || *oresrc == "" {
+			return errors.New("--oauth-issuer and --oauth-resource go together")
+		}
+		if mcpsrv.OAuth, err = NewOAuth(*oissuer, *oresrc); err != nil {
+			return err
+		}
+	}
+	mcpsrv.MaxPinnedTabs = *pinned
+	mcpsrv.MaxConcurrentCalls = *calls
+	mcpsrv.MaxQueuedCalls = *qcalls
+	mcpsrv.Retries = *retries
+	mcpsrv.SearchCache = NewSearchCache(*scache)
+	mcpsrv.RetryBackoff = *backoff
+	mcpsrv.Limiter.Delay = *delay
+	mcpsrv.Limiter.MaxPerHost = *perhost
+	mcpsrv.Profiles = profiles
+	mcpsrv.Limiter.HostDelay = profiles.HostDelay
+	mcpsrv.Limits = limits
+	mcpsrv.Searches = searches
+	mcpsrv.Recipes = recipes
+	mcpsrv.tabs.Balance = balance
+
+	// commands w/o browser using the server options.
+	switch args[0] {
+	case "capabilities":
+		return runcapabilities(mcpsrv, args[1:], stdout, stderr)
+	case "feed":
+		return runfeed(ctx, mcpsrv, args[1:], stdout, stderr)
+	}
+
+	if *otel != "" {
+		mcpsrv.Tracer = tracing.New(*otel, "gomcp")
+		// the last spans are exported even after an interrupt.
+		defer mcpsrv.Tracer.Close(context.WithoutCancel(ctx))
+	}
+
+	if *audit != "" {
+		a, err := OpenAuditLog(*audit)
+		if err != nil {
+			return err
+		}
+		defer a.Close()
+		mcpsrv.Audit = a
 	}
 
 	// commands with browser.
-	cdpws := "ws://127.0.0.1:9222"
-	if *cdp == "" {
+	// the fetch command salvages the page on interrupt and the sse server
+	// drains the running tool calls, the browser outlives the signal until
+	// the command returns.
+	bctx := ctx
+	if args[0] == "fetch" || args[0] == "sse" {
+		bctx = context.WithoutCancel(ctx)
+	}
+
+	cdpws := []string(cdp)
+
+	// the local browser is started if no cdp is given, or with
+	// --launch-browser if none of them is reachable.
+	launch := len(cdpws) == 0
+	if !launch && *launchb && !endpoints.Reachable(ctx, cdpws) {
+		slog.Info("no cdp endpoint reachable, launch a local browser")
+		launch = true
+	}
+
+	if launch {
 		// Start the local browser.
-		ctx, cancel := context.WithCancel(ctx)
+		ctx, cancel := context.WithCancel(bctx)
 		defer cancel()
 
-		browser, err := newbrowser(ctx)
+		wait, err := startbrowser(ctx, *binpath)
 		if err != nil {
 			if errors.Is(err, ErrNoBrowser) {
 				return errors.New("browser not found. Please run gocmp download first.")
@@ -116,40 +558,81 @@ func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.
 		}
 
 		// Ensure we wait until the browser stops.
-		done := make(chan struct{})
-		defer func() {
-			// wait until the browser stops.
-			<-done
-		}()
-
-		// Start the browser process.
-		go func() {
-			if err := browser.Run(); err != nil {
-				slog.Error("run browser", slog.Any("err", err))
-			}
-			// The browser is ended, notify to stop waiting.
-			close(done)
-		}()
+		defer wait()
 
 		// Ensure the context is cancelled before waiting the browser end.
 		// It will stops the process.
 		defer cancel()
-	} else {
-		cdpws = *cdp
+
+		ws, err := endpoints.Wait(ctx, "127.0.0.1:"+localCDPPort, browser.StartTimeout)
+		if err != nil {
+			return fmt.Errorf("browser start: %w", err)
+		}
+		cdpws = []string{ws}
 	}
 
-	cdpctx, cancel := chromedp.NewRemoteAllocator(ctx,
-		cdpws, chromedp.NoModifyURL,
-	)
-	defer cancel()
+	for _, ws := range cdpws {
+		ts := mcpsrv.AddBrowser(bctx, ws)
+		ts.MaxTabs = *maxtabs
+		ts.Policy = policy
+		ts.WaitTimeout = *ptime
+		ts.ResetState = !*reuse
+		ts.Headers = headers
+		ts.BlockPrivate = !*private
+	}
+	defer mcpsrv.Close()
 
-	mcpsrv := NewMCPServer("lightpanda go mcp", "1.0.0", cdpctx)
+	// drop the dead tabs in background.
+	go mcpsrv.tabs.Sweep(ctx, tabsSweepInterval)
+
+	switch args[0] {
+	case "search":
+		return runsearch(ctx, mcpsrv, args[1:], stdin, stdout, stderr)
+	case "research":
+		return runresearch(ctx, mcpsrv, args[1:], stdout, stderr)
+	case "canary":
+		return runcanary(ctx, mcpsrv, args[1:], stdout, stderr)
+	case "fetch":
+		return runfetch(ctx, mcpsrv, args[1:], stdout, stderr)
+	case "watch":
+		return runwatch(ctx, mcpsrv, args[1:], stdout, stderr)
+	case "archive":
+		return runarchive(ctx, mcpsrv, args[1:], stdout, stderr)
+	case "download":
+		return rundownload(ctx, mcpsrv, args[1:], stdout, stderr)
+	case "run":
+		return runrecipe(ctx, mcpsrv, args[1:], stdout, stderr)
+	}
+
+	// run the scheduled searches in background.
+	searches.Schedule(ctx, mcpsrv)
+
+	var sessions *Sessions
+	if args[0] == "sse" {
+		sessions = NewSessions()
+		sessions.IdleTTL = *idlettl
+		sessions.TTL = *ttl
+		sessions.QueueSize = *queue
+		if *sessdir != "" {
+			sessions.Store = &SessionStore{Dir: *sessdir}
+			if err := sessions.Restore(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if *standby != "" {
+		go mcpsrv.Replicate(ctx, sessions, *standby, *rtoken, *rtime)
+	}
 
 	switch args[0] {
 	case "stdio":
 		return runstd(ctx, stdin, stdout, mcpsrv)
+	case "daemon":
+		return rundaemon(ctx, mcpsrv, *dsock)
 	case "sse":
-		return runapi(ctx, *apiaddr, mcpsrv)
+		tlsopts := TLSOptions{Cert: *tlscert, Key: *tlskey, ClientCA: *tlsca}
+		return runapi(ctx, addr, lmode, tlsopts, sessions, mcpsrv, *drain)
 	}
 
 	flags.Usage()
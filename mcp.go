@@ -16,213 +16,1637 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"net/url"
+	"net/http"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
-	md "github.com/JohannesKaufmann/html-to-markdown"
-	"github.com/chromedp/cdproto/cdp"
 	"github.com/chromedp/chromedp"
 
 	"github.com/lightpanda-io/gomcp/mcp"
+	"github.com/lightpanda-io/gomcp/pkg/browser"
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+	"github.com/lightpanda-io/gomcp/pkg/search"
+	"github.com/lightpanda-io/gomcp/pkg/tracing"
 	"github.com/lightpanda-io/gomcp/rpc"
 )
 
 // A connection with a client
 type MCPConn struct {
-	srv       *MCPServer
-	cdpctx    context.Context
-	cdpcancel context.CancelFunc
+	srv    *MCPServer
+	tab    *tab
+	cdpctx context.Context
+	// pages of the connection in the order of opening, tab is the active
+	// one.
+	pages []*tab
+
+	history *History
+	// runs the tool calls, MaxConcurrentCalls at the same time.
+	calls *callPool
+	// screen emulated with the options given by the tools, nil uses the
+	// server's one.
+	emulated *Emulation
+	// guards client, hints and shape, set on initialize while the calls
+	// may run.
+	mu sync.Mutex
+	// client and hints given on initialize.
+	client mcp.Info
+	hints  mcp.ClientHints
+	// id of the HTTP SSE session, empty for the stdio server.
+	session string
+	// token authenticating the HTTP SSE client, nil without tokens.
+	token *APIToken
+	// shape of the tool results, adjusted to the client.
+	shape ResponseShape
+	// variables referenced by the tool arguments, scoped to the connection.
+	env SessionEnv
+	// last content returned in chunks.
+	paged pagedContent
+	// store of the tabs of an isolated session, nil uses the server's
+	// tabs.
+	isolated *targetStore
+	// serializes the tool calls using the tabs of the connection, held by
+	// one call at a time.
+	pagelock chan struct{}
+}
+
+// independentTools don't use the tabs nor the pages of the connection, they
+// run in parallel with the other calls.
+var independentTools = []string{
+	"search_batch", "research", "hostinfo", "head", "get_feed", "diff_page",
+	"get_history_page", "set_env", "over",
+}
+
+// lockPages waits for the tabs of the connection to be available for the
+// tool name, the returned func releases them.
+func (c *MCPConn) lockPages(ctx context.Context, name string) (func(), error) {
+	if slices.Contains(independentTools, name) {
+		return func() {}, nil
+	}
+
+	select {
+	case c.pagelock <- struct{}{}:
+		return func() { <-c.pagelock }, nil
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+// setClient sets the client and the hints given on initialize, and the
+// response shape adjusted to them.
+func (c *MCPConn) setClient(client mcp.Info, hints mcp.ClientHints) {
+	shape := c.srv.shape(hints)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.client, c.hints, c.shape = client, hints, shape
+}
+
+// clientInfo returns the client and the hints given on initialize.
+func (c *MCPConn) clientInfo() (mcp.Info, mcp.ClientHints) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.client, c.hints
+}
+
+// responseShape returns the shape of the tool results.
+func (c *MCPConn) responseShape() ResponseShape {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.shape
+}
+
+// Close cancels the connection's tool calls and gives its tabs back to the
+// server's tabs store. The tabs and the browser context of an isolated
+// connection are closed.
+func (c *MCPConn) Close() {
+	c.calls.Close()
+	c.checkin()
+	if c.isolated != nil {
+		c.isolated.Close()
+		c.isolated = nil
+	}
+}
+
+// Isolate opens the next tabs of the connection in a browser context of
+// their own, closed with the connection.
+func (c *MCPConn) Isolate(ctx context.Context) error {
+	ts, err := c.srv.tabs.Isolated(ctx)
+	if err != nil {
+		return err
+	}
+	c.isolated = ts
+
+	return nil
+}
+
+// checkin gives the connection's tabs back to their store.
+func (c *MCPConn) checkin() {
+	if c.tab != nil && !slices.Contains(c.pages, c.tab) {
+		c.srv.tabs.Checkin(c.tab)
+	}
+	for _, t := range c.pages {
+		c.srv.tabs.Checkin(t)
+	}
+	c.pages = nil
+	c.tab = nil
+	c.cdpctx = nil
+}
+
+// use makes the tab t the active page of the connection.
+func (c *MCPConn) use(t *tab) {
+	c.tab = t
+	c.cdpctx = t.ctx
+	if !slices.Contains(c.pages, t) {
+		c.pages = append(c.pages, t)
+	}
+}
+
+// tabctx returns a context of the current tab cancelled with ctx.
+func (c *MCPConn) tabctx(ctx context.Context) (context.Context, context.CancelFunc) {
+	tctx, cancel := context.WithCancel(c.cdpctx)
+	stop := context.AfterFunc(ctx, cancel)
+
+	return tctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// run executes the actions on the current tab until ctx is done.
+func (c *MCPConn) run(ctx context.Context, actions ...chromedp.Action) error {
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	return chromedp.Run(tctx, actions...)
+}
+
+func (c *MCPConn) connect(ctx context.Context) error {
+	// a pinned tab is kept until the connection closes.
+	if c.tab != nil && c.srv.tabs.IsPinned(c.tab) {
+		return nil
+	}
+
+	c.checkin()
+
+	if c.isolated != nil {
+		ctx = withIsolation(ctx, c.isolated)
+	}
+	_, span := c.srv.Tracer.Start(ctx, "checkout")
+	t, err := c.srv.checkout(ctx)
+	span.End(err)
+	if err != nil {
+		return err
+	}
+
+	c.use(t)
+
+	return nil
+}
+
+// Pin the current tab under name to keep it alive across sessions. If a tab is
+// already pinned with this name, the connection uses it.
+func (c *MCPConn) PinTab(name string) (string, error) {
+	if c.isolated != nil {
+		return "", ErrPinIsolated
+	}
+
+	t, err := c.srv.tabs.CheckoutPinned(name, c.tab)
+	if err == nil {
+		if t != c.tab {
+			c.checkin()
+			c.use(t)
+		}
+		return fmt.Sprintf("The connection uses the tab pinned as '%s'.", name), nil
+	}
+	if !errors.Is(err, ErrNoPinnedTab) {
+		return "", fmt.Errorf("pin tab %s: %w", name, err)
+	}
+
+	// the tab may have been pinned by the primary daemon.
+	t, err = c.srv.adopt(name)
+	if err == nil {
+		c.checkin()
+		c.use(t)
+		return fmt.Sprintf("The connection uses the tab pinned as '%s' by the primary server.", name), nil
+	}
+	if !errors.Is(err, ErrNoPinnedTab) {
+		slog.Error("adopt pinned tab", slog.String("name", name), slog.Any("err", err))
+	}
+
+	if c.tab == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+
+	if err := c.srv.tabs.Pin(name, c.tab, c.srv.MaxPinnedTabs); err != nil {
+		return "", fmt.Errorf("pin tab %s: %w", name, err)
+	}
+
+	return fmt.Sprintf("The current tab is pinned as '%s', it will be kept across sessions until unpinned.", name), nil
+}
+
+// Unpin the tab pinned under name.
+func (c *MCPConn) UnpinTab(name string) (string, error) {
+	if err := c.srv.tabs.Unpin(name); err != nil {
+		return "", fmt.Errorf("unpin tab %s: %w", name, err)
+	}
+
+	return fmt.Sprintf("The tab '%s' is unpinned.", name), nil
+}
+
+// Navigate to a specified URL
+func (c *MCPConn) Goto(ctx context.Context, url string) (string, error) {
+	if err := c.srv.checkURL(ctx, url); err != nil {
+		return "", err
+	}
+
+	if err := c.connect(ctx); err != nil {
+		return "", fmt.Errorf("browser connect: %w", err)
+	}
+	if err := emulate(c.cdpctx, c.tab, c.emulation()); err != nil {
+		return "", err
+	}
+
+	release, err := c.srv.Limiter.Wait(ctx, url)
+	if err != nil {
+		return "", err
+	}
+	err = c.navigate(ctx, url)
+	release()
+	if err != nil {
+		c.srv.diagnose(c.cdpctx, &c.env, "goto", err.Error())
+		return "", navigateError(url, err)
+	}
+	if err := c.checkLocation(ctx); err != nil {
+		return "", err
+	}
+	resp := c.tab.lastResponse()
+	redirects := c.tab.redirects()
+	if resp != nil && resp.Status >= 400 && c.srv.FailOnErrorStatus {
+		var location string
+		if err := c.run(ctx, chromedp.Location(&location)); err != nil {
+			return "", fmt.Errorf("page location: %w", err)
+		}
+		// the response may belong to a previous page.
+		if resp.URL == location {
+			c.srv.diagnose(c.cdpctx, &c.env, "goto", fmt.Sprintf("http status %d", resp.Status))
+			return "", &HTTPStatusError{URL: resp.URL, Status: resp.Status, Redirects: redirects}
+		}
+	}
+
+	if err := c.expand(ctx); err != nil {
+		return "", err
+	}
+
+	res := fmt.Sprintf("The browser correctly navigated to '%s', the page is loaded in the context of the browser and can be used.", url)
+	attachURL(ctx, url)
+	c.screenshot(ctx)
+	if resp != nil {
+		attachURL(ctx, resp.URL)
+		res += fmt.Sprintf("\nHTTP status: %d", resp.Status)
+		if s := fetch.FormatRedirects(redirects, resp.URL, resp.Status); s != "" {
+			res += "\n" + s
+		}
+	}
+
+	robots, err := c.robots(ctx)
+	if err != nil {
+		slog.Debug("page robots", slog.String("url", c.env.Redact(url)), slog.Any("err", err))
+	}
+	if s := robots.String(); s != "" {
+		res += "\n" + s
+	}
+
+	licenses, err := c.licenses(ctx)
+	if err != nil {
+		slog.Debug("page licenses", slog.String("url", c.env.Redact(url)), slog.Any("err", err))
+	}
+	if s := fetch.FormatLicenses(licenses); s != "" {
+		res += "\n" + s
+	}
+
+	if c.srv.SkipNoIndex && robots.NoStore() {
+		slog.Debug("page not kept, noindex or noarchive", slog.String("url", c.env.Redact(url)))
+		return res, nil
+	}
+
+	// keep a snapshot of the loaded page into the history.
+	if err := c.snapshot(ctx, licenses); err != nil {
+		slog.Error("page snapshot", slog.String("url", c.env.Redact(url)), slog.Any("err", err))
+	}
+
+	return res, nil
+}
+
+const interactTimeout = 10 * time.Second
+
+// Click on the element matching the selector.
+func (c *MCPConn) Click(ctx context.Context, sel string) (string, error) {
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, interactTimeout)
+	defer cancel()
+
+	expr, by := fetch.Query(sel)
+	err := c.run(ctx, chromedp.Click(expr, by, chromedp.NodeVisible), chromedp.Sleep(interactSettle))
+	if err != nil {
+		return "", fmt.Errorf("click %s: %w", sel, err)
+	}
+
+	c.screenshot(ctx)
+
+	return fmt.Sprintf("The element '%s' has been clicked.", sel), nil
+}
+
+// Type the text into the element matching the selector.
+func (c *MCPConn) Type(ctx context.Context, sel, text string) (string, error) {
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, interactTimeout)
+	defer cancel()
+
+	expr, by := fetch.Query(sel)
+	err := c.run(ctx, chromedp.SendKeys(expr, text, by, chromedp.NodeVisible), chromedp.Sleep(interactSettle))
+	if err != nil {
+		return "", fmt.Errorf("type %s: %w", sel, err)
+	}
+
+	c.screenshot(ctx)
+
+	return fmt.Sprintf("The text has been typed into '%s'.", sel), nil
+}
+
+// Save the current page into the history. The snapshot keeps the generic
+// conversion of the page, the output stages run when it is served.
+func (c *MCPConn) snapshot(ctx context.Context, licenses []fetch.License) error {
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	var location, title string
+	err := chromedp.Run(tctx, chromedp.Location(&location), chromedp.Title(&title))
+	if err != nil {
+		return fmt.Errorf("page info: %w", err)
+	}
+
+	html, err := fetch.HTML(tctx, "html")
+	if err == nil {
+		html, err = fetch.RemoveBoilerplate(html, c.srv.Boilerplate)
+	}
+	if err != nil {
+		return err
+	}
+	content, err := fetch.Convert(html)
+	if err != nil {
+		return err
+	}
+
+	var lang string
+	if meta, err := fetch.Meta(tctx); err == nil {
+		lang = meta.Language
+	}
+
+	var hidden []fetch.HiddenText
+	if c.srv.Injections != "" && c.srv.Injections != InjectionOff {
+		if hidden, err = fetch.HiddenTexts(tctx); err != nil {
+			slog.Debug("hidden texts", slog.Any("err", err))
+		}
+	}
+
+	c.history.Push(PageSnapshot{
+		URL:       location,
+		Title:     title,
+		Markdown:  content,
+		Language:  lang,
+		Hidden:    hidden,
+		Licenses:  licenses,
+		VisitedAt: time.Now(),
+	})
+
+	return nil
+}
+
+// robots returns the indexing directives of the current page.
+func (c *MCPConn) robots(ctx context.Context) (fetch.Robots, error) {
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	return fetch.ReadRobots(tctx, c.tab.lastResponse())
+}
+
+// licenses returns the license signals of the current page.
+func (c *MCPConn) licenses(ctx context.Context) ([]fetch.License, error) {
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	return fetch.Licenses(tctx)
+}
+
+// Return a previously visited page from the history, with the output stages
+// of the server applied on its content.
+func (c *MCPConn) GetHistoryPage(ctx context.Context, i int) (string, error) {
+	p, ok := c.history.Get(i)
+	if !ok {
+		return "", fmt.Errorf("no page at index %d, the history contains %d pages", i, c.history.Len())
+	}
+
+	var err error
+	if c.srv.Filter != nil {
+		if p.Markdown, err = c.srv.Filter.Check(ctx, p.URL, p.Markdown); err != nil {
+			return "", err
+		}
+	}
+
+	if c.srv.Injections != "" && c.srv.Injections != InjectionOff {
+		if found := fetch.FindInjections(p.Markdown, p.Hidden); len(found) > 0 {
+			p.Markdown = c.srv.flagInjections(p.URL, p.Markdown, found, false)
+		}
+	}
+
+	if c.srv.Translator != nil {
+		lang := p.Language
+		if lang == "" {
+			lang = fetch.DetectLanguage(p.Markdown)
+		}
+		if p.Markdown, err = c.srv.Translator.Translate(ctx, p.Markdown, lang); err != nil {
+			return "", err
+		}
+	}
+
+	p.Markdown = fetch.Truncate(p.Markdown, c.srv.MaxContent, c.srv.Truncation)
+
+	return p.String(), nil
+}
+
+// Return the document's content in Markdown format.
+func (c *MCPConn) GetMarkdown(ctx context.Context) (string, error) {
+	return c.markdown(ctx, false)
+}
+
+// markdown returns the content of the page, raw keeps the suspected prompt
+// injections.
+func (c *MCPConn) markdown(ctx context.Context, raw bool) (string, error) {
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+	// the page may have navigated since goto, e.g. with a redirection.
+	if err := c.checkLocation(ctx); err != nil {
+		return "", err
+	}
+
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	var (
+		site *fetch.SiteDoc
+		err  error
+	)
+	if c.srv.SiteExtractors {
+		if site, err = fetch.Site(tctx); err != nil {
+			slog.Debug("page site", slog.Any("err", err))
+		}
+	}
+
+	content := ""
+	if site != nil {
+		content = site.Markdown()
+	} else {
+		_, span := c.srv.Tracer.Start(ctx, "extract")
+		html, err := fetch.HTML(tctx, "html")
+		if err == nil {
+			html, err = fetch.RemoveBoilerplate(html, c.srv.Boilerplate)
+		}
+		span.End(err)
+		if err != nil {
+			return "", err
+		}
+
+		_, span = c.srv.Tracer.Start(ctx, "convert")
+		content, err = fetch.Convert(html)
+		span.End(err)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	content, err = c.screen(ctx, content)
+	if err != nil {
+		return "", err
+	}
+
+	content = c.guard(ctx, content, raw)
+
+	if c.srv.Translator != nil {
+		// the language declared by the page is more reliable.
+		var lang string
+		if meta, err := fetch.Meta(tctx); err == nil {
+			lang = meta.Language
+		}
+		if lang == "" {
+			lang = fetch.DetectLanguage(content)
+		}
+		if content, err = c.srv.Translator.Translate(ctx, content, lang); err != nil {
+			return "", err
+		}
+	}
+
+	return fetch.Truncate(content, c.srv.MaxContent, c.srv.Truncation), nil
+}
+
+// GetDOM returns the simplified DOM of the element matching sel, the whole
+// page by default, with the interactive elements numbered.
+func (c *MCPConn) GetDOM(ctx context.Context, sel string) (string, error) {
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+	if err := c.checkLocation(ctx); err != nil {
+		return "", err
+	}
+	if sel == "" {
+		sel = "html"
+	}
+
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	content, err := fetch.DOM(tctx, sel, c.srv.Boilerplate)
+	if err != nil {
+		return "", err
+	}
+
+	content, err = c.screen(ctx, content)
+	if err != nil {
+		return "", err
+	}
+
+	return "The interactive elements are numbered [id], give the id as element to click, type and set_file_input.\n" + c.guard(ctx, content, false), nil
+}
+
+// Return all links from a page
+func (c *MCPConn) GetLinks(ctx context.Context) ([]string, error) {
+	if c.cdpctx == nil {
+		return nil, errors.New("no browser connection, try to use goto first")
+	}
+	if err := c.checkLocation(ctx); err != nil {
+		return nil, err
+	}
+
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	// the links of the shadow roots are included.
+	links, err := fetch.Attributes(tctx, fetch.ShadowPrefix+"a[href]", "href")
+	if err != nil {
+		return nil, fmt.Errorf("get links: %w", err)
+	}
+
+	return links, nil
+}
+
+// ExtractStructured extracts the fields from the current page and returns
+// them in JSON.
+func (c *MCPConn) ExtractStructured(ctx context.Context, fields fetch.Fields) (string, error) {
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+	if err := c.checkLocation(ctx); err != nil {
+		return "", err
+	}
+
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	data, err := fetch.Structured(tctx, fields)
+	if err != nil {
+		return "", err
+	}
+	attachData(ctx, data)
+
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("json encode: %w", err)
+	}
+
+	return c.screen(ctx, string(b))
+}
+
+type MCPServer struct {
+	Name    string
+	Version string
+
+	// Number of page snapshots kept per connection.
+	HistorySize int
+	// Quota in bytes of the page snapshots per connection, 0 means no quota.
+	HistoryMaxBytes int
+	// Don't keep the snapshots of the noindex or noarchive pages.
+	SkipNoIndex bool
+	// Screen emulated before the navigations.
+	Emulation Emulation
+	// Selectors of the elements clicked after the navigations, before the
+	// extractions, and the wait after the clicks.
+	Clicks    []string
+	ClickWait time.Duration
+	// Close the consent dialogs of the known platforms before the clicks.
+	DismissConsent bool
+	// Extract the pages of the known sites with fetch.SiteExtractors.
+	SiteExtractors bool
+	// Load the pages of the pipeline in the browser or with plain HTTP.
+	Mode FetchMode
+	// CSS selectors of the boilerplate elements removed before the
+	// extractions, nil keeps them.
+	Boilerplate []string
+	// Rules choosing the formatter of the tool results per client.
+	ContentFormats ContentFormats
+	// Include a screenshot in the navigation results.
+	Screenshots ScreenshotPolicy
+	// Maximum size of the results text, 0 adjusts it to the client's
+	// context window.
+	MaxResponseText int
+	// Maximum size of the pages content in bytes, 0 means no limit.
+	MaxContent int
+	// Truncation of the pages content exceeding MaxContent.
+	Truncation fetch.Truncation
+	// Size of the chunks of the pages content returned by the tools, 0
+	// disables the pagination.
+	ChunkSize int
+	// Fail the navigations answered with a 4xx or 5xx status instead of
+	// loading the error page.
+	FailOnErrorStatus bool
+	// Remove the invisible characters of the tool results, they can hide
+	// prompt injections from the users.
+	Sanitize bool
+	// Handling of the suspected prompt injections of the pages contents.
+	Injections InjectionPolicy
+	// Filter screens the pages contents, nil disables it.
+	Filter *ContentFilter
+	// Blocklist screens the urls before the navigations, nil disables it.
+	Blocklist *Blocklist
+	// Allow the navigations to the private, loopback, link-local and cloud
+	// metadata addresses.
+	AllowPrivateNetworks bool
+	// Translator translates the pages content, nil disables it.
+	Translator *Translator
+	// Last snapshots of the watched pages.
+	Snapshots Snapshots
+	// Vault gives the credentials of the login tool, nil disables it.
+	Vault *Vault
+	// Tracer exports the spans of the tool calls, nil disables it.
+	Tracer *tracing.Tracer
+	// Audit records the tool calls and the navigations, nil disables it.
+	Audit *AuditLog
+	// Directory receiving a screenshot and the DOM of the pages when a
+	// navigation or a search fails, empty disables it.
+	DebugArtifacts string
+	// Directory of the files saved by the download tool.
+	DownloadDir string
+	// Directory of the files the agents can upload in the pages, empty
+	// disables the uploads.
+	UploadDir string
+	// Maximum number of tabs pinned at the same time.
+	MaxPinnedTabs int
+	// Maximum number of tool calls running at the same time per connection.
+	MaxConcurrentCalls int
+	// Maximum number of tool calls waiting for a running one to end per
+	// connection, the next ones are refused.
+	MaxQueuedCalls int
+	// Number of retries of a navigation failing with a transient error, e.g.
+	// a browser disconnection, a connection reset or an empty page.
+	Retries int
+	// Delay before the first retry, doubled on each retry.
+	RetryBackoff time.Duration
+	// Paces the navigations per host.
+	Limiter *HostLimiter
+	// Execution time and response size limits per tool.
+	Limits ToolLimits
+	// Saved searches, some of them are exposed as tools.
+	Searches SavedSearches
+	// Recipes, some of them are exposed as tools.
+	Recipes Recipes
+	// Per-domain presets applied to the fetched pages.
+	Profiles Profiles
+	// Results of the recent searches, nil disables the cache.
+	SearchCache *SearchCache
+	// Fail the navigations ending on a bot challenge or a CAPTCHA with a
+	// BotChallengeError.
+	DetectChallenges bool
+	// Solver of the bot challenges, nil returns the BotChallengeError at
+	// once.
+	ChallengeSolver *ChallengeSolver
+	// Token required from the standby daemons to replicate the state. Without
+	// token, only the local host can replicate it.
+	ReplicaToken string
+	// TLS configuration of the client replicating the state of an https
+	// primary, nil uses the system CAs.
+	ReplicaTLS *tls.Config
+	// Open the tabs of each HTTP SSE session in a browser context of its
+	// own.
+	IsolateSessions bool
+	// Tokens of the HTTP SSE clients, the tools are restricted to their
+	// scopes. Without tokens, the clients aren't authenticated.
+	Tokens APITokens
+	// Authorization of the HTTP SSE clients with the access tokens of an
+	// external authorization server, nil disables it.
+	OAuth *OAuth
+
+	tabs      *tabPools
+	endpoints *browser.Endpoints
+	replica   replica
+	// durations and failures of the pipeline stages.
+	metrics PipelineMetrics
+	// tool calls, navigations and searches exposed to Prometheus.
+	telemetry Metrics
+	// running tool calls, drained on shutdown.
+	calls inflight
+	// client of the pages fetched without browser.
+	http     *http.Client
+	httpOnce sync.Once
+}
+
+func NewMCPServer(name, version string, endpoints *browser.Endpoints) *MCPServer {
+	return &MCPServer{
+		Name:               name,
+		Version:            version,
+		HistorySize:        HistoryDefaultSize,
+		MaxPinnedTabs:      MaxPinnedTabsDefault,
+		MaxConcurrentCalls: MaxConcurrentCallsDefault,
+		MaxQueuedCalls:     MaxQueuedCallsDefault,
+		DetectChallenges:   true,
+		Retries:            RetriesDefault,
+		ChunkSize:          ChunkDefaultSize,
+		RetryBackoff:       RetryDefaultBackoff,
+		Limiter:            NewHostLimiter(),
+		Limits:             ToolLimits{},
+		Searches:           SavedSearches{},
+		Mode:               FetchModeBrowser,
+		tabs:               newTabPools(),
+		endpoints:          endpoints,
+	}
+}
+
+// AddBrowser adds a browser reachable with the CDP url ws to the server.
+// It returns the store of the browser's tabs.
+func (s *MCPServer) AddBrowser(ctx context.Context, ws string) *targetStore {
+	return s.tabs.Add(ctx, ws, s.endpoints)
+}
+
+// Close closes all the browser tabs opened by the server.
+func (s *MCPServer) Close() {
+	s.tabs.Close()
+}
+
+func (s *MCPServer) NewConn() *MCPConn {
+	history := NewHistory(s.HistorySize)
+	history.MaxBytes = s.HistoryMaxBytes
+
+	return &MCPConn{
+		srv:      s,
+		history:  history,
+		calls:    newCallPool(s.MaxConcurrentCalls, s.MaxQueuedCalls),
+		shape:    s.shape(mcp.ClientHints{}),
+		pagelock: make(chan struct{}, 1),
+	}
+}
+
+func (s *MCPServer) ListTools() []mcp.Tool {
+	tools := []mcp.Tool{
+		{
+			Name: "goto",
+			Description: "Navigate to a specified URL and load the page in" +
+				"memory so it can be reused later for info extraction. " +
+				"The result reports the robots directives and the license of the page, if any. " +
+				"The viewport, mobile, device, dpr, lang, timezone, geolocation, color_scheme and reduced_motion options emulate a screen, a region and media features for this navigation and the next ones.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"url":            mcp.NewSchemaString("The URL to navigate to, must be a valid URL."),
+				"viewport":       mcp.NewSchemaString("Optional size of the emulated screen, e.g. 1280x800."),
+				"mobile":         mcp.NewSchemaBoolean("Optional, load the page as a mobile device."),
+				"device":         mcp.NewSchemaString("Optional name of the emulated device, e.g. iPhone 14 or Pixel 5."),
+				"dpr":            mcp.NewSchemaNumber("Optional device pixel ratio of the emulated screen."),
+				"lang":           mcp.NewSchemaString("Optional language of the browser, e.g. fr-FR."),
+				"timezone":       mcp.NewSchemaString("Optional IANA timezone of the browser, e.g. Europe/Paris."),
+				"geolocation":    mcp.NewSchemaString("Optional position of the browser as latitude,longitude, e.g. 48.8566,2.3522."),
+				"color_scheme":   mcp.NewSchemaString("Optional color scheme preferred by the page: dark or light."),
+				"reduced_motion": mcp.NewSchemaBoolean("Optional, the page prefers reduced motion."),
+			}),
+		},
+		{
+			Name:        "search",
+			Description: "Use a search engine to look for specific words, terms, sentences. The results are returned and the search page is loaded in memory.",
+			InputSchema: mcp.NewSchemaObject(searchFiltersProperties(mcp.Properties{
+				"text": mcp.NewSchemaString("The text to search for, must be a valid search query."),
+				"type": mcp.NewSchemaString("Optional type of the results: web by default, news with their source and date, images with their size, or videos."),
+			})),
+		},
+		{
+			Name: "search_batch",
+			Description: "Run several search queries concurrently and return the results grouped by query. " +
+				"Prefer it to multiple search calls to explore a topic.",
+			InputSchema: mcp.NewSchemaObject(searchFiltersProperties(mcp.Properties{
+				"queries": mcp.NewSchemaArray("The queries to search for.", mcp.NewSchemaString("A search query.")),
+			})),
+		},
+		{
+			Name: "research",
+			Description: "Search the web and return the readable content of the first results, fetched concurrently. " +
+				"Prefer it to a search followed by a goto and a markdown per result.",
+			InputSchema: mcp.NewSchemaObject(searchFiltersProperties(mcp.Properties{
+				"text":    mcp.NewSchemaString("The text to search for, must be a valid search query."),
+				"results": mcp.NewSchemaInteger(fmt.Sprintf("Optional number of results fetched, %d by default, max %d.", ResearchDefaultResults, ResearchMaxResults)),
+			})),
+		},
+		{
+			Name: "lookup",
+			Description: "Get a compact card about an entity (person, company, place, concept...): description, key facts, official site and image. " +
+				"It uses Wikipedia and Wikidata and falls back to a web search.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"name": mcp.NewSchemaString("The name of the entity to look up."),
+				"lang": mcp.NewSchemaString("The language code of the card, en by default."),
+			}),
+		},
+		{
+			Name: "hostinfo",
+			Description: "Get the DNS records, the registration dates and registrar, and the TLS certificate of a domain. " +
+				"Useful to assess the credibility of a source.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"domain": mcp.NewSchemaString("The domain name or an URL of the host."),
+			}),
+		},
+		{
+			Name: "head",
+			Description: "Request an URL without browser and return its HTTP status, headers and validators (ETag, Last-Modified). " +
+				"Give the validators of a known version to check cheaply if the page changed before loading it with goto.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"url":               mcp.NewSchemaString("The URL to request."),
+				"get":               mcp.NewSchemaBoolean("Optional, use GET instead of HEAD, the body is discarded."),
+				"if_none_match":     mcp.NewSchemaString("Optional ETag of the known version."),
+				"if_modified_since": mcp.NewSchemaString("Optional Last-Modified date of the known version."),
+			}),
+		},
+		{
+			Name: "get_feed",
+			Description: "Get the RSS or Atom feed of a site without browser and return its latest entries with their title, link, date and summary. " +
+				"The URL is the feed or a page linking to it. Much cheaper than loading the article pages to find the latest posts.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"url":   mcp.NewSchemaString("The URL of the feed or of a page of the site."),
+				"limit": mcp.NewSchemaInteger("Optional maximum number of entries, 20 by default."),
+			}),
+		},
+		{
+			Name: "diff_page",
+			Description: "Fetch a page and return the unified diff of its markdown since the previous diff_page or watch of the same URL. " +
+				"Useful to monitor docs pages, changelogs or pricing pages.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"url": mcp.NewSchemaString("The URL of the page."),
+			}),
+		},
+		{
+			Name:        "click",
+			Description: "Click on an element of the opened page. The resulting page state is returned.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"selector": mcp.NewSchemaString("The CSS selector of the element to click, an XPath expression prefixed with xpath:, or a CSS selector prefixed with shadow: piercing the shadow roots."),
+				"element":  mcp.NewSchemaInteger("The id of the element in the dom snapshot, instead of the selector."),
+			}),
+		},
+		{
+			Name:        "type",
+			Description: "Type a text into an input element of the opened page. The resulting page state is returned.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"selector": mcp.NewSchemaString("The CSS selector of the input element, an XPath expression prefixed with xpath:, or a CSS selector prefixed with shadow: piercing the shadow roots."),
+				"element":  mcp.NewSchemaInteger("The id of the element in the dom snapshot, instead of the selector."),
+				"text":     mcp.NewSchemaString("The text to type."),
+			}),
+		},
+		{
+			Name: "press_key",
+			Description: "Press a key or a key combination in the opened page, e.g. Enter, Escape, ArrowDown, Tab or Control+a. " +
+				"The key goes to the focused element, or to the element of the selector. The resulting page state is returned.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"key":      mcp.NewSchemaString("The DOM name of the key, or a combination of modifiers (Control, Shift, Alt, Meta) and a key joined by +."),
+				"selector": mcp.NewSchemaString("Optional CSS selector of the element to focus first, an XPath expression prefixed with xpath:, or a CSS selector prefixed with shadow: piercing the shadow roots."),
+				"element":  mcp.NewSchemaInteger("Optional id of the element to focus first in the dom snapshot, instead of the selector."),
+			}),
+		},
+		{
+			Name:        "hover",
+			Description: "Move the mouse over an element of the opened page, e.g. to open a menu or a tooltip. The resulting page state is returned.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"selector": mcp.NewSchemaString("The CSS selector of the element, an XPath expression prefixed with xpath:, or a CSS selector prefixed with shadow: piercing the shadow roots."),
+				"element":  mcp.NewSchemaInteger("The id of the element in the dom snapshot, instead of the selector."),
+			}),
+		},
+		{
+			Name: "scroll_to",
+			Description: "Scroll an element of the opened page into view, or scroll the page with the mouse wheel, e.g. to load the next items of an infinite scroll. " +
+				"The resulting page state is returned.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"selector": mcp.NewSchemaString("The CSS selector of the element to scroll into view, an XPath expression prefixed with xpath:, or a CSS selector prefixed with shadow: piercing the shadow roots."),
+				"element":  mcp.NewSchemaInteger("The id of the element in the dom snapshot, instead of the selector."),
+				"delta_y":  mcp.NewSchemaInteger("Without element, the pixels to scroll down, negative to scroll up."),
+			}),
+		},
+		{
+			Name:        "drag_and_drop",
+			Description: "Drag an element of the opened page and drop it on another one, e.g. to sort a list, move a card or a slider. The resulting page state is returned.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"source":         mcp.NewSchemaString("The CSS selector of the dragged element, an XPath expression prefixed with xpath:, or a CSS selector prefixed with shadow: piercing the shadow roots."),
+				"target":         mcp.NewSchemaString("The CSS selector of the element to drop on."),
+				"source_element": mcp.NewSchemaInteger("The id of the dragged element in the dom snapshot, instead of source."),
+				"target_element": mcp.NewSchemaInteger("The id of the element to drop on in the dom snapshot, instead of target."),
+			}),
+		},
+		{
+			Name: "set_file_input",
+			Description: "Select files in a file input of the opened page, e.g. to upload a document in a form. " +
+				"The files are relative paths inside the upload directory of the server. Submit the form with click afterwards.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"selector": mcp.NewSchemaString("The CSS selector of the file input element, an XPath expression prefixed with xpath:, or a CSS selector prefixed with shadow: piercing the shadow roots."),
+				"element":  mcp.NewSchemaInteger("The id of the element in the dom snapshot, instead of the selector."),
+				"files":    mcp.NewSchemaArray("The paths of the files, relative to the upload directory.", mcp.NewSchemaString("A file path.")),
+			}),
+		},
+		{
+			Name: "download",
+			Description: "Download a file (PDF, CSV, ZIP...) served by an URL or triggered by a click in the opened page. " +
+				"It waits for the download completion and returns the saved file path, its size and SHA-256 checksum.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"url":      mcp.NewSchemaString("The URL of the file or of the page triggering the download."),
+				"selector": mcp.NewSchemaString("Without url, the CSS selector, XPath expression prefixed with xpath: or shadow piercing selector prefixed with shadow:, of the element of the opened page triggering the download."),
+			}),
+		},
+		{
+			Name:        "markdown",
+			Description: "Get the page content in markdown format.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"raw": mcp.NewSchemaBoolean("Optional, keep the passages suspected to be prompt injections in the content, they are still flagged."),
+			}),
+		},
+		{
+			Name: "dom",
+			Description: "Get a simplified DOM of the opened page: the visible texts and the interactive elements numbered with stable ids. " +
+				"Give an id as element to click, type and set_file_input instead of a selector.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"selector": mcp.NewSchemaString("Optional CSS selector of the element to describe, the whole page by default."),
+			}),
+		},
+		{
+			Name:        "links",
+			Description: "Extract all links in the opened page",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{}),
+		},
+		{
+			Name: "extract_structured",
+			Description: "Extract typed structured data from the opened page, e.g. the price, title and rating of a product. " +
+				"Give either fields, mapping each name to a CSS selector, an XPath expression prefixed with xpath:, a shadow piercing selector prefixed with shadow:, or to a field object, or a JSON schema whose properties have a selector keyword. " +
+				"A field object has a selector, an optional attr read instead of the text, a type (string, number, integer or boolean), " +
+				"all to return every match in an array, and fields to extract an object from each matched element. " +
+				"The missing values are null.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"fields": mcp.NewSchemaMap("The fields to extract by name, the values are CSS selectors or field objects.", true),
+				"schema": mcp.NewSchemaMap("A JSON schema of type object, each property gives its CSS selector with the selector keyword and optionally the attribute read with the attribute keyword.", true),
+			}),
+		},
+		{
+			Name: "browse",
+			Description: "Execute a list of actions in sequence within a time budget and return the extracted contents. " +
+				"It avoids multiple round trips for predictable multi-step extractions.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"actions": mcp.NewSchemaArray("The actions to execute in order.", mcp.NewSchemaObject(mcp.Properties{
+					"action":   mcp.NewSchemaString("The action type: navigate, wait, click, type, set_file_input or extract."),
+					"url":      mcp.NewSchemaString("The URL to navigate to, used by navigate."),
+					"selector": mcp.NewSchemaString("The CSS selector of the element to wait for, click, type into or extract, an XPath expression prefixed with xpath:, or a CSS selector prefixed with shadow: piercing the shadow roots. Extract uses the whole page by default."),
+					"text":     mcp.NewSchemaString("The text to type, used by type."),
+					"duration": mcp.NewSchemaInteger("The duration to wait in milliseconds, used by wait without selector."),
+					"files":    mcp.NewSchemaArray("The paths of the files relative to the upload directory, used by set_file_input.", mcp.NewSchemaString("A file path.")),
+					"fields":   mcp.NewSchemaMap("Optional fields extracted in JSON by extract instead of the markdown, as in extract_structured.", true),
+				})),
+				"timeout": mcp.NewSchemaInteger("The overall time budget in seconds, 30 seconds by default."),
+			}),
+		},
+		{
+			Name:        "get_history_page",
+			Description: "Get the markdown content of a previously visited page from the history, without navigating again.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"index": mcp.NewSchemaInteger("The position of the page in the history, 0 is the last visited page, 1 the one before, etc."),
+			}),
+		},
+		{
+			Name: "fetch_page_chunk",
+			Description: "Get the next chunk of a large content returned in chunks by markdown, dom or get_history_page. " +
+				"Each chunk ends with the cursor of the next one.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"cursor": mcp.NewSchemaString("The cursor given at the end of the previous chunk."),
+			}),
+		},
+		{
+			Name: "pin_tab",
+			Description: "Pin the current tab with a name to keep it alive across sessions, e.g. for long running monitoring. " +
+				"If a tab is already pinned with this name, it becomes the current tab.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"name": mcp.NewSchemaString("The name of the pinned tab."),
+			}),
+		},
+		{
+			Name:        "unpin_tab",
+			Description: "Unpin a tab previously pinned with pin_tab, the tab will be recycled.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"name": mcp.NewSchemaString("The name of the pinned tab."),
+			}),
+		},
+		{
+			Name: "list_pages",
+			Description: "List the pages of the session with their number, title and url. " +
+				"The pages opened by the interactions, e.g. popups or links opened in a new tab, are added to the session and become active.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{}),
+		},
+		{
+			Name:        "switch_page",
+			Description: "Make a page of the session the active one, the next tools use it.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"page": mcp.NewSchemaInteger("The number of the page given by list_pages."),
+			}),
+		},
+		{
+			Name: "login",
+			Description: "Log in a site with its credential stored in the server's vault, the password is never given. " +
+				"The login form of the page is filled and submitted, or the scripted login of the site is run. The resulting page state is returned.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"site": mcp.NewSchemaString("Optional host of the site, e.g. github.com. By default the site of the current page."),
+				"url":  mcp.NewSchemaString("Optional URL of the login page. By default the one stored with the credential or the current page."),
+			}),
+		},
+		{
+			Name: "set_env",
+			Description: "Set variables of the session environment, e.g. API tokens for authenticated requests. " +
+				"The arguments of the next tool calls reference them as ${env.NAME}. " +
+				"The values are kept out of the logs, are redacted from the results and are dropped with the session. An empty value removes the variable.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"vars": mcp.NewSchemaMap("The variables to set by name.", mcp.NewSchemaString("The value of the variable.")),
+			}),
+		},
+		{
+			Name:        "over",
+			Description: "Used to indicate that the task is over and give the final answer if there is any. This is the last tool to be called in a task.",
+			InputSchema: mcp.NewSchemaObject(mcp.Properties{
+				"result": mcp.NewSchemaString("The final result of the task."),
+			}),
+		},
+	}
+
+	tools = append(tools, s.Searches.Tools()...)
+	return append(tools, s.Recipes.Tools()...)
 }
 
-func (c *MCPConn) Close() {
-	if c.cdpcancel != nil {
-		c.cdpcancel()
-	}
-}
+var ErrNoTool = errors.New("no tool found")
+
+func (s *MCPServer) CallTool(ctx context.Context, conn *MCPConn, req mcp.ToolsCallRequest) (string, error) {
+	v := req.Params.Arguments
+	if req.Params.Name != "set_env" {
+		var err error
+		if v, err = conn.env.expandJSON(v); err != nil {
+			return "", err
+		}
+	}
+
+	switch req.Params.Name {
+	case "goto":
+		var args struct {
+			URL string `json:"url"`
+			emulationArgs
+		}
+
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
+
+		if args.URL == "" {
+			return "", errors.New("no url")
+		}
+
+		// the emulation options are kept for the next navigations.
+		if args.given() {
+			em, err := args.apply(conn.emulation())
+			if err != nil {
+				return "", err
+			}
+			conn.emulated = &em
+		}
+		return conn.interact(ctx, func() (string, error) {
+			return conn.Goto(ctx, args.URL)
+		})
+	case "search":
+		var args struct {
+			Text string `json:"text"`
+			Type string `json:"type"`
+			SearchFilters
+		}
+
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
+
+		if args.Text == "" {
+			return "", errors.New("no text")
+		}
+		ctx = args.context(ctx)
+		q, err := args.Query(args.Text)
+		if err != nil {
+			return "", err
+		}
+		var vertical search.Vertical
+		if err := vertical.Set(args.Type); err != nil {
+			return "", err
+		}
+		if vertical != "" && vertical != search.VerticalWeb {
+			return conn.interact(ctx, func() (string, error) {
+				return conn.SearchVertical(ctx, q, vertical)
+			})
+		}
+
+		return conn.interact(ctx, func() (string, error) {
+			res, err := conn.Search(ctx, q)
+			if err != nil {
+				return "", err
+			}
+			attachResults(ctx, res)
+			return search.Format(res), nil
+		})
+	case "search_batch":
+		var args struct {
+			Queries []string `json:"queries"`
+			SearchFilters
+		}
+
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
+
+		ctx = args.context(ctx)
+		queries := make([]search.Query, 0, len(args.Queries))
+		for _, text := range args.Queries {
+			if text == "" {
+				continue
+			}
+			q, err := args.Query(text)
+			if err != nil {
+				return "", err
+			}
+			queries = append(queries, q)
+		}
+
+		res, err := s.SearchBatch(ctx, queries)
+		if err != nil {
+			return "", err
+		}
+		for _, r := range res {
+			attachResults(ctx, r.Results)
+		}
+		return FormatSearchBatch(res), nil
+	case "research":
+		var args struct {
+			Text    string `json:"text"`
+			Results int    `json:"results"`
+			SearchFilters
+		}
+
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
+
+		if args.Text == "" {
+			return "", errors.New("no text")
+		}
+		ctx = args.context(ctx)
+		q, err := args.Query(args.Text)
+		if err != nil {
+			return "", err
+		}
+
+		res, err := s.Research(ctx, q, args.Results, nil)
+		if err != nil {
+			return "", err
+		}
+		return FormatResearch(res), nil
+	case "lookup":
+		var args struct {
+			Name string `json:"name"`
+			Lang string `json:"lang"`
+		}
+
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
+
+		if args.Name == "" {
+			return "", errors.New("no name")
+		}
+		return conn.Lookup(ctx, args.Name, args.Lang)
+	case "hostinfo":
+		var args struct {
+			Domain string `json:"domain"`
+		}
+
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
+
+		if args.Domain == "" {
+			return "", errors.New("no domain")
+		}
+		return s.HostInfo(ctx, args.Domain)
+	case "head":
+		var args struct {
+			URL             string `json:"url"`
+			Get             bool   `json:"get"`
+			IfNoneMatch     string `json:"if_none_match"`
+			IfModifiedSince string `json:"if_modified_since"`
+		}
+
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
+
+		if args.URL == "" {
+			return "", errors.New("no url")
+		}
+		res, err := s.Head(ctx, args.URL, HeadOptions{
+			Get:             args.Get,
+			IfNoneMatch:     args.IfNoneMatch,
+			IfModifiedSince: args.IfModifiedSince,
+		})
+		if err != nil {
+			return "", err
+		}
+		attachURL(ctx, res.URL)
+		return res.String(), nil
+	case "get_feed":
+		var args struct {
+			URL   string `json:"url"`
+			Limit int    `json:"limit"`
+		}
+
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
+
+		if args.URL == "" {
+			return "", errors.New("no url")
+		}
+		f, err := s.Feed(ctx, args.URL, args.Limit)
+		if err != nil {
+			return "", err
+		}
+		attachURL(ctx, f.URL)
+		return f.String(), nil
+	case "diff_page":
+		var args struct {
+			URL string `json:"url"`
+		}
+
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
 
-func (c *MCPConn) connect() error {
-	if c.cdpcancel != nil {
-		c.cdpcancel()
-	}
+		if args.URL == "" {
+			return "", errors.New("no url")
+		}
+		c, err := s.DiffPage(ctx, args.URL)
+		if err != nil {
+			return "", err
+		}
+		return FormatPageChange(c), nil
+	case "click":
+		var args struct {
+			Selector string `json:"selector"`
+			Element  int    `json:"element"`
+		}
 
-	ctx, cancel := chromedp.NewContext(c.srv.cdpctx)
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
 
-	// ensure the first tab is created
-	if err := chromedp.Run(ctx); err != nil {
-		cancel()
-		return fmt.Errorf("new tab: %w", err)
-	}
+		if args.Selector == "" && args.Element > 0 {
+			args.Selector = fetch.ElementSelector(args.Element)
+		}
+		if args.Selector == "" {
+			return "", errors.New("no selector nor element")
+		}
+		return conn.interact(ctx, func() (string, error) {
+			return conn.Click(ctx, args.Selector)
+		})
+	case "type":
+		var args struct {
+			Selector string `json:"selector"`
+			Element  int    `json:"element"`
+			Text     string `json:"text"`
+		}
 
-	c.cdpctx = ctx
-	c.cdpcancel = cancel
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
 
-	return nil
-}
+		if args.Selector == "" && args.Element > 0 {
+			args.Selector = fetch.ElementSelector(args.Element)
+		}
+		if args.Selector == "" {
+			return "", errors.New("no selector nor element")
+		}
+		return conn.interact(ctx, func() (string, error) {
+			return conn.Type(ctx, args.Selector, args.Text)
+		})
+	case "press_key":
+		var args struct {
+			Key      string `json:"key"`
+			Selector string `json:"selector"`
+			Element  int    `json:"element"`
+		}
 
-// Navigate to a specified URL
-func (c *MCPConn) Goto(url string) (string, error) {
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
 
-	if err := c.connect(); err != nil {
-		return "", fmt.Errorf("browser connect: %w", err)
-	}
+		if args.Key == "" {
+			return "", errors.New("no key")
+		}
+		if args.Selector == "" && args.Element > 0 {
+			args.Selector = fetch.ElementSelector(args.Element)
+		}
+		return conn.interact(ctx, func() (string, error) {
+			return conn.PressKey(ctx, args.Key, args.Selector)
+		})
+	case "hover":
+		var args struct {
+			Selector string `json:"selector"`
+			Element  int    `json:"element"`
+		}
 
-	err := chromedp.Run(c.cdpctx, chromedp.Navigate(url))
-	if err != nil {
-		return "", fmt.Errorf("navigate %s: %w", url, err)
-	}
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
 
-	return fmt.Sprintf("The browser correctly navigated to '%s', the page is loaded in the context of the browser and can be used.", url), nil
-}
+		if args.Selector == "" && args.Element > 0 {
+			args.Selector = fetch.ElementSelector(args.Element)
+		}
+		if args.Selector == "" {
+			return "", errors.New("no selector nor element")
+		}
+		return conn.interact(ctx, func() (string, error) {
+			return conn.Hover(ctx, args.Selector)
+		})
+	case "scroll_to":
+		var args struct {
+			Selector string `json:"selector"`
+			Element  int    `json:"element"`
+			DeltaY   int    `json:"delta_y"`
+		}
 
-// Return the document's content in Markdown format.
-func (c *MCPConn) GetMarkdown() (string, error) {
-	if c.cdpctx == nil {
-		return "", errors.New("no browser connection, try to use goto first")
-	}
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
 
-	var html string
-	err := chromedp.Run(c.cdpctx, chromedp.OuterHTML("html", &html))
-	if err != nil {
-		return "", fmt.Errorf("outerHTML: %w", err)
-	}
+		if args.Selector == "" && args.Element > 0 {
+			args.Selector = fetch.ElementSelector(args.Element)
+		}
+		return conn.interact(ctx, func() (string, error) {
+			return conn.ScrollTo(ctx, args.Selector, float64(args.DeltaY))
+		})
+	case "drag_and_drop":
+		var args struct {
+			Source        string `json:"source"`
+			Target        string `json:"target"`
+			SourceElement int    `json:"source_element"`
+			TargetElement int    `json:"target_element"`
+		}
 
-	converter := md.NewConverter("", true, nil)
-	content, err := converter.ConvertString(html)
-	if err != nil {
-		return "", fmt.Errorf("The document has been converted to markdown: %w", err)
-	}
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
 
-	return content, nil
-}
+		if args.Source == "" && args.SourceElement > 0 {
+			args.Source = fetch.ElementSelector(args.SourceElement)
+		}
+		if args.Target == "" && args.TargetElement > 0 {
+			args.Target = fetch.ElementSelector(args.TargetElement)
+		}
+		if args.Source == "" || args.Target == "" {
+			return "", errors.New("no source or target")
+		}
+		return conn.interact(ctx, func() (string, error) {
+			return conn.DragAndDrop(ctx, args.Source, args.Target)
+		})
+	case "set_file_input":
+		var args struct {
+			Selector string   `json:"selector"`
+			Element  int      `json:"element"`
+			Files    []string `json:"files"`
+		}
 
-// Return all links from a page
-func (c *MCPConn) GetLinks() ([]string, error) {
-	if c.cdpctx == nil {
-		return nil, errors.New("no browser connection, try to use goto first")
-	}
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
 
-	var a []*cdp.Node
-	if err := chromedp.Run(c.cdpctx, chromedp.Nodes(`a[href]`, &a)); err != nil {
-		return nil, fmt.Errorf("get links: %w", err)
-	}
+		if args.Selector == "" && args.Element > 0 {
+			args.Selector = fetch.ElementSelector(args.Element)
+		}
+		if args.Selector == "" {
+			return "", errors.New("no selector nor element")
+		}
+		return conn.interact(ctx, func() (string, error) {
+			return conn.SetFileInput(ctx, args.Selector, args.Files)
+		})
+	case "download":
+		var args struct {
+			URL      string `json:"url"`
+			Selector string `json:"selector"`
+		}
 
-	links := make([]string, 0, len(a))
-	for _, aa := range a {
-		v, ok := aa.Attribute("href")
-		if ok {
-			links = append(links, v)
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
 		}
-	}
 
-	return links, nil
-}
+		if args.URL == "" && args.Selector == "" {
+			return "", errors.New("no url nor selector")
+		}
+		return conn.interact(ctx, func() (string, error) {
+			return conn.Download(ctx, args.URL, args.Selector)
+		})
+	case "markdown":
+		var args struct {
+			Raw bool `json:"raw"`
+		}
 
-type MCPServer struct {
-	Name    string
-	Version string
+		if len(v) > 0 {
+			if err := json.Unmarshal(v, &args); err != nil {
+				return "", fmt.Errorf("args decode: %w", err)
+			}
+		}
 
-	cdpctx context.Context
-}
+		content, err := conn.markdown(ctx, args.Raw)
+		if err != nil {
+			return "", err
+		}
+		return conn.paginate(content), nil
+	case "dom":
+		var args struct {
+			Selector string `json:"selector"`
+		}
 
-func NewMCPServer(name, version string, cdpctx context.Context) *MCPServer {
-	return &MCPServer{
-		Name:    name,
-		Version: version,
-		cdpctx:  cdpctx,
-	}
-}
+		if len(v) > 0 {
+			if err := json.Unmarshal(v, &args); err != nil {
+				return "", fmt.Errorf("args decode: %w", err)
+			}
+		}
 
-func (s *MCPServer) NewConn() *MCPConn {
-	return &MCPConn{
-		srv: s,
-	}
-}
+		content, err := conn.GetDOM(ctx, args.Selector)
+		if err != nil {
+			return "", err
+		}
+		return conn.paginate(content), nil
+	case "links":
+		links, err := conn.GetLinks(ctx)
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(links, "\n"), nil
+	case "extract_structured":
+		var args struct {
+			Fields fetch.Fields    `json:"fields"`
+			Schema json.RawMessage `json:"schema"`
+		}
 
-func (s *MCPServer) ListTools() []mcp.Tool {
-	return []mcp.Tool{
-		{
-			Name: "goto",
-			Description: "Navigate to a specified URL and load the page in" +
-				"memory so it can be reused later for info extraction.",
-			InputSchema: mcp.NewSchemaObject(mcp.Properties{
-				"url": mcp.NewSchemaString("The URL to navigate to, must be a valid URL."),
-			}),
-		},
-		{
-			Name:        "search",
-			Description: "Use a search engine to look for specific words, terms, sentences. The search page will then be loaded in memory.",
-			InputSchema: mcp.NewSchemaObject(mcp.Properties{
-				"text": mcp.NewSchemaString("The text to search for, must be a valid search query."),
-			}),
-		},
-		{
-			Name:        "markdown",
-			Description: "Get the page content in markdown format.",
-			InputSchema: mcp.NewSchemaObject(mcp.Properties{}),
-		},
-		{
-			Name:        "links",
-			Description: "Extract all links in the opened page",
-			InputSchema: mcp.NewSchemaObject(mcp.Properties{}),
-		},
-		{
-			Name:        "over",
-			Description: "Used to indicate that the task is over and give the final answer if there is any. This is the last tool to be called in a task.",
-			InputSchema: mcp.NewSchemaObject(mcp.Properties{
-				"result": mcp.NewSchemaString("The final result of the task."),
-			}),
-		},
-	}
-}
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
 
-var ErrNoTool = errors.New("no tool found")
+		fields := args.Fields
+		if len(args.Schema) > 0 {
+			var err error
+			if fields, err = fetch.FieldsFromSchema(args.Schema); err != nil {
+				return "", err
+			}
+		}
+		if len(fields) == 0 {
+			return "", errors.New("no fields")
+		}
+		return conn.ExtractStructured(ctx, fields)
+	case "browse":
+		var args struct {
+			Actions []BrowseAction `json:"actions"`
+			Timeout int            `json:"timeout"`
+		}
 
-func (s *MCPServer) CallTool(ctx context.Context, conn *MCPConn, req mcp.ToolsCallRequest) (string, error) {
-	v := req.Params.Arguments
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
 
-	switch req.Params.Name {
-	case "goto":
+		// the structured extracts evaluate the fields in the page.
+		if slices.ContainsFunc(args.Actions, func(a BrowseAction) bool { return len(a.Fields) > 0 }) {
+			if err := conn.authorizeScope("browse", ScopeEval); err != nil {
+				return "", err
+			}
+		}
+
+		timeout := BrowseDefaultTimeout
+		if args.Timeout > 0 {
+			timeout = min(time.Duration(args.Timeout)*time.Second, BrowseMaxTimeout)
+		}
+
+		return conn.interact(ctx, func() (string, error) {
+			return conn.Browse(ctx, args.Actions, timeout)
+		})
+	case "get_history_page":
 		var args struct {
-			URL string `json:"url"`
+			Index int `json:"index"`
 		}
 
 		if err := json.Unmarshal(v, &args); err != nil {
 			return "", fmt.Errorf("args decode: %w", err)
 		}
 
-		if args.URL == "" {
-			return "", errors.New("no url")
+		content, err := conn.GetHistoryPage(ctx, args.Index)
+		if err != nil {
+			return "", err
 		}
-		return conn.Goto(args.URL)
-	case "search":
+		return conn.paginate(content), nil
+	case "fetch_page_chunk":
 		var args struct {
-			Text string `json:"text"`
+			Cursor string `json:"cursor"`
 		}
 
 		if err := json.Unmarshal(v, &args); err != nil {
 			return "", fmt.Errorf("args decode: %w", err)
 		}
 
-		if args.Text == "" {
-			return "", errors.New("no text")
+		return conn.GetChunk(args.Cursor)
+	case "pin_tab", "unpin_tab":
+		var args struct {
+			Name string `json:"name"`
 		}
 
-		var urlString = "https://duckduckgo.com/?q=" + url.QueryEscape(args.Text)
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
 
-		return conn.Goto(urlString)
-	case "markdown":
-		return conn.GetMarkdown()
-	case "links":
-		links, err := conn.GetLinks()
-		if err != nil {
+		if args.Name == "" {
+			return "", errors.New("no name")
+		}
+
+		if req.Params.Name == "pin_tab" {
+			return conn.PinTab(args.Name)
+		}
+		return conn.UnpinTab(args.Name)
+	case "list_pages":
+		return conn.ListPages(ctx)
+	case "switch_page":
+		var args struct {
+			Page int `json:"page"`
+		}
+
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
+
+		return conn.SwitchPage(ctx, args.Page)
+	case "login":
+		var args struct {
+			Site string `json:"site"`
+			URL  string `json:"url"`
+		}
+
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
+
+		return conn.Login(ctx, args.Site, args.URL)
+	case "set_env":
+		var args struct {
+			Vars map[string]string `json:"vars"`
+		}
+
+		if err := json.Unmarshal(v, &args); err != nil {
+			return "", fmt.Errorf("args decode: %w", err)
+		}
+
+		if err := conn.env.Set(args.Vars); err != nil {
 			return "", err
 		}
-		return strings.Join(links, "\n"), nil
+		slog.Debug("set env", slog.Any("vars", &conn.env))
+
+		names := conn.env.Names()
+		if len(names) == 0 {
+			return "The session environment is empty.", nil
+		}
+		return fmt.Sprintf("The session environment has the variables: %s. Reference them as ${env.NAME}.", strings.Join(names, ", ")), nil
 	case "over":
 		var args struct {
 			Text string `json:"result"`
@@ -235,6 +1659,41 @@ func (s *MCPServer) CallTool(ctx context.Context, conn *MCPConn, req mcp.ToolsCa
 		return args.Text, nil
 	}
 
+	if r, ok := s.Recipes.ByTool(req.Params.Name); ok {
+		var args map[string]string
+		if len(v) > 0 {
+			if err := json.Unmarshal(v, &args); err != nil {
+				return "", fmt.Errorf("args decode: %w", err)
+			}
+		}
+
+		return conn.interact(ctx, func() (string, error) {
+			return r.Run(ctx, conn, args)
+		})
+	}
+
+	if ss, ok := s.Searches.ByTool(req.Params.Name); ok {
+		var args map[string]string
+		if len(v) > 0 {
+			if err := json.Unmarshal(v, &args); err != nil {
+				return "", fmt.Errorf("args decode: %w", err)
+			}
+		}
+
+		q, err := ss.Build(args)
+		if err != nil {
+			return "", err
+		}
+
+		return conn.interact(ctx, func() (string, error) {
+			res, err := conn.Search(ctx, q)
+			if err != nil {
+				return "", err
+			}
+			return search.Format(res), nil
+		})
+	}
+
 	// no tool found
 	return "", ErrNoTool
 }
@@ -276,9 +1735,13 @@ func (s *MCPServer) Handle(
 	mcpconn *MCPConn,
 	send SendFn,
 ) error {
+	// the tool calls' responses are sent by the workers of the connection.
+	send = mcpconn.calls.Send(send)
+
 	var senderr error
 	switch r := rreq.(type) {
 	case mcp.InitializeRequest:
+		mcpconn.setClient(r.Params.ClientInfo, r.Params.Hints)
 		senderr = send("message", rpc.NewResponse(mcp.InitializeResponse{
 			ProtocolVersion: mcp.Version,
 			ServerInfo: mcp.Info{
@@ -293,38 +1756,93 @@ func (s *MCPServer) Handle(
 		senderr = send("message", rpc.NewResponse(struct{}{}, r.Id))
 	case mcp.ToolsListRequest:
 		senderr = send("message", rpc.NewResponse(mcp.ToolsListResponse{
-			Tools: s.ListTools(),
+			Tools: mcpconn.allowedTools(s.ListTools()),
 		}, r.Id))
 	case mcp.ToolsCallRequest:
 		slog.Debug("call tool", slog.String("name", r.Params.Name), slog.Int("id", r.Id))
-		go func() {
-			res, err := s.CallTool(ctx, mcpconn, r)
+
+		if !s.calls.start() {
+			// the server is shutting down.
+			senderr = send("message", rpc.NewErrorResponse(
+				rpc.ServerErrorCode, "server shutting down", r.Id,
+			))
+			break
+		}
+
+		// the call runs in a worker of the connection, its response is sent
+		// when it ends, before or after the responses of the previous calls.
+		err := mcpconn.calls.Push(ctx, r.Id, func(ctx context.Context) {
+			var senderr error
+			defer func() {
+				if senderr != nil {
+					slog.Error("send message", slog.Int("id", r.Id), slog.Any("err", senderr))
+				}
+			}()
+
+			ctx, data := withToolData(ctx)
+			res, err := s.callTool(ctx, mcpconn, r)
+			if errors.Is(context.Cause(ctx), ErrCallCancelled) {
+				// the client doesn't expect a response.
+				slog.Debug("call cancelled", slog.String("name", r.Params.Name), slog.Int("id", r.Id))
+				return
+			}
+			// the env values are never returned nor logged.
+			res, err = mcpconn.env.Redact(res), mcpconn.env.RedactError(err)
 
 			if err != nil {
-				slog.Error("call tool", slog.String("name", r.Params.Name), slog.Any("err", err))
+				class := classify(err)
+				slog.Error("call tool", slog.String("name", r.Params.Name), slog.String("class", class.Name), slog.Any("err", err))
 				senderr = send("message", rpc.NewResponse(mcp.ToolsCallResponse{
 					IsError: true,
 					Content: []mcp.ToolsCallContent{{
 						Type: "text",
 						Text: err.Error(),
 					}},
+					Meta: &mcp.ToolsCallErrorMeta{
+						Code:  class.Code,
+						Class: class.Name,
+					},
 				}, r.Id))
+				return
 			}
 
-			senderr = send("message", rpc.NewResponse(mcp.ToolsCallResponse{
-				Content: []mcp.ToolsCallContent{{
-					Type: "text",
-					Text: res,
-				}},
-			}, r.Id))
-		}()
+			if s.Sanitize {
+				var n int
+				if res, n = fetch.Sanitize(res); n > 0 {
+					slog.Debug("invisible characters removed", slog.String("name", r.Params.Name), slog.Int("count", n))
+				}
+			}
+			res = mcpconn.responseShape().truncate(res)
+			client, _ := mcpconn.clientInfo()
+			f := s.ContentFormats.Formatter(client)
+			tr := data.result(r.Params.Name, res)
+			tr.URL = mcpconn.env.Redact(tr.URL)
+			senderr = send("message", rpc.NewResponse(f.Format(tr), r.Id))
+		}, s.calls.done)
+		switch {
+		case errors.Is(err, ErrCallQueueFull):
+			// too many calls are waiting, reject the request.
+			slog.Debug("too many queued calls", slog.String("name", r.Params.Name), slog.Int("id", r.Id))
+			senderr = send("message", rpc.NewErrorResponse(
+				rpc.TooManyRequestsCode, "too many concurrent requests, retry later", r.Id,
+			))
+		case err != nil:
+			senderr = send("message", rpc.NewErrorResponse(
+				rpc.InvalidRequestCode, err.Error(), r.Id,
+			))
+		}
+		if err != nil {
+			s.calls.done()
+		}
 
 	case mcp.NotificationsCancelledRequest:
 		slog.Debug("cancelled",
 			slog.Int("id", r.Params.RequestId),
 			slog.String("reason", r.Params.Reason),
 		)
-		// TODO cancel the corresponding request.
+		if !mcpconn.calls.Cancel(r.Params.RequestId) {
+			slog.Debug("cancelled call not pending", slog.Int("id", r.Params.RequestId))
+		}
 	}
 
 	if senderr != nil {
@@ -35,6 +35,16 @@ func Decode(r rpc.Request) (Request, error) {
 			return nil, fmt.Errorf("decode: %w", err)
 		}
 
+		var hints struct {
+			Capabilities struct {
+				Experimental ClientHints `json:"experimental"`
+			} `json:"capabilities"`
+		}
+		if err := json.Unmarshal(r.Params, &hints); err != nil {
+			return nil, fmt.Errorf("decode hints: %w", err)
+		}
+		rr.Params.Hints = hints.Capabilities.Experimental
+
 		return rr, nil
 	case NotificationsInitializedMethod:
 		return NotificationsInitializedRequest(r), nil
@@ -73,12 +83,22 @@ type Info struct {
 	Version string `json:"version"`
 }
 
+// ClientHints are the non standard hints given by the client in the
+// experimental capabilities on initialize.
+type ClientHints struct {
+	// The client displays the image contents.
+	Images bool `json:"images"`
+	// Size of the model context window in tokens.
+	ContextWindow int `json:"contextWindow"`
+}
+
 type InitializeRequest struct {
 	rpc.Request
 	Params struct {
 		ProtocolVersion string       `json:"protocolVersion"`
 		ClientInfo      Info         `json:"clientInfo"`
 		Capabilities    Capabilities `json:"capabilities"`
+		Hints           ClientHints  `json:"-"`
 	} `json:"params"`
 }
 
@@ -133,10 +153,25 @@ type ToolsCallRequest struct {
 
 type ToolsCallContent struct {
 	Type string `json:"type"`
-	Text string `json:"text"`
+	Text string `json:"text,omitempty"`
+	// base64 data of the image contents.
+	Data string `json:"data,omitempty"`
+	// fields of the resource_link contents.
+	URI         string `json:"uri,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ToolsCallErrorMeta gives the failure class of a tool call error.
+type ToolsCallErrorMeta struct {
+	Code  int    `json:"errorCode"`
+	Class string `json:"errorClass"`
 }
 
 type ToolsCallResponse struct {
-	IsError bool               `json:"isError"`
-	Content []ToolsCallContent `json:"content"`
+	IsError           bool                `json:"isError"`
+	Content           []ToolsCallContent  `json:"content"`
+	StructuredContent any                 `json:"structuredContent,omitempty"`
+	Meta              *ToolsCallErrorMeta `json:"_meta,omitempty"`
 }
@@ -27,6 +27,36 @@ func NewSchemaString(description string) schemaString {
 	return schemaString(SchemaType{Type: "string", Description: description})
 }
 
+type schemaInteger SchemaType
+
+func NewSchemaInteger(description string) schemaInteger {
+	return schemaInteger(SchemaType{Type: "integer", Description: description})
+}
+
+type schemaNumber SchemaType
+
+func NewSchemaNumber(description string) schemaNumber {
+	return schemaNumber(SchemaType{Type: "number", Description: description})
+}
+
+type schemaBoolean SchemaType
+
+func NewSchemaBoolean(description string) schemaBoolean {
+	return schemaBoolean(SchemaType{Type: "boolean", Description: description})
+}
+
+type schemaArray struct {
+	SchemaType
+	Items Schema `json:"items"`
+}
+
+func NewSchemaArray(description string, items Schema) schemaArray {
+	return schemaArray{
+		SchemaType: SchemaType{Type: "array", Description: description},
+		Items:      items,
+	}
+}
+
 type Properties map[string]Schema
 
 type schemaObject struct {
@@ -42,6 +72,21 @@ func NewSchemaObject(p map[string]Schema) schemaObject {
 	}
 }
 
+// schemaMap is an object with arbitrary keys.
+type schemaMap struct {
+	SchemaType
+	AdditionalProperties Schema `json:"additionalProperties"`
+}
+
+// NewSchemaMap returns the schema of an object with arbitrary keys, its
+// values follow the values schema. true accepts any value.
+func NewSchemaMap(description string, values Schema) schemaMap {
+	return schemaMap{
+		SchemaType:           SchemaType{Type: "object", Description: description},
+		AdditionalProperties: values,
+	}
+}
+
 type Tool struct {
 	Name        string       `json:"name"`
 	Description string       `json:"description,omitempty"`
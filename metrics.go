@@ -0,0 +1,262 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/pkg/search"
+)
+
+// metricsBuckets are the upper bounds of the durations histograms, in
+// seconds.
+var metricsBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// histogram counts the observed durations per bucket.
+type histogram struct {
+	counts []int
+	sum    float64
+	count  int
+}
+
+func (h *histogram) observe(d time.Duration) {
+	if h.counts == nil {
+		h.counts = make([]int, len(metricsBuckets))
+	}
+	v := d.Seconds()
+	for i, le := range metricsBuckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Metrics counts the tool calls, the navigations and the searches of the
+// server, exposed in the Prometheus text format.
+type Metrics struct {
+	sync.Mutex
+	// calls per tool and status.
+	calls map[[2]string]int
+	// durations per tool, per navigation status and per search engine and
+	// type.
+	tools       map[string]*histogram
+	navigations map[string]*histogram
+	searches    map[[2]string]*histogram
+}
+
+// status returns the label of the outcome of an operation, ok or the class
+// of its error.
+func status(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return classify(err).Name
+}
+
+func observe[K comparable](m *map[K]*histogram, k K, d time.Duration) {
+	if *m == nil {
+		*m = make(map[K]*histogram)
+	}
+	h, ok := (*m)[k]
+	if !ok {
+		h = &histogram{}
+		(*m)[k] = h
+	}
+	h.observe(d)
+}
+
+func (m *Metrics) tool(name string, d time.Duration, err error) {
+	// the names given by the clients aren't labels.
+	if errors.Is(err, ErrNoTool) {
+		name = "unknown"
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if m.calls == nil {
+		m.calls = make(map[[2]string]int)
+	}
+	m.calls[[2]string{name, status(err)}]++
+	observe(&m.tools, name, d)
+}
+
+// navigation observes a navigation, err is the raw failure of the browser.
+func (m *Metrics) navigation(d time.Duration, err error) {
+	if err != nil {
+		err = navigateError("", err)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	observe(&m.navigations, status(err), d)
+}
+
+func (m *Metrics) search(q search.Query, v search.Vertical, d time.Duration) {
+	engine := q.Engine
+	if engine == "" {
+		engine = search.DefaultEngine
+	}
+	if v == "" {
+		v = search.VerticalWeb
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	observe(&m.searches, [2]string{engine, string(v)}, d)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats the label pairs, name then value.
+func labels(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], labelEscaper.Replace(pairs[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// promWriter writes the metrics families in the Prometheus text format.
+type promWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *promWriter) printf(format string, args ...any) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, args...)
+}
+
+func (p *promWriter) family(name, typ, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// histogram writes the histogram h of the family name, pairs are its labels
+// names and values.
+func (p *promWriter) histogram(name string, h *histogram, pairs ...string) {
+	for i, le := range metricsBuckets {
+		p.printf("%s_bucket%s %d\n", name, labels(append(slices.Clone(pairs), "le", fmt.Sprint(le))...), h.counts[i])
+	}
+	p.printf("%s_bucket%s %d\n", name, labels(append(slices.Clone(pairs), "le", "+Inf")...), h.count)
+	p.printf("%s_sum%s %g\n", name, labels(pairs...), h.sum)
+	p.printf("%s_count%s %d\n", name, labels(pairs...), h.count)
+}
+
+// WriteMetrics writes the metrics of the server in the Prometheus text
+// format: the tool calls, the navigations, the searches, the pipeline stages,
+// the tab pools and the cdp endpoints cache.
+func (s *MCPServer) WriteMetrics(w io.Writer) error {
+	p := &promWriter{w: w}
+	m := &s.telemetry
+
+	m.Lock()
+	p.family("gomcp_tool_calls_total", "counter", "Tool calls per tool and status, ok or the error class.")
+	for _, k := range slices.SortedFunc(maps.Keys(m.calls), func(a, b [2]string) int {
+		return strings.Compare(a[0]+"\x00"+a[1], b[0]+"\x00"+b[1])
+	}) {
+		p.printf("gomcp_tool_calls_total%s %d\n", labels("tool", k[0], "status", k[1]), m.calls[k])
+	}
+	p.family("gomcp_tool_duration_seconds", "histogram", "Duration of the tool calls.")
+	for _, k := range slices.Sorted(maps.Keys(m.tools)) {
+		p.histogram("gomcp_tool_duration_seconds", m.tools[k], "tool", k)
+	}
+	p.family("gomcp_navigation_duration_seconds", "histogram", "Duration of the page navigations per status, ok or the error class.")
+	for _, k := range slices.Sorted(maps.Keys(m.navigations)) {
+		p.histogram("gomcp_navigation_duration_seconds", m.navigations[k], "status", k)
+	}
+	p.family("gomcp_search_duration_seconds", "histogram", "Latency of the searches per engine and type.")
+	for _, k := range slices.SortedFunc(maps.Keys(m.searches), func(a, b [2]string) int {
+		return strings.Compare(a[0]+"\x00"+a[1], b[0]+"\x00"+b[1])
+	}) {
+		p.histogram("gomcp_search_duration_seconds", m.searches[k], "engine", k[0], "type", k[1])
+	}
+	m.Unlock()
+
+	stages := s.metrics.Snapshot()
+	p.family("gomcp_pipeline_stage_runs_total", "counter", "Runs of the pipeline stages.")
+	for _, st := range slices.Sorted(maps.Keys(stages)) {
+		p.printf("gomcp_pipeline_stage_runs_total%s %d\n", labels("stage", string(st)), stages[st].Runs)
+	}
+	p.family("gomcp_pipeline_stage_failures_total", "counter", "Failures of the pipeline stages.")
+	for _, st := range slices.Sorted(maps.Keys(stages)) {
+		p.printf("gomcp_pipeline_stage_failures_total%s %d\n", labels("stage", string(st)), stages[st].Failures)
+	}
+	p.family("gomcp_pipeline_stage_seconds_total", "counter", "Total duration of the pipeline stages.")
+	for _, st := range slices.Sorted(maps.Keys(stages)) {
+		p.printf("gomcp_pipeline_stage_seconds_total%s %g\n", labels("stage", string(st)), stages[st].Total.Seconds())
+	}
+
+	pools := s.tabs.Stats()
+	hosts := slices.Sorted(maps.Keys(pools))
+	p.family("gomcp_tabs", "gauge", "Tabs of the pools per host and state.")
+	for _, h := range hosts {
+		st := pools[h]
+		p.printf("gomcp_tabs%s %d\n", labels("host", h, "state", "idle"), st.Idle)
+		p.printf("gomcp_tabs%s %d\n", labels("host", h, "state", "checked_out"), st.CheckedOut)
+		p.printf("gomcp_tabs%s %d\n", labels("host", h, "state", "pinned"), st.Pinned)
+	}
+	p.family("gomcp_tabs_max", "gauge", "Maximum number of tabs of the pools, 0 means no limit.")
+	for _, h := range hosts {
+		p.printf("gomcp_tabs_max%s %d\n", labels("host", h), pools[h].Max)
+	}
+	p.family("gomcp_tab_checkouts_total", "counter", "Checkouts of the pools tabs.")
+	for _, h := range hosts {
+		p.printf("gomcp_tab_checkouts_total%s %d\n", labels("host", h), pools[h].Checkouts)
+	}
+	p.family("gomcp_tab_checkout_waits_total", "counter", "Checkouts which waited for a free tab.")
+	for _, h := range hosts {
+		p.printf("gomcp_tab_checkout_waits_total%s %d\n", labels("host", h), pools[h].Waits)
+	}
+	p.family("gomcp_tab_checkout_failures_total", "counter", "Checkouts failed with all the tabs in use.")
+	for _, h := range hosts {
+		p.printf("gomcp_tab_checkout_failures_total%s %d\n", labels("host", h), pools[h].Timeouts+pools[h].Rejected)
+	}
+	p.family("gomcp_cdp_reconnects_total", "counter", "Reconnections to the browsers after a lost connection.")
+	for _, h := range hosts {
+		p.printf("gomcp_cdp_reconnects_total%s %d\n", labels("host", h), pools[h].Reconnects)
+	}
+
+	hits, misses := s.endpoints.CacheStats()
+	p.family("gomcp_cache_lookups_total", "counter", "Lookups of the caches per result, hit or miss.")
+	p.printf("gomcp_cache_lookups_total%s %d\n", labels("cache", "cdp_endpoints", "result", "hit"), hits)
+	p.printf("gomcp_cache_lookups_total%s %d\n", labels("cache", "cdp_endpoints", "result", "miss"), misses)
+
+	return p.err
+}
+
+func handleMetrics(srv *MCPServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := srv.WriteMetrics(w); err != nil {
+			slog.Error("metrics write", slog.Any("err", err))
+		}
+	}
+}
@@ -0,0 +1,597 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// OAuthKeysTTL is the lifetime of the cached signing keys of the
+	// authorization server.
+	OAuthKeysTTL = time.Hour
+
+	// minimum delay between two fetches of the keys for an unknown key id.
+	oauthRefreshDelay = time.Minute
+	oauthTimeout      = 10 * time.Second
+	// tolerated clock difference with the authorization server.
+	oauthLeeway = time.Minute
+
+	// OAuthMetadataPath is the path of the protected resource metadata,
+	// RFC 9728.
+	OAuthMetadataPath = "/.well-known/oauth-protected-resource"
+)
+
+var (
+	// ErrNoAccessToken is returned for the requests without bearer token.
+	ErrNoAccessToken = errors.New("missing access token")
+	// ErrInvalidAccessToken is returned for the access tokens refused by
+	// OAuth.
+	ErrInvalidAccessToken = errors.New("invalid access token")
+)
+
+// OAuth authorizes the MCP clients with the JWT access tokens issued by an
+// external authorization server, following the MCP authorization spec. The
+// tokens must be issued for Resource, their scopes are the tools scopes.
+type OAuth struct {
+	// Issuer of the access tokens, the authorization server advertised to
+	// the clients.
+	Issuer string
+	// Canonical url of the MCP server, the audience of the access tokens.
+	Resource string
+
+	cli  *http.Client
+	mu   sync.Mutex
+	uri  string
+	keys map[string]oauthKey
+	// last fetch of the keys.
+	fetched time.Time
+	// fetch of the keys in progress, shared by the concurrent requests.
+	fetching *keysFetch
+}
+
+// oauthKey is a signing key of the authorization server.
+type oauthKey struct {
+	pub crypto.PublicKey
+	// algorithm of the key, any algorithm matching the key type if empty.
+	alg string
+}
+
+// keysFetch is a fetch of the keys, done is closed when it ends.
+type keysFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewOAuth returns an OAuth validating the tokens of the issuer for the
+// resource.
+func NewOAuth(issuer, resource string) (*OAuth, error) {
+	for _, v := range []string{issuer, resource} {
+		u, err := url.Parse(v)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			return nil, fmt.Errorf("oauth: invalid url %q", v)
+		}
+	}
+
+	return &OAuth{
+		Issuer:   issuer,
+		Resource: resource,
+		cli:      &http.Client{Timeout: oauthTimeout},
+	}, nil
+}
+
+// ResourceMetadata is the protected resource metadata served to the clients
+// to discover the authorization server.
+type ResourceMetadata struct {
+	Resource             string   `json:"resource"`
+	AuthorizationServers []string `json:"authorization_servers"`
+	BearerMethods        []string `json:"bearer_methods_supported"`
+	Scopes               []Scope  `json:"scopes_supported"`
+	Name                 string   `json:"resource_name,omitempty"`
+}
+
+// Metadata returns the protected resource metadata.
+func (o *OAuth) Metadata() ResourceMetadata {
+	return ResourceMetadata{
+		Resource:             o.Resource,
+		AuthorizationServers: []string{o.Issuer},
+		BearerMethods:        []string{"header"},
+		Scopes:               []Scope{ScopeRead, ScopeBrowse, ScopeEval},
+		Name:                 "gomcp",
+	}
+}
+
+// MetadataURL returns the url of the protected resource metadata, the path
+// of the resource is appended to the well-known path.
+func (o *OAuth) MetadataURL() string {
+	u, err := url.Parse(o.Resource)
+	if err != nil {
+		return ""
+	}
+
+	return u.Scheme + "://" + u.Host + OAuthMetadataPath + strings.TrimSuffix(u.Path, "/")
+}
+
+// challenge sets the WWW-Authenticate header of the unauthorized responses,
+// giving the url of the metadata and the error of the refused token.
+func (o *OAuth) challenge(w http.ResponseWriter, err error) {
+	v := fmt.Sprintf("Bearer resource_metadata=%q", o.MetadataURL())
+	if err != nil && !errors.Is(err, ErrNoAccessToken) {
+		v += fmt.Sprintf(", error=\"invalid_token\", error_description=%q", err.Error())
+	}
+	w.Header().Set("WWW-Authenticate", v)
+}
+
+// Authenticate validates the bearer access token of the request and returns
+// a token named after its subject with its scopes.
+func (o *OAuth) Authenticate(req *http.Request) (*APIToken, error) {
+	raw, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || raw == "" {
+		return nil, ErrNoAccessToken
+	}
+
+	claims, err := o.verify(req.Context(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidAccessToken, err)
+	}
+
+	tok := &APIToken{Name: "oauth:" + claims.Subject}
+	for _, sc := range claims.scopes() {
+		switch sc := Scope(sc); sc {
+		case ScopeRead, ScopeBrowse, ScopeEval:
+			tok.Scopes = append(tok.Scopes, sc)
+		}
+	}
+
+	return tok, nil
+}
+
+// jwtClaims are the claims of an access token, RFC 9068.
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	Expires   int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+	Scope     string          `json:"scope"`
+	// Microsoft Entra gives the scopes in scp.
+	Scp json.RawMessage `json:"scp"`
+}
+
+// audience returns the audiences, a string or an array.
+func (c jwtClaims) audience() []string {
+	var aud []string
+	if err := json.Unmarshal(c.Audience, &aud); err == nil {
+		return aud
+	}
+	var s string
+	if err := json.Unmarshal(c.Audience, &s); err == nil && s != "" {
+		return []string{s}
+	}
+
+	return nil
+}
+
+// scopes returns the scopes of the space separated scope claim or the scp
+// claim.
+func (c jwtClaims) scopes() []string {
+	if c.Scope != "" {
+		return strings.Fields(c.Scope)
+	}
+	var scp []string
+	if err := json.Unmarshal(c.Scp, &scp); err == nil {
+		return scp
+	}
+	var s string
+	if err := json.Unmarshal(c.Scp, &s); err == nil {
+		return strings.Fields(s)
+	}
+
+	return nil
+}
+
+// verify checks the signature and the claims of the JWT access token.
+func (o *OAuth) verify(ctx context.Context, raw string) (jwtClaims, error) {
+	var claims jwtClaims
+
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return claims, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return claims, fmt.Errorf("header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, fmt.Errorf("signature: %w", err)
+	}
+
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return claims, err
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return claims, fmt.Errorf("algorithm %s doesn't match the key", header.Alg)
+	}
+	if err := verifySignature(header.Alg, key.pub, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return claims, err
+	}
+
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return claims, fmt.Errorf("claims: %w", err)
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != o.Issuer:
+		return claims, fmt.Errorf("issuer %s", claims.Issuer)
+	case !slices.ContainsFunc(claims.audience(), o.audience):
+		return claims, errors.New("token not issued for this resource")
+	case claims.Expires == 0 || now.After(time.Unix(claims.Expires, 0).Add(oauthLeeway)):
+		return claims, errors.New("token expired")
+	case claims.NotBefore != 0 && now.Add(oauthLeeway).Before(time.Unix(claims.NotBefore, 0)):
+		return claims, errors.New("token not yet valid")
+	case claims.Subject == "":
+		return claims, errors.New("missing subject")
+	}
+
+	return claims, nil
+}
+
+// audience returns true if aud is the resource, ignoring a trailing slash.
+func (o *OAuth) audience(aud string) bool {
+	return strings.TrimSuffix(aud, "/") == strings.TrimSuffix(o.Resource, "/")
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+// verifySignature verifies the signature of the signed content with the
+// asymmetric algorithms, RS*, PS* and ES*. The ES* algorithms require the
+// key of their curve.
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var (
+		h     crypto.Hash
+		curve elliptic.Curve
+	)
+	switch alg[min(len(alg), 2):] {
+	case "256":
+		h, curve = crypto.SHA256, elliptic.P256()
+	case "384":
+		h, curve = crypto.SHA384, elliptic.P384()
+	case "512":
+		h, curve = crypto.SHA512, elliptic.P521()
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	var digest []byte
+	switch h {
+	case crypto.SHA256:
+		d := sha256.Sum256(signed)
+		digest = d[:]
+	case crypto.SHA384:
+		d := sha512.Sum384(signed)
+		digest = d[:]
+	case crypto.SHA512:
+		d := sha512.Sum512(signed)
+		digest = d[:]
+	}
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(pub, h, digest, sig)
+		case "PS":
+			return rsa.VerifyPSS(pub, h, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || pub.Curve != curve || len(sig) != 2*size {
+			break
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("algorithm %s doesn't match the key", alg)
+}
+
+// key returns the signing key kid of the authorization server. The keys are
+// fetched again after OAuthKeysTTL or for an unknown key id.
+func (o *OAuth) key(ctx context.Context, kid string) (oauthKey, error) {
+	o.mu.Lock()
+	k, ok := o.lookup(kid)
+	age, fetched := time.Since(o.fetched), o.keys != nil
+	o.mu.Unlock()
+
+	if ok && age < OAuthKeysTTL {
+		return k, nil
+	}
+	if fetched && age < oauthRefreshDelay {
+		return k, fmt.Errorf("unknown key %q", kid)
+	}
+
+	if err := o.refresh(ctx); err != nil {
+		return k, fmt.Errorf("authorization server keys: %w", err)
+	}
+
+	o.mu.Lock()
+	k, ok = o.lookup(kid)
+	o.mu.Unlock()
+	if ok {
+		return k, nil
+	}
+
+	return k, fmt.Errorf("unknown key %q", kid)
+}
+
+// lookup returns the key kid, o.mu must be held.
+func (o *OAuth) lookup(kid string) (oauthKey, bool) {
+	if k, ok := o.keys[kid]; ok {
+		return k, true
+	}
+	// a key without id is used if it is the only one.
+	if kid == "" && len(o.keys) == 1 {
+		for _, k := range o.keys {
+			return k, true
+		}
+	}
+
+	return oauthKey{}, false
+}
+
+// refresh fetches the keys without holding o.mu, the concurrent callers wait
+// for the fetch in progress.
+func (o *OAuth) refresh(ctx context.Context) error {
+	o.mu.Lock()
+	f := o.fetching
+	if f == nil {
+		f = &keysFetch{done: make(chan struct{})}
+		o.fetching = f
+		uri := o.uri
+		// the fetch outlives a canceled request, the other callers wait
+		// for it.
+		go func() {
+			keys, uri, err := o.fetchKeys(context.WithoutCancel(ctx), uri)
+
+			o.mu.Lock()
+			if err == nil {
+				o.uri, o.keys, o.fetched = uri, keys, time.Now()
+			}
+			o.fetching = nil
+			o.mu.Unlock()
+
+			f.err = err
+			close(f.done)
+		}()
+	}
+	o.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetchKeys discovers the JWKS uri of the authorization server if empty and
+// fetches its keys.
+func (o *OAuth) fetchKeys(ctx context.Context, uri string) (map[string]oauthKey, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, oauthTimeout)
+	defer cancel()
+
+	if uri == "" {
+		var err error
+		if uri, err = o.discover(ctx); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := o.get(ctx, uri, &set); err != nil {
+		return nil, "", err
+	}
+
+	keys := make(map[string]oauthKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		pub, err := k.public()
+		if err != nil {
+			// the unsupported keys are ignored.
+			continue
+		}
+		keys[k.Kid] = oauthKey{pub: pub, alg: k.Alg}
+	}
+
+	return keys, uri, nil
+}
+
+// discover returns the jwks_uri of the authorization server metadata, RFC
+// 8414, or of its OpenID configuration.
+func (o *OAuth) discover(ctx context.Context) (string, error) {
+	u, err := url.Parse(o.Issuer)
+	if err != nil {
+		return "", fmt.Errorf("issuer: %w", err)
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	origin := u.Scheme + "://" + u.Host
+
+	var errs []error
+	for _, uri := range []string{
+		origin + "/.well-known/oauth-authorization-server" + path,
+		origin + "/.well-known/openid-configuration" + path,
+		origin + path + "/.well-known/openid-configuration",
+	} {
+		var meta struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := o.get(ctx, uri, &meta); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if meta.Issuer != o.Issuer {
+			errs = append(errs, fmt.Errorf("%s: issuer %s", uri, meta.Issuer))
+			continue
+		}
+		if meta.JWKSURI == "" {
+			errs = append(errs, fmt.Errorf("%s: no jwks_uri", uri))
+			continue
+		}
+
+		return meta.JWKSURI, nil
+	}
+
+	return "", fmt.Errorf("discover: %w", errors.Join(errs...))
+}
+
+func (o *OAuth) get(ctx context.Context, uri string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return fmt.Errorf("create http req: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := o.cli.Do(req)
+	if err != nil {
+		return fmt.Errorf("do req: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: bad status code: %d", uri, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: decode: %w", uri, err)
+	}
+
+	return nil
+}
+
+// jwk is a public key of a JWKS, RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) public() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 || exp.Int64() < 3 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		var (
+			curve elliptic.Curve
+			check ecdh.Curve
+		)
+		switch k.Crv {
+		case "P-256":
+			curve, check = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, check = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, check = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("invalid point")
+		}
+		// ecdh checks the point is on the curve.
+		if _, err := check.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func handleOAuthMetadata(o *OAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(o.Metadata()); err != nil {
+			slog.Error("oauth metadata encode", slog.Any("err", err))
+		}
+	}
+}
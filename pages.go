@@ -0,0 +1,144 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+)
+
+var ErrNoPage = errors.New("no page with this number, use list_pages")
+
+// follow attaches to the pages opened by the active page, e.g. popups or links
+// opened in a new tab, and makes the last one active. It returns a message
+// describing the switch, empty if no page has been opened.
+func (c *MCPConn) follow() string {
+	opener := c.tab
+	var n int
+	for _, id := range opener.takeOpened() {
+		t, err := opener.store.Attach(id)
+		if err != nil {
+			slog.Debug("attach opened page", slog.String("id", id.String()), slog.Any("err", err))
+			continue
+		}
+		c.use(t)
+		n = len(c.pages)
+	}
+	if n == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("The page opened a new page, page %d is now the active page. Use list_pages and switch_page to go back.", n)
+}
+
+// fallback makes the last open page active if the active page has been closed,
+// e.g. by a popup closing itself. It returns a message describing the switch,
+// empty if the active page is still open.
+func (c *MCPConn) fallback(ctx context.Context) string {
+	infos, err := c.targets(ctx)
+	if err != nil {
+		slog.Debug("page targets", slog.Any("err", err))
+		return ""
+	}
+	if _, ok := infos[c.tab.id]; ok {
+		return ""
+	}
+
+	for i := len(c.pages) - 1; i >= 0; i-- {
+		if _, ok := infos[c.pages[i].id]; ok {
+			c.use(c.pages[i])
+			return fmt.Sprintf("The active page has been closed, page %d is now the active page.", i+1)
+		}
+	}
+
+	return ""
+}
+
+// targets returns the info of the browser's page targets by id.
+func (c *MCPConn) targets(ctx context.Context) (map[target.ID]*target.Info, error) {
+	var infos []*target.Info
+	err := c.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		infos, err = chromedp.Targets(ctx)
+		return err
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("get targets: %w", err)
+	}
+
+	m := make(map[target.ID]*target.Info, len(infos))
+	for _, info := range infos {
+		m[info.TargetID] = info
+	}
+
+	return m, nil
+}
+
+// ListPages lists the pages of the connection.
+func (c *MCPConn) ListPages(ctx context.Context) (string, error) {
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+
+	infos, err := c.targets(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for i, t := range c.pages {
+		active := ""
+		if t == c.tab {
+			active = " (active)"
+		}
+		info, ok := infos[t.id]
+		if !ok {
+			fmt.Fprintf(&b, "%d. closed%s\n", i+1, active)
+			continue
+		}
+		fmt.Fprintf(&b, "%d. %s - %s%s\n", i+1, info.Title, info.URL, active)
+	}
+
+	return b.String(), nil
+}
+
+// SwitchPage makes the page number n of the connection the active one.
+func (c *MCPConn) SwitchPage(ctx context.Context, n int) (string, error) {
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+	if n < 1 || n > len(c.pages) {
+		return "", ErrNoPage
+	}
+
+	t := c.pages[n-1]
+	if !t.alive() {
+		return "", fmt.Errorf("page %d is closed", n)
+	}
+	c.use(t)
+
+	var title, location string
+	if err := c.run(ctx, chromedp.Title(&title), chromedp.Location(&location)); err != nil {
+		return "", fmt.Errorf("page info: %w", err)
+	}
+
+	return fmt.Sprintf("Page %d is now the active page: %s - %s", n, title, location), nil
+}
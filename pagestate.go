@@ -0,0 +1,175 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// PageState is a compact description of the page used to report what changed
+// after an interaction.
+type PageState struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	// Regions maps the main regions of the page to a hash of their text.
+	Regions map[string]string `json:"regions"`
+}
+
+// pageStateJS returns the page url, title and a hash of the text of the
+// landmarks and of the body's children.
+const pageStateJS = `(() => {
+	const hash = (s) => {
+		let h = 5381;
+		for (let i = 0; i < s.length; i++) {
+			h = ((h << 5) + h + s.charCodeAt(i)) | 0;
+		}
+		return (h >>> 0).toString(16);
+	};
+	const name = (e) => {
+		let n = e.tagName.toLowerCase();
+		if (e.id) {
+			n += '#' + e.id;
+		} else if (typeof e.className === 'string' && e.className.trim() !== '') {
+			n += '.' + e.className.trim().split(/\s+/)[0];
+		}
+		return n;
+	};
+	const regions = {};
+	const add = (e) => {
+		regions[name(e)] = hash(e.textContent || '');
+	};
+	if (document.body) {
+		Array.from(document.body.children).forEach(add);
+	}
+	document.querySelectorAll('header, nav, main, aside, footer, form, dialog, [role=dialog], [role=alert]').forEach(add);
+	return {url: document.location.href, title: document.title, regions: regions};
+})()`
+
+// pageState returns the state of the current page.
+func (c *MCPConn) pageState(ctx context.Context) (PageState, error) {
+	var st PageState
+	if err := c.run(ctx, chromedp.Evaluate(pageStateJS, &st)); err != nil {
+		return st, fmt.Errorf("page state: %w", err)
+	}
+
+	return st, nil
+}
+
+// Delta describes the changes from the state prev to st.
+func (st PageState) Delta(prev PageState, dialogs []string) string {
+	var b strings.Builder
+
+	b.WriteString("Page state:\n")
+	if st.URL != prev.URL {
+		fmt.Fprintf(&b, "- URL: %s (changed)\n", st.URL)
+	} else {
+		fmt.Fprintf(&b, "- URL: %s\n", st.URL)
+	}
+	if st.Title != prev.Title {
+		fmt.Fprintf(&b, "- Title: %s (changed)\n", st.Title)
+	} else {
+		fmt.Fprintf(&b, "- Title: %s\n", st.Title)
+	}
+
+	// regions are compared only if we stay on the same page.
+	if st.URL == prev.URL {
+		var added, changed, removed []string
+		for k, v := range st.Regions {
+			pv, ok := prev.Regions[k]
+			switch {
+			case !ok:
+				added = append(added, k)
+			case pv != v:
+				changed = append(changed, k)
+			}
+		}
+		for k := range prev.Regions {
+			if _, ok := st.Regions[k]; !ok {
+				removed = append(removed, k)
+			}
+		}
+
+		writeList(&b, "New regions", added)
+		writeList(&b, "Changed regions", changed)
+		writeList(&b, "Removed regions", removed)
+	}
+
+	if len(dialogs) > 0 {
+		b.WriteString("- Dialogs opened and accepted:\n")
+		for _, d := range dialogs {
+			fmt.Fprintf(&b, "  - %s\n", d)
+		}
+	}
+
+	return b.String()
+}
+
+func writeList(b *strings.Builder, title string, l []string) {
+	if len(l) == 0 {
+		return
+	}
+
+	slices.Sort(l)
+	fmt.Fprintf(b, "- %s: %s\n", title, strings.Join(l, ", "))
+}
+
+// Delay given to the page to react after an interaction.
+const interactSettle = 500 * time.Millisecond
+
+// interact runs the interaction fn and appends the resulting page state delta
+// to its result.
+func (c *MCPConn) interact(ctx context.Context, fn func() (string, error)) (string, error) {
+	var prev PageState
+	if c.cdpctx != nil {
+		// a failure means there is no previous page to compare with.
+		prev, _ = c.pageState(ctx)
+	}
+
+	res, err := fn()
+	if err != nil {
+		return "", err
+	}
+
+	if c.cdpctx == nil {
+		return res, nil
+	}
+	// the interaction may have navigated to a blocked url.
+	if err := c.checkLocation(ctx); err != nil {
+		return "", err
+	}
+
+	// the dialogs are opened by the page interacted with.
+	dialogs := c.tab.takeDialogs()
+	for _, msg := range []string{c.follow(), c.fallback(ctx)} {
+		if msg != "" {
+			res += "\n\n" + msg
+		}
+	}
+
+	st, err := c.pageState(ctx)
+	if err != nil {
+		slog.Debug("page state", slog.Any("err", err))
+		return res, nil
+	}
+
+	return res + "\n\n" + st.Delta(prev, append(dialogs, c.tab.takeDialogs()...)), nil
+}
@@ -0,0 +1,647 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+	"github.com/lightpanda-io/gomcp/pkg/search"
+	"github.com/lightpanda-io/gomcp/pkg/tracing"
+	"github.com/lightpanda-io/gomcp/pkg/warc"
+)
+
+// Stage of the page pipeline.
+type Stage string
+
+const (
+	// Check and build the url.
+	StageResolve Stage = "resolve"
+	// Get a tab of the pool.
+	StageCheckout Stage = "checkout"
+	StageNavigate Stage = "navigate"
+	// Wait for the page content, e.g. dismiss the consent dialogs and click
+	// the expand buttons.
+	StageWait Stage = "wait"
+	// Read the page content and metadata.
+	StageExtract Stage = "extract"
+	// Convert the content, e.g. in markdown.
+	StageConvert Stage = "convert"
+	// Give the result to the caller.
+	StageEmit Stage = "emit"
+)
+
+// StageError wraps the failure of a pipeline stage.
+type StageError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// StageTiming is the duration of a stage of a pipeline run.
+type StageTiming struct {
+	Stage    Stage
+	Duration time.Duration
+}
+
+// MarshalJSON encodes the duration in milliseconds.
+func (t StageTiming) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Stage    Stage   `json:"stage"`
+		Duration float64 `json:"duration_ms"`
+	}{t.Stage, float64(t.Duration.Microseconds()) / 1000})
+}
+
+// StageMetrics aggregates the runs of a stage.
+type StageMetrics struct {
+	Runs     int           `json:"runs"`
+	Failures int           `json:"failures"`
+	Total    time.Duration `json:"total"`
+}
+
+// PipelineMetrics aggregates the stages of the pipeline runs of the server.
+type PipelineMetrics struct {
+	sync.Mutex
+	stages map[Stage]StageMetrics
+}
+
+func (m *PipelineMetrics) add(st Stage, d time.Duration, failed bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.stages == nil {
+		m.stages = make(map[Stage]StageMetrics)
+	}
+	sm := m.stages[st]
+	sm.Runs++
+	sm.Total += d
+	if failed {
+		sm.Failures++
+	}
+	m.stages[st] = sm
+}
+
+// Snapshot returns the metrics per stage.
+func (m *PipelineMetrics) Snapshot() map[Stage]StageMetrics {
+	m.Lock()
+	defer m.Unlock()
+
+	stages := make(map[Stage]StageMetrics, len(m.stages))
+	for st, sm := range m.stages {
+		stages[st] = sm
+	}
+
+	return stages
+}
+
+// pipeline is a run of the stages of a job.
+type pipeline struct {
+	srv *MCPServer
+	// url or query of the job, for the logs.
+	target  string
+	timings []StageTiming
+	// tab checked out by the checkout stage.
+	tab *tab
+	// session of the tool call, for the audit log.
+	session string
+	// env of the tool call, its values are redacted from the logs.
+	env *SessionEnv
+	// fail with ErrEmptyContent instead of emitting an empty page, to retry
+	// it.
+	failEmpty bool
+}
+
+// stage runs fn as the stage st with its own context, canceled when the
+// stage ends. The stage is measured and its error wrapped.
+func (p *pipeline) stage(ctx context.Context, st Stage, fn func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ctx, span := p.srv.Tracer.Start(ctx, "pipeline."+string(st), tracing.String("target", p.target))
+	start := time.Now()
+	err := fn(ctx)
+	d := time.Since(start)
+	span.End(err)
+
+	p.timings = append(p.timings, StageTiming{Stage: st, Duration: d})
+	p.srv.metrics.add(st, d, err != nil)
+	slog.Debug("pipeline stage",
+		slog.String("target", p.target),
+		slog.String("stage", string(st)),
+		slog.Duration("duration", d),
+		slog.Bool("failed", err != nil),
+	)
+
+	if err != nil {
+		return &StageError{Stage: st, Err: err}
+	}
+
+	return nil
+}
+
+// tabctx returns the chromedp context of the pipeline's tab, canceled with
+// ctx.
+func (p *pipeline) tabctx(ctx context.Context) (context.Context, context.CancelFunc) {
+	tctx, cancel := context.WithCancel(p.tab.ctx)
+	stop := context.AfterFunc(ctx, cancel)
+
+	return tctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// checkout checks out a tab and applies the server's emulation. The caller
+// must call release.
+func (p *pipeline) checkout(ctx context.Context) error {
+	return p.stage(ctx, StageCheckout, func(ctx context.Context) error {
+		t, err := p.srv.checkout(ctx)
+		if err != nil {
+			return fmt.Errorf("browser connect: %w", err)
+		}
+		p.tab = t
+
+		return emulate(ctx, t, p.srv.Emulation)
+	})
+}
+
+// release checks in the tab.
+func (p *pipeline) release() {
+	if p.tab != nil {
+		p.srv.tabs.Checkin(p.tab)
+	}
+}
+
+// navigate loads the url u in the tab of tctx at the pace of the server's
+// host limiter. On a 429 status, the next navigations to the host are delayed
+// by the Retry-After of the response and the navigation is tried once more.
+// A bot challenge fails with a BotChallengeError, unless the server's solver
+// solves it, then the navigation is tried once more.
+func (p *pipeline) navigate(tctx context.Context, u string) (*network.Response, error) {
+	for attempt := 0; ; attempt++ {
+		release, err := p.srv.Limiter.Wait(tctx, u)
+		if err != nil {
+			return nil, err
+		}
+		start, received := time.Now(), p.tab.received()
+		resp, err := chromedp.RunResponse(tctx, chromedp.Navigate(u))
+		p.srv.telemetry.navigation(time.Since(start), err)
+		aerr := err
+		if aerr != nil {
+			aerr = navigateError(u, err)
+		}
+		p.srv.Audit.navigation(p.session, p.env.Redact(u), start, p.tab.received()-received, p.env.RedactError(aerr))
+		release()
+		if err != nil {
+			return resp, err
+		}
+
+		if resp != nil && resp.Status == http.StatusTooManyRequests && attempt == 0 {
+			d := retryAfter(resp.Headers)
+			p.srv.Limiter.Backoff(u, d, p.env)
+			if d <= RateLimitMaxBackoff {
+				continue
+			}
+		}
+
+		// a solved challenge is loaded once more.
+		again, err := p.srv.challenge(tctx, p.env, attempt == 0)
+		if !again {
+			return resp, err
+		}
+	}
+}
+
+// PageJob is a page fetched by the pipeline.
+type PageJob struct {
+	// Operation named in the debug artifacts.
+	Op      string
+	URL     string
+	Options fetch.Options
+	// Return the content rendered so far if the job is canceled during the
+	// navigation.
+	Salvage bool
+	// Translate the markdown with the server's Translator, if any.
+	Translate bool
+	// WARC receives the HTTP exchanges of the page, it may be nil.
+	WARC *warc.Writer
+	// Emit receives the result, it may be nil. Its failure is diagnosed.
+	Emit func(fetch.Result) error
+}
+
+// FetchPage runs the job through the pipeline stages: resolve, checkout,
+// navigate, wait, extract, convert and emit. It returns the result with the
+// duration of the stages.
+// The job is retried up to the server's Retries times if it fails with a
+// transient error or loads an empty page, the timings include all the
+// attempts.
+func (s *MCPServer) FetchPage(ctx context.Context, job PageJob) (fetch.Result, []StageTiming, error) {
+	env := envOf(ctx)
+	p := &pipeline{srv: s, target: env.Redact(job.URL), session: sessionOf(ctx), env: env}
+
+	var res fetch.Result
+	err := retry(ctx, "fetch "+job.URL, s.Retries, s.RetryBackoff, transient, func(attempt int) error {
+		// the last attempt returns the empty page.
+		p.failEmpty = attempt < s.Retries
+		var err error
+		res, err = p.fetch(ctx, job)
+		return err
+	})
+
+	return res, p.timings, err
+}
+
+func (p *pipeline) fetch(ctx context.Context, job PageJob) (fetch.Result, error) {
+	var res fetch.Result
+	profile := p.srv.Profiles.Match(job.URL)
+	job.Options = profile.options(job.Options)
+	opts := job.Options
+
+	// the timeout bounds the stages from the navigation to the conversion.
+	runctx := ctx
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		runctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	err := p.stage(ctx, StageResolve, func(ctx context.Context) error {
+		return p.srv.checkURL(ctx, job.URL)
+	})
+	if err != nil {
+		return res, err
+	}
+
+	// the static pages don't need the browser, the WARC records the
+	// browser's exchanges. The profiles tuning the browser need it.
+	if p.srv.Mode == FetchModeHTTP && job.WARC == nil && (profile == nil || !profile.browser()) {
+		res, err := p.static(runctx, job)
+		if !errors.Is(err, fetch.ErrNeedsBrowser) {
+			return res, err
+		}
+		slog.Debug("page needs a browser", slog.String("url", p.env.Redact(job.URL)))
+	}
+
+	if err := p.checkout(ctx); err != nil {
+		return res, err
+	}
+	defer p.release()
+	if profile != nil {
+		// the tab is restored before its release.
+		defer func() {
+			ctx, cancel := context.WithTimeout(p.tab.ctx, tabResetTimeout)
+			defer cancel()
+			if err := profile.unapply(ctx, p.tab); err != nil {
+				slog.Debug("profile unapply", slog.String("profile", profile.name), slog.Any("err", err))
+			}
+		}()
+	}
+
+	// the exchanges are recorded from the navigation to the extraction.
+	var rec *fetch.Recorder
+	if job.WARC != nil {
+		rctx, cancel := context.WithCancel(p.tab.ctx)
+		defer cancel()
+		var err error
+		if rec, err = fetch.Record(rctx); err != nil {
+			return res, err
+		}
+	}
+
+	// response of the page, for the robots headers.
+	var resp *network.Response
+	err = p.stage(runctx, StageNavigate, func(ctx context.Context) error {
+		tctx, cancel := p.tabctx(ctx)
+		defer cancel()
+
+		if profile != nil {
+			if err := profile.apply(tctx, p.tab); err != nil {
+				return err
+			}
+		}
+
+		chain, err := fetch.Listen(tctx)
+		if err != nil {
+			return err
+		}
+
+		resp, err = p.navigate(tctx, job.URL)
+		if err != nil {
+			return navigateError(job.URL, err)
+		}
+		if resp != nil {
+			res.Status = resp.Status
+		}
+		res.Redirects = chain.Redirects()
+
+		if err := chromedp.Run(tctx, chromedp.Location(&res.URL), chromedp.Title(&res.Title)); err != nil {
+			return fmt.Errorf("page info: %w", err)
+		}
+		if err := p.srv.checkURL(ctx, res.URL); err != nil {
+			return err
+		}
+		if err := p.srv.checkResponse(res.URL, resp); err != nil {
+			return err
+		}
+		if opts.FailOnErrorStatus && res.Status >= 400 {
+			return &fetch.StatusError{URL: res.URL, Status: res.Status, Redirects: res.Redirects}
+		}
+
+		return nil
+	})
+	if err != nil && job.Salvage && ctx.Err() != nil {
+		slog.Debug("pipeline canceled, salvage the page", slog.String("url", p.env.Redact(job.URL)))
+		return p.salvage(res, job)
+	}
+	if err != nil {
+		p.srv.diagnose(p.tab.ctx, p.env, job.Op, err.Error())
+		return res, err
+	}
+
+	err = p.stage(runctx, StageWait, func(ctx context.Context) error {
+		tctx, cancel := p.tabctx(ctx)
+		defer cancel()
+
+		if err := profile.waitPage(tctx); err != nil {
+			return err
+		}
+		if opts.DismissConsent {
+			if _, err := fetch.DismissConsent(tctx, nil); err != nil {
+				return err
+			}
+		}
+
+		return fetch.Expand(tctx, opts.Clicks, opts.ClickWait)
+	})
+	if err != nil {
+		return res, err
+	}
+
+	var html string
+	err = p.stage(runctx, StageExtract, func(ctx context.Context) error {
+		tctx, cancel := p.tabctx(ctx)
+		defer cancel()
+
+		sel := opts.Selector
+		if sel == "" {
+			sel = "html"
+		}
+		var err error
+		if opts.Format != fetch.FormatDOM && opts.Sites && opts.Selector == "" {
+			// the generic extraction is the fallback.
+			if res.Site, err = fetch.Site(tctx); err != nil {
+				slog.Debug("page site", slog.String("url", p.env.Redact(job.URL)), slog.Any("err", err))
+			}
+		}
+		switch {
+		case opts.Format == fetch.FormatDOM:
+			res.DOM, err = fetch.DOM(tctx, sel, opts.Boilerplate)
+		case res.Site == nil:
+			html, err = fetch.HTML(tctx, sel)
+		}
+		if err != nil {
+			return err
+		}
+
+		// the metadata are optional.
+		if meta, err := fetch.Meta(tctx); err != nil {
+			slog.Debug("page meta", slog.String("url", p.env.Redact(job.URL)), slog.Any("err", err))
+		} else {
+			res.Description, res.Language = meta.Description, meta.Language
+		}
+		if res.Robots, err = fetch.ReadRobots(tctx, resp); err != nil {
+			slog.Debug("page robots", slog.String("url", p.env.Redact(job.URL)), slog.Any("err", err))
+		}
+		if res.Licenses, err = fetch.Licenses(tctx); err != nil {
+			slog.Debug("page licenses", slog.String("url", p.env.Redact(job.URL)), slog.Any("err", err))
+		}
+
+		// the jar keeps the session of the profile's sites.
+		if err := profile.saveCookies(tctx, res.URL); err != nil {
+			slog.Warn("profile cookies", slog.String("url", p.env.Redact(res.URL)), slog.Any("err", err))
+		}
+
+		if rec != nil {
+			for _, ex := range rec.Exchanges(tctx) {
+				if err := job.WARC.WriteExchange(ex); err != nil {
+					return fmt.Errorf("warc: %w", err)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		p.srv.diagnose(p.tab.ctx, p.env, job.Op, err.Error())
+		return res, err
+	}
+
+	err = p.stage(runctx, StageConvert, func(ctx context.Context) error {
+		// the DOM snapshot is already text.
+		md := res.DOM
+		if opts.Format != fetch.FormatDOM {
+			var err error
+			if res.Site != nil {
+				md = res.Site.Markdown()
+			} else {
+				html, err := fetch.RemoveBoilerplate(html, opts.Boilerplate)
+				if err != nil {
+					return err
+				}
+				if md, err = fetch.Convert(html); err != nil {
+					return err
+				}
+			}
+			// the images of the page are screened like the navigations.
+			images := opts.Images
+			images.Client = p.srv.httpClient()
+			if md, err = fetch.Images(ctx, md, res.URL, images); err != nil {
+				return err
+			}
+		}
+		if opts.Sanitize {
+			var n int
+			if md, n = fetch.Sanitize(md); n > 0 {
+				slog.Debug("invisible characters removed", slog.String("url", p.env.Redact(res.URL)), slog.Int("count", n))
+			}
+		}
+
+		md, err := p.srv.Filter.Check(ctx, res.URL, md)
+		if opts.Format == fetch.FormatDOM {
+			res.DOM = md
+			return err
+		}
+		if res.Language == "" {
+			res.Language = fetch.DetectLanguage(md)
+		}
+		if err == nil && job.Translate {
+			md, err = p.srv.Translator.Translate(ctx, md, res.Language)
+		}
+		res.Markdown = fetch.Truncate(md, opts.MaxContent, opts.Truncation)
+		return err
+	})
+	if err != nil {
+		return res, err
+	}
+	if p.failEmpty && strings.TrimSpace(res.Markdown) == "" && strings.TrimSpace(res.DOM) == "" {
+		return res, ErrEmptyContent
+	}
+
+	return res, p.emit(ctx, res, job)
+}
+
+// salvage stops the loading of the page and emits the content rendered so
+// far.
+func (p *pipeline) salvage(res fetch.Result, job PageJob) (fetch.Result, error) {
+	// the job's context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), salvageTimeout)
+	defer cancel()
+
+	err := p.stage(ctx, StageExtract, func(ctx context.Context) error {
+		tctx, cancel := p.tabctx(ctx)
+		defer cancel()
+
+		var err error
+		res, err = fetch.Salvage(tctx, res, job.Options.Selector, job.Options.Boilerplate)
+		return err
+	})
+	if err != nil {
+		return res, err
+	}
+
+	err = p.stage(ctx, StageConvert, func(ctx context.Context) error {
+		var err error
+		res.Markdown, err = p.srv.Filter.Check(ctx, res.URL, res.Markdown)
+		return err
+	})
+	if err != nil {
+		return res, err
+	}
+
+	return res, p.emit(ctx, res, job)
+}
+
+func (p *pipeline) emit(ctx context.Context, res fetch.Result, job PageJob) error {
+	if job.Emit == nil {
+		return nil
+	}
+
+	err := p.stage(ctx, StageEmit, func(context.Context) error {
+		return job.Emit(res)
+	})
+	if err != nil {
+		p.srv.diagnose(p.tab.ctx, p.env, job.Op, err.Error())
+	}
+
+	return err
+}
+
+// SearchPage runs the query in a tab checked out for the time of the search,
+// through the pipeline stages: resolve, checkout, navigate and extract.
+// The search is retried up to the server's Retries times if it fails with a
+// transient error.
+func (s *MCPServer) SearchPage(ctx context.Context, q search.Query) ([]search.Result, []StageTiming, error) {
+	env := envOf(ctx)
+	p := &pipeline{srv: s, target: env.Redact(q.Text), session: sessionOf(ctx), env: env}
+
+	res, err := cachedSearch(ctx, s.SearchCache, q, search.VerticalWeb, func() ([]search.Result, error) {
+		var res []search.Result
+		start := time.Now()
+		err := retry(ctx, "search "+q.Text, s.Retries, s.RetryBackoff, transient, func(int) error {
+			var err error
+			res, err = p.search(ctx, q)
+			return err
+		})
+		s.telemetry.search(q, search.VerticalWeb, time.Since(start))
+		return res, err
+	})
+
+	return res, p.timings, err
+}
+
+func (p *pipeline) search(ctx context.Context, q search.Query) ([]search.Result, error) {
+	var u string
+	err := p.stage(ctx, StageResolve, func(context.Context) error {
+		var err error
+		u, err = search.URL(q)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.checkout(ctx); err != nil {
+		return nil, err
+	}
+	defer p.release()
+
+	err = p.stage(ctx, StageNavigate, func(ctx context.Context) error {
+		tctx, cancel := p.tabctx(ctx)
+		defer cancel()
+
+		if _, err := p.navigate(tctx, u); err != nil {
+			return navigateError(u, err)
+		}
+		return nil
+	})
+	if err != nil {
+		p.srv.diagnose(p.tab.ctx, p.env, "search", err.Error())
+		return nil, fmt.Errorf("search %s: %w", q.Text, err)
+	}
+
+	var res []search.Result
+	err = p.stage(ctx, StageExtract, func(ctx context.Context) error {
+		tctx, cancel := p.tabctx(ctx)
+		defer cancel()
+
+		return chromedp.Run(tctx, search.Results(&res))
+	})
+	if err != nil {
+		p.srv.diagnose(p.tab.ctx, p.env, "search", err.Error())
+		return nil, fmt.Errorf("search %s: %w", q.Text, err)
+	}
+	if len(res) == 0 {
+		p.srv.diagnose(p.tab.ctx, p.env, "search", "no result for "+q.Text)
+	}
+
+	return res, nil
+}
+
+func handlePipelineStats(srv *MCPServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(srv.metrics.Snapshot()); err != nil {
+			slog.Error("pipeline stats encode", slog.Any("err", err))
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package browser resolves the websocket debugger urls of the browsers
+// speaking the Chrome DevTools Protocol.
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	resolveTimeout  = 2 * time.Second
+	resolveInterval = 200 * time.Millisecond
+	probeTimeout    = 500 * time.Millisecond
+
+	// StartTimeout is the time given to a local browser to start.
+	StartTimeout = 10 * time.Second
+
+	CacheDefaultTTL = 5 * time.Minute
+)
+
+type endpoint struct {
+	ws       string
+	cachedAt time.Time
+}
+
+// Endpoints caches the websocket debugger urls of the CDP hosts.
+type Endpoints struct {
+	sync.Mutex
+	urls map[string]endpoint
+	// lookups of the cache answered by a live cached url, or resolved.
+	hits, misses int
+
+	// Cached urls older than TTL are resolved again, 0 disables it.
+	TTL time.Duration
+}
+
+func NewEndpoints() *Endpoints {
+	return &Endpoints{
+		urls: make(map[string]endpoint),
+		TTL:  CacheDefaultTTL,
+	}
+}
+
+// Set caches the websocket url of the host.
+func (s *Endpoints) Set(host, ws string) {
+	s.Lock()
+	s.urls[host] = endpoint{ws: ws, cachedAt: time.Now()}
+	s.Unlock()
+}
+
+// Forget removes the cached url of the host. It returns false if the host
+// wasn't cached.
+func (s *Endpoints) Forget(host string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	_, ok := s.urls[host]
+	delete(s.urls, host)
+
+	return ok
+}
+
+// Entries returns the cached urls per host, the expired ones included.
+func (s *Endpoints) Entries() map[string]string {
+	s.Lock()
+	defer s.Unlock()
+
+	entries := make(map[string]string, len(s.urls))
+	for host, e := range s.urls {
+		entries[host] = e.ws
+	}
+
+	return entries
+}
+
+// cached returns the cached url of the host if it isn't expired.
+func (s *Endpoints) cached(host string) (string, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	e, ok := s.urls[host]
+	if !ok {
+		return "", false
+	}
+	if s.TTL > 0 && time.Since(e.cachedAt) > s.TTL {
+		delete(s.urls, host)
+		return "", false
+	}
+
+	return e.ws, true
+}
+
+func (s *Endpoints) count(hit bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	if hit {
+		s.hits++
+	} else {
+		s.misses++
+	}
+}
+
+// CacheStats returns the number of the resolutions answered by the cache and
+// of the ones requested to the hosts.
+func (s *Endpoints) CacheStats() (hits, misses int) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.hits, s.misses
+}
+
+// Resolve returns the websocket debugger url of the host. The url is
+// requested to the /json/version endpoint of the host if it isn't cached,
+// if the cached url is expired or if the host doesn't answer anymore.
+func (s *Endpoints) Resolve(ctx context.Context, host string) (string, error) {
+	if ws, ok := s.cached(host); ok {
+		if probe(ctx, host) {
+			s.count(true)
+			return ws, nil
+		}
+		slog.Debug("cdp host unreachable, resolve again", slog.String("host", host))
+		s.Forget(host)
+	}
+	s.count(false)
+
+	ws, err := jsonVersion(ctx, host)
+	if err != nil {
+		return "", err
+	}
+
+	s.Set(host, ws)
+
+	return ws, nil
+}
+
+// Wait resolves the websocket url of the host, retrying until the host
+// answers or the timeout expires.
+func (s *Endpoints) Wait(ctx context.Context, host string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(resolveInterval)
+	defer ticker.Stop()
+
+	for {
+		ws, err := s.Resolve(ctx, host)
+		if err == nil {
+			return ws, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("resolve %s: %w", host, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+var ErrNoDebuggerURL = errors.New("no websocket debugger url")
+
+// jsonVersion requests the websocket debugger url to the host.
+func jsonVersion(ctx context.Context, host string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/json/version", nil)
+	if err != nil {
+		return "", fmt.Errorf("create http req: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("do req: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bad status code: %d", resp.StatusCode)
+	}
+
+	var v struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return "", fmt.Errorf("decode version: %w", err)
+	}
+	if v.WebSocketDebuggerURL == "" {
+		return "", ErrNoDebuggerURL
+	}
+
+	return v.WebSocketDebuggerURL, nil
+}
+
+// probe returns true if the host accepts TCP connections.
+func probe(ctx context.Context, host string) bool {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+
+	return true
+}
+
+// Reachable returns true if at least one of the endpoints answers.
+func (s *Endpoints) Reachable(ctx context.Context, endpoints []string) bool {
+	for _, ws := range endpoints {
+		if _, err := s.Resolve(ctx, Host(ws)); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Host returns the host of the cdp websocket url.
+func Host(ws string) string {
+	u, err := url.Parse(ws)
+	if err != nil || u.Host == "" {
+		return ws
+	}
+
+	return u.Host
+}
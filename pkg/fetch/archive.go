@@ -0,0 +1,370 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// ArchiveFormat is the format of the archived pages.
+// It implements flag.Value.
+type ArchiveFormat string
+
+const (
+	// Single MHTML file captured by the browser.
+	ArchiveMHTML ArchiveFormat = "mhtml"
+	// Directory of the rendered HTML and its assets.
+	ArchiveDir ArchiveFormat = "dir"
+)
+
+var InvalidArchiveFormat = errors.New("invalid archive format, must be mhtml or dir")
+
+func (f ArchiveFormat) String() string {
+	return string(f)
+}
+
+func (f *ArchiveFormat) Set(v string) error {
+	switch ArchiveFormat(v) {
+	case ArchiveMHTML, ArchiveDir:
+		*f = ArchiveFormat(v)
+		return nil
+	}
+
+	return InvalidArchiveFormat
+}
+
+// Archive is an archived page.
+type Archive struct {
+	URL    string        `json:"url"`
+	Format ArchiveFormat `json:"format"`
+	// Path of the MHTML file or of the directory.
+	Path string `json:"path"`
+	// Size of the written files.
+	Size int64 `json:"size"`
+	// Number of the saved assets and of the assets not loaded by the page,
+	// they keep their remote url.
+	Assets  int `json:"assets,omitempty"`
+	Missing int `json:"missing,omitempty"`
+}
+
+const (
+	archiveIndex  = "index.html"
+	archiveAssets = "assets"
+)
+
+// ArchivePage saves the page loaded in the tab of the chromedp context ctx as
+// rendered, in a MHTML file or in a directory at out. The directory contains
+// the rendered HTML in index.html, without its scripts, and the assets loaded
+// by the page in assets/, the references are rewritten to the local copies.
+func ArchivePage(ctx context.Context, out string, f ArchiveFormat) (Archive, error) {
+	a := Archive{Format: f, Path: out}
+	if err := chromedp.Run(ctx, chromedp.Location(&a.URL)); err != nil {
+		return a, fmt.Errorf("page location: %w", err)
+	}
+
+	if f == ArchiveMHTML {
+		return a, archiveMHTML(ctx, &a)
+	}
+	return a, archiveDir(ctx, &a)
+}
+
+func archiveMHTML(ctx context.Context, a *Archive) error {
+	var data string
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		data, err = page.CaptureSnapshot().WithFormat(page.CaptureSnapshotFormatMhtml).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return fmt.Errorf("capture snapshot: %w", err)
+	}
+
+	if err := os.WriteFile(a.Path, []byte(data), 0644); err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+	a.Size = int64(len(data))
+
+	return nil
+}
+
+// cssURLRe matches the urls of the stylesheets.
+var cssURLRe = regexp.MustCompile(`url\(\s*(['"]?)([^'")]+)(['"]?)\s*\)`)
+
+// archiver saves the assets of a page from the browser's cache.
+type archiver struct {
+	ctx context.Context
+	dir string
+	a   *Archive
+	// frames of the resources loaded by the page.
+	resources map[string]cdp.FrameID
+	mimes     map[string]string
+	// names of the saved assets.
+	saved  map[string]string
+	failed map[string]bool
+}
+
+func archiveDir(ctx context.Context, a *Archive) error {
+	html, err := HTML(ctx, "html")
+	if err != nil {
+		return err
+	}
+
+	var tree *page.FrameResourceTree
+	err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		tree, err = page.GetResourceTree().Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return fmt.Errorf("resource tree: %w", err)
+	}
+
+	ar := &archiver{
+		ctx:       ctx,
+		dir:       a.Path,
+		a:         a,
+		resources: make(map[string]cdp.FrameID),
+		mimes:     make(map[string]string),
+		saved:     make(map[string]string),
+		failed:    make(map[string]bool),
+	}
+	ar.collect(tree)
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return fmt.Errorf("parse html: %w", err)
+	}
+
+	base, err := url.Parse(a.URL)
+	if err != nil {
+		return fmt.Errorf("parse url: %w", err)
+	}
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if u, err := base.Parse(href); err == nil {
+			base = u
+		}
+	}
+	doc.Find("base").Remove()
+
+	// the DOM is already rendered, the scripts would run again.
+	doc.Find(`script, link[rel~="preload"][as="script"], link[rel~="modulepreload"]`).Remove()
+	// the rewritten stylesheets don't match their hashes.
+	doc.Find("[integrity]").RemoveAttr("integrity")
+
+	if err := os.MkdirAll(filepath.Join(a.Path, archiveAssets), 0755); err != nil {
+		return fmt.Errorf("archive dir: %w", err)
+	}
+
+	prefix := archiveAssets + "/"
+	for _, r := range []struct{ sel, attr string }{
+		{"img[src], source[src], video[src], audio[src], track[src], embed[src], input[src]", "src"},
+		{`link[rel~="stylesheet"][href], link[rel~="icon"][href]`, "href"},
+		{"video[poster]", "poster"},
+		{"img[srcset], source[srcset]", "srcset"},
+	} {
+		doc.Find(r.sel).Each(func(_ int, s *goquery.Selection) {
+			v, _ := s.Attr(r.attr)
+			if r.attr == "srcset" {
+				s.SetAttr(r.attr, ar.srcset(base, v, prefix))
+				return
+			}
+			s.SetAttr(r.attr, ar.ref(base, v, prefix))
+		})
+	}
+	doc.Find("style").Each(func(_ int, s *goquery.Selection) {
+		s.SetText(ar.css(base, s.Text(), prefix))
+	})
+	doc.Find("[style]").Each(func(_ int, s *goquery.Selection) {
+		v, _ := s.Attr("style")
+		s.SetAttr("style", ar.css(base, v, prefix))
+	})
+
+	out, err := goquery.OuterHtml(doc.Find("html"))
+	if err != nil {
+		return fmt.Errorf("render html: %w", err)
+	}
+	out = "<!DOCTYPE html>\n" + out
+	if err := os.WriteFile(filepath.Join(a.Path, archiveIndex), []byte(out), 0644); err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+	a.Size += int64(len(out))
+	a.Path = filepath.Join(a.Path, archiveIndex)
+
+	return nil
+}
+
+// collect indexes the resources of the frame tree loaded by the page.
+func (ar *archiver) collect(tree *page.FrameResourceTree) {
+	if tree == nil {
+		return
+	}
+	for _, r := range tree.Resources {
+		if r.Failed || r.Canceled || r.Type == network.ResourceTypeDocument {
+			continue
+		}
+		ar.resources[r.URL] = tree.Frame.ID
+		ar.mimes[r.URL] = r.MimeType
+	}
+	for _, c := range tree.ChildFrames {
+		ar.collect(c)
+	}
+}
+
+// ref returns the local path of the asset referenced by v from base, prefixed
+// with prefix, or v if the asset isn't available.
+func (ar *archiver) ref(base *url.URL, v, prefix string) string {
+	u, err := base.Parse(strings.TrimSpace(v))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return v
+	}
+	u.Fragment = ""
+
+	name, ok := ar.save(u.String())
+	if !ok {
+		// the remote url stays usable from another directory.
+		return u.String()
+	}
+
+	return prefix + name
+}
+
+// srcset rewrites the urls of a srcset attribute.
+func (ar *archiver) srcset(base *url.URL, v, prefix string) string {
+	cands := strings.Split(v, ",")
+	for i, c := range cands {
+		fields := strings.Fields(c)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = ar.ref(base, fields[0], prefix)
+		cands[i] = strings.Join(fields, " ")
+	}
+
+	return strings.Join(cands, ", ")
+}
+
+// css rewrites the urls of the stylesheet.
+func (ar *archiver) css(base *url.URL, v, prefix string) string {
+	return cssURLRe.ReplaceAllStringFunc(v, func(m string) string {
+		sub := cssURLRe.FindStringSubmatch(m)
+		if strings.HasPrefix(sub[2], "data:") {
+			return m
+		}
+		return fmt.Sprintf("url(%q)", ar.ref(base, sub[2], prefix))
+	})
+}
+
+// save writes the asset of the url in the assets directory and returns its
+// name. It returns false if the page didn't load the asset.
+func (ar *archiver) save(u string) (string, bool) {
+	if name, ok := ar.saved[u]; ok {
+		return name, true
+	}
+	if ar.failed[u] {
+		return "", false
+	}
+	frame, ok := ar.resources[u]
+	if !ok {
+		ar.fail(u)
+		return "", false
+	}
+
+	var content []byte
+	err := chromedp.Run(ar.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		content, err = page.GetResourceContent(frame, u).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		slog.Debug("archive asset", slog.String("url", u), slog.Any("err", err))
+		ar.fail(u)
+		return "", false
+	}
+
+	mt := ar.mimes[u]
+	name := assetName(u, mt)
+	// the name is known before the nested assets, in case of cycle.
+	ar.saved[u] = name
+
+	if mt == "text/css" {
+		// the nested assets are next to the stylesheet.
+		if base, err := url.Parse(u); err == nil {
+			content = []byte(ar.css(base, string(content), ""))
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(ar.dir, archiveAssets, name), content, 0644); err != nil {
+		slog.Debug("archive asset", slog.String("url", u), slog.Any("err", err))
+		delete(ar.saved, u)
+		ar.fail(u)
+		return "", false
+	}
+	ar.a.Assets++
+	ar.a.Size += int64(len(content))
+
+	return name, true
+}
+
+func (ar *archiver) fail(u string) {
+	ar.failed[u] = true
+	ar.a.Missing++
+}
+
+// assetName returns the file name of the asset, a hash of its url with the
+// extension of its path, or of its mime type if the path's one is of another
+// kind, e.g. an image served by a php script.
+func assetName(u, mt string) string {
+	sum := sha256.Sum256([]byte(u))
+	name := hex.EncodeToString(sum[:8])
+
+	var ext string
+	if pu, err := url.Parse(u); err == nil {
+		ext = strings.ToLower(path.Ext(pu.Path))
+	}
+	if ext != "" && (mt == "" || sameKind(mime.TypeByExtension(ext), mt)) {
+		return name + ext
+	}
+	if exts, _ := mime.ExtensionsByType(mt); len(exts) > 0 {
+		return name + exts[0]
+	}
+
+	return name
+}
+
+// sameKind reports whether the mime types have the same top-level type, e.g.
+// image.
+func sameKind(a, b string) bool {
+	a, _, _ = strings.Cut(a, "/")
+	b, _, _ = strings.Cut(b, "/")
+	return a != "" && strings.EqualFold(a, b)
+}
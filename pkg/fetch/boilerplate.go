@@ -0,0 +1,90 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Boilerplate are the CSS selectors of the elements removed by default
+// before the extractions: the navigation, the footers, the cookie banners and
+// the share widgets.
+var Boilerplate = []string{
+	"nav",
+	"footer",
+	"[role=navigation]",
+	"[role=contentinfo]",
+	// cookie banners.
+	"#onetrust-consent-sdk",
+	"#CybotCookiebotDialog",
+	"#didomi-host",
+	".qc-cmp2-container",
+	".cc-window",
+	"[id*=cookie-banner]",
+	"[class*=cookie-banner]",
+	"[id*=cookie-consent]",
+	"[class*=cookie-consent]",
+	// share widgets.
+	".addthis_toolbox",
+	".a2a_kit",
+	".sharethis-inline-share-buttons",
+	"[class*=share-buttons]",
+	"[class*=social-share]",
+}
+
+// RemoveBoilerplate removes the elements matching the selectors from the
+// outer HTML of an element, as returned by HTML. The element itself is kept
+// even if it matches, e.g. when a footer is extracted on purpose.
+func RemoveBoilerplate(html string, sels []string) (string, error) {
+	if len(sels) == 0 {
+		return html, nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return "", fmt.Errorf("parse html: %w", err)
+	}
+
+	// the parser wraps the element in a document.
+	whole := strings.HasPrefix(strings.ToLower(strings.TrimSpace(html)), "<html")
+	root := doc.Find("body").Children()
+	if whole {
+		root = doc.Find("html")
+	}
+
+	n := 0
+	for _, sel := range sels {
+		s := root.Find(sel)
+		n += s.Length()
+		s.Remove()
+	}
+	if n == 0 {
+		return html, nil
+	}
+
+	if whole {
+		return goquery.OuterHtml(root)
+	}
+
+	res, err := doc.Find("body").Html()
+	if err != nil {
+		return "", fmt.Errorf("render html: %w", err)
+	}
+
+	return res, nil
+}
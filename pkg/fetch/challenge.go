@@ -0,0 +1,178 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/chromedp"
+)
+
+// ChallengeMaxText is the maximum size of the text of an interstitial
+// challenge page, the larger pages embed the CAPTCHA in their content, e.g. a
+// login form.
+const ChallengeMaxText = 2000
+
+// challengePoll is the interval of the checks of a challenge passing by
+// itself.
+const challengePoll = 500 * time.Millisecond
+
+// ChallengeRule detects the challenge page of a bot protection or a CAPTCHA.
+type ChallengeRule struct {
+	Name string `json:"name"`
+	// Selector of the challenge elements, the rule applies if it matches.
+	Detect string `json:"detect"`
+	// Prefixes of the titles of the challenge pages, in lower case. The
+	// rule applies if one matches, even without Detect.
+	Titles []string `json:"titles,omitempty"`
+	// The rule applies to the pages with less than ChallengeMaxText of
+	// text only.
+	Interstitial bool `json:"interstitial,omitempty"`
+	// Attribute of the challenge element giving the site key of the
+	// CAPTCHA.
+	SiteKey string `json:"siteKey,omitempty"`
+	// The challenge may pass by itself, e.g. the JavaScript checks of
+	// Cloudflare.
+	Passes bool `json:"passes,omitempty"`
+}
+
+// ChallengeRules are the built-in rules of the common bot protections and
+// CAPTCHAs.
+var ChallengeRules = []ChallengeRule{
+	{
+		Name:   "Cloudflare",
+		Detect: "#challenge-form, #challenge-running, #cf-challenge-running, #challenge-stage",
+		Titles: []string{"just a moment", "attention required! | cloudflare"},
+		Passes: true,
+	},
+	{
+		Name:   "DuckDuckGo",
+		Detect: ".anomaly-modal, .anomaly-modal__title, form[action*='anomaly']",
+	},
+	{
+		Name:   "DataDome",
+		Detect: "iframe[src*='captcha-delivery.com']",
+	},
+	{
+		Name:   "PerimeterX",
+		Detect: "#px-captcha",
+	},
+	{
+		Name:         "reCAPTCHA",
+		Detect:       ".g-recaptcha, iframe[src*='google.com/recaptcha'], iframe[src*='recaptcha.net']",
+		Interstitial: true,
+		SiteKey:      "data-sitekey",
+	},
+	{
+		Name:         "hCaptcha",
+		Detect:       ".h-captcha, iframe[src*='hcaptcha.com']",
+		Interstitial: true,
+		SiteKey:      "data-sitekey",
+	},
+	{
+		Name:         "Turnstile",
+		Detect:       ".cf-turnstile, iframe[src*='challenges.cloudflare.com']",
+		Interstitial: true,
+		SiteKey:      "data-sitekey",
+	},
+}
+
+// Challenge is a bot challenge detected in a page.
+type Challenge struct {
+	// Name of the matching rule.
+	Provider string `json:"provider"`
+	URL      string `json:"url"`
+	Title    string `json:"title"`
+	SiteKey  string `json:"siteKey,omitempty"`
+	// The challenge may pass by itself.
+	Passes bool `json:"passes,omitempty"`
+}
+
+// challengeJS returns the challenge matching the rules given in JSON, null if
+// none.
+const challengeJS = `((rules, max) => {
+	const title = document.title.trim();
+	const lower = title.toLowerCase();
+	const text = document.body === null ? '' : document.body.innerText.trim();
+	for (const r of rules) {
+		if (r.interstitial && text.length >= max) continue;
+		const e = r.detect ? document.querySelector(r.detect) : null;
+		if (e === null && !(r.titles || []).some((t) => lower.startsWith(t))) continue;
+		let key = '';
+		if (r.siteKey) {
+			const k = e !== null && e.hasAttribute(r.siteKey) ? e : document.querySelector('[' + r.siteKey + ']');
+			if (k !== null) key = k.getAttribute(r.siteKey);
+		}
+		return {provider: r.name, url: location.href, title: title, siteKey: key, passes: !!r.passes};
+	}
+	return null;
+})(%s, %d)`
+
+// DetectChallenge returns the challenge of the page loaded in the tab of the
+// chromedp context ctx matching the rules, ChallengeRules by default, nil if
+// the page isn't a challenge. A challenge passing by itself is given up to
+// wait to pass.
+func DetectChallenge(ctx context.Context, rules []ChallengeRule, wait time.Duration) (*Challenge, error) {
+	if rules == nil {
+		rules = ChallengeRules
+	}
+
+	b, err := json.Marshal(rules)
+	if err != nil {
+		return nil, fmt.Errorf("challenge rules: %w", err)
+	}
+	js := fmt.Sprintf(challengeJS, b, ChallengeMaxText)
+
+	deadline := time.Now().Add(wait)
+	for {
+		var ch *Challenge
+		if err := chromedp.Run(ctx, chromedp.Evaluate(js, &ch)); err != nil {
+			return nil, fmt.Errorf("detect challenge: %w", err)
+		}
+		if ch == nil || !ch.Passes || time.Now().After(deadline) {
+			return ch, nil
+		}
+
+		if err := chromedp.Run(ctx, chromedp.Sleep(challengePoll)); err != nil {
+			return nil, fmt.Errorf("detect challenge: %w", err)
+		}
+	}
+}
+
+// docChallenge returns the name of the rule matching the challenge page doc,
+// empty if none.
+func docChallenge(doc *goquery.Document, text int) string {
+	title := strings.ToLower(strings.TrimSpace(doc.Find("title").First().Text()))
+	for _, r := range ChallengeRules {
+		if r.Interstitial && text >= ChallengeMaxText {
+			continue
+		}
+		if doc.Find(r.Detect).Length() > 0 {
+			return r.Name
+		}
+		for _, t := range r.Titles {
+			if strings.HasPrefix(title, t) {
+				return r.Name
+			}
+		}
+	}
+
+	return ""
+}
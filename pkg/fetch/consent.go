@@ -0,0 +1,125 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ConsentRule dismisses the consent dialog of a consent management platform.
+type ConsentRule struct {
+	Name string `json:"name"`
+	// Selector of the dialog, the rule applies if it matches.
+	Detect string `json:"detect"`
+	// Selectors of the buttons closing the dialog, the first matching one is
+	// clicked. The reject buttons come first.
+	Buttons []string `json:"buttons"`
+	// Selector of the elements removed after the click, e.g. the dialog and
+	// its overlay.
+	Remove string `json:"remove"`
+}
+
+// ConsentRules are the built-in rules of the common consent management
+// platforms.
+var ConsentRules = []ConsentRule{
+	{
+		Name:    "OneTrust",
+		Detect:  "#onetrust-consent-sdk, #onetrust-banner-sdk",
+		Buttons: []string{"#onetrust-reject-all-handler", ".ot-pc-refuse-all-handler", "#onetrust-accept-btn-handler"},
+		Remove:  "#onetrust-consent-sdk, #onetrust-banner-sdk",
+	},
+	{
+		Name:   "Cookiebot",
+		Detect: "#CybotCookiebotDialog",
+		Buttons: []string{
+			"#CybotCookiebotDialogBodyButtonDecline",
+			"#CybotCookiebotDialogBodyLevelButtonLevelOptinDeclineAll",
+			"#CybotCookiebotDialogBodyLevelButtonLevelOptinAllowAll",
+			"#CybotCookiebotDialogBodyButtonAccept",
+		},
+		Remove: "#CybotCookiebotDialog, #CybotCookiebotDialogBodyUnderlay",
+	},
+	{
+		Name:    "Quantcast",
+		Detect:  ".qc-cmp2-container, #qc-cmp2-container",
+		Buttons: []string{".qc-cmp2-summary-buttons button[mode=secondary]", ".qc-cmp2-summary-buttons button[mode=primary]"},
+		Remove:  ".qc-cmp2-container, #qc-cmp2-container",
+	},
+	{
+		Name:    "Didomi",
+		Detect:  "#didomi-host",
+		Buttons: []string{"#didomi-notice-disagree-button", "#didomi-notice-agree-button"},
+		Remove:  "#didomi-host",
+	},
+	{
+		Name:    "TrustArc",
+		Detect:  "#truste-consent-track",
+		Buttons: []string{"#truste-consent-required", "#truste-consent-button"},
+		Remove:  "#truste-consent-track, .truste_overlay, .truste_box_overlay",
+	},
+}
+
+// consentJS applies the rules given in JSON and returns the names of the
+// dismissed dialogs. The dialogs often block the page scroll, it is restored.
+const consentJS = `((rules) => {
+	const res = [];
+	for (const r of rules) {
+		if (document.querySelector(r.detect) === null) continue;
+		for (const sel of r.buttons) {
+			const b = document.querySelector(sel);
+			if (b instanceof HTMLElement) {
+				b.click();
+				break;
+			}
+		}
+		document.querySelectorAll(r.remove).forEach((e) => e.remove());
+		res.push(r.name);
+	}
+	if (res.length > 0) {
+		for (const e of [document.documentElement, document.body]) {
+			if (e !== null) e.style.removeProperty('overflow');
+		}
+	}
+	return res;
+})(%s)`
+
+// DismissConsent closes the consent dialogs of the page loaded in the tab of
+// the chromedp context ctx matching the rules, ConsentRules by default. It
+// returns the names of the dismissed dialogs.
+func DismissConsent(ctx context.Context, rules []ConsentRule) ([]string, error) {
+	if rules == nil {
+		rules = ConsentRules
+	}
+
+	b, err := json.Marshal(rules)
+	if err != nil {
+		return nil, fmt.Errorf("consent rules: %w", err)
+	}
+
+	var names []string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(fmt.Sprintf(consentJS, b), &names)); err != nil {
+		return nil, fmt.Errorf("dismiss consent: %w", err)
+	}
+	if len(names) > 0 {
+		slog.Debug("consent dismissed", slog.Any("cmp", names))
+	}
+
+	return names, nil
+}
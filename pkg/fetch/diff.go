@@ -0,0 +1,165 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Number of unchanged lines around the changes of the diffs.
+	diffContext = 3
+	// Maximum size of the table comparing the changed lines, the larger
+	// changes are diffed as a whole replacement.
+	maxDiffCells = 1 << 22
+)
+
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// Diff returns the unified diff of the lines of a and b, labeled from and to,
+// empty if they are equal.
+func Diff(from, to, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	// line numbers of a and b before each op.
+	olds := make([]int, len(ops)+1)
+	news := make([]int, len(ops)+1)
+	for i, op := range ops {
+		olds[i+1], news[i+1] = olds[i], news[i]
+		if op.kind != '+' {
+			olds[i+1]++
+		}
+		if op.kind != '-' {
+			news[i+1]++
+		}
+	}
+
+	var w strings.Builder
+	fmt.Fprintf(&w, "--- %s\n+++ %s\n", from, to)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// the hunk merges the changes separated by less than two contexts.
+		last := i
+		for j := i + 1; j < len(ops) && j <= last+2*diffContext; j++ {
+			if ops[j].kind != ' ' {
+				last = j
+			}
+		}
+		start, end := max(0, i-diffContext), min(len(ops), last+diffContext+1)
+
+		fmt.Fprintf(&w, "@@ -%s +%s @@\n", hunkRange(olds[start], olds[end]), hunkRange(news[start], news[end]))
+		for _, op := range ops[start:end] {
+			w.WriteByte(op.kind)
+			w.WriteString(op.line)
+			w.WriteByte('\n')
+		}
+		i = end
+	}
+
+	return w.String()
+}
+
+// hunkRange returns the range of the lines from+1 to to of a hunk.
+func hunkRange(from, to int) string {
+	n := to - from
+	if n == 0 {
+		return fmt.Sprintf("%d,0", from)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", from+1)
+	}
+	return fmt.Sprintf("%d,%d", from+1, n)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the edit script of a into b, from their longest common
+// subsequence.
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+
+	// the common prefix and suffix are kept out of the table.
+	p := 0
+	for p < len(a) && p < len(b) && a[p] == b[p] {
+		ops = append(ops, diffOp{' ', a[p]})
+		p++
+	}
+	s := 0
+	for s < len(a)-p && s < len(b)-p && a[len(a)-1-s] == b[len(b)-1-s] {
+		s++
+	}
+	ma, mb := a[p:len(a)-s], b[p:len(b)-s]
+
+	n, m := len(ma), len(mb)
+	if n*m > maxDiffCells {
+		for _, l := range ma {
+			ops = append(ops, diffOp{'-', l})
+		}
+		for _, l := range mb {
+			ops = append(ops, diffOp{'+', l})
+		}
+	} else {
+		// lcs[i*(m+1)+j] is the length of the lcs of ma[i:] and mb[j:].
+		lcs := make([]int32, (n+1)*(m+1))
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+				} else {
+					lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && ma[i] == mb[j]:
+				ops = append(ops, diffOp{' ', ma[i]})
+				i++
+				j++
+			case j == m || (i < n && lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]):
+				ops = append(ops, diffOp{'-', ma[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', mb[j]})
+				j++
+			}
+		}
+	}
+
+	for _, l := range a[len(a)-s:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+
+	return ops
+}
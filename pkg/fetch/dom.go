@@ -0,0 +1,162 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Format is the output format of the extracted content.
+// It implements flag.Value.
+type Format string
+
+const (
+	// The content converted in markdown.
+	FormatMarkdown Format = "markdown"
+	// A simplified DOM with the interactive elements numbered, see DOM.
+	FormatDOM Format = "dom"
+)
+
+var InvalidFormat = errors.New("invalid format, use markdown or dom")
+
+func (f Format) String() string {
+	return string(f)
+}
+
+func (f *Format) Set(v string) error {
+	switch Format(v) {
+	case FormatMarkdown, FormatDOM:
+		*f = Format(v)
+		return nil
+	}
+
+	return InvalidFormat
+}
+
+// DOMIDAttr is the attribute set by DOM on the numbered elements.
+const DOMIDAttr = "data-gomcp-id"
+
+// ElementSelector returns the selector of the element numbered id by DOM.
+func ElementSelector(id int) string {
+	return fmt.Sprintf(`%s[%s="%d"]`, ShadowPrefix, DOMIDAttr, id)
+}
+
+// domJS returns the simplified DOM of the element matching the selector, or
+// null if it is missing. The visible texts are kept one per line, the
+// headings are prefixed with #, and the interactive elements are printed
+// with their main attributes and numbered [id]. The numbers are kept in the
+// elements, they are stable until the page is reloaded.
+const domJS = `((sel, boilerplate) => {
+` + queryJS + `
+const root = query(sel, document);
+if (root === null) return null;
+const interactive = 'a[href], button, input:not([type=hidden]), select, textarea, summary, [contenteditable=""], [contenteditable=true], ' +
+	'[role=button], [role=link], [role=checkbox], [role=radio], [role=switch], [role=tab], [role=menuitem], [role=option], [role=combobox], [role=textbox], [onclick]';
+const skip = new Set(['script', 'style', 'noscript', 'template', 'head', 'svg', 'iframe', 'object']);
+const attrs = ['href', 'type', 'name', 'placeholder', 'aria-label', 'title', 'alt', 'role'];
+const text = (s) => s.replace(/\s+/g, ' ').trim();
+const clip = (s) => s.length > 100 ? s.slice(0, 100) + '...' : s;
+const visible = (e) => {
+	const s = getComputedStyle(e);
+	if (s.display === 'contents') return true;
+	return s.display !== 'none' && s.visibility !== 'hidden' && e.getClientRects().length > 0;
+};
+const matches = (e) => boilerplate.some((b) => {
+	try {
+		return e.matches(b);
+	} catch {
+		return false;
+	}
+});
+let next = window.__gomcpNextID ?? 1;
+const lines = [];
+const element = (e) => {
+	let id = e.getAttribute('` + DOMIDAttr + `');
+	if (id === null) {
+		id = String(next++);
+		e.setAttribute('` + DOMIDAttr + `', id);
+	}
+	const tag = e.localName;
+	let s = '[' + id + ']<' + tag;
+	for (const a of attrs) {
+		const v = e.getAttribute(a);
+		if (v !== null && v !== '') s += ' ' + a + '="' + clip(text(v)) + '"';
+	}
+	if (tag === 'input' || tag === 'textarea' || tag === 'select') {
+		if (e.value) s += ' value="' + clip(text(e.value)) + '"';
+		if (e.checked) s += ' checked';
+	}
+	if (e.disabled) s += ' disabled';
+	if (tag === 'input') return s + '>';
+	return s + '>' + clip(text(e.innerText ?? e.textContent)) + '</' + tag + '>';
+};
+const walk = (n) => {
+	if (n.nodeType === Node.TEXT_NODE) {
+		const t = text(n.textContent);
+		if (t !== '') lines.push(t);
+		return;
+	}
+	if (n.nodeType !== Node.ELEMENT_NODE) return;
+	const tag = n.localName;
+	if (skip.has(tag)) return;
+	if (tag === 'slot') {
+		n.assignedNodes({flatten: true}).forEach(walk);
+		return;
+	}
+	if (!visible(n) || (n !== root && matches(n))) return;
+	if (n.matches(interactive)) {
+		lines.push(element(n));
+		return;
+	}
+	if (/^h[1-6]$/.test(tag)) {
+		const t = text(n.innerText ?? n.textContent);
+		if (t !== '') lines.push('#'.repeat(+tag[1]) + ' ' + t);
+		return;
+	}
+	(n.shadowRoot ?? n).childNodes.forEach(walk);
+};
+walk(root);
+window.__gomcpNextID = next;
+return lines.join('\n');
+})(%q, %s)`
+
+// DOM returns the simplified DOM of the node matching sel in the page loaded
+// in the tab of the chromedp context ctx, without the elements matching the
+// boilerplate selectors. The interactive elements are numbered [id], select
+// them with ElementSelector(id) in the following actions.
+func DOM(ctx context.Context, sel string, boilerplate []string) (string, error) {
+	b, err := json.Marshal(boilerplate)
+	if err != nil {
+		return "", fmt.Errorf("boilerplate: %w", err)
+	}
+	if boilerplate == nil {
+		b = []byte("[]")
+	}
+
+	var dom *string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(fmt.Sprintf(domJS, sel, b), &dom)); err != nil {
+		return "", fmt.Errorf("dom snapshot: %w", err)
+	}
+	if dom == nil {
+		return "", fmt.Errorf("dom snapshot: no element matching %s", sel)
+	}
+
+	return *dom, nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+)
+
+// Download is a file downloaded by the browser.
+type Download struct {
+	// URL of the downloaded resource.
+	URL string `json:"url"`
+	// Path of the saved file.
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+var ErrDownloadCanceled = errors.New("download canceled")
+
+type downloadDone struct {
+	guid string
+	err  error
+}
+
+// DownloadFile triggers a file download in the tab of the chromedp context
+// ctx and waits for its completion. The download is triggered by the
+// navigation to u, or by a click on the element matching sel in the current
+// page if u is empty. The file is saved in dir with its suggested name.
+func DownloadFile(ctx context.Context, u, sel, dir string) (Download, error) {
+	var res Download
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return res, fmt.Errorf("download dir: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return res, fmt.Errorf("download dir: %w", err)
+	}
+
+	// the downloads are reported by the browser target.
+	lctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var guid, name string
+	begun := make(chan struct{})
+	done := make(chan downloadDone, 1)
+	chromedp.ListenBrowser(lctx, func(ev any) {
+		switch ev := ev.(type) {
+		case *browser.EventDownloadWillBegin:
+			if guid != "" {
+				return
+			}
+			guid, name, res.URL = ev.GUID, ev.SuggestedFilename, ev.URL
+			close(begun)
+		case *browser.EventDownloadProgress:
+			if ev.GUID != guid {
+				return
+			}
+			switch ev.State {
+			case browser.DownloadProgressStateCompleted:
+				done <- downloadDone{guid: ev.GUID}
+			case browser.DownloadProgressStateCanceled:
+				done <- downloadDone{guid: ev.GUID, err: ErrDownloadCanceled}
+			}
+		}
+	})
+
+	// the files are saved under their guid, then renamed.
+	err = chromedp.Run(ctx, downloadBehavior(browser.SetDownloadBehaviorBehaviorAllowAndName, dir))
+	if err != nil {
+		return res, fmt.Errorf("download behavior: %w", err)
+	}
+	defer func() {
+		// the tab returns to the pool, the downloads are denied again.
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), downloadResetTimeout)
+		defer cancel()
+		chromedp.Run(rctx, downloadBehavior(browser.SetDownloadBehaviorBehaviorDefault, "")) // nolint:errcheck
+	}()
+
+	if u != "" {
+		// a navigation turning into a download is aborted.
+		err = chromedp.Run(ctx, chromedp.Navigate(u))
+		if err != nil && !strings.Contains(err.Error(), "net::ERR_ABORTED") {
+			return res, fmt.Errorf("navigate %s: %w", u, err)
+		}
+	} else {
+		expr, by := Query(sel)
+		if err := chromedp.Run(ctx, chromedp.Click(expr, by, chromedp.NodeVisible)); err != nil {
+			return res, fmt.Errorf("click %s: %w", sel, err)
+		}
+	}
+
+	select {
+	case <-begun:
+	case <-ctx.Done():
+		return res, fmt.Errorf("no download started: %w", ctx.Err())
+	}
+
+	var d downloadDone
+	select {
+	case d = <-done:
+	case <-ctx.Done():
+		return res, fmt.Errorf("download %s: %w", res.URL, ctx.Err())
+	}
+	if d.err != nil {
+		return res, fmt.Errorf("download %s: %w", res.URL, d.err)
+	}
+
+	res.Path, err = rename(filepath.Join(dir, d.guid), dir, name)
+	if err != nil {
+		return res, err
+	}
+	res.Size, res.SHA256, err = checksum(res.Path)
+
+	return res, err
+}
+
+const downloadResetTimeout = 5 * time.Second
+
+func downloadBehavior(b browser.SetDownloadBehaviorBehavior, dir string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		c := chromedp.FromContext(ctx)
+		p := browser.SetDownloadBehavior(b).WithEventsEnabled(b != browser.SetDownloadBehaviorBehaviorDefault)
+		if dir != "" {
+			p = p.WithDownloadPath(dir)
+		}
+		return p.Do(cdp.WithExecutor(ctx, c.Browser))
+	})
+}
+
+// rename moves the file to its suggested name in dir without overwriting an
+// existing file.
+func rename(src, dir, name string) (string, error) {
+	name = filepath.Base(filepath.Clean("/" + name))
+	if name == "/" || name == "." {
+		name = filepath.Base(src)
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	dst := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(dst); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		dst = filepath.Join(dir, base+"-"+strconv.Itoa(i)+ext)
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		return "", fmt.Errorf("rename download: %w", err)
+	}
+
+	return dst, nil
+}
+
+// checksum returns the size and the SHA-256 of the file.
+func checksum(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("checksum: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", fmt.Errorf("checksum: %w", err)
+	}
+
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,305 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fetch loads the web pages in a browser tab and extracts their
+// content and metadata.
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// Options of Page.
+type Options struct {
+	// Selector of the element converted in markdown, the whole page by
+	// default. The selectors prefixed with XPathPrefix are XPath
+	// expressions.
+	Selector string
+	// Timeout of the page load and extraction, 0 means no timeout.
+	Timeout time.Duration
+	// Return a StatusError instead of the content of the 4xx and 5xx pages.
+	FailOnErrorStatus bool
+	// Selectors of the elements clicked before the extraction, e.g. read
+	// more or expand buttons, CSS or XPath.
+	Clicks []string
+	// Wait after each click, ClickDefaultWait by default.
+	ClickWait time.Duration
+	// Close the consent dialogs of the known platforms before the clicks.
+	DismissConsent bool
+	// Extract the pages of the known sites with their SiteExtractors, unless
+	// a Selector is given.
+	Sites bool
+	// Processing of the images of the markdown.
+	Images ImageOptions
+	// Remove the invisible characters of the markdown, see Sanitize.
+	Sanitize bool
+	// CSS selectors of the elements removed before the extraction, e.g.
+	// Boilerplate.
+	Boilerplate []string
+	// Format of the content, markdown by default.
+	Format Format
+	// Maximum size of the markdown in bytes, 0 means no limit. The larger
+	// markdown is cut with Truncation, see Truncate.
+	MaxContent int
+	Truncation Truncation
+}
+
+const ClickDefaultWait = 500 * time.Millisecond
+
+// Result is a fetched page.
+type Result struct {
+	// URL of the page after the redirections.
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	// Language declared by the page or detected from its content.
+	Language string `json:"language,omitempty"`
+	// Final HTTP status.
+	Status    int64      `json:"status"`
+	Redirects []Redirect `json:"redirects,omitempty"`
+	Markdown  string     `json:"markdown"`
+	// Content of the page of a known site, see Site.
+	Site *SiteDoc `json:"site,omitempty"`
+	// Simplified DOM, instead of the markdown with FormatDOM.
+	DOM      string    `json:"dom,omitempty"`
+	Robots   Robots    `json:"robots"`
+	Licenses []License `json:"licenses,omitempty"`
+	// The loading was stopped, the content is the one rendered so far.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// Page loads the url in the tab of the chromedp context ctx and returns its
+// content in markdown with its metadata. The page stays loaded in the tab.
+func Page(ctx context.Context, url string, opts Options) (Result, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	var res Result
+
+	// the listener stops with the page.
+	lctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	chain, err := Listen(lctx)
+	if err != nil {
+		return res, err
+	}
+
+	resp, err := chromedp.RunResponse(ctx, chromedp.Navigate(url))
+	if err != nil {
+		return res, fmt.Errorf("navigate %s: %w", url, err)
+	}
+	if resp != nil {
+		res.Status = resp.Status
+	}
+	res.Redirects = chain.Redirects()
+
+	if err := chromedp.Run(ctx, chromedp.Location(&res.URL), chromedp.Title(&res.Title)); err != nil {
+		return res, fmt.Errorf("page info: %w", err)
+	}
+
+	if opts.FailOnErrorStatus && res.Status >= 400 {
+		return res, &StatusError{URL: res.URL, Status: res.Status, Redirects: res.Redirects}
+	}
+
+	if opts.DismissConsent {
+		if _, err := DismissConsent(ctx, nil); err != nil {
+			return res, err
+		}
+	}
+	if err := Expand(ctx, opts.Clicks, opts.ClickWait); err != nil {
+		return res, err
+	}
+
+	sel := opts.Selector
+	if sel == "" {
+		sel = "html"
+	}
+	if opts.Format == FormatDOM {
+		if res.DOM, err = DOM(ctx, sel, opts.Boilerplate); err != nil {
+			return res, err
+		}
+		if opts.Sanitize {
+			res.DOM, _ = Sanitize(res.DOM)
+		}
+	} else {
+		if opts.Sites && opts.Selector == "" {
+			if res.Site, err = Site(ctx); err != nil {
+				slog.Debug("page site", slog.String("url", url), slog.Any("err", err))
+			}
+		}
+		if res.Site != nil {
+			res.Markdown = res.Site.Markdown()
+		} else if res.Markdown, err = Markdown(ctx, sel, opts.Boilerplate); err != nil {
+			return res, err
+		}
+		if res.Markdown, err = Images(ctx, res.Markdown, res.URL, opts.Images); err != nil {
+			return res, err
+		}
+		if opts.Sanitize {
+			res.Markdown, _ = Sanitize(res.Markdown)
+		}
+		res.Markdown = Truncate(res.Markdown, opts.MaxContent, opts.Truncation)
+	}
+
+	// the metadata are optional.
+	if meta, err := Meta(ctx); err != nil {
+		slog.Debug("page meta", slog.String("url", url), slog.Any("err", err))
+	} else {
+		res.Description, res.Language = meta.Description, meta.Language
+	}
+	if res.Language == "" {
+		res.Language = DetectLanguage(res.Markdown)
+	}
+	if res.Robots, err = ReadRobots(ctx, resp); err != nil {
+		slog.Debug("page robots", slog.String("url", url), slog.Any("err", err))
+	}
+	if res.Licenses, err = Licenses(ctx); err != nil {
+		slog.Debug("page licenses", slog.String("url", url), slog.Any("err", err))
+	}
+
+	return res, nil
+}
+
+// clickJS clicks the elements matching the selector and returns their count.
+const clickJS = `(() => {
+` + queryJS + `
+return queryAll(%q, document).filter((e) => {
+	if (!(e instanceof HTMLElement)) return false;
+	e.click();
+	return true;
+}).length;
+})()`
+
+// Expand clicks the elements matching each selector in order, waiting wait
+// after the clicks, e.g. to reveal truncated articles. The missing elements
+// are ignored.
+func Expand(ctx context.Context, sels []string, wait time.Duration) error {
+	if wait <= 0 {
+		wait = ClickDefaultWait
+	}
+
+	for _, sel := range sels {
+		var n int
+		if err := chromedp.Run(ctx, chromedp.Evaluate(fmt.Sprintf(clickJS, sel), &n)); err != nil {
+			return fmt.Errorf("click %s: %w", sel, err)
+		}
+		if n == 0 {
+			slog.Debug("click: no element", slog.String("selector", sel))
+			continue
+		}
+
+		slog.Debug("click", slog.String("selector", sel), slog.Int("count", n))
+		if err := chromedp.Run(ctx, chromedp.Sleep(wait)); err != nil {
+			return fmt.Errorf("click %s: %w", sel, err)
+		}
+	}
+
+	return nil
+}
+
+// Salvage stops the loading of the page in the tab of the chromedp context
+// ctx and returns the content rendered so far, e.g. after the interruption of
+// Page. res is the result returned by Page, it is completed and marked
+// partial. The elements matching the boilerplate selectors are removed.
+func Salvage(ctx context.Context, res Result, sel string, boilerplate []string) (Result, error) {
+	res.Partial = true
+
+	if err := chromedp.Run(ctx, page.StopLoading()); err != nil {
+		return res, fmt.Errorf("stop loading: %w", err)
+	}
+
+	if err := chromedp.Run(ctx, chromedp.Location(&res.URL), chromedp.Title(&res.Title)); err != nil {
+		return res, fmt.Errorf("page info: %w", err)
+	}
+
+	if sel == "" {
+		sel = "html"
+	}
+	// the element may not be rendered yet.
+	var ok bool
+	if err := chromedp.Run(ctx, chromedp.Evaluate(fmt.Sprintf("(() => {\n"+queryJS+"\nreturn query(%q, document) !== null;\n})()", sel), &ok)); err != nil {
+		return res, fmt.Errorf("query %s: %w", sel, err)
+	}
+	if !ok {
+		return res, nil
+	}
+
+	md, err := Markdown(ctx, sel, boilerplate)
+	if err != nil {
+		return res, err
+	}
+	res.Markdown = md
+
+	return res, nil
+}
+
+// Markdown returns the node matching sel converted in markdown, without the
+// elements matching the boilerplate selectors. sel is a CSS selector or an
+// XPath expression prefixed with XPathPrefix.
+func Markdown(ctx context.Context, sel string, boilerplate []string) (string, error) {
+	html, err := HTML(ctx, sel)
+	if err != nil {
+		return "", err
+	}
+	if html, err = RemoveBoilerplate(html, boilerplate); err != nil {
+		return "", err
+	}
+
+	return Convert(html)
+}
+
+// HTML returns the outer HTML of the node matching sel, with the content of
+// its open shadow roots. sel is a CSS selector, an XPath expression prefixed
+// with XPathPrefix or a CSS selector piercing the shadow roots prefixed with
+// ShadowPrefix.
+func HTML(ctx context.Context, sel string) (string, error) {
+	var html string
+	expr, by := Query(sel)
+	err := chromedp.Run(ctx, chromedp.OuterHTML(expr, &html, by))
+	if err != nil {
+		return "", fmt.Errorf("outerHTML: %w", err)
+	}
+
+	// outerHTML doesn't serialize the shadow roots.
+	var flat string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(fmt.Sprintf(flattenJS, sel), &flat)); err != nil {
+		return "", fmt.Errorf("flatten shadow roots: %w", err)
+	}
+	if flat != "" {
+		return flat, nil
+	}
+
+	return html, nil
+}
+
+// Convert converts the HTML in markdown.
+func Convert(html string) (string, error) {
+	converter := md.NewConverter("", true, nil)
+	content, err := converter.ConvertString(html)
+	if err != nil {
+		return "", fmt.Errorf("The document has been converted to markdown: %w", err)
+	}
+
+	return content, nil
+}
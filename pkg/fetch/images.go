@@ -0,0 +1,233 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ImagePolicy is the processing of the images of the markdown.
+// It implements flag.Value.
+type ImagePolicy string
+
+const (
+	// Keep the image links.
+	ImagesKeep ImagePolicy = "keep"
+	// Download the images in a directory and link them relatively.
+	ImagesDownload ImagePolicy = "download"
+	// Inline the small images as data URIs.
+	ImagesInline ImagePolicy = "inline"
+	// Remove the images.
+	ImagesStrip ImagePolicy = "strip"
+)
+
+var InvalidImagePolicy = errors.New("invalid image policy, use keep, download, inline or strip")
+
+func (p ImagePolicy) String() string {
+	return string(p)
+}
+
+func (p *ImagePolicy) Set(v string) error {
+	switch ImagePolicy(v) {
+	case ImagesKeep, ImagesDownload, ImagesInline, ImagesStrip:
+		*p = ImagePolicy(v)
+		return nil
+	}
+
+	return InvalidImagePolicy
+}
+
+const (
+	// Maximum size of the inlined images.
+	ImageDefaultInlineMax = 32 << 10
+	// Maximum size of the downloaded images.
+	imageMaxSize = 20 << 20
+	imageTimeout = 10 * time.Second
+	// Maximum number of images downloaded per page.
+	imageMaxCount = 100
+)
+
+// ImageOptions are the options of Images.
+type ImageOptions struct {
+	Policy ImagePolicy
+	// Directory of the downloaded images, the links are relative to the
+	// current directory.
+	Dir string
+	// Maximum size of the inlined images, the bigger ones keep their link.
+	// ImageDefaultInlineMax by default.
+	InlineMax int
+	// Client downloading the images, http.DefaultClient by default.
+	Client *http.Client
+}
+
+var (
+	// imageRe matches the markdown images: ![alt](src "title").
+	imageRe = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(\s+"[^"]*")?\)`)
+	// emptyLinkRe matches the links left empty by the stripped images.
+	emptyLinkRe = regexp.MustCompile(`\[\s*\]\([^)]*\)`)
+)
+
+// Images processes the images of the markdown md of the page at base
+// following the policy. The relative sources are resolved against base. The
+// images failing to download keep their absolute link.
+func Images(ctx context.Context, md, base string, opts ImageOptions) (string, error) {
+	switch opts.Policy {
+	case "", ImagesKeep:
+		return md, nil
+	case ImagesStrip:
+		md = imageRe.ReplaceAllString(md, "")
+		return emptyLinkRe.ReplaceAllString(md, ""), nil
+	case ImagesDownload:
+		if opts.Dir == "" {
+			return md, errors.New("images: no download directory")
+		}
+		if err := os.MkdirAll(opts.Dir, 0775); err != nil {
+			return md, fmt.Errorf("images: %w", err)
+		}
+	}
+	if opts.InlineMax <= 0 {
+		opts.InlineMax = ImageDefaultInlineMax
+	}
+
+	bu, err := url.Parse(base)
+	if err != nil {
+		return md, fmt.Errorf("images: parse base url: %w", err)
+	}
+
+	// the same image is fetched once.
+	done := map[string]string{}
+	count := 0
+	md = imageRe.ReplaceAllStringFunc(md, func(m string) string {
+		sub := imageRe.FindStringSubmatch(m)
+		alt, src, title := sub[1], sub[2], sub[3]
+		if strings.HasPrefix(src, "data:") {
+			return m
+		}
+
+		u, err := bu.Parse(src)
+		if err != nil {
+			return m
+		}
+		abs := u.String()
+
+		dst, ok := done[abs]
+		if !ok {
+			dst = abs
+			if count < imageMaxCount {
+				count++
+				if dst, err = fetchImage(ctx, abs, opts); err != nil {
+					slog.Debug("image", slog.String("url", abs), slog.Any("err", err))
+					dst = abs
+				}
+			}
+			done[abs] = dst
+		}
+
+		return "![" + alt + "](" + dst + title + ")"
+	})
+
+	return md, ctx.Err()
+}
+
+// fetchImage downloads the image at u and returns its new source.
+func fetchImage(ctx context.Context, u string, opts ImageOptions) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, imageTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", fmt.Errorf("create http req: %w", err)
+	}
+
+	cli := opts.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("do req: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bad status code: %d", resp.StatusCode)
+	}
+
+	limit := imageMaxSize
+	if opts.Policy == ImagesInline {
+		limit = opts.InlineMax
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return "", fmt.Errorf("read: %w", err)
+	}
+	if len(b) > limit {
+		return "", fmt.Errorf("image bigger than %d bytes", limit)
+	}
+
+	ctype := resp.Header.Get("Content-Type")
+	if ctype == "" {
+		ctype = http.DetectContentType(b)
+	}
+	if mt, _, err := mime.ParseMediaType(ctype); err == nil {
+		ctype = mt
+	}
+	if !strings.HasPrefix(ctype, "image/") {
+		return "", fmt.Errorf("not an image: %s", ctype)
+	}
+
+	if opts.Policy == ImagesInline {
+		return "data:" + ctype + ";base64," + base64.StdEncoding.EncodeToString(b), nil
+	}
+
+	sum := sha256.Sum256([]byte(u))
+	name := hex.EncodeToString(sum[:8]) + imageExt(u, ctype)
+	p := filepath.Join(opts.Dir, name)
+	if err := os.WriteFile(p, b, 0644); err != nil {
+		return "", fmt.Errorf("write: %w", err)
+	}
+
+	return filepath.ToSlash(p), nil
+}
+
+// imageExt returns the extension of the image, from its url or its type.
+func imageExt(u, ctype string) string {
+	if pu, err := url.Parse(u); err == nil {
+		if ext := strings.ToLower(path.Ext(pu.Path)); ext != "" && len(ext) <= 5 {
+			return ext
+		}
+	}
+	if exts, err := mime.ExtensionsByType(ctype); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,143 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopwords are frequent words of the languages written in latin script.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "it", "for", "with", "was", "on", "are", "this", "you"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "pour", "dans", "que", "qui", "pas", "sur", "du", "avec"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ein", "eine", "zu", "mit", "den", "auf", "für", "sich", "auch"},
+	"es": {"el", "los", "las", "y", "es", "una", "por", "para", "con", "que", "del", "se", "como", "pero", "más"},
+	"it": {"il", "gli", "e", "è", "di", "che", "una", "per", "con", "non", "sono", "della", "nel", "anche", "come"},
+	"pt": {"os", "as", "e", "é", "uma", "para", "com", "não", "que", "do", "da", "em", "mais", "como", "são"},
+	"nl": {"de", "het", "een", "en", "van", "is", "niet", "dat", "op", "te", "zijn", "voor", "met", "ook", "maar"},
+}
+
+// Minimum number of stopwords found to detect a latin language.
+const minStopwords = 5
+
+// DetectLanguage returns the ISO 639-1 code of the language of the text, from
+// its script or its frequent words, empty if it is unknown.
+func DetectLanguage(text string) string {
+	if lang := detectScript(text); lang != "" {
+		return lang
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	// the beginning of the text is enough.
+	words = words[:min(len(words), 2000)]
+
+	count := make(map[string]int, len(words))
+	for _, w := range words {
+		count[w]++
+	}
+
+	best, score := "", 0
+	for lang, sw := range stopwords {
+		n := 0
+		for _, w := range sw {
+			n += count[w]
+		}
+		if n > score || (n == score && lang < best) {
+			best, score = lang, n
+		}
+	}
+	if score < minStopwords {
+		return ""
+	}
+
+	return best
+}
+
+// detectScript returns the language of the text written in a non latin
+// script, empty for the latin scripts.
+func detectScript(text string) string {
+	var letters, kana, hangul, han, cyrillic, ukrainian, arabic, hebrew, greek, thai, devanagari int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				ukrainian++
+			}
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Hebrew, r):
+			hebrew++
+		case unicode.Is(unicode.Greek, r):
+			greek++
+		case unicode.Is(unicode.Thai, r):
+			thai++
+		case unicode.Is(unicode.Devanagari, r):
+			devanagari++
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// a script is the language's one if it writes a third of the letters.
+	major := func(n int) bool { return n*3 >= letters }
+	switch {
+	case kana > 0 && major(kana+han):
+		return "ja"
+	case major(hangul):
+		return "ko"
+	case major(han):
+		return "zh"
+	case major(cyrillic):
+		if ukrainian > 0 {
+			return "uk"
+		}
+		return "ru"
+	case major(arabic):
+		return "ar"
+	case major(hebrew):
+		return "he"
+	case major(greek):
+		return "el"
+	case major(thai):
+		return "th"
+	case major(devanagari):
+		return "hi"
+	}
+
+	return ""
+}
+
+// PrimaryLanguage returns the primary subtag of the language tag, in lower
+// case, e.g. en for en-US.
+func PrimaryLanguage(tag string) string {
+	tag, _, _ = strings.Cut(strings.TrimSpace(tag), "-")
+	tag, _, _ = strings.Cut(tag, "_")
+	return strings.ToLower(tag)
+}
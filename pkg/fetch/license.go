@@ -0,0 +1,150 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// License is a license signal found in a page.
+type License struct {
+	// Value is the license url, or its text if the page gives no url.
+	Value string `json:"value"`
+	// Name is the short name of the known licenses, e.g. CC BY-SA 4.0.
+	Name string `json:"name,omitempty"`
+	// Sources are the markups declaring the license: rel=license,
+	// cc:license, dcterms or schema.org.
+	Sources []string `json:"sources"`
+}
+
+func (l License) String() string {
+	s := l.Value
+	if l.Name != "" {
+		s = l.Name + " <" + l.Value + ">"
+	}
+
+	return s + " (" + strings.Join(l.Sources, ", ") + ")"
+}
+
+// licensesJS returns the license signals of the page as value and source
+// pairs.
+const licensesJS = `(() => {
+	const res = [];
+	const add = (v, src) => {
+		if (typeof v === 'string' && v.trim() !== '') res.push([v.trim(), src]);
+	};
+	const val = (e) => e.getAttribute('href') || e.getAttribute('resource') || e.getAttribute('content') || e.textContent;
+
+	document.querySelectorAll('link[rel~=license i], a[rel~=license i], area[rel~=license i]').forEach((e) => add(e.href, 'rel=license'));
+	document.querySelectorAll('[rel~="cc:license"], [property~="cc:license"]').forEach((e) => add(val(e), 'cc:license'));
+	document.querySelectorAll('meta[name="dcterms.license" i], meta[name="dc.rights" i], meta[name="dcterms.rights" i]').forEach((e) => add(e.content, 'dcterms'));
+	document.querySelectorAll('[itemprop~=license]').forEach((e) => add(val(e), 'schema.org'));
+
+	const walk = (o) => {
+		if (Array.isArray(o)) return o.forEach(walk);
+		if (o === null || typeof o !== 'object') return;
+		for (const [k, v] of Object.entries(o)) {
+			if (k === 'license') {
+				[].concat(v).forEach((l) => add(typeof l === 'object' && l !== null ? (l.url || l['@id'] || l.name) : l, 'schema.org'));
+				continue;
+			}
+			walk(v);
+		}
+	};
+	document.querySelectorAll('script[type="application/ld+json"]').forEach((s) => {
+		try { walk(JSON.parse(s.textContent)); } catch (e) {}
+	});
+
+	return res;
+})()`
+
+// Licenses returns the license signals of the page loaded in the tab of the
+// chromedp context ctx.
+func Licenses(ctx context.Context) ([]License, error) {
+	var found [][2]string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(licensesJS, &found)); err != nil {
+		return nil, fmt.Errorf("page licenses: %w", err)
+	}
+
+	var res []License
+	for _, f := range found {
+		value, src := f[0], f[1]
+		i := slices.IndexFunc(res, func(l License) bool { return l.Value == value })
+		if i < 0 {
+			res = append(res, License{Value: value, Name: licenseName(value)})
+			i = len(res) - 1
+		}
+		if !slices.Contains(res[i].Sources, src) {
+			res[i].Sources = append(res[i].Sources, src)
+		}
+	}
+
+	return res, nil
+}
+
+// licenseName returns the short name of the Creative Commons licenses urls,
+// e.g. https://creativecommons.org/licenses/by-sa/4.0/ is CC BY-SA 4.0.
+func licenseName(v string) string {
+	u, err := url.Parse(v)
+	if err != nil {
+		return ""
+	}
+	if h := u.Hostname(); h != "creativecommons.org" && !strings.HasSuffix(h, ".creativecommons.org") {
+		return ""
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 {
+		return ""
+	}
+
+	var name string
+	switch {
+	case parts[0] == "licenses":
+		name = "CC " + strings.ToUpper(parts[1])
+	case parts[0] == "publicdomain" && parts[1] == "zero":
+		name = "CC0"
+	case parts[0] == "publicdomain" && parts[1] == "mark":
+		name = "Public Domain Mark"
+	default:
+		return ""
+	}
+	if len(parts) > 2 {
+		name += " " + parts[2]
+	}
+
+	return name
+}
+
+// FormatLicenses returns the licenses on one line, or an empty string if
+// there is none.
+func FormatLicenses(licenses []License) string {
+	if len(licenses) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(licenses))
+	for _, l := range licenses {
+		parts = append(parts, l.String())
+	}
+
+	return "License: " + strings.Join(parts, "; ")
+}
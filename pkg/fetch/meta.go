@@ -0,0 +1,86 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"gopkg.in/yaml.v3"
+)
+
+// metaJS returns the description and the declared language of the page.
+const metaJS = `(() => {
+	const meta = (sel) => {
+		const e = document.querySelector(sel);
+		return e ? (e.getAttribute('content') || '').trim() : '';
+	};
+	return {
+		description: meta('meta[name="description" i]') || meta('meta[property="og:description"]'),
+		language: (document.documentElement.lang || meta('meta[http-equiv="content-language" i]')).trim(),
+	};
+})()`
+
+// PageMeta are the descriptive metadata of a page.
+type PageMeta struct {
+	Description string `json:"description"`
+	Language    string `json:"language"`
+}
+
+// Meta returns the description and the declared language of the page loaded
+// in the tab of the chromedp context ctx.
+func Meta(ctx context.Context) (PageMeta, error) {
+	var m PageMeta
+	if err := chromedp.Run(ctx, chromedp.Evaluate(metaJS, &m)); err != nil {
+		return m, fmt.Errorf("page meta: %w", err)
+	}
+
+	return m, nil
+}
+
+// FrontMatter returns the YAML front matter of the fetched page, for the
+// static sites and the notes or RAG pipelines.
+func FrontMatter(res Result, fetchedAt time.Time) (string, error) {
+	content := res.Markdown
+	if res.DOM != "" {
+		content = res.DOM
+	}
+
+	fm := struct {
+		URL         string `yaml:"url"`
+		Title       string `yaml:"title"`
+		Description string `yaml:"description,omitempty"`
+		FetchedAt   string `yaml:"fetched_at"`
+		WordCount   int    `yaml:"word_count"`
+		Language    string `yaml:"language,omitempty"`
+	}{
+		URL:         res.URL,
+		Title:       res.Title,
+		Description: res.Description,
+		FetchedAt:   fetchedAt.UTC().Format(time.RFC3339),
+		WordCount:   len(strings.Fields(content)),
+		Language:    res.Language,
+	}
+
+	b, err := yaml.Marshal(fm)
+	if err != nil {
+		return "", fmt.Errorf("front matter: %w", err)
+	}
+
+	return "---\n" + string(b) + "---\n\n", nil
+}
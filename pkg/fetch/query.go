@@ -0,0 +1,122 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	// XPathPrefix prefixes the XPath expressions given instead of the CSS
+	// selectors, e.g. xpath://h1.
+	XPathPrefix = "xpath:"
+	// ShadowPrefix prefixes the CSS selectors matched in the open shadow
+	// roots too, e.g. shadow:button.submit.
+	ShadowPrefix = "shadow:"
+)
+
+// Query returns the expression of the selector sel and its chromedp query
+// option. sel is an XPath expression if it starts with XPathPrefix, a CSS
+// selector piercing the shadow roots if it starts with ShadowPrefix, a CSS
+// selector otherwise.
+func Query(sel string) (string, chromedp.QueryOption) {
+	if expr, ok := strings.CutPrefix(sel, XPathPrefix); ok {
+		return expr, chromedp.BySearch
+	}
+	if strings.HasPrefix(sel, ShadowPrefix) {
+		return fmt.Sprintf("(() => {\n"+queryJS+"\nreturn query(%q, document);\n})()", sel), chromedp.ByJSPath
+	}
+
+	return sel, chromedp.ByQuery
+}
+
+// queryJS defines the query and queryAll functions of the scripts, they
+// select the elements matching a CSS selector, an XPath expression prefixed
+// with XPathPrefix or a CSS selector prefixed with ShadowPrefix under root.
+// The XPath expressions are relative to root, e.g. .//a. The closed shadow
+// roots are not reachable.
+const queryJS = `const deepAll = (sel, root) => {
+	const res = Array.from(root.querySelectorAll(sel));
+	for (const e of [root, ...root.querySelectorAll('*')]) {
+		if (e.shadowRoot) res.push(...deepAll(sel, e.shadowRoot));
+	}
+	return res;
+};
+const queryAll = (sel, root) => {
+	if (sel.startsWith('` + XPathPrefix + `')) {
+		const r = document.evaluate(sel.slice(6), root, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+		return Array.from({length: r.snapshotLength}, (_, i) => r.snapshotItem(i));
+	}
+	if (sel.startsWith('` + ShadowPrefix + `')) return deepAll(sel.slice(7), root);
+	return Array.from(root.querySelectorAll(sel));
+};
+const query = (sel, root) => queryAll(sel, root)[0] ?? null;
+`
+
+// flattenJS returns the outer HTML of the element matching the selector with
+// the content of its open shadow roots, the slots are replaced by their
+// assigned nodes. It returns an empty string if the element hosts no shadow
+// root, outerHTML is enough.
+const flattenJS = `((sel) => {
+` + queryJS + `
+const e = query(sel, document);
+if (e === null || ![e, ...e.querySelectorAll('*')].some((n) => n.shadowRoot)) return '';
+const voids = new Set(['area', 'base', 'br', 'col', 'embed', 'hr', 'img', 'input', 'link', 'meta', 'source', 'track', 'wbr']);
+const esc = (s) => s.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;').replace(/"/g, '&quot;');
+const flat = (n) => {
+	switch (n.nodeType) {
+	case Node.TEXT_NODE:
+		return esc(n.textContent);
+	case Node.DOCUMENT_FRAGMENT_NODE:
+		return Array.from(n.childNodes, flat).join('');
+	case Node.ELEMENT_NODE:
+		break;
+	default:
+		return '';
+	}
+	const tag = n.localName;
+	if (tag === 'slot') {
+		const a = n.assignedNodes({flatten: true});
+		return (a.length > 0 ? a : Array.from(n.childNodes)).map(flat).join('');
+	}
+	const attrs = Array.from(n.attributes, (a) => ' ' + a.name + '="' + esc(a.value) + '"').join('');
+	if (voids.has(tag)) return '<' + tag + attrs + '>';
+	const content = n.shadowRoot ? flat(n.shadowRoot) : Array.from(n.childNodes, flat).join('');
+	return '<' + tag + attrs + '>' + content + '</' + tag + '>';
+};
+return flat(e);
+})(%q)`
+
+// attrJS returns the attribute of the elements matching the selector.
+const attrJS = `((sel, attr) => {
+` + queryJS + `
+return queryAll(sel, document).map((e) => e.getAttribute(attr)).filter((v) => v !== null);
+})(%q, %q)`
+
+// Attributes returns the values of the attribute attr of the elements
+// matching sel, e.g. the href of the links. sel accepts the XPathPrefix and
+// ShadowPrefix prefixes.
+func Attributes(ctx context.Context, sel, attr string) ([]string, error) {
+	var vals []string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(fmt.Sprintf(attrJS, sel, attr), &vals)); err != nil {
+		return nil, fmt.Errorf("query %s: %w", sel, err)
+	}
+
+	return vals, nil
+}
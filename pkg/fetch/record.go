@@ -0,0 +1,153 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/lightpanda-io/gomcp/pkg/warc"
+)
+
+// Recorder records the HTTP exchanges of a tab, for a WARC file.
+type Recorder struct {
+	mu      sync.Mutex
+	pending map[network.RequestID]*warc.Exchange
+	// the finished exchanges, in order, their body is read by Exchanges.
+	done []recorded
+}
+
+type recorded struct {
+	id network.RequestID
+	ex *warc.Exchange
+	// the redirects have no body.
+	redirect bool
+}
+
+// Record records the HTTP exchanges of the tab of the chromedp context ctx
+// until ctx is done. The request bodies are not recorded.
+func Record(ctx context.Context) (*Recorder, error) {
+	// the tab is created on the first run.
+	if err := chromedp.Run(ctx); err != nil {
+		return nil, fmt.Errorf("tab: %w", err)
+	}
+
+	r := &Recorder{pending: make(map[network.RequestID]*warc.Exchange)}
+	chromedp.ListenTarget(ctx, r.Handle)
+
+	return r, nil
+}
+
+// Handle records the exchange of a network event.
+func (r *Recorder) Handle(ev any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch ev := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		if !strings.HasPrefix(ev.Request.URL, "http") {
+			return
+		}
+		// the redirects reuse the request id.
+		if prev, ok := r.pending[ev.RequestID]; ok && ev.RedirectResponse != nil {
+			respond(prev, ev.RedirectResponse)
+			r.done = append(r.done, recorded{id: ev.RequestID, ex: prev, redirect: true})
+		}
+		ex := &warc.Exchange{
+			Date:           time.Now(),
+			Method:         ev.Request.Method,
+			URL:            ev.Request.URL,
+			RequestHeaders: headers(ev.Request.Headers),
+		}
+		if ev.WallTime != nil {
+			ex.Date = ev.WallTime.Time()
+		}
+		r.pending[ev.RequestID] = ex
+	case *network.EventResponseReceived:
+		if ex, ok := r.pending[ev.RequestID]; ok {
+			respond(ex, ev.Response)
+		}
+	case *network.EventLoadingFinished:
+		if ex, ok := r.pending[ev.RequestID]; ok && ex.Status > 0 {
+			r.done = append(r.done, recorded{id: ev.RequestID, ex: ex})
+		}
+		delete(r.pending, ev.RequestID)
+	case *network.EventLoadingFailed:
+		delete(r.pending, ev.RequestID)
+	}
+}
+
+func respond(ex *warc.Exchange, resp *network.Response) {
+	ex.Status = int(resp.Status)
+	ex.StatusText = resp.StatusText
+	ex.ResponseHeaders = headers(resp.Headers)
+	ex.IPAddress = resp.RemoteIPAddress
+	// the headers actually sent, with the cookies.
+	if len(resp.RequestHeaders) > 0 {
+		ex.RequestHeaders = headers(resp.RequestHeaders)
+	}
+}
+
+// headers converts the headers of the protocol, the values of a repeated
+// header are separated by new lines.
+func headers(h network.Headers) http.Header {
+	hh := make(http.Header, len(h))
+	for name, v := range h {
+		// the pseudo headers of HTTP/2.
+		if strings.HasPrefix(name, ":") {
+			continue
+		}
+		for _, line := range strings.Split(fmt.Sprint(v), "\n") {
+			hh.Add(name, line)
+		}
+	}
+
+	return hh
+}
+
+// Exchanges returns the exchanges finished so far with the bodies of their
+// responses, read from the tab of the chromedp context ctx. The exchanges
+// whose body isn't available anymore are skipped.
+func (r *Recorder) Exchanges(ctx context.Context) []warc.Exchange {
+	r.mu.Lock()
+	done := r.done
+	r.done = nil
+	r.mu.Unlock()
+
+	exs := make([]warc.Exchange, 0, len(done))
+	for _, d := range done {
+		if !d.redirect && d.ex.Status != http.StatusNoContent && d.ex.Status != http.StatusNotModified {
+			err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+				var err error
+				d.ex.Body, err = network.GetResponseBody(d.id).Do(ctx)
+				return err
+			}))
+			if err != nil {
+				slog.Debug("record body", slog.String("url", d.ex.URL), slog.Any("err", err))
+				continue
+			}
+		}
+		exs = append(exs, *d.ex)
+	}
+
+	return exs
+}
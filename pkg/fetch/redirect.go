@@ -0,0 +1,117 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Redirect is a hop of a redirect chain.
+type Redirect struct {
+	URL    string `json:"url"`
+	Status int64  `json:"status"`
+}
+
+// StatusError is returned when the page answers with a 4xx or 5xx status.
+type StatusError struct {
+	URL       string
+	Status    int64
+	Redirects []Redirect
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("navigate %s: http status %d", e.URL, e.Status)
+}
+
+// Chain records the redirect chain of the document loaded in the main frame.
+type Chain struct {
+	mu    sync.Mutex
+	hops  []Redirect
+	frame string
+}
+
+// NewChain returns a chain recording the redirects of the main frame
+// frame, the target id of the tab.
+func NewChain(frame string) *Chain {
+	return &Chain{frame: frame}
+}
+
+// Handle records the redirect of a network event. A new navigation resets the
+// chain.
+func (c *Chain) Handle(ev any) {
+	req, ok := ev.(*network.EventRequestWillBeSent)
+	if !ok || req.Type != network.ResourceTypeDocument || string(req.FrameID) != c.frame {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if req.RedirectResponse == nil {
+		c.hops = nil
+		return
+	}
+	c.hops = append(c.hops, Redirect{URL: req.RedirectResponse.URL, Status: req.RedirectResponse.Status})
+}
+
+// Redirects returns the redirects of the last navigation.
+func (c *Chain) Redirects() []Redirect {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.hops) == 0 {
+		return nil
+	}
+
+	hops := make([]Redirect, len(c.hops))
+	copy(hops, c.hops)
+	return hops
+}
+
+// Listen records the redirects of the tab of the chromedp context ctx until
+// ctx is done.
+func Listen(ctx context.Context) (*Chain, error) {
+	// the tab is created on the first run.
+	if err := chromedp.Run(ctx); err != nil {
+		return nil, fmt.Errorf("tab: %w", err)
+	}
+
+	chain := NewChain(string(chromedp.FromContext(ctx).Target.TargetID))
+	chromedp.ListenTarget(ctx, chain.Handle)
+
+	return chain, nil
+}
+
+// FormatRedirects returns a description of the redirect chain ending on the
+// url with the status, empty without redirect.
+func FormatRedirects(chain []Redirect, url string, status int64) string {
+	if len(chain) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(chain)+1)
+	for _, r := range chain {
+		parts = append(parts, fmt.Sprintf("%s (%d)", r.URL, r.Status))
+	}
+	parts = append(parts, fmt.Sprintf("%s (%d)", url, status))
+
+	return "Redirects: " + strings.Join(parts, " -> ")
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Robots are the indexing directives of a page.
+type Robots struct {
+	// Directives of the robots and googlebot meta tags.
+	Meta []string `json:"meta,omitempty"`
+	// Directives of the X-Robots-Tag response header.
+	Header []string `json:"header,omitempty"`
+}
+
+// robotsMetaJS returns the content of the robots meta tags and the page url.
+const robotsMetaJS = `({
+	url: document.location.href,
+	metas: Array.from(document.querySelectorAll('meta[name=robots i], meta[name=googlebot i]')).map((m) => m.content || ''),
+})`
+
+// ReadRobots returns the indexing directives of the page loaded in the tab of
+// the chromedp context ctx. resp is the response of the page, its
+// X-Robots-Tag header is ignored if it belongs to another page.
+func ReadRobots(ctx context.Context, resp *network.Response) (Robots, error) {
+	var r Robots
+
+	var page struct {
+		URL   string   `json:"url"`
+		Metas []string `json:"metas"`
+	}
+	if err := chromedp.Run(ctx, chromedp.Evaluate(robotsMetaJS, &page)); err != nil {
+		return r, fmt.Errorf("robots meta: %w", err)
+	}
+	for _, m := range page.Metas {
+		r.Meta = append(r.Meta, directives(m)...)
+	}
+
+	// the response may belong to a previous page.
+	if resp != nil && resp.URL == page.URL {
+		for _, h := range headerValues(resp.Headers, "X-Robots-Tag") {
+			r.Header = append(r.Header, directives(h)...)
+		}
+	}
+
+	return r, nil
+}
+
+// directives splits a comma separated list of directives. The directives
+// targeting a user agent, e.g. "googlebot: noindex", keep their prefix.
+func directives(v string) []string {
+	var d []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			d = append(d, s)
+		}
+	}
+
+	return d
+}
+
+// headerValues returns the values of the header name, the headers names are
+// case insensitive. Multiple values are separated by new lines.
+func headerValues(h network.Headers, name string) []string {
+	var values []string
+	for k, v := range h {
+		if !strings.EqualFold(k, name) {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			values = append(values, strings.Split(s, "\n")...)
+		}
+	}
+
+	return values
+}
+
+// has returns true if one of the directives is d, or none or all which
+// imply it.
+func (r Robots) has(d string) bool {
+	for _, v := range slices.Concat(r.Meta, r.Header) {
+		// remove the user agent prefix.
+		if _, after, ok := strings.Cut(v, ":"); ok {
+			v = strings.TrimSpace(after)
+		}
+		if v == d || (v == "none" && (d == "noindex" || d == "nofollow")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NoStore returns true if the page must not be kept.
+func (r Robots) NoStore() bool {
+	return r.has("noindex") || r.has("noarchive")
+}
+
+func (r Robots) String() string {
+	if len(r.Meta) == 0 && len(r.Header) == 0 {
+		return ""
+	}
+
+	var parts []string
+	if len(r.Meta) > 0 {
+		parts = append(parts, strings.Join(r.Meta, ", ")+" (meta robots)")
+	}
+	if len(r.Header) > 0 {
+		parts = append(parts, strings.Join(r.Header, ", ")+" (X-Robots-Tag)")
+	}
+
+	return "Robots directives: " + strings.Join(parts, "; ")
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	zwnj      = '\u200c'
+	zwj       = '\u200d'
+	blackFlag = '\U0001F3F4'
+)
+
+// invisible returns true if r is an invisible character hiding content from
+// the readers: the format characters, e.g. the zero width spaces, the bidi
+// overrides and isolates and the tags, the control characters, the
+// variation selectors of the supplement and the fillers.
+func invisible(r rune) bool {
+	switch {
+	case r == '\t' || r == '\n' || r == '\r':
+		return false
+	// the left-to-right and right-to-left marks are needed by the RTL texts.
+	case r == '\u200e' || r == '\u200f':
+		return false
+	case unicode.Is(unicode.Cf, r), unicode.IsControl(r):
+		return true
+	// variation selectors supplement.
+	case r >= '\U000E0100' && r <= '\U000E01EF':
+		return true
+	case r == '\u115f' || r == '\u1160' || r == '\u3164' || r == '\uffa0' || r == '\u034f':
+		return true
+	}
+
+	return false
+}
+
+// Sanitize removes the invisible characters of s which can hide prompt
+// injections from the readers. The zero width joiners between non ASCII
+// characters, e.g. in the emoji sequences or the Indic scripts, and the tags
+// of the flag emoji are kept. It returns the number of removed characters.
+func Sanitize(s string) (string, int) {
+	var b strings.Builder
+	removed := 0
+	// the tags follow a black flag in the flag emoji.
+	inflag := false
+	var prev rune
+	for i, r := range s {
+		switch {
+		case r == blackFlag:
+			inflag = true
+		case r >= '\U000E0020' && r <= '\U000E007F':
+			if inflag {
+				b.WriteRune(r)
+				prev = r
+				continue
+			}
+		default:
+			inflag = false
+		}
+
+		if (r == zwj || r == zwnj) && prev >= utf8.RuneSelf {
+			next, _ := utf8.DecodeRuneInString(s[i+utf8.RuneLen(r):])
+			if next >= utf8.RuneSelf && next != utf8.RuneError && !invisible(next) {
+				b.WriteRune(r)
+				prev = r
+				continue
+			}
+		}
+
+		if invisible(r) {
+			removed++
+			continue
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	if removed == 0 {
+		return s, 0
+	}
+
+	return b.String(), removed
+}
@@ -0,0 +1,384 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// SiteMaxItems is the maximum number of items, e.g. comments, of a SiteDoc.
+const SiteMaxItems = 500
+
+// SiteDoc is the content of a page of a known site returned by its extractor.
+type SiteDoc struct {
+	Site string `json:"site"`
+	// Kind of page, e.g. issue, question or thread.
+	Kind  string      `json:"kind"`
+	Title string      `json:"title"`
+	Meta  []SiteField `json:"meta,omitempty"`
+	// Main content in markdown.
+	Body string `json:"body,omitempty"`
+	// Title of the section of the items, e.g. Comments.
+	Section string     `json:"section,omitempty"`
+	Items   []SiteItem `json:"items,omitempty"`
+}
+
+// SiteField is a metadata of a SiteDoc, e.g. the score or the state.
+type SiteField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SiteItem is an answer or a comment of a SiteDoc.
+type SiteItem struct {
+	Author string `json:"author,omitempty"`
+	Score  string `json:"score,omitempty"`
+	// Depth of the comment in the thread, 0 for the top level.
+	Depth    int  `json:"depth,omitempty"`
+	Accepted bool `json:"accepted,omitempty"`
+	// Content in markdown.
+	Body string `json:"body"`
+}
+
+// SiteExtractor extracts the content of the pages of a site.
+type SiteExtractor struct {
+	Name string
+	// Hosts of the site, their subdomains match too.
+	Hosts []string
+	// JS returns the document of the page, with the bodies in HTML, or null
+	// if the page isn't supported.
+	JS string
+}
+
+// Match returns true if the host of the url is one of the extractor's hosts.
+func (e SiteExtractor) Match(u string) bool {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(pu.Hostname())
+
+	return slices.ContainsFunc(e.Hosts, func(h string) bool {
+		return host == h || strings.HasSuffix(host, "."+h)
+	})
+}
+
+// siteHelpersJS are the helpers shared by the extractors.
+const siteHelpersJS = `
+	const q = (sel, root = document) => root.querySelector(sel);
+	const qa = (sel, root = document) => Array.from(root.querySelectorAll(sel));
+	const text = (e) => e ? e.textContent.replace(/\s+/g, ' ').trim() : '';
+	const html = (e) => e ? e.innerHTML : '';
+	const attr = (e, name) => e ? (e.getAttribute(name) || '').trim() : '';
+	const child = (e, fn) => e ? Array.from(e.children).find(fn) : undefined;
+	const meta = (pairs) => pairs.filter(([, v]) => v).map(([name, value]) => ({name, value}));
+`
+
+// SiteExtractors are the built-in extractors of the popular sources.
+var SiteExtractors = []SiteExtractor{
+	{
+		Name:  "github",
+		Hosts: []string{"github.com"},
+		JS: `(() => {` + siteHelpersJS + `
+	const path = location.pathname.split('/').filter(Boolean);
+	if (path.length >= 4 && (path[2] === 'issues' || path[2] === 'pull')) {
+		const title = text(q('bdi.js-issue-title, [data-testid="issue-title"], .gh-header-title .markdown-title'));
+		if (!title) return null;
+		const comments = qa('.timeline-comment, .react-issue-comment, [data-testid="comment-viewer-outer-box"]')
+			.map((c) => ({
+				author: text(q('.author, [data-testid="avatar-link"]', c)),
+				body: html(q('.js-comment-body, .markdown-body', c)),
+			}))
+			.filter((c) => c.body);
+		const first = comments.shift() || {};
+		return {
+			kind: path[2] === 'pull' ? 'pull_request' : 'issue',
+			title: title,
+			meta: meta([
+				['repository', path[0] + '/' + path[1]],
+				['number', path[3]],
+				['state', text(q('.gh-header-meta .State, [data-testid="header-state"]'))],
+				['author', first.author],
+				['labels', qa('.js-issue-labels .IssueLabel, [data-testid="issue-labels"] a').map(text).join(', ')],
+			]),
+			body: first.body,
+			section: 'Comments',
+			items: comments,
+		};
+	}
+	if (path.length === 2) {
+		const readme = q('article.markdown-body');
+		return {
+			kind: 'repository',
+			title: path[0] + '/' + path[1],
+			meta: meta([
+				['description', attr(q('meta[name="description"]'), 'content')],
+				['stars', attr(q('#repo-stars-counter-star'), 'title') || text(q('#repo-stars-counter-star'))],
+				['forks', attr(q('#repo-network-counter'), 'title') || text(q('#repo-network-counter'))],
+				['topics', qa('a.topic-tag').map(text).join(', ')],
+			]),
+			body: html(readme),
+		};
+	}
+	return null;
+})()`,
+	},
+	{
+		Name:  "stackoverflow",
+		Hosts: []string{"stackoverflow.com", "stackexchange.com", "superuser.com", "serverfault.com", "askubuntu.com", "mathoverflow.net"},
+		JS: `(() => {` + siteHelpersJS + `
+	const title = text(q('#question-header h1'));
+	const question = q('#question, .question');
+	if (!title || !question) return null;
+	const author = (post) => text(qa('.post-signature .user-details a', post).pop());
+	const score = (post) => attr(q('.js-vote-count', post), 'data-value') || text(q('.js-vote-count', post));
+	const answers = qa('.answer').map((a) => ({
+		author: author(a),
+		score: score(a),
+		accepted: a.classList.contains('accepted-answer'),
+		body: html(q('.js-post-body, .s-prose', a)),
+	}));
+	// the accepted answer, or the best scored one.
+	const answer = answers.find((a) => a.accepted) ||
+		answers.sort((a, b) => (parseInt(b.score) || 0) - (parseInt(a.score) || 0))[0];
+	return {
+		kind: 'question',
+		title: title,
+		meta: meta([
+			['author', author(question)],
+			['score', score(question)],
+			['asked', attr(q('time[itemprop="dateCreated"]'), 'datetime')],
+			['tags', qa('.post-tag', question).map(text).join(', ')],
+			['answers', String(answers.length)],
+		]),
+		body: html(q('.js-post-body, .s-prose', question)),
+		section: answer && answer.accepted ? 'Accepted answer' : 'Top answer',
+		items: answer ? [answer] : [],
+	};
+})()`,
+	},
+	{
+		Name:  "wikipedia",
+		Hosts: []string{"wikipedia.org"},
+		JS: `(() => {` + siteHelpersJS + `
+	const title = text(q('#firstHeading'));
+	const content = q('#mw-content-text .mw-parser-output');
+	if (!title || !content) return null;
+	const clean = (e) => {
+		qa('sup.reference, .mw-editsection, style, .noprint', e).forEach((n) => n.remove());
+		return e;
+	};
+	const infobox = q('table.infobox', content);
+	const fields = infobox ? qa('tr', clean(infobox.cloneNode(true)))
+		.map((tr) => [text(q('th', tr)), text(q('td', tr))])
+		.filter(([k]) => k) : [];
+	const body = clean(content.cloneNode(true));
+	qa('table.infobox, .navbox, .vertical-navbox, .sidebar, .hatnote, .metadata, .shortdescription, #toc, .toc, .reflist, .mw-references-wrap, .reference', body)
+		.forEach((n) => n.remove());
+	return {
+		kind: 'article',
+		title: title,
+		meta: meta(fields),
+		body: html(body),
+	};
+})()`,
+	},
+	{
+		Name:  "hackernews",
+		Hosts: []string{"news.ycombinator.com"},
+		JS: `(() => {` + siteHelpersJS + `
+	const item = q('.fatitem');
+	if (!item) return null;
+	const link = q('.titleline > a', item);
+	const comments = qa('tr.athing.comtr').map((c) => {
+		const body = q('.commtext', c);
+		qa('.reply', body || c).forEach((n) => n.remove());
+		return {
+			author: text(q('.hnuser', c)),
+			depth: parseInt(attr(q('td.ind', c), 'indent')) || 0,
+			body: html(body),
+		};
+	}).filter((c) => c.body);
+	return {
+		kind: 'thread',
+		title: text(link) || text(q('.commtext', item)).slice(0, 80),
+		meta: meta([
+			['link', link ? link.href : ''],
+			['points', text(q('.score', item))],
+			['author', text(q('.hnuser', item))],
+			['posted', attr(q('.age', item), 'title')],
+		]),
+		body: html(q('.toptext', item)),
+		section: 'Comments',
+		items: comments,
+	};
+})()`,
+	},
+	{
+		Name:  "reddit",
+		Hosts: []string{"reddit.com"},
+		JS: `(() => {` + siteHelpersJS + `
+	const post = q('shreddit-post');
+	if (post) {
+		const comments = qa('shreddit-comment').map((c) => ({
+			author: attr(c, 'author'),
+			score: attr(c, 'score'),
+			depth: parseInt(attr(c, 'depth')) || 0,
+			body: html(child(c, (e) => attr(e, 'slot') === 'comment')),
+		})).filter((c) => c.body);
+		return {
+			kind: 'thread',
+			title: attr(post, 'post-title'),
+			meta: meta([
+				['subreddit', attr(post, 'subreddit-prefixed-name')],
+				['author', attr(post, 'author')],
+				['score', attr(post, 'score')],
+				['comments', attr(post, 'comment-count')],
+				['posted', attr(post, 'created-timestamp')],
+				['link', attr(post, 'post-type') === 'link' ? attr(post, 'content-href') : ''],
+			]),
+			body: html(q('[slot="text-body"]', post)),
+			section: 'Comments',
+			items: comments,
+		};
+	}
+	// old reddit.
+	const link = q('#siteTable .thing.link');
+	if (!link) return null;
+	const depth = (e) => {
+		let d = 0;
+		for (let p = e.parentElement.closest('.thing.comment'); p; p = p.parentElement.closest('.thing.comment')) d++;
+		return d;
+	};
+	const comments = qa('.commentarea .thing.comment').map((c) => {
+		const entry = child(c, (e) => e.classList.contains('entry'));
+		return {
+			author: attr(c, 'data-author'),
+			score: attr(q('.score.unvoted', entry), 'title'),
+			depth: depth(c),
+			body: html(q('.usertext-body .md', entry)),
+		};
+	}).filter((c) => c.body);
+	return {
+		kind: 'thread',
+		title: text(q('a.title', link)),
+		meta: meta([
+			['subreddit', attr(link, 'data-subreddit-prefixed')],
+			['author', attr(link, 'data-author')],
+			['score', attr(link, 'data-score')],
+			['comments', attr(link, 'data-comments-count')],
+			['link', attr(link, 'data-domain').startsWith('self.') ? '' : attr(link, 'data-url')],
+		]),
+		body: html(q('.expando .usertext-body .md', link)),
+		section: 'Comments',
+		items: comments,
+	};
+})()`,
+	},
+}
+
+// Site returns the content of the page loaded in the tab of the chromedp
+// context ctx extracted by the extractor of its site. It returns nil if no
+// extractor matches the page, e.g. the site changed its layout.
+func Site(ctx context.Context) (*SiteDoc, error) {
+	var location string
+	if err := chromedp.Run(ctx, chromedp.Location(&location)); err != nil {
+		return nil, fmt.Errorf("page location: %w", err)
+	}
+
+	i := slices.IndexFunc(SiteExtractors, func(e SiteExtractor) bool {
+		return e.Match(location)
+	})
+	if i < 0 {
+		return nil, nil
+	}
+	ext := SiteExtractors[i]
+
+	var doc *SiteDoc
+	if err := chromedp.Run(ctx, chromedp.Evaluate(ext.JS, &doc)); err != nil {
+		return nil, fmt.Errorf("site %s: %w", ext.Name, err)
+	}
+	if doc == nil || doc.Title == "" {
+		slog.Debug("site: unsupported page", slog.String("site", ext.Name), slog.String("url", location))
+		return nil, nil
+	}
+	doc.Site = ext.Name
+
+	// the bodies are converted from HTML.
+	var err error
+	if doc.Body, err = Convert(doc.Body); err != nil {
+		return nil, err
+	}
+	if len(doc.Items) > SiteMaxItems {
+		doc.Items = doc.Items[:SiteMaxItems]
+	}
+	for i := range doc.Items {
+		if doc.Items[i].Body, err = Convert(doc.Items[i].Body); err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+// Markdown returns the document in markdown: the title, the metadata, the
+// body and the items, the nested comments are quoted.
+func (d *SiteDoc) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", d.Title)
+	for _, f := range d.Meta {
+		fmt.Fprintf(&b, "- %s: %s\n", f.Name, f.Value)
+	}
+	if len(d.Meta) > 0 {
+		b.WriteString("\n")
+	}
+	if d.Body != "" {
+		b.WriteString(strings.TrimSpace(d.Body))
+		b.WriteString("\n\n")
+	}
+
+	if len(d.Items) == 0 {
+		return b.String()
+	}
+	fmt.Fprintf(&b, "## %s\n\n", d.Section)
+	for _, it := range d.Items {
+		head := "**" + it.Author + "**"
+		if it.Author == "" {
+			head = "**[deleted]**"
+		}
+		if it.Score != "" {
+			head += " (" + it.Score + ")"
+		}
+		if it.Accepted {
+			head += " accepted"
+		}
+
+		prefix := strings.Repeat("> ", it.Depth)
+		for _, line := range strings.Split(head+"\n\n"+strings.TrimSpace(it.Body), "\n") {
+			b.WriteString(strings.TrimRight(prefix+line, " "))
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
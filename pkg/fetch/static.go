@@ -0,0 +1,206 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// StaticMinText is the minimum size of the text of a page fetched
+	// without browser, the shorter pages are likely rendered by JavaScript.
+	StaticMinText = 200
+
+	staticMaxBody = 10 << 20
+	staticAgent   = "gomcp (https://github.com/lightpanda-io/gomcp)"
+)
+
+// ErrNeedsBrowser is returned by Static when the page must be rendered by a
+// browser, e.g. its content is rendered by JavaScript.
+var ErrNeedsBrowser = errors.New("the page needs a browser")
+
+// spaRoots are the mount points of the JavaScript frameworks, their page is
+// empty without browser.
+var spaRoots = []string{"#root", "#app", "#__next", "#__nuxt", "#___gatsby", "[ng-version]", "[data-reactroot]"}
+
+// Static fetches the url with the HTTP client, without browser, and returns
+// its content in markdown like Page. It returns ErrNeedsBrowser if the page
+// isn't a static HTML page or the options need a browser: clicks, the DOM
+// format, XPath or shadow selectors and the pages of the SiteExtractors.
+// The client's CheckRedirect still applies to the redirections, its errors
+// are returned as is.
+func Static(ctx context.Context, cli *http.Client, u string, opts Options) (Result, error) {
+	var res Result
+
+	if len(opts.Clicks) > 0 || opts.Format == FormatDOM ||
+		strings.HasPrefix(opts.Selector, XPathPrefix) || strings.HasPrefix(opts.Selector, ShadowPrefix) {
+		return res, ErrNeedsBrowser
+	}
+	if opts.Sites && opts.Selector == "" && slices.ContainsFunc(SiteExtractors, func(e SiteExtractor) bool { return e.Match(u) }) {
+		return res, ErrNeedsBrowser
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	// the redirects are recorded like the browser's chain, the errors of
+	// the client's CheckRedirect are returned as is.
+	var rerr error
+	c := *cli
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		res.Redirects = append(res.Redirects, Redirect{URL: req.Response.Request.URL.String(), Status: int64(req.Response.StatusCode)})
+		if cli.CheckRedirect != nil {
+			rerr = cli.CheckRedirect(req, via)
+			return rerr
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return res, fmt.Errorf("create http req: %w", err)
+	}
+	req.Header.Set("User-Agent", staticAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+
+	resp, err := c.Do(req)
+	if err != nil && rerr != nil {
+		return res, rerr
+	}
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+
+	res.URL = resp.Request.URL.String()
+	res.Status = int64(resp.StatusCode)
+	if opts.FailOnErrorStatus && res.Status >= 400 {
+		return res, &StatusError{URL: res.URL, Status: res.Status, Redirects: res.Redirects}
+	}
+
+	ctype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if ctype != "text/html" && ctype != "application/xhtml+xml" {
+		return res, ErrNeedsBrowser
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, staticMaxBody), resp.Header.Get("Content-Type"))
+	if err != nil {
+		return res, fmt.Errorf("decode %s: %w", res.URL, err)
+	}
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return res, fmt.Errorf("parse %s: %w", res.URL, err)
+	}
+
+	if needsBrowser(doc) {
+		return res, ErrNeedsBrowser
+	}
+
+	res.Title = strings.TrimSpace(doc.Find("title").First().Text())
+	res.Description = metaContent(doc, `meta[name="description" i]`, `meta[property="og:description"]`)
+	res.Language = strings.TrimSpace(doc.Find("html").AttrOr("lang", metaContent(doc, `meta[http-equiv="content-language" i]`)))
+	doc.Find(`meta[name=robots i], meta[name=googlebot i]`).Each(func(_ int, s *goquery.Selection) {
+		res.Robots.Meta = append(res.Robots.Meta, directives(s.AttrOr("content", ""))...)
+	})
+	for _, h := range resp.Header.Values("X-Robots-Tag") {
+		res.Robots.Header = append(res.Robots.Header, directives(h)...)
+	}
+
+	sel := opts.Selector
+	if sel == "" {
+		sel = "html"
+	}
+	node := doc.Find(sel).First()
+	// the element may be rendered by JavaScript.
+	if node.Length() == 0 {
+		return res, ErrNeedsBrowser
+	}
+	html, err := goquery.OuterHtml(node)
+	if err != nil {
+		return res, fmt.Errorf("outerHTML: %w", err)
+	}
+	if html, err = RemoveBoilerplate(html, opts.Boilerplate); err != nil {
+		return res, err
+	}
+	if res.Markdown, err = Convert(html); err != nil {
+		return res, err
+	}
+	if res.Markdown, err = Images(ctx, res.Markdown, res.URL, opts.Images); err != nil {
+		return res, err
+	}
+	if opts.Sanitize {
+		res.Markdown, _ = Sanitize(res.Markdown)
+	}
+	if res.Language == "" {
+		res.Language = DetectLanguage(res.Markdown)
+	}
+	res.Markdown = Truncate(res.Markdown, opts.MaxContent, opts.Truncation)
+
+	return res, nil
+}
+
+// needsBrowser returns true if the page is likely rendered by JavaScript: its
+// text is too short or a framework mount point is empty, or if it is a bot
+// challenge.
+func needsBrowser(doc *goquery.Document) bool {
+	body := doc.Find("body").Clone()
+	body.Find("script, style, noscript, template").Remove()
+	text := len(strings.Join(strings.Fields(body.Text()), " "))
+	if text < StaticMinText {
+		return true
+	}
+	// the browser may pass the challenge.
+	if name := docChallenge(doc, text); name != "" {
+		slog.Debug("static challenge", slog.String("provider", name))
+		return true
+	}
+
+	for _, sel := range spaRoots {
+		if root := body.Find(sel).First(); root.Length() > 0 && strings.TrimSpace(root.Text()) == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// metaContent returns the content of the first meta tag matching one of the
+// selectors.
+func metaContent(doc *goquery.Document, sels ...string) string {
+	for _, sel := range sels {
+		if v := strings.TrimSpace(doc.Find(sel).First().AttrOr("content", "")); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,218 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Field describes how a value is extracted from the page.
+type Field struct {
+	// CSS selector of the element, relative to the parent field's element,
+	// or XPath expression prefixed with XPathPrefix, e.g. xpath:.//h1.
+	// Empty selects the parent element.
+	Selector string `json:"selector,omitempty"`
+	// Attribute read instead of the text, e.g. href, src or content.
+	Attr string `json:"attr,omitempty"`
+	// Type of the value: string, number, integer or boolean. string by
+	// default.
+	Type string `json:"type,omitempty"`
+	// Return the values of all the matching elements in an array.
+	All bool `json:"all,omitempty"`
+	// Fields of the object extracted from the element, e.g. the fields of
+	// each product of a list.
+	Fields Fields `json:"fields,omitempty"`
+}
+
+// UnmarshalJSON accepts a selector alone as a string field.
+func (f *Field) UnmarshalJSON(b []byte) error {
+	var sel string
+	if err := json.Unmarshal(b, &sel); err == nil {
+		*f = Field{Selector: sel}
+		return nil
+	}
+
+	type field Field
+	return json.Unmarshal(b, (*field)(f))
+}
+
+// Fields maps the names of the extracted values to their fields.
+type Fields map[string]Field
+
+var ErrInvalidField = errors.New("invalid field")
+
+// Validate checks the types of the fields.
+func (fs Fields) Validate() error {
+	for name, f := range fs {
+		switch f.Type {
+		case "", "string", "number", "integer", "boolean":
+		default:
+			return fmt.Errorf("%w %s: unknown type %s", ErrInvalidField, name, f.Type)
+		}
+		if f.Selector == "" && f.All {
+			return fmt.Errorf("%w %s: no selector", ErrInvalidField, name)
+		}
+		if err := f.Fields.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// schema is the subset of JSON schema describing the extraction. The
+// properties give their selector with the selector keyword.
+type schema struct {
+	Type       string            `json:"type"`
+	Selector   string            `json:"selector"`
+	Attr       string            `json:"attribute"`
+	Properties map[string]schema `json:"properties"`
+	Items      *schema           `json:"items"`
+}
+
+// FieldsFromSchema returns the fields of a JSON schema of type object. Each
+// property gives the CSS selector of its value with the selector keyword and
+// optionally the attribute read with the attribute keyword. The arrays return
+// all the matches, the objects and the arrays of objects are extracted from
+// the element of their selector.
+func FieldsFromSchema(b []byte) (Fields, error) {
+	var s schema
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("schema decode: %w", err)
+	}
+	if s.Type != "object" && s.Type != "" {
+		return nil, fmt.Errorf("schema: type %s, want object", s.Type)
+	}
+
+	return s.fields()
+}
+
+func (s schema) fields() (Fields, error) {
+	fs := make(Fields, len(s.Properties))
+	for name, p := range s.Properties {
+		f, err := p.field()
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+		fs[name] = f
+	}
+
+	return fs, nil
+}
+
+func (s schema) field() (Field, error) {
+	f := Field{Selector: s.Selector, Attr: s.Attr}
+
+	switch s.Type {
+	case "array":
+		f.All = true
+		if s.Items == nil {
+			return f, nil
+		}
+		item, err := s.Items.field()
+		if err != nil {
+			return f, err
+		}
+		// the items are read from the array's matches.
+		f.Attr, f.Type, f.Fields = item.Attr, item.Type, item.Fields
+		if f.Attr == "" {
+			f.Attr = s.Attr
+		}
+	case "object":
+		fs, err := s.fields()
+		if err != nil {
+			return f, err
+		}
+		f.Fields = fs
+	default:
+		f.Type = s.Type
+	}
+
+	return f, nil
+}
+
+// structuredJS extracts the fields given in JSON from the page. The numbers
+// are read from the first numeric part of the text, with the comma or the dot
+// as decimal separator.
+const structuredJS = `((fields) => {
+` + queryJS + `
+	const number = (v) => {
+		const m = v.match(/-?\d[\d\s.,]*/);
+		if (m === null) return null;
+		let s = m[0].replace(/\s/g, '').replace(/[.,]+$/, '');
+		const dot = s.lastIndexOf('.'), comma = s.lastIndexOf(',');
+		if (dot >= 0 && comma >= 0) {
+			s = dot > comma ? s.replace(/,/g, '') : s.replace(/\./g, '').replace(',', '.');
+		} else if (comma >= 0) {
+			s = /^-?\d{1,3}(,\d{3})+$/.test(s) ? s.replace(/,/g, '') : s.replace(',', '.');
+		} else if (/^-?\d{1,3}(\.\d{3}){2,}$/.test(s)) {
+			s = s.replace(/\./g, '');
+		}
+		const n = parseFloat(s);
+		return isNaN(n) ? null : n;
+	};
+	const convert = (v, type) => {
+		if (v === null || v === undefined) return null;
+		v = v.trim();
+		switch (type) {
+		case 'number': return number(v);
+		case 'integer': { const n = number(v); return n === null ? null : Math.trunc(n); }
+		case 'boolean': return !['', 'false', '0', 'no', 'off'].includes(v.toLowerCase());
+		}
+		return v;
+	};
+	const read = (e, f) => {
+		if (f.fields) return extract(f.fields, e);
+		return convert(f.attr ? e.getAttribute(f.attr) : (e.innerText ?? e.textContent), f.type);
+	};
+	const extract = (fields, root) => {
+		const res = {};
+		for (const [name, f] of Object.entries(fields)) {
+			if (f.all) {
+				res[name] = queryAll(f.selector, root).map((e) => read(e, f));
+				continue;
+			}
+			const e = f.selector ? query(f.selector, root) : root;
+			res[name] = e === null ? null : read(e, f);
+		}
+		return res;
+	};
+	return extract(fields, document.documentElement);
+})(%s)`
+
+// Structured extracts the fields from the page loaded in the tab of the
+// chromedp context ctx. The values of the missing elements are null.
+func Structured(ctx context.Context, fields Fields) (map[string]any, error) {
+	if err := fields.Validate(); err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("fields encode: %w", err)
+	}
+
+	var res map[string]any
+	if err := chromedp.Run(ctx, chromedp.Evaluate(fmt.Sprintf(structuredJS, b), &res)); err != nil {
+		return nil, fmt.Errorf("extract structured: %w", err)
+	}
+
+	return res, nil
+}
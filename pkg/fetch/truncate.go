@@ -0,0 +1,224 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Truncation is the strategy cutting the content exceeding its maximum size.
+// It implements flag.Value.
+type Truncation string
+
+const (
+	// Keep the beginning of the content.
+	TruncateHead Truncation = "head"
+	// Keep the title, the headings and the first paragraphs of each
+	// section, as many as fit.
+	TruncateSmart Truncation = "smart"
+)
+
+var InvalidTruncation = errors.New("invalid truncation, must be head or smart")
+
+func (t Truncation) String() string {
+	return string(t)
+}
+
+func (t *Truncation) Set(v string) error {
+	switch Truncation(v) {
+	case TruncateHead, TruncateSmart:
+		*t = Truncation(v)
+		return nil
+	}
+
+	return InvalidTruncation
+}
+
+// sectionGap replaces the paragraphs removed from a section.
+const sectionGap = "[...]"
+
+// Truncate cuts the markdown md to limit bytes, marker included, with the
+// strategy t. It returns md unchanged if it fits or if limit is 0. The cut
+// content ends with a marker giving the sizes.
+func Truncate(md string, limit int, t Truncation) string {
+	if limit <= 0 || len(md) <= limit {
+		return md
+	}
+
+	// the marker size depends on the kept size, reserve the largest one.
+	budget := limit - len(truncatedMarker(limit, len(md), t))
+	if budget <= 0 {
+		return head(md, limit)
+	}
+
+	kept := ""
+	if t == TruncateSmart {
+		kept = smart(md, budget)
+	}
+	if kept == "" {
+		t = TruncateHead
+		kept = head(md, budget)
+	}
+
+	return kept + truncatedMarker(len(kept), len(md), t)
+}
+
+func truncatedMarker(kept, total int, t Truncation) string {
+	if t == TruncateSmart {
+		return fmt.Sprintf("\n\n[truncated, %d of %d bytes: the headings and the first paragraphs of each section are kept]", kept, total)
+	}
+
+	return fmt.Sprintf("\n\n[truncated, %d of %d bytes]", kept, total)
+}
+
+// head returns the beginning of s up to limit bytes, cut at the last paragraph
+// break if any in the second half, without cutting a character.
+func head(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+
+	n := limit
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	s = s[:n]
+	if i := strings.LastIndex(s, "\n\n"); i > n/2 {
+		s = s[:i]
+	}
+
+	return strings.TrimRight(s, " \n")
+}
+
+// section is a heading followed by its blocks.
+type section struct {
+	heading string
+	blocks  []string
+}
+
+// sections splits the markdown in sections of blocks separated by blank
+// lines. The fenced code blocks are kept whole. The blocks before the first
+// heading form a section without heading.
+func sections(md string) []section {
+	secs := []section{{}}
+	var block []string
+	fenced := false
+
+	flush := func() {
+		if len(block) == 0 {
+			return
+		}
+		b := strings.Join(block, "\n")
+		block = nil
+
+		cur := &secs[len(secs)-1]
+		if strings.HasPrefix(b, "#") && !strings.Contains(b, "\n") {
+			secs = append(secs, section{heading: b})
+			return
+		}
+		cur.blocks = append(cur.blocks, b)
+	}
+
+	for _, line := range strings.Split(md, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+		}
+		switch {
+		case fenced:
+			block = append(block, line)
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "#"):
+			// a heading is a block of its own.
+			flush()
+			block = append(block, line)
+			flush()
+		default:
+			block = append(block, line)
+		}
+	}
+	flush()
+
+	return secs
+}
+
+// smart returns the title, the headings and the first n paragraphs of each
+// section of md, with the largest n fitting in limit bytes. It returns an empty
+// string if the headings alone don't fit.
+func smart(md string, limit int) string {
+	secs := sections(md)
+
+	most := 0
+	for _, s := range secs {
+		most = max(most, len(s.blocks))
+	}
+
+	for n := most; n >= 0; n-- {
+		var parts []string
+		for i, s := range secs {
+			if s.heading != "" {
+				parts = append(parts, s.heading)
+			}
+			keep := n
+			// the beginning of the page holds the title.
+			if i == 0 {
+				keep = max(keep, 1)
+			}
+			keep = min(keep, len(s.blocks))
+			parts = append(parts, s.blocks[:keep]...)
+			if keep < len(s.blocks) {
+				parts = append(parts, sectionGap)
+			}
+		}
+
+		if out := strings.Join(parts, "\n\n"); len(out) <= limit {
+			return out
+		}
+	}
+
+	return ""
+}
+
+// Chunk returns the part of s starting at offset of up to size bytes, cut at
+// a paragraph break if possible, and the offset of the next part, len(s) at
+// the end.
+func Chunk(s string, offset, size int) (string, int) {
+	rest := s[offset:]
+	if size <= 0 || len(rest) <= size {
+		return rest, len(s)
+	}
+
+	part := head(rest, size)
+	if part == "" {
+		// only blanks before the limit.
+		n := size
+		for n > 0 && !utf8.RuneStart(rest[n]) {
+			n--
+		}
+		part = rest[:max(n, 1)]
+	}
+
+	// the blanks between the parts are skipped.
+	next := offset + len(part)
+	for next < len(s) && (s[next] == '\n' || s[next] == ' ') {
+		next++
+	}
+
+	return part, next
+}
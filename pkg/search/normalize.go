@@ -0,0 +1,136 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"net/url"
+	"strings"
+)
+
+// TrackingParams are the query parameters removed from the result urls. The
+// names ending with _ are prefixes.
+var TrackingParams = []string{
+	"utm_", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid",
+	"yclid", "igshid", "mc_cid", "mc_eid", "_hsenc", "_hsmi", "mkt_tok",
+}
+
+// tracking returns true if the query parameter name is a tracking one.
+func tracking(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range TrackingParams {
+		if name == p || (strings.HasSuffix(p, "_") && strings.HasPrefix(name, p)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NormalizeURL returns the destination of the duckduckgo redirect links,
+// without the tracking parameters, with the scheme and the host in lower case
+// and without the default port. The invalid urls are returned unchanged.
+func NormalizeURL(u string) string {
+	pu, err := url.Parse(strings.TrimSpace(u))
+	if err != nil || pu.Host == "" {
+		return u
+	}
+
+	// https://duckduckgo.com/l/?uddg=<destination>&rut=...
+	if strings.HasSuffix(strings.ToLower(pu.Hostname()), "duckduckgo.com") && pu.Path == "/l/" {
+		if dst := pu.Query().Get("uddg"); dst != "" {
+			if pd, err := url.Parse(dst); err == nil && pd.Host != "" {
+				pu = pd
+			}
+		}
+	}
+
+	pu.Scheme = strings.ToLower(pu.Scheme)
+	pu.Host = strings.ToLower(pu.Host)
+	if port := pu.Port(); (pu.Scheme == "http" && port == "80") || (pu.Scheme == "https" && port == "443") {
+		pu.Host = pu.Hostname()
+	}
+
+	// the order of the parameters is kept.
+	if pu.RawQuery != "" {
+		params := strings.Split(pu.RawQuery, "&")
+		kept := params[:0]
+		for _, p := range params {
+			name, _, _ := strings.Cut(p, "=")
+			if name, err := url.QueryUnescape(name); err == nil && tracking(name) {
+				continue
+			}
+			if p != "" {
+				kept = append(kept, p)
+			}
+		}
+		pu.RawQuery = strings.Join(kept, "&")
+	}
+
+	return pu.String()
+}
+
+// dedupeKey returns the key of the normalized url u shared by the near
+// identical urls: the scheme, the www prefix, the trailing slash and the
+// fragment are ignored.
+func dedupeKey(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+
+	host := strings.TrimPrefix(pu.Host, "www.")
+	path := strings.TrimSuffix(pu.EscapedPath(), "/")
+
+	return host + path + "?" + pu.RawQuery
+}
+
+// Dedupe normalizes the urls of the results and removes the results of the
+// urls already seen. The first result of each url is kept.
+func Dedupe(res []Result) []Result {
+	seen := make(map[string]bool, len(res))
+	out := res[:0]
+	for _, r := range res {
+		r.URL = NormalizeURL(r.URL)
+		k := dedupeKey(r.URL)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, r)
+	}
+
+	return out
+}
+
+// Key returns the key identifying the results of the query on the vertical v:
+// the text is in lower case with its spaces collapsed, the default engine and
+// the web vertical are explicit.
+func (q Query) Key(v Vertical) string {
+	engine := q.Engine
+	if engine == "" {
+		engine = DefaultEngine
+	}
+	if v == "" {
+		v = VerticalWeb
+	}
+	text := strings.ToLower(strings.Join(strings.Fields(q.Text), " "))
+
+	return strings.Join([]string{
+		engine, string(v),
+		strings.ToLower(q.Region), strings.ToLower(q.Lang),
+		string(q.Safe), string(q.Time),
+		text,
+	}, "\x00")
+}
@@ -0,0 +1,206 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package search runs web searches in a browser tab.
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+const DefaultEngine = "duckduckgo"
+
+// A search engine result.
+type Result struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// Query describes a search.
+type Query struct {
+	Text   string
+	Engine string
+	// Region of the results, e.g. us-en, or a country, e.g. us.
+	Region string
+	// Language of the results, e.g. en.
+	Lang string
+	Safe SafeSearch
+	Time TimeRange
+}
+
+// SafeSearch is the filtering of the explicit results.
+// It implements flag.Value.
+type SafeSearch string
+
+const (
+	SafeOn       SafeSearch = "on"
+	SafeModerate SafeSearch = "moderate"
+	SafeOff      SafeSearch = "off"
+)
+
+var InvalidSafeSearch = errors.New("invalid safe search, must be on, moderate or off")
+
+func (s SafeSearch) String() string {
+	return string(s)
+}
+
+func (s *SafeSearch) Set(v string) error {
+	switch SafeSearch(v) {
+	case "", SafeOn, SafeModerate, SafeOff:
+		*s = SafeSearch(v)
+		return nil
+	}
+
+	return InvalidSafeSearch
+}
+
+// TimeRange restricts the results to the pages published since the last day,
+// week, month or year.
+// It implements flag.Value.
+type TimeRange string
+
+const (
+	TimeDay   TimeRange = "d"
+	TimeWeek  TimeRange = "w"
+	TimeMonth TimeRange = "m"
+	TimeYear  TimeRange = "y"
+)
+
+var InvalidTimeRange = errors.New("invalid time range, must be d, w, m or y")
+
+func (t TimeRange) String() string {
+	return string(t)
+}
+
+func (t *TimeRange) Set(v string) error {
+	switch TimeRange(v) {
+	case "", TimeDay, TimeWeek, TimeMonth, TimeYear:
+		*t = TimeRange(v)
+		return nil
+	}
+
+	return InvalidTimeRange
+}
+
+var ErrInvalidEngine = errors.New("invalid search engine")
+
+// ddgSafe maps the safe searches to the duckduckgo kp parameter.
+var ddgSafe = map[SafeSearch]string{
+	SafeOn:       "1",
+	SafeModerate: "-1",
+	SafeOff:      "-2",
+}
+
+// ddgRegion returns the duckduckgo kl parameter, a country and a language,
+// e.g. us-en. The language replaces the one of the region, "wt" is the
+// worldwide region.
+func ddgRegion(region, lang string) string {
+	if lang == "" {
+		return region
+	}
+
+	country, _, _ := strings.Cut(region, "-")
+	if country == "" {
+		country = "wt"
+	}
+
+	return country + "-" + lang
+}
+
+// URL returns the url of the results page of the query.
+func URL(q Query) (string, error) {
+	switch q.Engine {
+	case "", DefaultEngine:
+		v := url.Values{}
+		v.Set("q", q.Text)
+		if kl := ddgRegion(q.Region, q.Lang); kl != "" {
+			v.Set("kl", kl)
+		}
+		if kp, ok := ddgSafe[q.Safe]; ok {
+			v.Set("kp", kp)
+		}
+		if q.Time != "" {
+			v.Set("df", string(q.Time))
+		}
+		// the html version doesn't require javascript to display the
+		// results.
+		return "https://html.duckduckgo.com/html/?" + v.Encode(), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrInvalidEngine, q.Engine)
+}
+
+// resultsJS extracts the results from the duckduckgo html page.
+const resultsJS = `Array.from(document.querySelectorAll('.result:not(.result--ad)')).map((r) => {
+	const a = r.querySelector('a.result__a');
+	const s = r.querySelector('.result__snippet');
+	return {
+		title: a ? a.textContent.trim() : '',
+		url: a ? a.href : '',
+		snippet: s ? s.textContent.trim() : '',
+	};
+}).filter((r) => r.url !== '')`
+
+// Results returns the action extracting the results of the loaded results
+// page into res. The urls are normalized and the duplicates removed, see
+// Dedupe.
+func Results(res *[]Result) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := chromedp.Evaluate(resultsJS, res).Do(ctx); err != nil {
+			return err
+		}
+		*res = Dedupe(*res)
+		return nil
+	})
+}
+
+// Run runs the query in the tab of the chromedp context ctx and returns the
+// results. The results page stays loaded in the tab.
+func Run(ctx context.Context, q Query) ([]Result, error) {
+	u, err := URL(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Result
+	if err := chromedp.Run(ctx, chromedp.Navigate(u), Results(&res)); err != nil {
+		return nil, fmt.Errorf("search %s: %w", q.Text, err)
+	}
+
+	return res, nil
+}
+
+// Format returns the results as a numbered text list.
+func Format(res []Result) string {
+	if len(res) == 0 {
+		return "No result found."
+	}
+
+	var b strings.Builder
+	for i, r := range res {
+		fmt.Fprintf(&b, "%d. %s\n   %s\n", i+1, r.Title, r.URL)
+		if r.Snippet != "" {
+			fmt.Fprintf(&b, "   %s\n", r.Snippet)
+		}
+	}
+
+	return b.String()
+}
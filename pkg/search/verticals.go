@@ -0,0 +1,269 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// Vertical is the kind of results of a search.
+// It implements flag.Value.
+type Vertical string
+
+const (
+	VerticalWeb    Vertical = "web"
+	VerticalNews   Vertical = "news"
+	VerticalImages Vertical = "images"
+	VerticalVideos Vertical = "videos"
+)
+
+var InvalidVertical = errors.New("invalid search type, must be web, news, images or videos")
+
+func (v Vertical) String() string {
+	return string(v)
+}
+
+func (v *Vertical) Set(s string) error {
+	switch Vertical(s) {
+	case "", VerticalWeb, VerticalNews, VerticalImages, VerticalVideos:
+		*v = Vertical(s)
+		return nil
+	}
+
+	return InvalidVertical
+}
+
+// A news result.
+type NewsResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+	Source  string `json:"source"`
+	// Publication date in RFC 3339, empty if unknown.
+	Date string `json:"date"`
+}
+
+// An image result.
+type ImageResult struct {
+	Title string `json:"title"`
+	// URL of the page showing the image.
+	URL       string `json:"url"`
+	Image     string `json:"image"`
+	Thumbnail string `json:"thumbnail"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Source    string `json:"source"`
+}
+
+// A video result.
+type VideoResult struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+	Publisher   string `json:"publisher"`
+	Duration    string `json:"duration"`
+	// Publication date in RFC 3339, empty if unknown.
+	Published string `json:"published"`
+	Thumbnail string `json:"thumbnail"`
+}
+
+// VerticalURL returns the url of the page loaded before the extraction of the
+// results of the vertical v.
+func VerticalURL(q Query, v Vertical) (string, error) {
+	switch q.Engine {
+	case "", DefaultEngine:
+		p := url.Values{}
+		p.Set("q", q.Text)
+		p.Set("ia", string(v))
+		p.Set("iax", string(v))
+		return "https://duckduckgo.com/?" + p.Encode(), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrInvalidEngine, q.Engine)
+}
+
+// ddgVerticals maps the verticals to their duckduckgo json endpoints.
+var ddgVerticals = map[Vertical]string{
+	VerticalNews:   "news.js",
+	VerticalImages: "i.js",
+	VerticalVideos: "v.js",
+}
+
+// ddgImagesTime maps the time ranges to the duckduckgo images filter.
+var ddgImagesTime = map[TimeRange]string{
+	TimeDay:   "time:Day",
+	TimeWeek:  "time:Week",
+	TimeMonth: "time:Month",
+	TimeYear:  "time:Year",
+}
+
+// verticalJS fetches the results of a vertical from the loaded duckduckgo
+// page, with the vqd token of the query found in the page. The results are
+// mapped to the fields of the vertical's struct.
+const verticalJS = `(async () => {
+	const m = document.documentElement.innerHTML.match(/vqd=["']?([\d-]+)["']?/);
+	if (!m) throw new Error('no vqd token in the page');
+	const params = new URLSearchParams(%s);
+	params.set('vqd', m[1]);
+	const resp = await fetch('/%s?' + params.toString());
+	if (!resp.ok) throw new Error('status ' + resp.status);
+	const data = await resp.json();
+	const text = (s) => (s || '').replace(/<[^>]+>/g, '').trim();
+	const date = (d) => {
+		if (!d) return '';
+		const t = typeof d === 'number' ? new Date(d * 1000) : new Date(d);
+		return isNaN(t) ? '' : t.toISOString();
+	};
+	return (data.results || []).map(%s).filter((r) => r.url !== '');
+})()`
+
+// the javascript functions mapping the results of each vertical.
+var ddgMappers = map[Vertical]string{
+	VerticalNews: `(r) => ({
+		title: text(r.title),
+		url: r.url || '',
+		snippet: text(r.excerpt),
+		source: r.source || '',
+		date: date(r.date),
+	})`,
+	VerticalImages: `(r) => ({
+		title: text(r.title),
+		url: r.url || '',
+		image: r.image || '',
+		thumbnail: r.thumbnail || '',
+		width: r.width || 0,
+		height: r.height || 0,
+		source: r.source || '',
+	})`,
+	VerticalVideos: `(r) => ({
+		title: text(r.title),
+		url: r.content || '',
+		description: text(r.description),
+		publisher: r.publisher || '',
+		duration: r.duration || '',
+		published: date(r.published),
+		thumbnail: r.images ? (r.images.medium || r.images.small || '') : '',
+	})`,
+}
+
+// VerticalResults returns the action extracting the results of the vertical v
+// of the query into res, a pointer to a slice of NewsResult, ImageResult or
+// VideoResult. The page given by VerticalURL must be loaded.
+func VerticalResults(q Query, v Vertical, res any) chromedp.Action {
+	endpoint, ok := ddgVerticals[v]
+	if !ok {
+		return chromedp.ActionFunc(func(context.Context) error {
+			return fmt.Errorf("%w: %s", InvalidVertical, v)
+		})
+	}
+
+	p := map[string]string{"q": q.Text, "o": "json"}
+	if kl := ddgRegion(q.Region, q.Lang); kl != "" {
+		p["l"] = kl
+	}
+	if kp, ok := ddgSafe[q.Safe]; ok {
+		p["p"] = kp
+	}
+	if v == VerticalImages {
+		if f, ok := ddgImagesTime[q.Time]; ok {
+			p["f"] = f
+		}
+	} else if q.Time != "" {
+		p["df"] = string(q.Time)
+	}
+	params, _ := json.Marshal(p)
+
+	js := fmt.Sprintf(verticalJS, params, endpoint, ddgMappers[v])
+	return chromedp.Evaluate(js, res, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+		return p.WithAwaitPromise(true)
+	})
+}
+
+// FormatNews returns the news results as a numbered text list.
+func FormatNews(res []NewsResult) string {
+	if len(res) == 0 {
+		return "No result found."
+	}
+
+	var b strings.Builder
+	for i, r := range res {
+		fmt.Fprintf(&b, "%d. %s\n   %s\n", i+1, r.Title, r.URL)
+		if meta := join(r.Source, r.Date); meta != "" {
+			fmt.Fprintf(&b, "   %s\n", meta)
+		}
+		if r.Snippet != "" {
+			fmt.Fprintf(&b, "   %s\n", r.Snippet)
+		}
+	}
+
+	return b.String()
+}
+
+// FormatImages returns the image results as a numbered text list.
+func FormatImages(res []ImageResult) string {
+	if len(res) == 0 {
+		return "No result found."
+	}
+
+	var b strings.Builder
+	for i, r := range res {
+		fmt.Fprintf(&b, "%d. %s\n   %s\n   image: %s (%dx%d)\n", i+1, r.Title, r.URL, r.Image, r.Width, r.Height)
+		if r.Thumbnail != "" {
+			fmt.Fprintf(&b, "   thumbnail: %s\n", r.Thumbnail)
+		}
+	}
+
+	return b.String()
+}
+
+// FormatVideos returns the video results as a numbered text list.
+func FormatVideos(res []VideoResult) string {
+	if len(res) == 0 {
+		return "No result found."
+	}
+
+	var b strings.Builder
+	for i, r := range res {
+		fmt.Fprintf(&b, "%d. %s\n   %s\n", i+1, r.Title, r.URL)
+		if meta := join(r.Publisher, r.Duration, r.Published); meta != "" {
+			fmt.Fprintf(&b, "   %s\n", meta)
+		}
+		if r.Description != "" {
+			fmt.Fprintf(&b, "   %s\n", r.Description)
+		}
+	}
+
+	return b.String()
+}
+
+// join joins the non empty strings with a dot.
+func join(s ...string) string {
+	var l []string
+	for _, v := range s {
+		if v != "" {
+			l = append(l, v)
+		}
+	}
+
+	return strings.Join(l, " · ")
+}
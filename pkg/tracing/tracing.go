@@ -0,0 +1,341 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing records spans and exports them to an OpenTelemetry
+// collector with the OTLP/HTTP JSON protocol.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Spans are exported every ExportInterval or by batches of BatchSize.
+	ExportInterval = 5 * time.Second
+	BatchSize      = 512
+	// Spans kept while the collector is unreachable, the next ones are
+	// dropped.
+	MaxQueue = 8 * BatchSize
+
+	exportTimeout = 10 * time.Second
+	tracesPath    = "/v1/traces"
+)
+
+// Kinds of span.
+const (
+	KindInternal = 1
+	KindServer   = 2
+)
+
+// Status codes of span.
+const (
+	statusOK    = 1
+	statusError = 2
+)
+
+// Attr is an attribute of a span.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(k, v string) Attr  { return Attr{k, v} }
+func Int(k string, v int) Attr { return Attr{k, v} }
+
+// Tracer records the spans and exports them in background.
+// A nil tracer records nothing.
+type Tracer struct {
+	endpoint string
+	service  string
+
+	mu      sync.Mutex
+	queue   []*Span
+	dropped int
+
+	flush chan struct{}
+	done  chan struct{}
+}
+
+// New returns a tracer exporting the spans of the service to the collector
+// at endpoint, e.g. http://localhost:4318, until Close.
+func New(endpoint, service string) *Tracer {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(endpoint, tracesPath) {
+		endpoint += tracesPath
+	}
+
+	t := &Tracer{
+		endpoint: endpoint,
+		service:  service,
+		flush:    make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+
+	return t
+}
+
+type spanKey struct{}
+
+// Span is a timed operation of a trace.
+type Span struct {
+	t       *Tracer
+	traceID [16]byte
+	id      [8]byte
+	parent  [8]byte
+	name    string
+	kind    int
+	start   time.Time
+	end     time.Time
+	attrs   []Attr
+	err     error
+}
+
+// Start starts the span name, child of the span of ctx if any. The root
+// spans are server spans, e.g. the MCP requests. The returned context
+// carries the span.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{t: t, name: name, kind: KindInternal, start: time.Now(), attrs: attrs}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.traceID, s.parent = parent.traceID, parent.id
+	} else {
+		s.kind = KindServer
+		rand.Read(s.traceID[:]) // nolint:errcheck
+	}
+	rand.Read(s.id[:]) // nolint:errcheck
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttrs adds attributes to the span.
+func (s *Span) SetAttrs(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// End ends the span, failed if err isn't nil, and queues it for the export.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.end, s.err = time.Now(), err
+	s.t.enqueue(s)
+}
+
+func (t *Tracer) enqueue(s *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.queue) >= MaxQueue {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, s)
+	if len(t.queue) >= BatchSize {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tracer) run() {
+	ticker := time.NewTicker(ExportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		case <-t.flush:
+		}
+		t.export(context.Background())
+	}
+}
+
+// Close stops the background export and exports the remaining spans.
+func (t *Tracer) Close(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	close(t.done)
+	t.export(ctx)
+}
+
+// export sends the queued spans by batches. The failed batches are dropped.
+func (t *Tracer) export(ctx context.Context) {
+	for {
+		t.mu.Lock()
+		n := min(len(t.queue), BatchSize)
+		batch := t.queue[:n:n]
+		t.queue = t.queue[n:]
+		dropped := t.dropped
+		t.dropped = 0
+		t.mu.Unlock()
+
+		if dropped > 0 {
+			slog.Warn("tracing queue full, spans dropped", slog.Int("count", dropped))
+		}
+		if n == 0 {
+			return
+		}
+
+		if err := t.send(ctx, batch); err != nil {
+			slog.Error("tracing export", slog.String("endpoint", t.endpoint), slog.Any("err", err))
+			return
+		}
+	}
+}
+
+func (t *Tracer) send(ctx context.Context, spans []*Span) error {
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	b, err := json.Marshal(t.request(spans))
+	if err != nil {
+		return fmt.Errorf("encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("create http req: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do req: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// OTLP JSON encoding, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID      string     `json:"traceId"`
+	SpanID       string     `json:"spanId"`
+	ParentSpanID string     `json:"parentSpanId,omitempty"`
+	Name         string     `json:"name"`
+	Kind         int        `json:"kind"`
+	Start        string     `json:"startTimeUnixNano"`
+	End          string     `json:"endTimeUnixNano"`
+	Attributes   []otlpAttr `json:"attributes,omitempty"`
+	Status       otlpStatus `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func attr(a Attr) otlpAttr {
+	var v otlpValue
+	switch x := a.Value.(type) {
+	case int:
+		s := strconv.Itoa(x)
+		v.IntValue = &s
+	case bool:
+		v.BoolValue = &x
+	default:
+		s := fmt.Sprint(x)
+		v.StringValue = &s
+	}
+
+	return otlpAttr{Key: a.Key, Value: v}
+}
+
+func (t *Tracer) request(spans []*Span) otlpRequest {
+	scope := otlpScopeSpans{Spans: make([]otlpSpan, 0, len(spans))}
+	scope.Scope.Name = t.service
+	for _, s := range spans {
+		o := otlpSpan{
+			TraceID: hex.EncodeToString(s.traceID[:]),
+			SpanID:  hex.EncodeToString(s.id[:]),
+			Name:    s.name,
+			Kind:    s.kind,
+			Start:   strconv.FormatInt(s.start.UnixNano(), 10),
+			End:     strconv.FormatInt(s.end.UnixNano(), 10),
+			Status:  otlpStatus{Code: statusOK},
+		}
+		if s.parent != ([8]byte{}) {
+			o.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, a := range s.attrs {
+			o.Attributes = append(o.Attributes, attr(a))
+		}
+		if s.err != nil {
+			o.Status = otlpStatus{Code: statusError, Message: s.err.Error()}
+		}
+		scope.Spans = append(scope.Spans, o)
+	}
+
+	rs := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scope}}
+	rs.Resource.Attributes = []otlpAttr{attr(String("service.name", t.service))}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{rs}}
+}
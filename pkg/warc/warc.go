@@ -0,0 +1,241 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package warc writes WARC 1.1 files, the format of the web archives read by
+// pywb or warcio.
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Record types.
+	TypeInfo     = "warcinfo"
+	TypeRequest  = "request"
+	TypeResponse = "response"
+
+	contentTypeRequest  = "application/http;msgtype=request"
+	contentTypeResponse = "application/http;msgtype=response"
+)
+
+// Record is a WARC record.
+type Record struct {
+	Type      string
+	TargetURI string
+	Date      time.Time
+	// Content type of the block.
+	ContentType string
+	// Id of the record concurrent to this one, e.g. the request of a
+	// response.
+	ConcurrentTo string
+	// IP address of the server.
+	IPAddress string
+	// Digest of the payload, the HTTP body of the responses.
+	PayloadDigest string
+	Block         []byte
+}
+
+// Writer writes the records to a WARC file. The compressed writer writes
+// each record in its own gzip member, as expected in the .warc.gz files.
+// It is safe for a concurrent use.
+type Writer struct {
+	mu       sync.Mutex
+	w        io.Writer
+	compress bool
+}
+
+// NewWriter returns a writer of the records to w, compressed with gzip if
+// compress is true.
+func NewWriter(w io.Writer, compress bool) *Writer {
+	return &Writer{w: w, compress: compress}
+}
+
+// Digest returns the WARC digest of b, in base32 encoded SHA-1.
+func Digest(b []byte) string {
+	sum := sha1.Sum(b)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// WriteRecord writes the record and returns its id.
+func (w *Writer) WriteRecord(r Record) (string, error) {
+	id := "<urn:uuid:" + uuid.NewString() + ">"
+	if r.Date.IsZero() {
+		r.Date = time.Now()
+	}
+
+	var b bytes.Buffer
+	b.WriteString("WARC/1.1\r\n")
+	fmt.Fprintf(&b, "WARC-Type: %s\r\n", r.Type)
+	fmt.Fprintf(&b, "WARC-Record-ID: %s\r\n", id)
+	fmt.Fprintf(&b, "WARC-Date: %s\r\n", r.Date.UTC().Format(time.RFC3339Nano))
+	if r.TargetURI != "" {
+		fmt.Fprintf(&b, "WARC-Target-URI: %s\r\n", r.TargetURI)
+	}
+	if r.ConcurrentTo != "" {
+		fmt.Fprintf(&b, "WARC-Concurrent-To: %s\r\n", r.ConcurrentTo)
+	}
+	if r.IPAddress != "" {
+		fmt.Fprintf(&b, "WARC-IP-Address: %s\r\n", r.IPAddress)
+	}
+	fmt.Fprintf(&b, "WARC-Block-Digest: %s\r\n", Digest(r.Block))
+	if r.PayloadDigest != "" {
+		fmt.Fprintf(&b, "WARC-Payload-Digest: %s\r\n", r.PayloadDigest)
+	}
+	if r.ContentType != "" {
+		fmt.Fprintf(&b, "Content-Type: %s\r\n", r.ContentType)
+	}
+	fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n", len(r.Block))
+	b.Write(r.Block)
+	b.WriteString("\r\n\r\n")
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.compress {
+		if _, err := w.w.Write(b.Bytes()); err != nil {
+			return "", fmt.Errorf("write record: %w", err)
+		}
+		return id, nil
+	}
+
+	gz := gzip.NewWriter(w.w)
+	if _, err := gz.Write(b.Bytes()); err != nil {
+		return "", fmt.Errorf("write record: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("write record: %w", err)
+	}
+
+	return id, nil
+}
+
+// WriteInfo writes the warcinfo record starting the file.
+func (w *Writer) WriteInfo(software string) error {
+	block := fmt.Sprintf("software: %s\r\nformat: WARC File Format 1.1\r\n", software)
+	_, err := w.WriteRecord(Record{
+		Type:        TypeInfo,
+		ContentType: "application/warc-fields",
+		Block:       []byte(block),
+	})
+
+	return err
+}
+
+// Exchange is a HTTP request and its response.
+type Exchange struct {
+	Date            time.Time
+	Method          string
+	URL             string
+	RequestHeaders  http.Header
+	RequestBody     []byte
+	Status          int
+	StatusText      string
+	ResponseHeaders http.Header
+	// Decoded body of the response.
+	Body      []byte
+	IPAddress string
+}
+
+// WriteExchange writes the request and the response records of the
+// exchange. The body is decoded, its encoding headers are replaced by its
+// length.
+func (w *Writer) WriteExchange(ex Exchange) error {
+	u, err := url.Parse(ex.URL)
+	if err != nil {
+		return fmt.Errorf("parse url: %w", err)
+	}
+
+	var req bytes.Buffer
+	fmt.Fprintf(&req, "%s %s HTTP/1.1\r\n", ex.Method, u.RequestURI())
+	reqh := ex.RequestHeaders.Clone()
+	if reqh == nil {
+		reqh = http.Header{}
+	}
+	if reqh.Get("Host") == "" {
+		reqh.Set("Host", u.Host)
+	}
+	writeHeaders(&req, reqh)
+	req.Write(ex.RequestBody)
+
+	reqid, err := w.WriteRecord(Record{
+		Type:        TypeRequest,
+		TargetURI:   ex.URL,
+		Date:        ex.Date,
+		ContentType: contentTypeRequest,
+		Block:       req.Bytes(),
+	})
+	if err != nil {
+		return err
+	}
+
+	text := ex.StatusText
+	if text == "" {
+		text = http.StatusText(ex.Status)
+	}
+	var resp bytes.Buffer
+	fmt.Fprintf(&resp, "HTTP/1.1 %d %s\r\n", ex.Status, text)
+	resph := ex.ResponseHeaders.Clone()
+	if resph == nil {
+		resph = http.Header{}
+	}
+	resph.Del("Content-Encoding")
+	resph.Del("Transfer-Encoding")
+	resph.Set("Content-Length", fmt.Sprint(len(ex.Body)))
+	writeHeaders(&resp, resph)
+	resp.Write(ex.Body)
+
+	_, err = w.WriteRecord(Record{
+		Type:          TypeResponse,
+		TargetURI:     ex.URL,
+		Date:          ex.Date,
+		ContentType:   contentTypeResponse,
+		ConcurrentTo:  reqid,
+		IPAddress:     ex.IPAddress,
+		PayloadDigest: Digest(ex.Body),
+		Block:         resp.Bytes(),
+	})
+
+	return err
+}
+
+// writeHeaders writes the headers sorted by name and the blank line ending
+// them.
+func writeHeaders(b *bytes.Buffer, h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, v := range h[name] {
+			fmt.Fprintf(b, "%s: %s\r\n", name, strings.ReplaceAll(v, "\r\n", " "))
+		}
+	}
+	b.WriteString("\r\n")
+}
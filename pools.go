@@ -0,0 +1,399 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/target"
+
+	"github.com/lightpanda-io/gomcp/pkg/browser"
+)
+
+// Balance policy used to choose the browser of a new checkout.
+type BalancePolicy string
+
+const (
+	// Use the browsers in turn.
+	BalanceRoundRobin BalancePolicy = "round-robin"
+	// Use the browser with the fewest tabs in use.
+	BalanceLeastLoaded BalancePolicy = "least-loaded"
+)
+
+var InvalidBalancePolicy = errors.New("invalid balance policy")
+
+func (p BalancePolicy) String() string {
+	return string(p)
+}
+
+func (p *BalancePolicy) Set(v string) error {
+	switch BalancePolicy(v) {
+	case BalanceRoundRobin, BalanceLeastLoaded:
+		*p = BalancePolicy(v)
+		return nil
+	}
+
+	return InvalidBalancePolicy
+}
+
+const BalanceDefaultPolicy = BalanceRoundRobin
+
+// CDPEndpoints is the list of the browsers CDP urls.
+// It implements flag.Value, the flag can be repeated or contain a comma
+// separated list.
+type CDPEndpoints []string
+
+func (e *CDPEndpoints) String() string {
+	return strings.Join(*e, ",")
+}
+
+func (e *CDPEndpoints) Set(v string) error {
+	for _, ws := range strings.Split(v, ",") {
+		if ws = strings.TrimSpace(ws); ws != "" {
+			*e = append(*e, ws)
+		}
+	}
+
+	return nil
+}
+
+// tabPools distributes the tabs across the targetStores of several browsers.
+type tabPools struct {
+	sync.Mutex
+	stores []*targetStore
+	// index of the next store with the round-robin policy.
+	next int
+
+	// Policy used to choose the store of a checkout.
+	Balance BalancePolicy
+}
+
+func newTabPools() *tabPools {
+	return &tabPools{Balance: BalanceDefaultPolicy}
+}
+
+// Add creates the store of the browser reachable with the CDP url ws. The
+// browser connection is closed with ctx.
+func (p *tabPools) Add(ctx context.Context, ws string, endpoints *browser.Endpoints) *targetStore {
+	ts := newTargetStore(ctx, ws, endpoints)
+
+	p.Lock()
+	p.stores = append(p.stores, ts)
+	p.Unlock()
+
+	return ts
+}
+
+// order returns the stores in the order they must be tried for a checkout.
+// The healthy stores with free tabs come first, sorted by the balance policy.
+func (p *tabPools) order() []*targetStore {
+	p.Lock()
+	stores := slices.Clone(p.stores)
+	if p.Balance != BalanceLeastLoaded && len(stores) > 0 {
+		start := p.next % len(stores)
+		p.next++
+		stores = append(stores[start:], stores[:start]...)
+	}
+	p.Unlock()
+
+	now := time.Now()
+	type state struct {
+		rank int
+		load int
+	}
+	states := make(map[*targetStore]state, len(stores))
+	for _, ts := range stores {
+		ts.Lock()
+		st := state{load: ts.load()}
+		if len(ts.idle) == 0 && ts.full() {
+			st.rank = 1
+		}
+		if !ts.healthy(now) {
+			st.rank = 2
+		}
+		if ts.draining {
+			st.rank = 3
+		}
+		ts.Unlock()
+		states[ts] = st
+	}
+
+	slices.SortStableFunc(stores, func(a, b *targetStore) int {
+		sa, sb := states[a], states[b]
+		if sa.rank != sb.rank {
+			return sa.rank - sb.rank
+		}
+		if p.Balance == BalanceLeastLoaded {
+			return sa.load - sb.load
+		}
+		return 0
+	})
+
+	return stores
+}
+
+// Checkout returns a tab of the first store able to give one.
+func (p *tabPools) Checkout() (*tab, error) {
+	stores := p.order()
+	if len(stores) == 0 {
+		return nil, errors.New("no browser")
+	}
+	if len(stores) == 1 {
+		return stores[0].Checkout()
+	}
+
+	var errs []error
+	for _, ts := range stores {
+		t, err := ts.Checkout()
+		if err == nil {
+			return t, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", ts.Host, err))
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// Checkin gives back the tab to its store.
+func (p *tabPools) Checkin(t *tab) {
+	t.store.Checkin(t)
+}
+
+// all returns a copy of the stores.
+func (p *tabPools) all() []*targetStore {
+	p.Lock()
+	defer p.Unlock()
+
+	return slices.Clone(p.stores)
+}
+
+// Pin pins the tab in its store. The names and the limit are shared by all
+// the stores.
+func (p *tabPools) Pin(name string, t *tab, limit int) error {
+	others := 0
+	for _, ts := range p.all() {
+		if ts == t.store {
+			continue
+		}
+
+		ts.Lock()
+		_, exists := ts.pinned[name]
+		others += len(ts.pinned)
+		ts.Unlock()
+
+		if exists {
+			return ErrPinExists
+		}
+	}
+
+	return t.store.Pin(name, t, limit-others)
+}
+
+// Unpin unpins the tab pinned under name in any store.
+func (p *tabPools) Unpin(name string) error {
+	for _, ts := range p.all() {
+		if err := ts.Unpin(name); !errors.Is(err, ErrNoPinnedTab) {
+			return err
+		}
+	}
+
+	return ErrNoPinnedTab
+}
+
+// CheckoutPinned returns the tab pinned under name in any store.
+func (p *tabPools) CheckoutPinned(name string, cur *tab) (*tab, error) {
+	for _, ts := range p.all() {
+		t, err := ts.CheckoutPinned(name, cur)
+		if !errors.Is(err, ErrNoPinnedTab) {
+			return t, err
+		}
+	}
+
+	return nil, ErrNoPinnedTab
+}
+
+// Adopt attaches to the tab pinned under name by another daemon on the
+// browser host.
+func (p *tabPools) Adopt(name, host string, id target.ID) (*tab, error) {
+	for _, ts := range p.all() {
+		if ts.Host == host {
+			return ts.Adopt(name, id)
+		}
+	}
+
+	return nil, fmt.Errorf("no browser %s", host)
+}
+
+// Pins returns the pinned tabs of all the stores.
+func (p *tabPools) Pins() []PinLease {
+	var pins []PinLease
+	for _, ts := range p.all() {
+		ts.Lock()
+		for name, t := range ts.pinned {
+			pins = append(pins, PinLease{Name: name, Host: ts.Host, TargetID: t.id.String()})
+		}
+		ts.Unlock()
+	}
+
+	return pins
+}
+
+// IsPinned returns true if the tab is pinned.
+func (p *tabPools) IsPinned(t *tab) bool {
+	return t.store.IsPinned(t)
+}
+
+// Prune drops the dead tabs of all the stores.
+func (p *tabPools) Prune() int {
+	n := 0
+	for _, ts := range p.all() {
+		n += ts.Prune()
+	}
+
+	return n
+}
+
+// Sweep prunes the dead tabs of all the stores every interval until ctx is
+// done.
+func (p *tabPools) Sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Prune()
+		}
+	}
+}
+
+// List returns the info of the tabs of all the stores.
+func (p *tabPools) List() []TabInfo {
+	var infos []TabInfo
+	for _, ts := range p.all() {
+		infos = append(infos, ts.List()...)
+	}
+
+	return infos
+}
+
+// Stats returns the tabs counts per CDP host.
+func (p *tabPools) Stats() map[string]TabsStats {
+	stats := map[string]TabsStats{}
+	for _, ts := range p.all() {
+		for host, st := range ts.Stats() {
+			stats[host] = st
+		}
+	}
+
+	return stats
+}
+
+// Ping checks the websocket of the browsers having tabs. It fails if none of
+// them answers, the browsers without tab aren't connected yet.
+func (p *tabPools) Ping() error {
+	var errs []error
+	checked := 0
+	for _, ts := range p.all() {
+		t := ts.anyTab()
+		if t == nil {
+			continue
+		}
+		checked++
+		if err := t.ping(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ts.Host, err))
+		}
+	}
+	if checked > 0 && len(errs) == checked {
+		return errors.Join(errs...)
+	}
+
+	return nil
+}
+
+// CloseTarget closes the target id, it is tried on each browser until one
+// succeeds.
+func (p *tabPools) CloseTarget(id target.ID) error {
+	var errs []error
+	for _, ts := range p.all() {
+		err := ts.CloseTarget(id)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Drain drains the store of the browser host in the background and removes
+// it once its tabs are closed. done is called with the drain result.
+func (p *tabPools) Drain(host string, done func(error)) error {
+	var found *targetStore
+	for _, ts := range p.all() {
+		if ts.Host == host {
+			found = ts
+		}
+	}
+	if found == nil {
+		return fmt.Errorf("no browser %s", host)
+	}
+
+	// the store is marked first so it is drained once.
+	found.Lock()
+	draining := found.draining
+	found.draining = true
+	found.Unlock()
+	if draining {
+		return ErrDraining
+	}
+
+	go func() {
+		err := found.Drain(found.ctx)
+		if err == nil {
+			p.Lock()
+			p.stores = slices.DeleteFunc(p.stores, func(ts *targetStore) bool { return ts == found })
+			p.Unlock()
+		}
+		done(err)
+	}()
+
+	return nil
+}
+
+// Clear closes the tabs of all the stores.
+func (p *tabPools) Clear() int {
+	n := 0
+	for _, ts := range p.all() {
+		n += ts.Clear()
+	}
+
+	return n
+}
+
+// Close closes the tabs of all the stores.
+func (p *tabPools) Close() {
+	for _, ts := range p.all() {
+		ts.Close()
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"gopkg.in/yaml.v3"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+// profilesKey is the key of the profiles in the config file, it isn't an
+// option.
+const profilesKey = "profiles"
+
+// Profile tunes the fetches of the pages of some domains, e.g. the sites
+// scraped frequently.
+type Profile struct {
+	// Domains of the pages, a domain matches its subdomains too, *.domain
+	// only its subdomains.
+	Match []string `json:"match"`
+	// User agent of the browser.
+	UserAgent string `json:"userAgent"`
+	// Extra HTTP headers, added to the --header ones.
+	Headers map[string]string `json:"headers"`
+	// Selector of the element waited for before the extraction.
+	WaitFor string `json:"waitFor"`
+	// Delay after the page load, e.g. 2s.
+	Wait string `json:"wait"`
+	// Selector of the element converted in markdown, unless the fetch gives
+	// one.
+	Selector string `json:"selector"`
+	// Selectors of the elements removed before the extraction, added to the
+	// fetch ones.
+	Boilerplate []string `json:"boilerplate"`
+	// Minimum delay between two navigations to a host of the profile, e.g.
+	// 5s, it replaces the --host-delay one.
+	RateLimit string `json:"rateLimit"`
+	// JSON file of the cookies loaded before the navigations and updated
+	// after the fetches, relative to the config file.
+	Cookies string `json:"cookies"`
+
+	name      string
+	wait      time.Duration
+	rateLimit time.Duration
+	// serializes the updates of the cookies file by the concurrent fetches.
+	mu sync.Mutex
+}
+
+var ErrInvalidProfile = errors.New("invalid profile")
+
+// init checks the profile named name. The relative cookies file is resolved
+// from dir.
+func (p *Profile) init(name, dir string) error {
+	p.name = name
+	if len(p.Match) == 0 {
+		return fmt.Errorf("%w: no match", ErrInvalidProfile)
+	}
+	for i, m := range p.Match {
+		m = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(m), "."))
+		if strings.TrimPrefix(m, "*.") == "" || strings.ContainsAny(m, "/:") {
+			return fmt.Errorf("%w: match %q", ErrInvalidProfile, p.Match[i])
+		}
+		p.Match[i] = m
+	}
+
+	var err error
+	if p.Wait != "" {
+		if p.wait, err = time.ParseDuration(p.Wait); err != nil {
+			return fmt.Errorf("%w: wait: %w", ErrInvalidProfile, err)
+		}
+	}
+	if p.RateLimit != "" {
+		if p.rateLimit, err = time.ParseDuration(p.RateLimit); err != nil {
+			return fmt.Errorf("%w: rate limit: %w", ErrInvalidProfile, err)
+		}
+	}
+	if p.Cookies != "" && !filepath.IsAbs(p.Cookies) {
+		p.Cookies = filepath.Join(dir, p.Cookies)
+	}
+
+	return nil
+}
+
+// match returns the length of the pattern of the profile matching the host,
+// 0 if none matches.
+func (p *Profile) match(host string) int {
+	n := 0
+	for _, m := range p.Match {
+		domain, sub := strings.CutPrefix(m, "*.")
+		if (!sub && host == domain) || strings.HasSuffix(host, "."+domain) {
+			n = max(n, len(m))
+		}
+	}
+
+	return n
+}
+
+// browser returns true if the profile needs the browser, the static fetches
+// apply its extraction options only.
+func (p *Profile) browser() bool {
+	return p.UserAgent != "" || len(p.Headers) > 0 || p.WaitFor != "" || p.wait > 0 || p.Cookies != ""
+}
+
+// options returns the fetch options completed with the profile's.
+func (p *Profile) options(opts fetch.Options) fetch.Options {
+	if p == nil {
+		return opts
+	}
+	if opts.Selector == "" {
+		opts.Selector = p.Selector
+	}
+	if len(p.Boilerplate) > 0 {
+		opts.Boilerplate = append(slices.Clip(opts.Boilerplate), p.Boilerplate...)
+	}
+
+	return opts
+}
+
+// Profiles maps the names to the profiles.
+type Profiles map[string]*Profile
+
+// LoadProfiles reads the profiles of the YAML config file. A missing file
+// returns no profile.
+func LoadProfiles(path string) (Profiles, error) {
+	ps := Profiles{}
+	if path == "" {
+		return ps, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ps, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	// the profiles are converted in JSON like the recipes.
+	var doc struct {
+		Profiles any `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("decode config %s: %w", path, err)
+	}
+	if doc.Profiles == nil {
+		return ps, nil
+	}
+	j, err := json.Marshal(doc.Profiles)
+	if err != nil {
+		return nil, fmt.Errorf("decode profiles %s: %w", path, err)
+	}
+	if err := json.Unmarshal(j, &ps); err != nil {
+		return nil, fmt.Errorf("decode profiles %s: %w", path, err)
+	}
+
+	for name, p := range ps {
+		if err := p.init(name, filepath.Dir(path)); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+
+	return ps, nil
+}
+
+// Match returns the profile of the url u, nil if none. The profile with the
+// longest matching pattern wins, e.g. news.example.com before example.com.
+func (ps Profiles) Match(u string) *Profile {
+	pu, err := url.Parse(u)
+	if err != nil || pu.Host == "" {
+		return nil
+	}
+	host := strings.ToLower(strings.TrimSuffix(pu.Hostname(), "."))
+
+	var (
+		best *Profile
+		n    int
+	)
+	// the names break the ties.
+	for _, name := range slices.Sorted(maps.Keys(ps)) {
+		if m := ps[name].match(host); m > n {
+			best, n = ps[name], m
+		}
+	}
+
+	return best
+}
+
+// HostDelay returns the delay between two navigations to the host of the url
+// u given by its profile, if any.
+func (ps Profiles) HostDelay(u string) (time.Duration, bool) {
+	if p := ps.Match(u); p != nil && p.RateLimit != "" {
+		return p.rateLimit, true
+	}
+
+	return 0, false
+}
+
+// apply sets the user agent, the headers and the cookies of the profile in
+// the tab t before the navigation, unapply restores the tab.
+func (p *Profile) apply(ctx context.Context, t *tab) error {
+	if p.UserAgent == "" && len(p.Headers) == 0 && p.Cookies == "" {
+		return nil
+	}
+
+	var actions []chromedp.Action
+	if p.UserAgent != "" {
+		actions = append(actions, emulation.SetUserAgentOverride(p.UserAgent))
+	}
+	if len(p.Headers) > 0 {
+		h := make(network.Headers, len(t.store.Headers)+len(p.Headers))
+		for k, v := range t.store.Headers {
+			h[k] = v
+		}
+		for k, v := range p.Headers {
+			h[k] = v
+		}
+		actions = append(actions, network.SetExtraHTTPHeaders(h))
+	}
+	if p.Cookies != "" {
+		jar, err := p.loadCookies()
+		if err != nil {
+			return err
+		}
+		if len(jar) > 0 {
+			actions = append(actions, network.SetCookies(cookieParams(jar)))
+		}
+	}
+
+	if err := chromedp.Run(ctx, actions...); err != nil {
+		return fmt.Errorf("profile %s: %w", p.name, err)
+	}
+
+	return nil
+}
+
+// unapply restores the user agent and the headers of the tab t changed by
+// apply, the cookies are reset with the tab state.
+func (p *Profile) unapply(ctx context.Context, t *tab) error {
+	if p.UserAgent == "" && len(p.Headers) == 0 {
+		return nil
+	}
+
+	var actions []chromedp.Action
+	if p.UserAgent != "" {
+		// an empty user agent restores the browser's one.
+		actions = append(actions, emulation.SetUserAgentOverride(""))
+	}
+	if len(p.Headers) > 0 {
+		h := make(network.Headers, len(t.store.Headers))
+		for k, v := range t.store.Headers {
+			h[k] = v
+		}
+		actions = append(actions, network.SetExtraHTTPHeaders(h))
+	}
+
+	return chromedp.Run(ctx, actions...)
+}
+
+// waitPage waits for the element and the delay of the profile after the
+// page load.
+func (p *Profile) waitPage(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	if p.WaitFor != "" {
+		expr, by := fetch.Query(p.WaitFor)
+		if err := chromedp.Run(ctx, chromedp.WaitReady(expr, by)); err != nil {
+			return fmt.Errorf("profile %s: wait for %s: %w", p.name, p.WaitFor, err)
+		}
+	}
+	if p.wait > 0 {
+		if err := chromedp.Run(ctx, chromedp.Sleep(p.wait)); err != nil {
+			return fmt.Errorf("profile %s: wait: %w", p.name, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Profile) loadCookies() ([]SessionCookie, error) {
+	b, err := os.ReadFile(p.Cookies)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: read cookies: %w", p.name, err)
+	}
+
+	var jar []SessionCookie
+	if err := json.Unmarshal(b, &jar); err != nil {
+		return nil, fmt.Errorf("profile %s: decode cookies %s: %w", p.name, p.Cookies, err)
+	}
+
+	return jar, nil
+}
+
+// saveCookies updates the cookies file of the profile with the cookies of
+// the page u loaded in the tab of ctx.
+func (p *Profile) saveCookies(ctx context.Context, u string) error {
+	if p == nil || p.Cookies == "" {
+		return nil
+	}
+
+	var cookies []*network.Cookie
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = network.GetCookies().WithURLs([]string{u}).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return fmt.Errorf("profile %s: get cookies: %w", p.name, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	jar, err := p.loadCookies()
+	if err != nil {
+		return err
+	}
+	// the page's cookies replace the ones of the same name, domain and
+	// path.
+	key := func(c SessionCookie) string { return c.Name + "\x00" + c.Domain + "\x00" + c.Path }
+	fresh := cookieJar(cookies)
+	seen := make(map[string]bool, len(fresh))
+	for _, c := range fresh {
+		seen[key(c)] = true
+	}
+	jar = append(slices.DeleteFunc(jar, func(c SessionCookie) bool { return seen[key(c)] }), fresh...)
+
+	b, err := json.MarshalIndent(jar, "", "  ")
+	if err != nil {
+		return fmt.Errorf("profile %s: encode cookies: %w", p.name, err)
+	}
+	// the cookies authenticate the user.
+	if err := os.MkdirAll(filepath.Dir(p.Cookies), 0700); err != nil {
+		return fmt.Errorf("profile %s: cookies dir: %w", p.name, err)
+	}
+	// the temporary file is created with the 0600 permissions.
+	tmp, err := os.CreateTemp(filepath.Dir(p.Cookies), filepath.Base(p.Cookies)+".*")
+	if err != nil {
+		return fmt.Errorf("profile %s: create cookies: %w", p.name, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("profile %s: write cookies: %w", p.name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("profile %s: write cookies: %w", p.name, err)
+	}
+	if err := os.Rename(tmp.Name(), p.Cookies); err != nil {
+		return fmt.Errorf("profile %s: write cookies: %w", p.name, err)
+	}
+
+	slog.Debug("profile cookies saved", slog.String("profile", p.name), slog.Int("count", len(fresh)))
+
+	return nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+const (
+	// Delay before the next request to a host answering 429 without a
+	// valid Retry-After.
+	RateLimitDefaultBackoff = 10 * time.Second
+	// Maximum delay honored from a Retry-After.
+	RateLimitMaxBackoff = time.Minute
+)
+
+// HostLimiter paces the navigations per host, it is shared by the concurrent
+// navigations so the sites don't ban the browser.
+type HostLimiter struct {
+	// Minimum delay between the starts of two navigations to the same host,
+	// up to 50% of jitter is added.
+	Delay time.Duration
+	// Maximum number of navigations to the same host at the same time, 0
+	// means no limit.
+	MaxPerHost int
+	// Delay of the host of an url replacing Delay, e.g. given by a profile.
+	HostDelay func(u string) (time.Duration, bool)
+
+	mu    sync.Mutex
+	hosts map[string]*hostPace
+}
+
+// hostPace is the state of a host of the limiter.
+type hostPace struct {
+	// no navigation starts before.
+	next   time.Time
+	active int
+	// closed and replaced each time a navigation ends.
+	released chan struct{}
+}
+
+func NewHostLimiter() *HostLimiter {
+	return &HostLimiter{hosts: make(map[string]*hostPace)}
+}
+
+// host returns the state of the host of the url u.
+// The limiter must be locked.
+func (l *HostLimiter) host(u string) *hostPace {
+	host := u
+	if pu, err := url.Parse(u); err == nil && pu.Host != "" {
+		host = strings.ToLower(pu.Hostname())
+	}
+
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostPace{released: make(chan struct{})}
+		l.hosts[host] = h
+	}
+
+	return h
+}
+
+// Wait waits for the turn of a navigation to the url u. The returned function
+// must be called when the navigation ends.
+func (l *HostLimiter) Wait(ctx context.Context, u string) (func(), error) {
+	for {
+		l.mu.Lock()
+		h := l.host(u)
+		now := time.Now()
+		full := l.MaxPerHost > 0 && h.active >= l.MaxPerHost
+		if !full && !now.Before(h.next) {
+			h.active++
+			h.next = now.Add(l.delay(u))
+			l.mu.Unlock()
+			return func() { l.release(h) }, nil
+		}
+
+		// wait for a navigation to end or for the delay.
+		var wake <-chan time.Time
+		var timer *time.Timer
+		if !full {
+			timer = time.NewTimer(h.next.Sub(now))
+			wake = timer.C
+		}
+		released := h.released
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+		case <-released:
+		case <-wake:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// delay returns the delay of the url u with its jitter.
+func (l *HostLimiter) delay(u string) time.Duration {
+	d := l.Delay
+	if l.HostDelay != nil {
+		if hd, ok := l.HostDelay(u); ok {
+			d = hd
+		}
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return d + rand.N(d/2+1)
+}
+
+func (l *HostLimiter) release(h *hostPace) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h.active--
+	close(h.released)
+	h.released = make(chan struct{})
+}
+
+// Backoff delays the next navigations to the host of the url u by d, capped
+// to RateLimitMaxBackoff. The url is logged redacted with env.
+func (l *HostLimiter) Backoff(u string, d time.Duration, env *SessionEnv) {
+	d = min(d, RateLimitMaxBackoff)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h := l.host(u)
+	if next := time.Now().Add(d); next.After(h.next) {
+		h.next = next
+	}
+
+	slog.Warn("host rate limited", slog.String("url", env.Redact(u)), slog.Duration("backoff", d))
+}
+
+// retryAfter returns the delay of the Retry-After header, in seconds or as a
+// date, RateLimitDefaultBackoff if it is missing or invalid.
+func retryAfter(headers network.Headers) time.Duration {
+	var v string
+	for k, h := range headers {
+		if strings.EqualFold(k, "Retry-After") {
+			v, _ = h.(string)
+			break
+		}
+	}
+	v = strings.TrimSpace(v)
+
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+
+	return RateLimitDefaultBackoff
+}
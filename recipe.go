@@ -0,0 +1,317 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+const recipesDir = "recipes"
+
+// recipesdir returns the default directory of the recipes.
+func recipesdir() string {
+	dir, err := configdir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, recipesDir)
+}
+
+// Recipe is a multi-step automation of a site declared in YAML, e.g. to
+// check the status of an order. It can be run with the run command or exposed
+// as a dedicated MCP tool.
+type Recipe struct {
+	Description string `json:"description"`
+	// Params gives the default values of the parameters.
+	Params map[string]string `json:"params"`
+	// Steps are the browse actions, their url, selector and text are
+	// text/templates executed with the parameters, e.g.
+	// "https://shop.example.com/orders/{{.order}}".
+	Steps []BrowseAction `json:"steps"`
+	// Timeout of the whole recipe, e.g. 1m, BrowseDefaultTimeout by
+	// default.
+	Timeout string `json:"timeout"`
+	// Tool is the name of the MCP tool running the recipe, empty to not
+	// expose it.
+	Tool string `json:"tool"`
+
+	name    string
+	timeout time.Duration
+}
+
+// Recipes maps the names to the recipes.
+type Recipes map[string]*Recipe
+
+// LoadRecipe reads the recipe from the YAML file, it is named after the file.
+func LoadRecipe(path string) (*Recipe, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read recipe: %w", err)
+	}
+
+	// the recipe is converted in JSON to reuse the decoding of the browse
+	// actions.
+	var doc any
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("decode recipe %s: %w", path, err)
+	}
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("decode recipe %s: %w", path, err)
+	}
+	var r Recipe
+	if err := json.Unmarshal(j, &r); err != nil {
+		return nil, fmt.Errorf("decode recipe %s: %w", path, err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if err := r.init(name); err != nil {
+		return nil, fmt.Errorf("recipe %s: %w", name, err)
+	}
+
+	return &r, nil
+}
+
+// LoadRecipes reads the recipes of the YAML files of the directory. A missing
+// directory returns no recipe.
+func LoadRecipes(dir string) (Recipes, error) {
+	rs := Recipes{}
+	if dir == "" {
+		return rs, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return rs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read recipes: %w", err)
+	}
+
+	tools := map[string]string{}
+	for _, e := range entries {
+		if e.IsDir() || !slices.Contains([]string{".yaml", ".yml"}, filepath.Ext(e.Name())) {
+			continue
+		}
+
+		r, err := LoadRecipe(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		rs[r.name] = r
+
+		if r.Tool == "" {
+			continue
+		}
+		if other, ok := tools[r.Tool]; ok {
+			return nil, fmt.Errorf("recipe %s: tool %s already used by %s", r.name, r.Tool, other)
+		}
+		tools[r.Tool] = r.name
+	}
+
+	return rs, nil
+}
+
+// init validates the recipe.
+func (r *Recipe) init(name string) error {
+	r.name = name
+
+	if len(r.Steps) == 0 {
+		return ErrNoAction
+	}
+	if len(r.Steps) > BrowseMaxActions {
+		return fmt.Errorf("too many steps: %d, max %d", len(r.Steps), BrowseMaxActions)
+	}
+	for i, st := range r.Steps {
+		for _, v := range []string{st.URL, st.Selector, st.Text} {
+			if _, err := r.template(v); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+		if err := st.Fields.Validate(); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+
+	r.timeout = BrowseDefaultTimeout
+	if r.Timeout != "" {
+		d, err := time.ParseDuration(r.Timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout: %s", r.Timeout)
+		}
+		r.timeout = d
+	}
+
+	return nil
+}
+
+func (r *Recipe) template(v string) (*template.Template, error) {
+	tmpl, err := template.New(r.name).Funcs(searchFuncs).Option("missingkey=error").Parse(v)
+	if err != nil {
+		return nil, fmt.Errorf("template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// Build returns the steps with the params, merged over the default ones.
+func (r *Recipe) Build(params map[string]string) ([]BrowseAction, error) {
+	data := make(map[string]string, len(r.Params)+len(params))
+	for k, v := range r.Params {
+		data[k] = v
+	}
+	for k, v := range params {
+		data[k] = v
+	}
+
+	exec := func(v string) (string, error) {
+		tmpl, err := r.template(v)
+		if err != nil {
+			return "", err
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", fmt.Errorf("template: %w", err)
+		}
+		return b.String(), nil
+	}
+
+	steps := slices.Clone(r.Steps)
+	for i := range steps {
+		for _, v := range []*string{&steps[i].URL, &steps[i].Selector, &steps[i].Text} {
+			var err error
+			if *v, err = exec(*v); err != nil {
+				return nil, fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+	}
+
+	return steps, nil
+}
+
+// Run executes the recipe with the connection and returns the extracted
+// contents.
+func (r *Recipe) Run(ctx context.Context, conn *MCPConn, params map[string]string) (string, error) {
+	steps, err := r.Build(params)
+	if err != nil {
+		return "", err
+	}
+
+	return conn.Browse(ctx, steps, r.timeout)
+}
+
+// names returns the sorted names of the recipes.
+func (rs Recipes) names() []string {
+	names := make([]string, 0, len(rs))
+	for name := range rs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// ByTool returns the recipe exposed as the tool name.
+func (rs Recipes) ByTool(name string) (*Recipe, bool) {
+	for _, r := range rs {
+		if r.Tool != "" && r.Tool == name {
+			return r, true
+		}
+	}
+
+	return nil, false
+}
+
+// Tools returns the MCP tools of the recipes exposed as tools.
+func (rs Recipes) Tools() []mcp.Tool {
+	var tools []mcp.Tool
+	for _, name := range rs.names() {
+		r := rs[name]
+		if r.Tool == "" {
+			continue
+		}
+
+		desc := r.Description
+		if desc == "" {
+			desc = fmt.Sprintf("Run the recipe %s and return the extracted contents.", name)
+		}
+
+		props := mcp.Properties{}
+		for p, v := range r.Params {
+			props[p] = mcp.NewSchemaString(fmt.Sprintf("The %s parameter of the recipe, '%s' by default.", p, v))
+		}
+
+		tools = append(tools, mcp.Tool{
+			Name:        r.Tool,
+			Description: desc,
+			InputSchema: mcp.NewSchemaObject(props),
+		})
+	}
+
+	return tools
+}
+
+// runrecipe executes the recipe of the YAML file and prints the extracted
+// contents.
+func runrecipe(ctx context.Context, mcpsrv *MCPServer, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	params := SearchParams{}
+	flags.Var(params, "param", "parameter of the recipe as name=value, can be repeated")
+
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: run [options] <recipe.yaml>\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("bad arguments")
+	}
+
+	r, err := LoadRecipe(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	conn := mcpsrv.NewConn()
+	defer conn.Close()
+
+	res, err := r.Run(ctx, conn, params)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(stdout, res)
+	return err
+}
@@ -0,0 +1,208 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/target"
+)
+
+const (
+	ReplicaDefaultInterval = 10 * time.Second
+
+	replicaTimeout = 5 * time.Second
+)
+
+// PinLease is a tab pinned by a daemon, a standby daemon can take it over.
+type PinLease struct {
+	Name     string `json:"name"`
+	Host     string `json:"host"`
+	TargetID string `json:"targetId"`
+}
+
+// ReplicaState is the state replicated from a primary daemon to its standby.
+type ReplicaState struct {
+	// Cached cdp websocket urls per host.
+	Endpoints map[string]string `json:"endpoints"`
+	Pins      []PinLease        `json:"pins"`
+	// Persisted sse sessions, their clients resume them on the standby.
+	Sessions []ReplicaSession `json:"sessions,omitempty"`
+}
+
+// ReplicaSession is the record of a persisted session with its cookie jar.
+type ReplicaSession struct {
+	SessionRecord
+	Jar []SessionCookie `json:"jar,omitempty"`
+}
+
+// replica keeps the pinned tabs of the primary daemon.
+type replica struct {
+	sync.Mutex
+	pins map[string]PinLease
+	// hosts of the endpoints replicated from the primary.
+	hosts map[string]bool
+}
+
+// State returns the state replicated to the standby daemons.
+func (s *MCPServer) State() ReplicaState {
+	return ReplicaState{
+		Endpoints: s.endpoints.Entries(),
+		Pins:      s.tabs.Pins(),
+	}
+}
+
+// Replicate copies the state of the primary daemon every interval until ctx
+// is done. primary is the base url of the primary's API server. The sessions
+// are parked in sessions, nil ignores them.
+func (s *MCPServer) Replicate(ctx context.Context, sessions *Sessions, primary, token string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.replicate(ctx, sessions, primary, token); err != nil {
+			slog.Error("replicate", slog.String("primary", primary), slog.Any("err", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *MCPServer) replicate(ctx context.Context, sessions *Sessions, primary, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, replicaTimeout)
+	defer cancel()
+
+	u := strings.TrimSuffix(primary, "/") + "/replica/state"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("create http req: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	cli := http.DefaultClient
+	if s.ReplicaTLS != nil {
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.TLSClientConfig = s.ReplicaTLS.Clone()
+		cli = &http.Client{Transport: tr}
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return fmt.Errorf("do req: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status code: %d", resp.StatusCode)
+	}
+
+	var st ReplicaState
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return fmt.Errorf("decode state: %w", err)
+	}
+
+	hosts := make(map[string]bool, len(st.Endpoints))
+	for host, ws := range st.Endpoints {
+		s.endpoints.Set(host, ws)
+		hosts[host] = true
+	}
+
+	pins := make(map[string]PinLease, len(st.Pins))
+	for _, p := range st.Pins {
+		pins[p.Name] = p
+	}
+
+	s.replica.Lock()
+	// the endpoints forgotten by the primary are forgotten too.
+	for host := range s.replica.hosts {
+		if !hosts[host] {
+			s.endpoints.Forget(host)
+		}
+	}
+	s.replica.hosts = hosts
+	s.replica.pins = pins
+	s.replica.Unlock()
+
+	if strings.HasPrefix(primary, "https://") {
+		sessions.Replicate(st.Sessions)
+	}
+
+	return nil
+}
+
+// adopt takes over the tab pinned under name by the primary daemon.
+func (s *MCPServer) adopt(name string) (*tab, error) {
+	s.replica.Lock()
+	p, ok := s.replica.pins[name]
+	if ok {
+		// the lease is used once.
+		delete(s.replica.pins, name)
+	}
+	s.replica.Unlock()
+
+	if !ok {
+		return nil, ErrNoPinnedTab
+	}
+
+	return s.tabs.Adopt(name, p.Host, target.ID(p.TargetID))
+}
+
+// replicaAuth restricts the handler to the standby daemons giving the token.
+// Without token, the handler is restricted to the local host.
+func replicaAuth(token string, next http.HandlerFunc) http.HandlerFunc {
+	if token == "" {
+		return admin(next)
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			slog.Debug("replica access denied", slog.String("addr", req.RemoteAddr))
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		next(w, req)
+	}
+}
+
+func handleReplicaState(srv *MCPServer, sessions *Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		st := srv.State()
+		// the session ids resume the sessions and the jars authenticate
+		// them, they are sent over TLS only.
+		if req.TLS != nil {
+			st.Sessions = sessions.Replica()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(st); err != nil {
+			slog.Error("replica state encode", slog.Any("err", err))
+		}
+	}
+}
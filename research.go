@@ -0,0 +1,230 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+	"github.com/lightpanda-io/gomcp/pkg/search"
+	"github.com/lightpanda-io/gomcp/pkg/warc"
+)
+
+const (
+	ResearchDefaultResults = 3
+	ResearchMaxResults     = 10
+	// Number of pages fetched at the same time, each one uses its own tab.
+	ResearchConcurrency = 4
+	// Timeout of the fetch of each page.
+	ResearchPageTimeout = 30 * time.Second
+	// Maximum size of the content of each page, in bytes.
+	ResearchMaxContent = 20000
+)
+
+// ResearchResult is a page of the results of a research.
+type ResearchResult struct {
+	Query string `json:"query"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	// Language of the page, before its translation.
+	Language string `json:"language,omitempty"`
+	Content  string `json:"content"`
+	// Failure of the fetch, the content is empty.
+	Error string `json:"error,omitempty"`
+}
+
+// Research runs the query and fetches the n first results concurrently, each
+// one in a dedicated tab of the pool. The results are returned in the order
+// of the search, a failing page doesn't stop the others. The HTTP exchanges of
+// the pages are written to archive if not nil.
+func (s *MCPServer) Research(ctx context.Context, q search.Query, n int, archive *warc.Writer) ([]ResearchResult, error) {
+	if n <= 0 {
+		n = ResearchDefaultResults
+	}
+	if n > ResearchMaxResults {
+		return nil, fmt.Errorf("too many results: %d, max %d", n, ResearchMaxResults)
+	}
+
+	found, _, err := s.SearchPage(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("search %s: %w", q.Text, err)
+	}
+	found = found[:min(n, len(found))]
+
+	res := make([]ResearchResult, len(found))
+	sem := make(chan struct{}, ResearchConcurrency)
+
+	var wg sync.WaitGroup
+	for i, r := range found {
+		res[i] = ResearchResult{Query: q.Text, URL: r.URL, Title: r.Title}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				res[i].Error = ctx.Err().Error()
+				return
+			}
+
+			page, _, err := s.FetchPage(ctx, PageJob{
+				Op:  "research",
+				URL: r.URL,
+				Options: fetch.Options{
+					Timeout:           ResearchPageTimeout,
+					FailOnErrorStatus: true,
+					Clicks:            s.Clicks,
+					ClickWait:         s.ClickWait,
+					DismissConsent:    s.DismissConsent,
+					Sites:             s.SiteExtractors,
+					Sanitize:          s.Sanitize,
+					MaxContent:        s.MaxContent,
+					Truncation:        s.Truncation,
+					Boilerplate:       s.Boilerplate,
+				},
+				Translate: true,
+				WARC:      archive,
+			})
+			if err != nil {
+				res[i].Error = err.Error()
+				return
+			}
+
+			if page.URL != "" {
+				res[i].URL = page.URL
+			}
+			if page.Title != "" {
+				res[i].Title = page.Title
+			}
+			res[i].Language = page.Language
+			res[i].Content = s.researchContent(page.URL, page.Markdown)
+		}()
+	}
+	wg.Wait()
+
+	return res, nil
+}
+
+// researchContent flags the injections of the content of the page at location and
+// truncates it to ResearchMaxContent.
+func (s *MCPServer) researchContent(location, content string) string {
+	if s.Injections != "" && s.Injections != InjectionOff {
+		if found := fetch.FindInjections(content, nil); len(found) > 0 {
+			content = s.flagInjections(location, content, found, false)
+		}
+	}
+
+	return ResponseShape{MaxText: ResearchMaxContent}.truncate(content)
+}
+
+// FormatResearch returns the pages of the research, one section per page.
+func FormatResearch(res []ResearchResult) string {
+	if len(res) == 0 {
+		return "No result found."
+	}
+
+	var b strings.Builder
+	for i, r := range res {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "## %d. %s\n%s\n", i+1, r.Title, r.URL)
+		if r.Language != "" {
+			fmt.Fprintf(&b, "Language: %s\n", r.Language)
+		}
+		b.WriteString("\n")
+		if r.Error != "" {
+			fmt.Fprintf(&b, "Error: %s\n", r.Error)
+			continue
+		}
+		b.WriteString(strings.TrimSpace(r.Content))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// runresearch executes the research command.
+func runresearch(ctx context.Context, mcpsrv *MCPServer, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("research", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var (
+		n       = flags.Int("results", ResearchDefaultResults, fmt.Sprintf("number of results fetched, max %d", ResearchMaxResults))
+		asjson  = flags.Bool("json", false, "print the pages in JSON")
+		region  = flags.String("region", "", "region of the results, e.g. us-en")
+		lang    = flags.String("lang", "", "language of the results, e.g. en")
+		warcout = flags.String("warc", "", "WARC file receiving the HTTP requests and responses of the fetched pages, compressed if it ends with .gz, e.g. out.warc.gz")
+	)
+
+	var (
+		safe search.SafeSearch
+		tr   search.TimeRange
+	)
+	flags.Var(&safe, "safe", "filtering of the explicit results: on, moderate or off")
+	flags.Var(&tr, "time", "recency of the results: d, w, m or y")
+
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: research [options] <text>\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	text := strings.Join(flags.Args(), " ")
+	if text == "" {
+		flags.Usage()
+		return errors.New("no text")
+	}
+
+	var archive *warc.Writer
+	if *warcout != "" {
+		w, f, err := createWARC(*warcout)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		archive = w
+	}
+
+	res, err := mcpsrv.Research(ctx, search.Query{Text: text, Region: *region, Lang: *lang, Safe: safe, Time: tr}, *n, archive)
+	if err != nil {
+		return err
+	}
+
+	if *asjson {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			return fmt.Errorf("json encode: %w", err)
+		}
+		return nil
+	}
+
+	_, err = io.WriteString(stdout, FormatResearch(res))
+	return err
+}
@@ -0,0 +1,215 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+const (
+	RetriesDefault      = 2
+	RetryDefaultBackoff = 500 * time.Millisecond
+)
+
+// ErrEmptyContent is the failure of an attempt loading a page without
+// content, e.g. a page still rendering or a blocked request.
+var ErrEmptyContent = errors.New("empty content")
+
+// RetryError is returned when all the attempts of an operation failed, it
+// gives the cause of every attempt.
+type RetryError struct {
+	Op       string
+	Attempts []error
+}
+
+func (e *RetryError) Error() string {
+	causes := make([]string, len(e.Attempts))
+	for i, err := range e.Attempts {
+		causes[i] = fmt.Sprintf("attempt %d: %s", i+1, err)
+	}
+
+	return fmt.Sprintf("%s failed after %d attempts: %s", e.Op, len(e.Attempts), strings.Join(causes, "; "))
+}
+
+// Unwrap returns the causes from the last attempt, so the error is classified
+// by its final cause.
+func (e *RetryError) Unwrap() []error {
+	errs := slices.Clone(e.Attempts)
+	slices.Reverse(errs)
+	return errs
+}
+
+// transientErrors are the messages of the navigation and CDP failures which
+// may not happen again.
+var transientErrors = []string{
+	"net::ERR_CONNECTION_RESET",
+	"net::ERR_CONNECTION_CLOSED",
+	"net::ERR_CONNECTION_REFUSED",
+	"net::ERR_CONNECTION_TIMED_OUT",
+	"net::ERR_TIMED_OUT",
+	"net::ERR_EMPTY_RESPONSE",
+	"net::ERR_NETWORK_CHANGED",
+	"net::ERR_INTERNET_DISCONNECTED",
+	"net::ERR_ADDRESS_UNREACHABLE",
+	"net::ERR_HTTP2_PROTOCOL_ERROR",
+	"websocket: close",
+	"use of closed network connection",
+	"broken pipe",
+}
+
+// transient returns true if the operation failing with err can be retried.
+func transient(err error) bool {
+	var (
+		cerr *CDPConnectError
+		serr *fetch.StatusError
+	)
+	switch {
+	case errors.Is(err, ErrEmptyContent), errors.Is(err, chromedp.ErrChannelClosed), errors.As(err, &cerr):
+		return true
+	case errors.As(err, &serr):
+		return serr.Status == http.StatusBadGateway || serr.Status == http.StatusServiceUnavailable || serr.Status == http.StatusGatewayTimeout
+	}
+
+	msg := err.Error()
+	for _, t := range transientErrors {
+		if strings.Contains(msg, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retry runs fn until it succeeds, up to retries more times while it fails
+// with an error retriable according to can. The delay between the attempts
+// starts at backoff and doubles. If several attempts failed, the returned
+// RetryError gives the cause of each one.
+func retry(ctx context.Context, op string, retries int, backoff time.Duration, can func(error) bool, fn func(attempt int) error) error {
+	var errs []error
+	delay := backoff
+	for attempt := 0; ; attempt++ {
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+
+		if attempt >= retries || ctx.Err() != nil || !can(err) {
+			break
+		}
+
+		// the op and the error may give the url.
+		env := envOf(ctx)
+		slog.Warn("retry",
+			slog.String("op", env.Redact(op)),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("delay", delay),
+			slog.Any("err", env.RedactError(err)),
+		)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+
+	return &RetryError{Op: op, Attempts: errs}
+}
+
+// navigate loads the url u and runs the actions on the current tab. The
+// navigation is audited, it fails with a BotChallengeError if the page is a
+// bot challenge not solved. The actions are
+// retried up to the server's Retries times with an exponential backoff if
+// they fail with a transient error or if the browser connection drops, the
+// connection is re-established first.
+func (c *MCPConn) navigate(ctx context.Context, u string, actions ...chromedp.Action) (err error) {
+	// the browser connection has been lost.
+	lost := false
+	can := func(err error) bool {
+		lost = c.tab == nil || !c.tab.alive()
+		return lost || transient(err)
+	}
+
+	ctx, span := c.srv.Tracer.Start(ctx, "navigate")
+	defer func() { span.End(err) }()
+
+	t, received := c.tab, c.tab.received()
+	start := time.Now()
+	err = retry(ctx, "navigate", c.srv.Retries, c.srv.RetryBackoff, can, func(attempt int) error {
+		if lost {
+			if err := c.reconnect(ctx); err != nil {
+				return fmt.Errorf("reconnect: %w", err)
+			}
+		}
+
+		return c.run(ctx, append([]chromedp.Action{chromedp.Navigate(u)}, actions...)...)
+	})
+	// a solved challenge is loaded once more.
+	for solve := true; err == nil; solve = false {
+		tctx, cancel := c.tabctx(ctx)
+		again, cerr := c.srv.challenge(tctx, &c.env, solve)
+		cancel()
+		if err = cerr; !again {
+			break
+		}
+		err = c.run(ctx, append([]chromedp.Action{chromedp.Navigate(u)}, actions...)...)
+	}
+	c.srv.telemetry.navigation(time.Since(start), err)
+
+	// a reconnected tab received only the last attempt.
+	if c.tab != t {
+		received = 0
+	}
+	aerr := err
+	if aerr != nil {
+		aerr = navigateError(u, err)
+	}
+	c.srv.Audit.navigation(c.session, c.env.Redact(u), start, c.tab.received()-received, c.env.RedactError(aerr))
+
+	return err
+}
+
+// reconnect replaces the dead tab of the connection with a new one, the
+// browser connection is re-established if needed.
+func (c *MCPConn) reconnect(ctx context.Context) error {
+	t := c.tab
+	if t != nil {
+		t.store.Reconnect(ctx, t)
+
+		// the dead tab is dropped on checkin.
+		c.srv.tabs.Checkin(t)
+		c.pages = slices.DeleteFunc(c.pages, func(p *tab) bool { return p == t })
+		c.tab = nil
+		c.cdpctx = nil
+	}
+
+	return c.connect(ctx)
+}
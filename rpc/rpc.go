@@ -22,6 +22,26 @@ import (
 
 const Version = "2.0"
 
+// Error codes.
+const (
+	InvalidRequestCode = -32600
+
+	// Implementation defined server error codes are reserved from -32000 to
+	// -32099.
+	TooManyRequestsCode = -32029
+
+	// Failure classes of the tools calls.
+	ServerErrorCode       = -32000
+	NavigationTimeoutCode = -32001
+	DNSFailureCode        = -32002
+	HTTPStatusCode        = -32003
+	CDPConnectCode        = -32004
+	BlockedCode           = -32005
+	LimitExceededCode     = -32006
+	ForbiddenCode         = -32007
+	BotChallengeCode      = -32008
+)
+
 type Error struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -60,7 +80,8 @@ func (req Request) Err() error {
 type Response struct {
 	Version string `json:"jsonrpc"`
 	Id      int    `json:"id"`
-	Result  any    `json:"result"`
+	Result  any    `json:"result,omitempty"`
+	Error   *Error `json:"error,omitempty"`
 }
 
 func NewResponse(data any, id int) Response {
@@ -70,3 +91,11 @@ func NewResponse(data any, id int) Response {
 		Version: Version,
 	}
 }
+
+func NewErrorResponse(code int, message string, id int) Response {
+	return Response{
+		Error:   &Error{Code: code, Message: message},
+		Id:      id,
+		Version: Version,
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+// Action applied to the flagged contents.
+type FilterAction string
+
+const (
+	// Replace the flagged parts of the content.
+	FilterRedact FilterAction = "redact"
+	// Return an error instead of the content.
+	FilterBlock FilterAction = "block"
+)
+
+var InvalidFilterAction = errors.New("invalid filter action")
+
+func (a FilterAction) String() string {
+	return string(a)
+}
+
+func (a *FilterAction) Set(v string) error {
+	switch FilterAction(v) {
+	case FilterRedact, FilterBlock:
+		*a = FilterAction(v)
+		return nil
+	}
+
+	return InvalidFilterAction
+}
+
+// Handling of the suspected prompt injections of the pages contents.
+type InjectionPolicy string
+
+const (
+	// Return the content verbatim.
+	InjectionOff InjectionPolicy = "off"
+	// Warn the agent about the injections found.
+	InjectionFlag InjectionPolicy = "flag"
+	// Warn the agent and remove the injections from the content.
+	InjectionStrip InjectionPolicy = "strip"
+
+	InjectionDefaultPolicy = InjectionFlag
+)
+
+var InvalidInjectionPolicy = errors.New("invalid injection policy")
+
+func (p InjectionPolicy) String() string {
+	return string(p)
+}
+
+func (p *InjectionPolicy) Set(v string) error {
+	switch InjectionPolicy(v) {
+	case InjectionOff, InjectionFlag, InjectionStrip:
+		*p = InjectionPolicy(v)
+		return nil
+	}
+
+	return InvalidInjectionPolicy
+}
+
+const (
+	FilterDefaultAction = FilterBlock
+
+	filterClassifierTimeout = 10 * time.Second
+	filterRedacted          = "[redacted]"
+)
+
+// BlockedError is returned when the filter blocks a content.
+type BlockedError struct {
+	URL    string
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("content of %s blocked by the safety filter: %s", e.URL, e.Reason)
+}
+
+// ContentFilter flags the unsafe pages by domain, by keyword or with an
+// external classifier before their content is returned to the agent.
+type ContentFilter struct {
+	// Domains flagged, their subdomains are flagged too.
+	Domains []string
+	// Keywords flagged, case insensitive.
+	Keywords []string
+	// Classifier is the url of an external classifier. It receives a JSON
+	// object with the url and the content of the page and returns a JSON
+	// object with a boolean flagged and a reason.
+	Classifier string
+
+	Action FilterAction
+}
+
+// NewContentFilter loads the domains and keywords list files, one entry per
+// line. It returns nil if no filter is configured.
+func NewContentFilter(domains, keywords, classifier string, action FilterAction) (*ContentFilter, error) {
+	if domains == "" && keywords == "" && classifier == "" {
+		return nil, nil
+	}
+
+	f := &ContentFilter{Classifier: classifier, Action: action}
+
+	var err error
+	if f.Domains, err = readList(domains); err != nil {
+		return nil, fmt.Errorf("filter domains: %w", err)
+	}
+	if f.Keywords, err = readList(keywords); err != nil {
+		return nil, fmt.Errorf("filter keywords: %w", err)
+	}
+
+	return f, nil
+}
+
+// readList returns the lowercased lines of the file, the empty lines and the
+// comments starting with # are ignored.
+func readList(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	var list []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.ToLower(strings.TrimSpace(s.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list = append(list, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	return list, nil
+}
+
+// Check returns the content of the page u, redacted, or a BlockedError if
+// it is flagged. A failing classifier lets the content pass.
+func (f *ContentFilter) Check(ctx context.Context, u, content string) (string, error) {
+	if f == nil {
+		return content, nil
+	}
+
+	if d := f.domain(u); d != "" {
+		return f.flag(u, content, "domain "+d)
+	}
+
+	if len(f.Keywords) > 0 {
+		if f.Action == FilterBlock {
+			if kw := f.keyword(content); kw != "" {
+				return "", &BlockedError{URL: u, Reason: "keyword " + kw}
+			}
+		} else {
+			content = f.redact(content)
+		}
+	}
+
+	if f.Classifier != "" {
+		flagged, reason, err := f.classify(ctx, u, content)
+		if err != nil {
+			slog.Error("safety classifier", slog.String("url", u), slog.Any("err", err))
+			return content, nil
+		}
+		if flagged {
+			return f.flag(u, content, "classifier "+reason)
+		}
+	}
+
+	return content, nil
+}
+
+// flag applies the action on the whole content.
+func (f *ContentFilter) flag(u, content, reason string) (string, error) {
+	slog.Info("unsafe content", slog.String("url", u), slog.String("reason", reason))
+	if f.Action == FilterRedact {
+		return filterRedacted + " " + reason, nil
+	}
+
+	return "", &BlockedError{URL: u, Reason: reason}
+}
+
+// domain returns the flagged domain matching the host of u.
+func (f *ContentFilter) domain(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(pu.Hostname())
+	for _, d := range f.Domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return d
+		}
+	}
+
+	return ""
+}
+
+// keyword returns the first flagged keyword found in the content.
+func (f *ContentFilter) keyword(content string) string {
+	lower := strings.ToLower(content)
+	for _, kw := range f.Keywords {
+		if strings.Contains(lower, kw) {
+			return kw
+		}
+	}
+
+	return ""
+}
+
+// redact replaces the lines containing a flagged keyword.
+func (f *ContentFilter) redact(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, l := range lines {
+		if f.keyword(l) != "" {
+			lines[i] = filterRedacted
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// classify sends the content to the external classifier.
+func (f *ContentFilter) classify(ctx context.Context, u, content string) (bool, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, filterClassifierTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(struct {
+		URL     string `json:"url"`
+		Content string `json:"content"`
+	}{u, content})
+	if err != nil {
+		return false, "", fmt.Errorf("encode: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.Classifier, bytes.NewReader(body))
+	if err != nil {
+		return false, "", fmt.Errorf("create http req: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, "", fmt.Errorf("do req: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("bad status code: %d", resp.StatusCode)
+	}
+
+	var res struct {
+		Flagged bool   `json:"flagged"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return false, "", fmt.Errorf("decode response: %w", err)
+	}
+
+	return res.Flagged, res.Reason, nil
+}
+
+// screen applies the safety filter of the server on the content of the
+// current page.
+func (c *MCPConn) screen(ctx context.Context, content string) (string, error) {
+	if c.srv.Filter == nil {
+		return content, nil
+	}
+
+	var location string
+	if err := c.run(ctx, chromedp.Location(&location)); err != nil {
+		return "", fmt.Errorf("page location: %w", err)
+	}
+
+	return c.srv.Filter.Check(ctx, location, content)
+}
+
+// guard scans the markdown content of the current page for prompt
+// injections, in its text and in the texts hidden with CSS, and flags or
+// strips them according to the injection policy of the server. raw keeps the
+// content verbatim, the injections are still flagged.
+func (c *MCPConn) guard(ctx context.Context, content string, raw bool) string {
+	policy := c.srv.Injections
+	if policy == "" || policy == InjectionOff {
+		return content
+	}
+
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	hidden, err := fetch.HiddenTexts(tctx)
+	if err != nil {
+		slog.Debug("hidden texts", slog.Any("err", err))
+	}
+	found := fetch.FindInjections(content, hidden)
+	if len(found) == 0 {
+		return content
+	}
+
+	var location string
+	if err := chromedp.Run(tctx, chromedp.Location(&location)); err != nil {
+		slog.Debug("page location", slog.Any("err", err))
+	}
+
+	return c.srv.flagInjections(location, content, found, raw)
+}
+
+// flagInjections flags or strips the injections found in the content of the
+// page at location according to the injection policy of the server.
+func (s *MCPServer) flagInjections(location, content string, found []fetch.Injection, raw bool) string {
+	slog.Info("suspected prompt injection", slog.String("url", location), slog.Int("count", len(found)))
+
+	strip := s.Injections == InjectionStrip && !raw
+	if strip {
+		content = fetch.StripInjections(content, found)
+	}
+
+	return fetch.FormatInjections(found, strip) + "\n" + content
+}
@@ -0,0 +1,375 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/mcp"
+	"github.com/lightpanda-io/gomcp/pkg/search"
+)
+
+// SavedSearch is a search declared in the config, it can be run manually, on
+// a schedule or exposed as a dedicated MCP tool.
+type SavedSearch struct {
+	Description string `json:"description"`
+	Engine      string `json:"engine"`
+	// Query is a text/template executed with the parameters, e.g.
+	// "golang {{.topic}} after:{{now.Format \"2006-01-02\"}}".
+	Query  string `json:"query"`
+	Region string `json:"region"`
+	// Params gives the default values of the query parameters.
+	Params map[string]string `json:"params"`
+	// Output is the sink of the results: empty or - for stdout, an http(s)
+	// url receiving a POST or a file path. Files and urls receive JSON.
+	Output string `json:"output"`
+	// Every is the interval of the scheduled runs, e.g. 1h. Empty disables
+	// the schedule.
+	Every string `json:"every"`
+	// Tool is the name of the MCP tool running the search, empty to not
+	// expose it.
+	Tool string `json:"tool"`
+
+	name  string
+	every time.Duration
+	tmpl  *template.Template
+}
+
+// SavedSearches maps the names to the saved searches.
+type SavedSearches map[string]*SavedSearch
+
+const savedSearchesFile = "searches.json"
+
+var ErrNoSavedSearch = errors.New("no saved search")
+
+// searchesfile returns the default path of the saved searches file.
+func searchesfile() string {
+	dir, err := configdir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, savedSearchesFile)
+}
+
+// LoadSavedSearches reads the saved searches from the JSON file. A missing
+// file returns no search.
+func LoadSavedSearches(path string) (SavedSearches, error) {
+	ss := SavedSearches{}
+	if path == "" {
+		return ss, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ss, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read saved searches: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return nil, fmt.Errorf("decode saved searches %s: %w", path, err)
+	}
+
+	tools := map[string]string{}
+	for name, s := range ss {
+		if err := s.init(name); err != nil {
+			return nil, fmt.Errorf("saved search %s: %w", name, err)
+		}
+
+		if s.Tool == "" {
+			continue
+		}
+		if other, ok := tools[s.Tool]; ok {
+			return nil, fmt.Errorf("saved search %s: tool %s already used by %s", name, s.Tool, other)
+		}
+		tools[s.Tool] = name
+	}
+
+	return ss, nil
+}
+
+var searchFuncs = template.FuncMap{
+	"now": time.Now,
+}
+
+// init validates the saved search and prepares its template.
+func (s *SavedSearch) init(name string) error {
+	s.name = name
+
+	if s.Query == "" {
+		return errors.New("no query")
+	}
+
+	tmpl, err := template.New(name).Funcs(searchFuncs).Option("missingkey=error").Parse(s.Query)
+	if err != nil {
+		return fmt.Errorf("query template: %w", err)
+	}
+	s.tmpl = tmpl
+
+	if s.Every != "" {
+		d, err := time.ParseDuration(s.Every)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid schedule: %s", s.Every)
+		}
+		s.every = d
+	}
+
+	if _, err := search.URL(search.Query{Engine: s.Engine}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Get returns the saved search name.
+func (ss SavedSearches) Get(name string) (*SavedSearch, error) {
+	s, ok := ss[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoSavedSearch, name)
+	}
+
+	return s, nil
+}
+
+// names returns the sorted names of the saved searches.
+func (ss SavedSearches) names() []string {
+	names := make([]string, 0, len(ss))
+	for name := range ss {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// ByTool returns the saved search exposed as the tool name.
+func (ss SavedSearches) ByTool(name string) (*SavedSearch, bool) {
+	for _, s := range ss {
+		if s.Tool != "" && s.Tool == name {
+			return s, true
+		}
+	}
+
+	return nil, false
+}
+
+// Build executes the query template with the params merged over the default
+// ones.
+func (s *SavedSearch) Build(params map[string]string) (search.Query, error) {
+	data := make(map[string]string, len(s.Params)+len(params))
+	for k, v := range s.Params {
+		data[k] = v
+	}
+	for k, v := range params {
+		data[k] = v
+	}
+
+	var b strings.Builder
+	if err := s.tmpl.Execute(&b, data); err != nil {
+		return search.Query{}, fmt.Errorf("query template: %w", err)
+	}
+
+	return search.Query{
+		Text:   strings.TrimSpace(b.String()),
+		Engine: s.Engine,
+		Region: s.Region,
+	}, nil
+}
+
+// Tools returns the MCP tools of the saved searches exposed as tools.
+func (ss SavedSearches) Tools() []mcp.Tool {
+	var tools []mcp.Tool
+	for _, name := range ss.names() {
+		s := ss[name]
+		if s.Tool == "" {
+			continue
+		}
+
+		desc := s.Description
+		if desc == "" {
+			desc = fmt.Sprintf("Run the saved search %s and return the results.", name)
+		}
+
+		props := mcp.Properties{}
+		for p, v := range s.Params {
+			props[p] = mcp.NewSchemaString(fmt.Sprintf("The %s parameter of the query, '%s' by default.", p, v))
+		}
+
+		tools = append(tools, mcp.Tool{
+			Name:        s.Tool,
+			Description: desc,
+			InputSchema: mcp.NewSchemaObject(props),
+		})
+	}
+
+	return tools
+}
+
+// SavedSearchReport is the JSON document sent to the file and url sinks.
+type SavedSearchReport struct {
+	Name    string          `json:"name"`
+	Query   string          `json:"query"`
+	Engine  string          `json:"engine,omitempty"`
+	Region  string          `json:"region,omitempty"`
+	RunAt   time.Time       `json:"runAt"`
+	Results []search.Result `json:"results"`
+}
+
+// Run executes the saved search with the connection and writes the results
+// to the search's output sink. stdout is used for the - sink.
+func (s *SavedSearch) Run(ctx context.Context, conn *MCPConn, params map[string]string, stdout io.Writer) error {
+	q, err := s.Build(params)
+	if err != nil {
+		return err
+	}
+
+	res, err := conn.Search(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	return s.write(ctx, SavedSearchReport{
+		Name:    s.name,
+		Query:   q.Text,
+		Engine:  q.Engine,
+		Region:  q.Region,
+		RunAt:   time.Now(),
+		Results: res,
+	}, stdout)
+}
+
+// write sends the report to the output sink.
+func (s *SavedSearch) write(ctx context.Context, r SavedSearchReport, stdout io.Writer) error {
+	switch {
+	case s.Output == "" || s.Output == "-":
+		_, err := io.WriteString(stdout, search.Format(r.Results))
+		return err
+	case strings.HasPrefix(s.Output, "http://") || strings.HasPrefix(s.Output, "https://"):
+		b, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("encode report: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Output, bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("create http req: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("do req: %w", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("bad status code: %d", resp.StatusCode)
+		}
+
+		return nil
+	}
+
+	// files receive one JSON report per line.
+	f, err := os.OpenFile(s.Output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open output: %w", err)
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(r); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+
+	return nil
+}
+
+// Schedule runs the saved searches with a schedule every interval until ctx
+// is done.
+func (ss SavedSearches) Schedule(ctx context.Context, srv *MCPServer) {
+	for _, name := range ss.names() {
+		s := ss[name]
+		if s.every == 0 {
+			continue
+		}
+		// stdout is used by the stdio transport.
+		if s.Output == "" || s.Output == "-" {
+			slog.Warn("scheduled search without output, ignored", slog.String("name", name))
+			continue
+		}
+
+		slog.Debug("schedule saved search", slog.String("name", name), slog.Duration("every", s.every))
+		go s.schedule(ctx, srv)
+	}
+}
+
+func (s *SavedSearch) schedule(ctx context.Context, srv *MCPServer) {
+	ticker := time.NewTicker(s.every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			conn := srv.NewConn()
+			err := s.Run(ctx, conn, nil, io.Discard)
+			conn.Close()
+			if err != nil {
+				slog.Error("scheduled search", slog.String("name", s.name), slog.Any("err", err))
+			}
+		}
+	}
+}
+
+// SearchParams are the query parameters given on the command line.
+// It implements flag.Value with the format name=value.
+type SearchParams map[string]string
+
+var InvalidSearchParam = errors.New("invalid search param, the format is name=value")
+
+func (p SearchParams) String() string {
+	s := make([]string, 0, len(p))
+	for k, v := range p {
+		s = append(s, k+"="+v)
+	}
+	slices.Sort(s)
+
+	return strings.Join(s, ",")
+}
+
+func (p SearchParams) Set(v string) error {
+	name, value, ok := strings.Cut(v, "=")
+	if !ok || name == "" {
+		return InvalidSearchParam
+	}
+
+	p[name] = value
+	return nil
+}
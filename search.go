@@ -0,0 +1,284 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/mcp"
+	"github.com/lightpanda-io/gomcp/pkg/search"
+)
+
+// Search runs the query and returns the results. The results page stays
+// loaded in the connection's tab, unless the results are cached.
+func (c *MCPConn) Search(ctx context.Context, q search.Query) ([]search.Result, error) {
+	return cachedSearch(ctx, c.srv.SearchCache, q, search.VerticalWeb, func() ([]search.Result, error) {
+		return c.search(ctx, q)
+	})
+}
+
+func (c *MCPConn) search(ctx context.Context, q search.Query) ([]search.Result, error) {
+	u, err := search.URL(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.connect(ctx); err != nil {
+		return nil, fmt.Errorf("browser connect: %w", err)
+	}
+	if err := emulate(c.cdpctx, c.tab, c.emulation()); err != nil {
+		return nil, err
+	}
+
+	var res []search.Result
+	release, err := c.srv.Limiter.Wait(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	err = c.navigate(ctx, u, search.Results(&res))
+	c.srv.telemetry.search(q, search.VerticalWeb, time.Since(start))
+	release()
+	if err != nil {
+		c.srv.diagnose(c.cdpctx, &c.env, "search", err.Error())
+		return nil, fmt.Errorf("search %s: %w", q.Text, navigateError(u, err))
+	}
+	if len(res) == 0 {
+		c.srv.diagnose(c.cdpctx, &c.env, "search", "no result for "+q.Text)
+	}
+
+	return res, nil
+}
+
+// SearchVertical runs the query on the vertical v and returns the formatted
+// results.
+func (c *MCPConn) SearchVertical(ctx context.Context, q search.Query, v search.Vertical) (string, error) {
+	switch v {
+	case search.VerticalNews:
+		res, err := searchVertical[search.NewsResult](ctx, c, q, v)
+		return search.FormatNews(res), err
+	case search.VerticalImages:
+		res, err := searchVertical[search.ImageResult](ctx, c, q, v)
+		return search.FormatImages(res), err
+	case search.VerticalVideos:
+		res, err := searchVertical[search.VideoResult](ctx, c, q, v)
+		return search.FormatVideos(res), err
+	}
+
+	res, err := c.Search(ctx, q)
+	return search.Format(res), err
+}
+
+// searchVertical runs the query on the vertical v and returns its results of
+// type T.
+func searchVertical[T any](ctx context.Context, c *MCPConn, q search.Query, v search.Vertical) ([]T, error) {
+	return cachedSearch(ctx, c.srv.SearchCache, q, v, func() ([]T, error) {
+		return runVertical[T](ctx, c, q, v)
+	})
+}
+
+func runVertical[T any](ctx context.Context, c *MCPConn, q search.Query, v search.Vertical) ([]T, error) {
+	u, err := search.VerticalURL(q, v)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.connect(ctx); err != nil {
+		return nil, fmt.Errorf("browser connect: %w", err)
+	}
+	if err := emulate(c.cdpctx, c.tab, c.emulation()); err != nil {
+		return nil, err
+	}
+
+	var res []T
+	release, err := c.srv.Limiter.Wait(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	err = c.navigate(ctx, u, search.VerticalResults(q, v, &res))
+	c.srv.telemetry.search(q, v, time.Since(start))
+	release()
+	if err != nil {
+		c.srv.diagnose(c.cdpctx, &c.env, "search", err.Error())
+		return nil, fmt.Errorf("search %s %s: %w", v, q.Text, navigateError(u, err))
+	}
+
+	return res, nil
+}
+
+// SearchFilters are the filters of the search tools' arguments.
+type SearchFilters struct {
+	Region string `json:"region"`
+	Lang   string `json:"lang"`
+	Safe   string `json:"safe"`
+	Time   string `json:"time"`
+	// Bypass the cached results.
+	Fresh bool `json:"fresh"`
+}
+
+// context returns ctx bypassing the search cache if the filters require fresh
+// results.
+func (f SearchFilters) context(ctx context.Context) context.Context {
+	if f.Fresh {
+		return withFreshSearch(ctx)
+	}
+
+	return ctx
+}
+
+// Query returns the query of the text with the filters.
+func (f SearchFilters) Query(text string) (search.Query, error) {
+	q := search.Query{Text: text, Region: f.Region, Lang: f.Lang}
+	if err := q.Safe.Set(f.Safe); err != nil {
+		return q, err
+	}
+	if err := q.Time.Set(f.Time); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+// searchFiltersProperties are the schemas of the SearchFilters arguments.
+func searchFiltersProperties(props mcp.Properties) mcp.Properties {
+	props["region"] = mcp.NewSchemaString("Optional region of the results, e.g. us-en, fr-fr, or a country, e.g. de.")
+	props["lang"] = mcp.NewSchemaString("Optional language of the results, e.g. en, it replaces the language of the region.")
+	props["safe"] = mcp.NewSchemaString("Optional filtering of the explicit results: on, moderate or off.")
+	props["time"] = mcp.NewSchemaString("Optional recency of the results: d, w, m or y for the last day, week, month or year.")
+	props["fresh"] = mcp.NewSchemaBoolean("Optional, run the search again instead of returning the results of an identical search made a few minutes ago.")
+
+	return props
+}
+
+// runsearch executes the search command. It runs a saved search with
+// --saved, the queries of a file with --queries or the query given in
+// arguments.
+func runsearch(ctx context.Context, mcpsrv *MCPServer, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var (
+		saved  = flags.String("saved", "", "name of the saved search to run")
+		engine = flags.String("engine", search.DefaultEngine, "search engine, ignored with --saved")
+		region = flags.String("region", "", "region of the results, e.g. us-en, ignored with --saved")
+		lang   = flags.String("lang", "", "language of the results, e.g. en, ignored with --saved")
+		qfile  = flags.String("queries", "", "file of queries run concurrently, one per line, - reads stdin")
+		incog  = flags.Bool("incognito", false, "run the searches in a throwaway browser context, sharing no cookie nor cache with the pooled tabs")
+		fresh  = flags.Bool("fresh", false, "bypass the cache of the search results")
+	)
+
+	var (
+		safe     search.SafeSearch
+		tr       search.TimeRange
+		vertical search.Vertical
+	)
+	flags.Var(&vertical, "type", "type of the results: web, news, images or videos, ignored with --saved and --queries")
+	flags.Var(&safe, "safe", "filtering of the explicit results: on, moderate or off, ignored with --saved")
+	flags.Var(&tr, "time", "recency of the results: d, w, m or y, ignored with --saved")
+
+	params := SearchParams{}
+	flags.Var(params, "param", "query parameter of the saved search as name=value, can be repeated")
+
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: search [options] <text>|--saved <name>|--queries <file>\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *incog {
+		var (
+			done func()
+			err  error
+		)
+		ctx, done, err = mcpsrv.incognito(ctx)
+		if err != nil {
+			return err
+		}
+		defer done()
+	}
+
+	if *fresh {
+		ctx = withFreshSearch(ctx)
+	}
+
+	base := search.Query{Engine: *engine, Region: *region, Lang: *lang, Safe: safe, Time: tr}
+
+	if *qfile != "" {
+		if *saved != "" || flags.NArg() > 0 {
+			return errors.New("usage: search --queries <file>")
+		}
+
+		texts, err := readQueries(*qfile, stdin)
+		if err != nil {
+			return err
+		}
+
+		queries := make([]search.Query, 0, len(texts))
+		for _, t := range texts {
+			q := base
+			q.Text = t
+			queries = append(queries, q)
+		}
+
+		res, err := mcpsrv.SearchBatch(ctx, queries)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(stdout, FormatSearchBatch(res))
+		return err
+	}
+
+	conn := mcpsrv.NewConn()
+	defer conn.Close()
+
+	if *saved != "" {
+		if flags.NArg() > 0 {
+			return errors.New("usage: search --saved <name>")
+		}
+
+		s, err := mcpsrv.Searches.Get(*saved)
+		if err != nil {
+			return err
+		}
+
+		return s.Run(ctx, conn, params, stdout)
+	}
+
+	text := strings.Join(flags.Args(), " ")
+	if text == "" {
+		flags.Usage()
+		return errors.New("no text")
+	}
+
+	q := base
+	q.Text = text
+	res, err := conn.SearchVertical(ctx, q, vertical)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(stdout, res)
+	return err
+}
@@ -0,0 +1,136 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/pkg/search"
+)
+
+const (
+	SearchCacheDefaultTTL = 5 * time.Minute
+	// maximum number of queries kept in the cache.
+	searchCacheSize = 1024
+)
+
+type searchEntry struct {
+	res     any
+	expires time.Time
+}
+
+// SearchCache keeps the results of the searches for a short time, the
+// identical searches don't reach the engine again.
+type SearchCache struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]searchEntry
+}
+
+// NewSearchCache returns a cache keeping the results for ttl. It returns nil
+// if ttl isn't positive, disabling the cache.
+func NewSearchCache(ttl time.Duration) *SearchCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &SearchCache{TTL: ttl, entries: make(map[string]searchEntry)}
+}
+
+func (c *SearchCache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return e.res, true
+}
+
+func (c *SearchCache) put(key string, res any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= searchCacheSize {
+		// remove the expired entries, then the oldest one.
+		var oldest string
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+				continue
+			}
+			if oldest == "" || e.expires.Before(c.entries[oldest].expires) {
+				oldest = k
+			}
+		}
+		if len(c.entries) >= searchCacheSize {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = searchEntry{res: res, expires: now.Add(c.TTL)}
+}
+
+type freshSearchKey struct{}
+
+// withFreshSearch returns a context whose searches bypass the cache, their
+// results replace the cached ones.
+func withFreshSearch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshSearchKey{}, true)
+}
+
+func freshSearch(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshSearchKey{}).(bool)
+	return fresh
+}
+
+// cachedSearch returns the cached results of the query q on the vertical v,
+// or runs the search with fn and caches its results. The failed searches and
+// the empty results aren't cached, the engine may have answered with a
+// challenge page.
+func cachedSearch[T any](ctx context.Context, c *SearchCache, q search.Query, v search.Vertical, fn func() ([]T, error)) ([]T, error) {
+	if c == nil {
+		return fn()
+	}
+
+	key := q.Key(v)
+	if !freshSearch(ctx) {
+		if res, ok := c.get(key); ok {
+			if res, ok := res.([]T); ok {
+				slog.Debug("search cache hit", slog.String("text", q.Text), slog.String("type", v.String()))
+				return slices.Clone(res), nil
+			}
+		}
+	}
+
+	res, err := fn()
+	if err != nil || len(res) == 0 {
+		return res, err
+	}
+	c.put(key, slices.Clone(res))
+
+	return res, nil
+}
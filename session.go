@@ -15,16 +15,25 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+
 	"github.com/lightpanda-io/gomcp/mcp"
 )
 
 var InvalidSessionId = errors.New("invalid session id")
 
+const SessionQueueSizeDefault = 16
+
+// SessionIdHeader gives the id of the session to resume on /sse, the query
+// parameter id works too.
+const SessionIdHeader = "Mcp-Session-Id"
+
 type SessionId uuid.UUID
 
 func (id SessionId) String() string {
@@ -48,12 +57,84 @@ func (id *SessionId) Set(v string) error {
 type Sessions struct {
 	sync.Mutex
 	s map[SessionId]*Session
+
+	// Sessions unused for longer than IdleTTL are expired, 0 disables it.
+	IdleTTL time.Duration
+	// Sessions older than TTL are expired, 0 disables it.
+	TTL time.Duration
+	// Number of requests queued per session before rejecting new ones.
+	QueueSize int
+
+	// Persistence of the sessions records, nil disables it.
+	Store *SessionStore
+
+	// the server is shutting down, no new session nor request is accepted.
+	draining bool
+	// records of the sessions of the previous run, not resumed yet.
+	parked map[SessionId]SessionRecord
+	// parked records replicated from a primary server.
+	replicated map[SessionId]bool
 }
 
 func NewSessions() *Sessions {
 	return &Sessions{
-		s: make(map[SessionId]*Session),
+		s:          make(map[SessionId]*Session),
+		QueueSize:  SessionQueueSizeDefault,
+		parked:     make(map[SessionId]SessionRecord),
+		replicated: make(map[SessionId]bool),
+	}
+}
+
+// New creates a new session and adds it.
+func (ss *Sessions) New() *Session {
+	s := NewSession(ss.QueueSize)
+	ss.Add(s)
+	return s
+}
+
+// Restore loads the records of the sessions persisted by the previous run,
+// their clients can resume them until they expire.
+func (ss *Sessions) Restore() error {
+	recs, err := ss.Store.Load()
+	if err != nil {
+		return err
+	}
+
+	ss.Lock()
+	defer ss.Unlock()
+	for _, rec := range recs {
+		var id SessionId
+		if err := id.Set(rec.ID); err != nil {
+			continue
+		}
+		ss.parked[id] = rec
+	}
+	if len(recs) > 0 {
+		slog.Info("sessions restored", slog.Int("count", len(ss.parked)))
 	}
+
+	return nil
+}
+
+// Resume creates the session id from its record, if the client gives the
+// same token. It returns false if there is no record.
+func (ss *Sessions) Resume(id SessionId, token string) (*Session, SessionRecord, bool) {
+	ss.Lock()
+	rec, ok := ss.parked[id]
+	if !ok || rec.Token != token {
+		ss.Unlock()
+		return nil, rec, false
+	}
+	delete(ss.parked, id)
+	delete(ss.replicated, id)
+	ss.Unlock()
+
+	s := NewSession(ss.QueueSize)
+	s.id = id
+	s.createdAt = rec.CreatedAt
+	ss.Add(s)
+
+	return s, rec, true
 }
 
 func (ss *Sessions) Add(s *Session) {
@@ -75,25 +156,223 @@ func (ss *Sessions) Remove(id SessionId) {
 	ss.Unlock()
 }
 
+// List returns the info of all the sessions.
+func (ss *Sessions) List() []SessionInfo {
+	ss.Lock()
+	defer ss.Unlock()
+
+	infos := make([]SessionInfo, 0, len(ss.s))
+	for _, s := range ss.s {
+		infos = append(infos, s.Info())
+	}
+
+	return infos
+}
+
+// Kill closes the session corresponding to the id.
+// The session is removed once its connection is closed.
+func (ss *Sessions) Kill(id SessionId) bool {
+	s, ok := ss.Get(id)
+	if !ok {
+		return false
+	}
+
+	s.Close()
+	return true
+}
+
+// Drain stops accepting new sessions and requests.
+func (ss *Sessions) Drain() {
+	ss.Lock()
+	ss.draining = true
+	ss.Unlock()
+}
+
+// Draining returns true if the sessions are drained.
+func (ss *Sessions) Draining() bool {
+	ss.Lock()
+	defer ss.Unlock()
+
+	return ss.draining
+}
+
+// CloseAll closes all the sessions.
+// The sessions are removed once their connections are closed.
+func (ss *Sessions) CloseAll() {
+	ss.Lock()
+	defer ss.Unlock()
+
+	for _, s := range ss.s {
+		s.Close()
+	}
+}
+
+// Reap closes expired sessions every interval until ctx is done.
+func (ss *Sessions) Reap(ctx context.Context, interval time.Duration) {
+	if ss.IdleTTL == 0 && ss.TTL == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			ss.Lock()
+			for _, s := range ss.s {
+				if s.Expired(now, ss.IdleTTL, ss.TTL) {
+					slog.Debug("session expired", slog.Any("id", s.id))
+					s.Close()
+				}
+			}
+			for id, rec := range ss.parked {
+				if expired(now, rec.CreatedAt, rec.LastUsedAt, ss.IdleTTL, ss.TTL) {
+					slog.Debug("session record expired", slog.Any("id", id))
+					delete(ss.parked, id)
+					delete(ss.replicated, id)
+					ss.Store.Remove(rec.ID)
+				}
+			}
+			ss.Unlock()
+		}
+	}
+}
+
+type SessionInfo struct {
+	Id         string        `json:"id"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	LastUsedAt time.Time     `json:"lastUsedAt"`
+	History    *HistoryStats `json:"history,omitempty"`
+}
+
 type Session struct {
 	sync.Mutex
-	id        SessionId
-	creq      chan mcp.Request
-	createdAt time.Time
+	id         SessionId
+	creq       chan mcp.Request
+	done       chan struct{}
+	closeOnce  sync.Once
+	createdAt  time.Time
+	lastUsedAt time.Time
+	history    *History
+	// token authenticating the client, the messages must give it too.
+	token *APIToken
 }
 
-func NewSession() *Session {
+func NewSession(queueSize int) *Session {
+	now := time.Now()
 	return &Session{
-		id:        SessionId(uuid.New()),
-		creq:      make(chan mcp.Request),
-		createdAt: time.Now(),
+		id:         SessionId(uuid.New()),
+		creq:       make(chan mcp.Request, queueSize),
+		done:       make(chan struct{}),
+		createdAt:  now,
+		lastUsedAt: now,
 	}
 }
 
+// Close notifies the session's connection to stop.
+// It can be called multiple times.
 func (s *Session) Close() {
-	close(s.creq)
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// Done returns a channel closed when the session is closed.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
 }
 
 func (s *Session) Requests() chan mcp.Request {
 	return s.creq
 }
+
+// SetHistory attaches the history of the session's connection, its usage is
+// reported in the session's info.
+func (s *Session) SetHistory(h *History) {
+	s.Lock()
+	s.history = h
+	s.Unlock()
+}
+
+// SetToken sets the token authenticating the client of the session.
+func (s *Session) SetToken(t *APIToken) {
+	s.Lock()
+	s.token = t
+	s.Unlock()
+}
+
+// Owned returns true if the token is the one of the session's client. The
+// OAuth access tokens are compared by subject, they are refreshed during the
+// session.
+func (s *Session) Owned(t *APIToken) bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.token == nil || t == nil {
+		return s.token == t
+	}
+	return s.token.Name == t.Name
+}
+
+// Touch updates the last usage time of the session.
+func (s *Session) Touch() {
+	s.Lock()
+	s.lastUsedAt = time.Now()
+	s.Unlock()
+}
+
+// Expired returns true if the session has been idle for longer than idleTTL
+// or is older than ttl. A zero duration disables the corresponding check.
+func (s *Session) Expired(now time.Time, idleTTL, ttl time.Duration) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return expired(now, s.createdAt, s.lastUsedAt, idleTTL, ttl)
+}
+
+// expired returns true if a session created and last used at the times is
+// expired.
+func expired(now, created, used time.Time, idleTTL, ttl time.Duration) bool {
+	if idleTTL > 0 && now.Sub(used) > idleTTL {
+		return true
+	}
+
+	return ttl > 0 && now.Sub(created) > ttl
+}
+
+// Record returns the record of the session, completed with its connection
+// by the caller.
+func (s *Session) Record() SessionRecord {
+	s.Lock()
+	defer s.Unlock()
+
+	rec := SessionRecord{
+		ID:         s.id.String(),
+		CreatedAt:  s.createdAt,
+		LastUsedAt: s.lastUsedAt,
+	}
+	if s.token != nil {
+		rec.Token = s.token.Name
+	}
+
+	return rec
+}
+
+func (s *Session) Info() SessionInfo {
+	s.Lock()
+	defer s.Unlock()
+
+	info := SessionInfo{
+		Id:         s.id.String(),
+		CreatedAt:  s.createdAt,
+		LastUsedAt: s.lastUsedAt,
+	}
+	if s.history != nil {
+		stats := s.history.Stats()
+		info.History = &stats
+	}
+
+	return info
+}
@@ -0,0 +1,384 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+
+	"github.com/lightpanda-io/gomcp/mcp"
+)
+
+// SessionRecord is the metadata of a sse session persisted on disk, its
+// client re-attaches to it after a restart of the server.
+type SessionRecord struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	// Client given on initialize, the resumed clients don't initialize
+	// again.
+	Client mcp.Info        `json:"client"`
+	Hints  mcp.ClientHints `json:"hints"`
+	// Name of the API token of the client, the same token must resume the
+	// session.
+	Token string `json:"token,omitempty"`
+	// Browser context of an isolated session, replaced on resume because
+	// the contexts don't survive their connection.
+	BrowserContext string `json:"browserContext,omitempty"`
+	// File of the cookie jar of an isolated session, in the store
+	// directory.
+	Cookies string `json:"cookies,omitempty"`
+}
+
+// SessionCookie is a cookie of the jar of an isolated session.
+type SessionCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	Secure   bool   `json:"secure,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	SameSite string `json:"sameSite,omitempty"`
+	// Expiration time in seconds since the epoch, 0 for the session
+	// cookies.
+	Expires float64 `json:"expires,omitempty"`
+}
+
+// cookieJar returns the jar of the browser cookies.
+func cookieJar(cookies []*network.Cookie) []SessionCookie {
+	jar := make([]SessionCookie, 0, len(cookies))
+	for _, c := range cookies {
+		sc := SessionCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HTTPOnly,
+			SameSite: string(c.SameSite),
+		}
+		if !c.Session {
+			sc.Expires = c.Expires
+		}
+		jar = append(jar, sc)
+	}
+
+	return jar
+}
+
+// cookieParams returns the parameters setting the cookies of the jar.
+func cookieParams(jar []SessionCookie) []*network.CookieParam {
+	params := make([]*network.CookieParam, 0, len(jar))
+	for _, c := range jar {
+		p := &network.CookieParam{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HTTPOnly,
+			SameSite: network.CookieSameSite(c.SameSite),
+		}
+		if c.Expires > 0 {
+			exp := cdp.TimeSinceEpoch(time.Unix(0, int64(c.Expires*float64(time.Second))))
+			p.Expires = &exp
+		}
+		params = append(params, p)
+	}
+
+	return params
+}
+
+// SessionStore persists the session records and their cookie jars in a
+// directory. A nil SessionStore persists nothing.
+type SessionStore struct {
+	Dir string
+}
+
+const (
+	sessionRecordExt = ".json"
+	sessionCookieExt = ".cookies.json"
+)
+
+// Save writes the record, replacing the previous one.
+func (st *SessionStore) Save(rec SessionRecord) error {
+	if st == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("json encode: %w", err)
+	}
+
+	return st.write(rec.ID+sessionRecordExt, b)
+}
+
+// SaveCookies writes the cookie jar of the session id and returns its file
+// name.
+func (st *SessionStore) SaveCookies(id string, jar []SessionCookie) (string, error) {
+	if st == nil {
+		return "", nil
+	}
+
+	b, err := json.Marshal(jar)
+	if err != nil {
+		return "", fmt.Errorf("json encode: %w", err)
+	}
+
+	name := id + sessionCookieExt
+	return name, st.write(name, b)
+}
+
+// write writes the file atomically, readable by the user only: the cookies
+// authenticate the sessions.
+func (st *SessionStore) write(name string, b []byte) error {
+	if err := os.MkdirAll(st.Dir, 0700); err != nil {
+		return fmt.Errorf("sessions dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(st.Dir, name+".*")
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(st.Dir, name))
+}
+
+// LoadCookies reads the cookie jar file name.
+func (st *SessionStore) LoadCookies(name string) ([]SessionCookie, error) {
+	if st == nil || name == "" {
+		return nil, nil
+	}
+
+	b, err := os.ReadFile(filepath.Join(st.Dir, filepath.Base(name)))
+	if err != nil {
+		return nil, fmt.Errorf("read cookies: %w", err)
+	}
+
+	var jar []SessionCookie
+	if err := json.Unmarshal(b, &jar); err != nil {
+		return nil, fmt.Errorf("json decode cookies: %w", err)
+	}
+
+	return jar, nil
+}
+
+// Load reads all the records. The unreadable ones are skipped.
+func (st *SessionStore) Load() ([]SessionRecord, error) {
+	if st == nil {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(st.Dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("sessions dir: %w", err)
+	}
+
+	var recs []SessionRecord
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, sessionRecordExt) || strings.HasSuffix(name, sessionCookieExt) {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(st.Dir, name))
+		if err != nil {
+			slog.Error("session record", slog.String("file", name), slog.Any("err", err))
+			continue
+		}
+		var rec SessionRecord
+		if err := json.Unmarshal(b, &rec); err != nil {
+			slog.Error("session record", slog.String("file", name), slog.Any("err", err))
+			continue
+		}
+		recs = append(recs, rec)
+	}
+
+	return recs, nil
+}
+
+// Remove removes the record of the session id and its cookie jar.
+func (st *SessionStore) Remove(id string) {
+	if st == nil {
+		return
+	}
+
+	for _, name := range []string{id + sessionRecordExt, id + sessionCookieExt} {
+		if err := os.Remove(filepath.Join(st.Dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("remove session record", slog.String("file", name), slog.Any("err", err))
+		}
+	}
+}
+
+// persist saves the record of the session s of the connection, with the
+// cookie jar of its isolated browser context.
+func (ss *Sessions) persist(ctx context.Context, s *Session, conn *MCPConn) {
+	if ss.Store == nil {
+		return
+	}
+
+	rec := s.Record()
+	rec.Client, rec.Hints = conn.clientInfo()
+	if conn.isolated != nil {
+		rec.BrowserContext = string(conn.isolated.BrowserContext())
+		cookies, err := conn.isolated.Cookies(ctx)
+		if err == nil {
+			rec.Cookies, err = ss.Store.SaveCookies(rec.ID, cookieJar(cookies))
+		}
+		if err != nil {
+			slog.Error("session cookies", slog.String("id", rec.ID), slog.Any("err", err))
+		}
+	}
+
+	if err := ss.Store.Save(rec); err != nil {
+		slog.Error("session record", slog.String("id", rec.ID), slog.Any("err", err))
+	}
+}
+
+// resume restores the connection of the resumed session from its record: the
+// client info and the cookie jar of its isolated browser context.
+func (ss *Sessions) resume(ctx context.Context, rec SessionRecord, conn *MCPConn) {
+	conn.setClient(rec.Client, rec.Hints)
+
+	if conn.isolated == nil || rec.Cookies == "" {
+		return
+	}
+	jar, err := ss.Store.LoadCookies(rec.Cookies)
+	if err == nil {
+		err = conn.isolated.SetCookies(ctx, cookieParams(jar))
+	}
+	if err != nil {
+		slog.Error("session cookies", slog.String("id", rec.ID), slog.Any("err", err))
+	}
+}
+
+// Replica returns the persisted sessions with their cookie jars, replicated
+// to the standby servers.
+func (ss *Sessions) Replica() []ReplicaSession {
+	if ss == nil || ss.Store == nil {
+		return nil
+	}
+
+	recs, err := ss.Store.Load()
+	if err != nil {
+		slog.Error("session records", slog.Any("err", err))
+		return nil
+	}
+
+	res := make([]ReplicaSession, 0, len(recs))
+	for _, rec := range recs {
+		jar, err := ss.Store.LoadCookies(rec.Cookies)
+		if err != nil {
+			slog.Error("session cookies", slog.String("id", rec.ID), slog.Any("err", err))
+		}
+		res = append(res, ReplicaSession{SessionRecord: rec, Jar: jar})
+	}
+
+	return res
+}
+
+// Replicate parks the sessions of the primary server, their clients resume
+// them after a failover. The sessions dropped by the primary are dropped too,
+// the store is required to keep the cookie jars.
+func (ss *Sessions) Replicate(recs []ReplicaSession) {
+	if ss == nil || ss.Store == nil {
+		return
+	}
+
+	// the files are written without holding the lock.
+	ids := make(map[SessionId]bool, len(recs))
+	var (
+		parked  []SessionRecord
+		dropped []SessionId
+	)
+	ss.Lock()
+	for _, r := range recs {
+		var id SessionId
+		if err := id.Set(r.ID); err != nil {
+			continue
+		}
+		ids[id] = true
+		if _, ok := ss.s[id]; !ok {
+			parked = append(parked, r.SessionRecord)
+		}
+	}
+	for id := range ss.replicated {
+		if !ids[id] {
+			delete(ss.parked, id)
+			delete(ss.replicated, id)
+			dropped = append(dropped, id)
+		}
+	}
+	ss.Unlock()
+
+	for _, id := range dropped {
+		ss.Store.Remove(id.String())
+	}
+
+	jars := make(map[string][]SessionCookie, len(recs))
+	for _, r := range recs {
+		jars[r.ID] = r.Jar
+	}
+	for i, rec := range parked {
+		// the browser context of the primary doesn't exist here.
+		rec.BrowserContext, rec.Cookies = "", ""
+		if jar := jars[rec.ID]; len(jar) > 0 {
+			var err error
+			if rec.Cookies, err = ss.Store.SaveCookies(rec.ID, jar); err != nil {
+				slog.Error("session cookies", slog.String("id", rec.ID), slog.Any("err", err))
+			}
+		}
+		if err := ss.Store.Save(rec); err != nil {
+			slog.Error("session record", slog.String("id", rec.ID), slog.Any("err", err))
+		}
+		parked[i] = rec
+	}
+
+	ss.Lock()
+	defer ss.Unlock()
+	for _, rec := range parked {
+		var id SessionId
+		id.Set(rec.ID) // nolint:errcheck
+		if _, ok := ss.s[id]; ok {
+			// resumed on this server meanwhile.
+			continue
+		}
+		ss.parked[id] = rec
+		ss.replicated[id] = true
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+
+	"github.com/lightpanda-io/gomcp/mcp"
+)
+
+// Screenshots policy of the navigation results.
+type ScreenshotPolicy string
+
+const (
+	// Include the screenshots if the client displays the images.
+	ScreenshotsAuto   ScreenshotPolicy = "auto"
+	ScreenshotsAlways ScreenshotPolicy = "always"
+	ScreenshotsNever  ScreenshotPolicy = "never"
+)
+
+var InvalidScreenshotPolicy = errors.New("invalid screenshot policy")
+
+func (p ScreenshotPolicy) String() string {
+	return string(p)
+}
+
+func (p *ScreenshotPolicy) Set(v string) error {
+	switch ScreenshotPolicy(v) {
+	case ScreenshotsAuto, ScreenshotsAlways, ScreenshotsNever:
+		*p = ScreenshotPolicy(v)
+		return nil
+	}
+
+	return InvalidScreenshotPolicy
+}
+
+const (
+	ScreenshotDefaultPolicy = ScreenshotsAuto
+
+	// Context window in tokens under which the results are truncated.
+	SmallContextWindow = 32000
+	// Share of the context window given to a result.
+	contextShare = 8
+	// Average size of a token in bytes.
+	tokenBytes = 4
+
+	screenshotQuality = 60
+	screenshotTimeout = 5 * time.Second
+)
+
+// ResponseShape adjusts the tool results to the client.
+type ResponseShape struct {
+	// Include a screenshot in the navigation results.
+	Screenshots bool
+	// Maximum size of the result text in bytes, 0 means no limit.
+	MaxText int
+}
+
+// shape returns the response shape of a client with the given hints. The
+// server options override the hints.
+func (s *MCPServer) shape(hints mcp.ClientHints) ResponseShape {
+	var sh ResponseShape
+
+	switch s.Screenshots {
+	case ScreenshotsAlways:
+		sh.Screenshots = true
+	case ScreenshotsNever:
+	default:
+		sh.Screenshots = hints.Images
+	}
+
+	switch {
+	case s.MaxResponseText > 0:
+		sh.MaxText = s.MaxResponseText
+	case hints.ContextWindow > 0 && hints.ContextWindow <= SmallContextWindow:
+		sh.MaxText = hints.ContextWindow * tokenBytes / contextShare
+	}
+
+	return sh
+}
+
+// truncate cuts the text to the maximum size.
+func (sh ResponseShape) truncate(text string) string {
+	if sh.MaxText <= 0 || len(text) <= sh.MaxText {
+		return text
+	}
+
+	// don't cut a character.
+	n := sh.MaxText
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+
+	return fmt.Sprintf("%s\n[truncated, %d of %d bytes]", text[:n], n, len(text))
+}
+
+// screenshot attaches a screenshot of the page to the result of the tool call
+// of ctx if the client displays the images.
+func (c *MCPConn) screenshot(ctx context.Context) {
+	if !c.responseShape().Screenshots || c.cdpctx == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, screenshotTimeout)
+	defer cancel()
+
+	var b []byte
+	err := c.run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		b, err = page.CaptureScreenshot().
+			WithFormat(page.CaptureScreenshotFormatJpeg).
+			WithQuality(screenshotQuality).
+			Do(ctx)
+		return err
+	}))
+	if err != nil {
+		slog.Debug("screenshot", slog.Any("err", err))
+		return
+	}
+
+	attachImage(ctx, b, "image/jpeg")
+}
@@ -0,0 +1,34 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package main
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// bindUnix listens on the unix socket path, created with the permissions
+// perm by the umask: the socket is never reachable with wider permissions.
+func bindUnix(path string, perm os.FileMode) (net.Listener, error) {
+	// the umask is process wide, it is restored at once.
+	old := syscall.Umask(int(^perm & 0777))
+	ln, err := net.Listen("unix", path)
+	syscall.Umask(old)
+
+	return ln, err
+}
@@ -0,0 +1,231 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	cdpfetch "github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Maximum duration of the screening of a request paused by the browser.
+const screenTimeout = 5 * time.Second
+
+// PrivateNetworkError is returned when a navigation targets a private,
+// loopback, link-local or cloud metadata address.
+type PrivateNetworkError struct {
+	URL  string
+	Host string
+	// Address of the host, invalid for a metadata host name.
+	Addr netip.Addr
+}
+
+func (e *PrivateNetworkError) Error() string {
+	if !e.Addr.IsValid() {
+		return fmt.Sprintf("url blocked: %s: %s is a cloud metadata service", e.URL, e.Host)
+	}
+
+	return fmt.Sprintf("url blocked: %s: %s resolves to the private address %s", e.URL, e.Host, e.Addr)
+}
+
+// metadataHosts are the names of the cloud metadata services.
+var metadataHosts = map[string]bool{
+	"metadata.google.internal": true,
+	"metadata.goog":            true,
+	"metadata.azure.com":       true,
+}
+
+// reservedPrefixes are the ranges refused in addition to the loopback,
+// private and link-local ones: the current network, the Alibaba metadata
+// service and the Azure host node.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.100.100.200/32"),
+	netip.MustParsePrefix("168.63.129.16/32"),
+}
+
+// privateAddr returns true if the address is loopback, private, link-local,
+// unspecified or reserved.
+func privateAddr(a netip.Addr) bool {
+	a = a.Unmap()
+	if a.IsLoopback() || a.IsPrivate() || a.IsLinkLocalUnicast() ||
+		a.IsLinkLocalMulticast() || a.IsUnspecified() {
+		return true
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(a) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkURL screens the url before a navigation with the blocklist and,
+// unless the private networks are allowed, the addresses of its host.
+func (s *MCPServer) checkURL(ctx context.Context, u string) error {
+	if err := s.Blocklist.Check(u); err != nil {
+		return err
+	}
+	if s.AllowPrivateNetworks {
+		return nil
+	}
+
+	return checkNetwork(ctx, u)
+}
+
+// checkResponse checks the address the browser connected to for the response
+// of the url, the host may have been resolved to another address by the
+// browser, e.g. with a DNS rebinding.
+func (s *MCPServer) checkResponse(u string, resp *network.Response) error {
+	if s.AllowPrivateNetworks || resp == nil || resp.RemoteIPAddress == "" {
+		return nil
+	}
+
+	// the IPv6 addresses are bracketed.
+	a, err := netip.ParseAddr(strings.Trim(resp.RemoteIPAddress, "[]"))
+	if err != nil || !privateAddr(a) {
+		return nil
+	}
+
+	return &PrivateNetworkError{URL: u, Host: resp.RemoteIPAddress, Addr: a}
+}
+
+// checkNetwork returns a PrivateNetworkError if the host of the url is a
+// cloud metadata service or resolves to a private address.
+func checkNetwork(ctx context.Context, u string) error {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil
+	}
+	switch pu.Scheme {
+	case "http", "https", "ws", "wss":
+	default:
+		return nil
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(pu.Hostname(), "."))
+	if host == "" {
+		return nil
+	}
+	if metadataHosts[host] {
+		return &PrivateNetworkError{URL: u, Host: host}
+	}
+
+	if a, ok := parseHostAddr(host); ok {
+		if privateAddr(a) {
+			return &PrivateNetworkError{URL: u, Host: host, Addr: a.Unmap()}
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		// the navigation reports the resolution failure.
+		slog.Debug("resolve host", slog.String("host", host), slog.Any("err", err))
+		return nil
+	}
+	for _, a := range addrs {
+		if privateAddr(a) {
+			return &PrivateNetworkError{URL: u, Host: host, Addr: a.Unmap()}
+		}
+	}
+
+	return nil
+}
+
+// parseHostAddr parses the host as an IP address, with the IPv4 forms
+// accepted by the browsers, e.g. 2130706433, 0x7f.1 or 0177.0.0.1.
+func parseHostAddr(host string) (netip.Addr, bool) {
+	if a, err := netip.ParseAddr(host); err == nil {
+		return a, true
+	}
+
+	parts := strings.Split(strings.TrimSuffix(host, "."), ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+	nums := make([]uint64, len(parts))
+	for i, p := range parts {
+		base := 10
+		switch {
+		case strings.HasPrefix(p, "0x"), strings.HasPrefix(p, "0X"):
+			p, base = p[2:], 16
+		case len(p) > 1 && p[0] == '0':
+			p, base = p[1:], 8
+		}
+		if p == "" && base == 16 {
+			// 0x is zero.
+			continue
+		}
+		n, err := strconv.ParseUint(p, base, 32)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		nums[i] = n
+	}
+
+	// the last number fills the remaining bytes.
+	var v uint64
+	for i, n := range nums[:len(nums)-1] {
+		if n > 255 {
+			return netip.Addr{}, false
+		}
+		v |= n << (8 * (3 - i))
+	}
+	last := nums[len(nums)-1]
+	if last >= 1<<(8*(5-len(nums))) {
+		return netip.Addr{}, false
+	}
+	v |= last
+
+	return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}), true
+}
+
+// blockPrivate pauses the requests of the tab so screen fails the ones to the
+// private networks, e.g. started by a click, a form or a script redirection.
+// The browsers without request interception rely on the location checks.
+func (t *tab) blockPrivate() {
+	err := chromedp.Run(t.ctx, cdpfetch.Enable().WithPatterns([]*cdpfetch.RequestPattern{{URLPattern: "*"}}))
+	if err != nil {
+		slog.Warn("no request interception, the private networks are checked after the navigations", slog.String("id", t.id.String()), slog.Any("err", err))
+	}
+}
+
+// screen fails the paused request if it targets a private network, or
+// continues it. It runs outside of the event handler.
+func (t *tab) screen(ev *cdpfetch.EventRequestPaused) {
+	ctx, cancel := context.WithTimeout(t.ctx, screenTimeout)
+	defer cancel()
+
+	var action chromedp.Action = cdpfetch.ContinueRequest(ev.RequestID)
+	if err := checkNetwork(ctx, ev.Request.URL); err != nil {
+		slog.Warn("request blocked", slog.String("id", t.id.String()), slog.Any("err", err))
+		action = cdpfetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient)
+	}
+	if err := chromedp.Run(t.ctx, action); err != nil {
+		slog.Debug("screen request", slog.String("id", t.id.String()), slog.Any("err", err))
+	}
+}
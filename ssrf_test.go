@@ -0,0 +1,89 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestPrivateAddr(t *testing.T) {
+	for _, tc := range []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"100.100.100.200", true},
+		{"168.63.129.16", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"8.8.8.8", false},
+		{"1.1.1.1", false},
+		{"2606:4700:4700::1111", false},
+		{"::ffff:8.8.8.8", false},
+	} {
+		t.Run(tc.addr, func(t *testing.T) {
+			if got := privateAddr(netip.MustParseAddr(tc.addr)); got != tc.want {
+				t.Errorf("privateAddr(%s) = %v, want %v", tc.addr, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for _, tc := range []struct {
+		url     string
+		private bool
+		blocked bool
+	}{
+		{url: "http://127.0.0.1/", blocked: true},
+		{url: "http://localhost:8080/admin", blocked: true},
+		{url: "http://169.254.169.254/latest/meta-data/", blocked: true},
+		{url: "http://metadata.google.internal/computeMetadata/v1/", blocked: true},
+		{url: "http://METADATA.GOOGLE.INTERNAL./", blocked: true},
+		{url: "http://[::1]/", blocked: true},
+		{url: "http://[::ffff:127.0.0.1]/", blocked: true},
+		{url: "http://2130706433/", blocked: true},
+		{url: "http://0x7f.1/", blocked: true},
+		{url: "http://0177.0.0.1/", blocked: true},
+		{url: "ws://10.0.0.1:9222/devtools", blocked: true},
+		{url: "http://8.8.8.8/"},
+		{url: "file:///etc/passwd"},
+		{url: "data:text/html,hello"},
+		{url: "http://127.0.0.1/", private: true},
+		{url: "http://169.254.169.254/", private: true},
+	} {
+		t.Run(tc.url, func(t *testing.T) {
+			s := &MCPServer{AllowPrivateNetworks: tc.private}
+			err := s.checkURL(context.Background(), tc.url)
+
+			var perr *PrivateNetworkError
+			if got := errors.As(err, &perr); got != tc.blocked {
+				t.Errorf("checkURL(%s) = %v, want blocked %v", tc.url, err, tc.blocked)
+			}
+		})
+	}
+}
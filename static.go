@@ -0,0 +1,158 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+// FetchMode chooses how the pipeline loads the pages.
+type FetchMode string
+
+const (
+	// Load the pages in the browser.
+	FetchModeBrowser FetchMode = "browser"
+	// Fetch the pages with plain HTTP, the pages needing JavaScript fall
+	// back to the browser.
+	FetchModeHTTP FetchMode = "http"
+)
+
+var InvalidFetchMode = errors.New("invalid mode, must be browser or http")
+
+func (m FetchMode) String() string {
+	return string(m)
+}
+
+func (m *FetchMode) Set(v string) error {
+	switch FetchMode(v) {
+	case FetchModeBrowser, FetchModeHTTP:
+		*m = FetchMode(v)
+		return nil
+	}
+
+	return InvalidFetchMode
+}
+
+// httpClient returns the client of the pages fetched without browser. The
+// redirections are checked like the navigations and the connections to the
+// private networks are refused, even after a DNS rebinding.
+func (s *MCPServer) httpClient() *http.Client {
+	s.httpOnce.Do(func() {
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.DialContext = s.dialer().DialContext
+		s.http = &http.Client{
+			Transport: tr,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 10 {
+					return errors.New("stopped after 10 redirects")
+				}
+				return s.checkURL(req.Context(), req.URL.String())
+			},
+		}
+	})
+
+	return s.http
+}
+
+// dialer returns a dialer refusing the connections to the private networks,
+// checked on the resolved addresses.
+func (s *MCPServer) dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			if s.AllowPrivateNetworks {
+				return nil
+			}
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil || !privateAddr(ap.Addr()) {
+				return nil
+			}
+			return &PrivateNetworkError{URL: address, Host: ap.Addr().String(), Addr: ap.Addr().Unmap()}
+		},
+	}
+}
+
+// static fetches the page without browser at the pace of the server's host
+// limiter. It returns fetch.ErrNeedsBrowser if the page must be loaded in the
+// browser.
+func (p *pipeline) static(ctx context.Context, job PageJob) (fetch.Result, error) {
+	var (
+		res   fetch.Result
+		needs bool
+	)
+	err := p.stage(ctx, StageNavigate, func(ctx context.Context) error {
+		release, err := p.srv.Limiter.Wait(ctx, job.URL)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		start := time.Now()
+		res, err = fetch.Static(ctx, p.srv.httpClient(), job.URL, job.Options)
+		// the fallback isn't a failure of the stage.
+		if errors.Is(err, fetch.ErrNeedsBrowser) {
+			needs = true
+			return nil
+		}
+		p.srv.telemetry.navigation(time.Since(start), err)
+		if err != nil {
+			var (
+				berr *BlockedURLError
+				perr *PrivateNetworkError
+				serr *fetch.StatusError
+			)
+			if !errors.As(err, &berr) && !errors.As(err, &perr) && !errors.As(err, &serr) {
+				err = navigateError(job.URL, err)
+			}
+		}
+		p.srv.Audit.navigation(p.session, job.URL, start, 0, err)
+
+		return err
+	})
+	if err != nil {
+		return res, err
+	}
+	if needs {
+		return res, fetch.ErrNeedsBrowser
+	}
+
+	err = p.stage(ctx, StageConvert, func(ctx context.Context) error {
+		md, err := p.srv.Filter.Check(ctx, res.URL, res.Markdown)
+		if err == nil && job.Translate {
+			md, err = p.srv.Translator.Translate(ctx, md, res.Language)
+		}
+		res.Markdown = md
+		return err
+	})
+	if err != nil {
+		return res, err
+	}
+	if p.failEmpty && res.Markdown == "" {
+		return res, ErrEmptyContent
+	}
+
+	slog.Debug("page fetched without browser", slog.String("url", p.env.Redact(job.URL)))
+
+	return res, p.emit(ctx, res, job)
+}
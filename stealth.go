@@ -0,0 +1,311 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// stealthAgent is the user agent given by the stealth mode when the
+// browser's one isn't a common browser's, e.g. Lightpanda's.
+const stealthAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+// StealthNoise are the fingerprints randomized by the stealth mode.
+// It implements flag.Value, a comma separated list of webgl and canvas.
+type StealthNoise struct {
+	// Report a common GPU and add noise to the pixels read back.
+	WebGL bool `json:"webgl"`
+	// Add noise to the images read back from the 2D canvases.
+	Canvas bool `json:"canvas"`
+}
+
+var InvalidStealthNoise = errors.New("invalid stealth noise, must be webgl, canvas or both")
+
+func (n *StealthNoise) String() string {
+	var s []string
+	if n.WebGL {
+		s = append(s, "webgl")
+	}
+	if n.Canvas {
+		s = append(s, "canvas")
+	}
+
+	return strings.Join(s, ",")
+}
+
+func (n *StealthNoise) Set(v string) error {
+	var noise StealthNoise
+	for _, s := range strings.Split(v, ",") {
+		switch strings.TrimSpace(s) {
+		case "":
+		case "webgl":
+			noise.WebGL = true
+		case "canvas":
+			noise.Canvas = true
+		default:
+			return InvalidStealthNoise
+		}
+	}
+	*n = noise
+
+	return nil
+}
+
+var chromeVersion = regexp.MustCompile(`(?:Headless)?Chrome/((\d+)[\d.]*)`)
+
+// stealthUserAgent returns the user agent ua without the headless marker,
+// with its client hints and the navigator.platform value. The agents of the
+// uncommon browsers are replaced with a desktop Chrome's.
+func stealthUserAgent(ua string) (string, *emulation.UserAgentMetadata, string) {
+	if !strings.HasPrefix(ua, "Mozilla/5.0") {
+		ua = stealthAgent
+	}
+	ua = strings.Replace(ua, "HeadlessChrome/", "Chrome/", 1)
+
+	platform, navplatform, arch := "Linux", "Linux x86_64", "x86"
+	switch {
+	case strings.Contains(ua, "Android"):
+		platform, navplatform, arch = "Android", "Linux armv8l", "arm"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		platform, navplatform = "iOS", "iPhone"
+	case strings.Contains(ua, "Windows"):
+		platform, navplatform = "Windows", "Win32"
+	case strings.Contains(ua, "Mac OS X"):
+		platform, navplatform = "macOS", "MacIntel"
+	}
+
+	// the client hints are sent by the chromium browsers only.
+	m := chromeVersion.FindStringSubmatch(ua)
+	if m == nil {
+		return ua, nil, navplatform
+	}
+	full, major := m[1], m[2]
+
+	return ua, &emulation.UserAgentMetadata{
+		Brands: []*emulation.UserAgentBrandVersion{
+			{Brand: "Chromium", Version: major},
+			{Brand: "Google Chrome", Version: major},
+			{Brand: "Not.A/Brand", Version: "99"},
+		},
+		FullVersionList: []*emulation.UserAgentBrandVersion{
+			{Brand: "Chromium", Version: full},
+			{Brand: "Google Chrome", Version: full},
+			{Brand: "Not.A/Brand", Version: "99.0.0.0"},
+		},
+		Platform:     platform,
+		Architecture: arch,
+		Bitness:      "64",
+		Mobile:       strings.Contains(ua, "Mobile"),
+	}, navplatform
+}
+
+// stealthUserAgentAction overrides the user agent ua, the browser's one if
+// empty, with the stealth one and the language lang, if any.
+func stealthUserAgentAction(ua, lang string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if ua == "" {
+			_, _, _, agent, _, err := browser.GetVersion().Do(ctx)
+			if err != nil {
+				return fmt.Errorf("user agent: %w", err)
+			}
+			ua = agent
+		}
+
+		agent, meta, platform := stealthUserAgent(ua)
+		p := emulation.SetUserAgentOverride(agent).WithPlatform(platform)
+		if meta != nil {
+			p = p.WithUserAgentMetadata(meta)
+		}
+		if lang != "" {
+			p = p.WithAcceptLanguage(lang)
+		}
+
+		return p.Do(ctx)
+	})
+}
+
+// stealthJS hides the automation markers of the page and randomizes the
+// fingerprints given by the options in JSON.
+const stealthJS = `((opts) => {
+	const getter = (obj, prop, get) => {
+		try {
+			Object.defineProperty(obj, prop, {get, configurable: true});
+		} catch (e) {}
+	};
+	getter(Navigator.prototype, 'webdriver', () => undefined);
+	if (opts.languages.length > 0) {
+		getter(Navigator.prototype, 'languages', () => opts.languages.slice());
+	}
+	if (navigator.plugins.length === 0) {
+		const plugins = ['PDF Viewer', 'Chrome PDF Viewer', 'Chromium PDF Viewer'].map((name) => ({
+			name, filename: 'internal-pdf-viewer', description: 'Portable Document Format', length: 1,
+		}));
+		plugins.item = (i) => plugins[i] || null;
+		plugins.namedItem = (n) => plugins.find((p) => p.name === n) || null;
+		plugins.refresh = () => {};
+		getter(Navigator.prototype, 'plugins', () => plugins);
+	}
+	if (!window.chrome) {
+		window.chrome = {runtime: {}, app: {isInstalled: false}, csi: () => ({}), loadTimes: () => ({})};
+	}
+	if (navigator.permissions && navigator.permissions.query) {
+		const query = navigator.permissions.query.bind(navigator.permissions);
+		navigator.permissions.query = (p) => p && p.name === 'notifications' ?
+			Promise.resolve({state: Notification.permission, onchange: null}) : query(p);
+	}
+
+	// xorshift seeded per tab, the noise is stable between the reads.
+	let seed = opts.seed;
+	const rand = () => {
+		seed ^= seed << 13;
+		seed ^= seed >>> 17;
+		seed ^= seed << 5;
+		return (seed >>> 0) / 4294967296;
+	};
+	const noise = (data) => {
+		for (let i = 0; i < data.length; i += 4 * 97) {
+			data[i] = data[i] ^ (rand() < 0.5 ? 1 : 0);
+		}
+	};
+
+	if (opts.webgl) {
+		for (const gl of [window.WebGLRenderingContext, window.WebGL2RenderingContext]) {
+			if (!gl) continue;
+			const getParameter = gl.prototype.getParameter;
+			gl.prototype.getParameter = function (p) {
+				if (p === 37445) return 'Intel Inc.';
+				if (p === 37446) return 'Intel Iris OpenGL Engine';
+				return getParameter.call(this, p);
+			};
+			const readPixels = gl.prototype.readPixels;
+			gl.prototype.readPixels = function (...args) {
+				readPixels.apply(this, args);
+				if (ArrayBuffer.isView(args[6])) noise(args[6]);
+			};
+		}
+	}
+	if (opts.canvas && window.CanvasRenderingContext2D) {
+		const getImageData = CanvasRenderingContext2D.prototype.getImageData;
+		CanvasRenderingContext2D.prototype.getImageData = function (...args) {
+			const img = getImageData.apply(this, args);
+			noise(img.data);
+			return img;
+		};
+		const blur = (canvas) => {
+			const ctx = canvas.getContext('2d');
+			if (ctx === null || canvas.width === 0 || canvas.height === 0) return;
+			ctx.putImageData(ctx.getImageData(0, 0, canvas.width, canvas.height), 0, 0);
+		};
+		for (const m of ['toDataURL', 'toBlob']) {
+			const orig = HTMLCanvasElement.prototype[m];
+			HTMLCanvasElement.prototype[m] = function (...args) {
+				blur(this);
+				return orig.apply(this, args);
+			};
+		}
+	}
+})(%s)`
+
+// stealthScript returns the script of the stealth mode with the languages
+// of lang, e.g. fr-FR, and the noise options.
+func stealthScript(lang string, noise StealthNoise) (string, error) {
+	opts := struct {
+		StealthNoise
+		Languages []string `json:"languages"`
+		Seed      uint32   `json:"seed"`
+	}{StealthNoise: noise, Languages: []string{}, Seed: rand.Uint32() | 1}
+	if lang != "" {
+		opts.Languages = append(opts.Languages, lang)
+		if base, _, ok := strings.Cut(lang, "-"); ok {
+			opts.Languages = append(opts.Languages, base)
+		}
+	}
+
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return "", fmt.Errorf("stealth options: %w", err)
+	}
+
+	return fmt.Sprintf(stealthJS, b), nil
+}
+
+// stealth installs the stealth script of the emulation e in the tab t, it is
+// evaluated before the scripts of the next pages. The script of the previous
+// options is replaced.
+func (t *tab) stealth(ctx context.Context, e Emulation) error {
+	key := e.Lang + "\x00" + e.Noise.String()
+
+	t.mu.Lock()
+	prev, installed := t.stealthed, t.stealthKey == key && t.stealthed != ""
+	t.mu.Unlock()
+	if installed {
+		return nil
+	}
+
+	js, err := stealthScript(e.Lang, e.Noise)
+	if err != nil {
+		return err
+	}
+
+	var id page.ScriptIdentifier
+	err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		if prev != "" {
+			if err := page.RemoveScriptToEvaluateOnNewDocument(prev).Do(ctx); err != nil {
+				return err
+			}
+		}
+		var err error
+		id, err = page.AddScriptToEvaluateOnNewDocument(js).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return fmt.Errorf("stealth: %w", err)
+	}
+
+	t.mu.Lock()
+	t.stealthed, t.stealthKey = id, key
+	t.mu.Unlock()
+
+	return nil
+}
+
+// unstealth removes the stealth script of the tab t and its user agent
+// override.
+func (t *tab) unstealth(ctx context.Context) error {
+	t.mu.Lock()
+	id := t.stealthed
+	t.stealthed, t.stealthKey = "", ""
+	t.mu.Unlock()
+	if id == "" {
+		return nil
+	}
+
+	return chromedp.Run(ctx,
+		page.RemoveScriptToEvaluateOnNewDocument(id),
+		// an empty user agent restores the browser's one.
+		emulation.SetUserAgentOverride(""),
+	)
+}
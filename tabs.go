@@ -0,0 +1,1256 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	cdpfetch "github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+
+	"github.com/lightpanda-io/gomcp/pkg/browser"
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+// A browser tab managed by the targetStore.
+type tab struct {
+	id     target.ID
+	ctx    context.Context
+	cancel context.CancelFunc
+	// store owning the tab.
+	store *targetStore
+	// generation of the store's browser connection.
+	gen int
+
+	// name of the pin, empty if the tab isn't pinned.
+	pin string
+
+	mu sync.Mutex
+	// javascript dialogs opened since the last call to takeDialogs.
+	dialogs []string
+	// response of the last document loaded in the main frame.
+	response *network.Response
+	// redirects of the last navigation of the main frame.
+	chain *fetch.Chain
+	// a screen or a region is emulated, it is reset with the tab state.
+	emulated bool
+	// stealth script installed in the tab and the key of its options.
+	stealthed  page.ScriptIdentifier
+	stealthKey string
+	// pages opened by the tab since the last call to takeOpened, e.g.
+	// popups or links opened in a new tab.
+	opened []target.ID
+	// bytes received by the tab, for the audit log.
+	bytes int64
+}
+
+// listen registers the tab's event listeners.
+func (t *tab) listen() {
+	t.chain = fetch.NewChain(string(t.id))
+	chromedp.ListenTarget(t.ctx, func(ev any) {
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			t.chain.Handle(ev)
+		case *cdpfetch.EventRequestPaused:
+			// the screening resolves the host.
+			go t.screen(ev)
+		case *page.EventJavascriptDialogOpening:
+			t.mu.Lock()
+			t.dialogs = append(t.dialogs, fmt.Sprintf("%s: %s", ev.Type, ev.Message))
+			t.mu.Unlock()
+
+			// accept the dialog to unblock the page. The action must be
+			// run outside of the event handler.
+			go func() {
+				if err := chromedp.Run(t.ctx, page.HandleJavaScriptDialog(true)); err != nil {
+					slog.Debug("handle dialog", slog.String("id", t.id.String()), slog.Any("err", err))
+				}
+			}()
+		case *network.EventResponseReceived:
+			// the main frame has the id of the target.
+			if ev.Type != network.ResourceTypeDocument || string(ev.FrameID) != string(t.id) {
+				return
+			}
+			t.mu.Lock()
+			t.response = ev.Response
+			t.mu.Unlock()
+		case *network.EventLoadingFinished:
+			t.mu.Lock()
+			t.bytes += int64(ev.EncodedDataLength)
+			t.mu.Unlock()
+		case *target.EventTargetCreated:
+			if ev.TargetInfo.Type != "page" || ev.TargetInfo.OpenerID != t.id {
+				return
+			}
+			t.mu.Lock()
+			t.opened = append(t.opened, ev.TargetInfo.TargetID)
+			t.mu.Unlock()
+		}
+	})
+}
+
+// lastResponse returns the response of the last document loaded in the main
+// frame, nil if none is known.
+func (t *tab) lastResponse() *network.Response {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.response
+}
+
+// received returns the number of bytes received by the tab, 0 for a nil tab.
+func (t *tab) received() int64 {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.bytes
+}
+
+// redirects returns the redirect chain of the last navigation of the main
+// frame.
+func (t *tab) redirects() []fetch.Redirect {
+	return t.chain.Redirects()
+}
+
+// takeDialogs returns the dialogs opened and resets the list.
+func (t *tab) takeDialogs() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	d := t.dialogs
+	t.dialogs = nil
+	return d
+}
+
+// takeOpened returns the pages opened by the tab and resets the list.
+func (t *tab) takeOpened() []target.ID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	o := t.opened
+	t.opened = nil
+	return o
+}
+
+// Pool policy applied on checkout when no idle tab is available and the
+// maximum number of tabs is reached.
+type PoolPolicy string
+
+const (
+	// Wait for a tab to be checked in.
+	PoolBlock PoolPolicy = "block"
+	// Create a surplus tab, closed on checkin.
+	PoolGrow PoolPolicy = "grow"
+	// Return an error.
+	PoolFail PoolPolicy = "fail"
+)
+
+var InvalidPoolPolicy = errors.New("invalid pool policy")
+
+func (p PoolPolicy) String() string {
+	return string(p)
+}
+
+func (p *PoolPolicy) Set(v string) error {
+	switch PoolPolicy(v) {
+	case PoolBlock, PoolGrow, PoolFail:
+		*p = PoolPolicy(v)
+		return nil
+	}
+
+	return InvalidPoolPolicy
+}
+
+const (
+	PoolDefaultPolicy      = PoolBlock
+	PoolDefaultWaitTimeout = 30 * time.Second
+)
+
+var (
+	ErrPoolFull    = errors.New("all the tabs are in use")
+	ErrPoolTimeout = errors.New("timeout waiting for a free tab")
+	ErrDraining    = errors.New("browser is draining")
+)
+
+// targetStore keeps the browser tabs so they can be reused by the
+// connections instead of being created and closed on each navigation.
+type targetStore struct {
+	sync.Mutex
+	// parent context of the browser connection.
+	ctx context.Context
+	// CDP url given by the user.
+	ws        string
+	endpoints *browser.Endpoints
+	cdpctx    context.Context
+	// cancels the browser connection.
+	disconnect context.CancelFunc
+	// incremented on each reconnection.
+	gen int
+
+	idle []*tab
+	busy map[target.ID]*tab
+	// pinned tabs are never recycled nor closed with the connections.
+	pinned map[string]*tab
+	// total number of tabs opened.
+	total int
+	// closed and replaced each time a tab is released to wake up the
+	// waiting checkouts.
+	released chan struct{}
+	// time of the last failure to open a tab, zero if the browser is
+	// reachable.
+	failedAt time.Time
+	// a draining store gives no more tabs and closes the released ones.
+	draining bool
+	// checkouts counters since the store creation.
+	waits checkoutStats
+	// browser context of the tabs of an isolated store, nil for the
+	// default one.
+	isolation *isolation
+
+	// CDP host of the browser.
+	Host string
+	// Maximum number of tabs kept by the store, 0 means no limit.
+	MaxTabs int
+	// Policy applied when MaxTabs is reached.
+	Policy PoolPolicy
+	// Maximum duration of a blocking checkout.
+	WaitTimeout time.Duration
+	// Reset the tabs state on checkin so nothing leaks between the
+	// connections.
+	ResetState bool
+	// Extra HTTP headers sent by the tabs.
+	Headers HTTPHeaders
+	// Fail the requests of the tabs to the private networks in the browser.
+	BlockPrivate bool
+}
+
+// HTTPHeaders are extra HTTP headers sent with the requests of the tabs.
+// It implements flag.Value with the format "Name: value".
+type HTTPHeaders map[string]string
+
+var InvalidHTTPHeader = errors.New("invalid header, the format is Name: value")
+
+func (h HTTPHeaders) String() string {
+	s := make([]string, 0, len(h))
+	for k, v := range h {
+		s = append(s, k+": "+v)
+	}
+	slices.Sort(s)
+
+	return strings.Join(s, ", ")
+}
+
+func (h HTTPHeaders) Set(v string) error {
+	name, value, ok := strings.Cut(v, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return InvalidHTTPHeader
+	}
+
+	h[name] = strings.TrimSpace(value)
+	return nil
+}
+
+func newTargetStore(ctx context.Context, ws string, endpoints *browser.Endpoints) *targetStore {
+	ts := &targetStore{
+		ctx:         ctx,
+		ws:          ws,
+		endpoints:   endpoints,
+		busy:        make(map[target.ID]*tab),
+		pinned:      make(map[string]*tab),
+		released:    make(chan struct{}),
+		Host:        browser.Host(ws),
+		Policy:      PoolDefaultPolicy,
+		WaitTimeout: PoolDefaultWaitTimeout,
+		ResetState:  true,
+	}
+	ts.cdpctx, ts.disconnect = chromedp.NewRemoteAllocator(ctx, ws, chromedp.NoModifyURL)
+
+	return ts
+}
+
+// Reconnect replaces the browser connection after t lost it. The websocket
+// url is resolved again because it changes when the browser restarts. The
+// tabs of the lost connection are closed.
+// Nothing is done if the store is already reconnected since t was opened.
+func (ts *targetStore) Reconnect(ctx context.Context, t *tab) {
+	ts.Lock()
+	if t.gen != ts.gen {
+		ts.Unlock()
+		return
+	}
+	ts.Unlock()
+
+	ts.endpoints.Forget(ts.Host)
+	ws := ts.resolve(ctx)
+
+	ts.Lock()
+	if t.gen != ts.gen {
+		ts.Unlock()
+		return
+	}
+
+	disconnect := ts.disconnect
+	ts.cdpctx, ts.disconnect = chromedp.NewRemoteAllocator(ts.ctx, ws, chromedp.NoModifyURL)
+	ts.gen++
+
+	// the idle tabs belong to the lost connection.
+	ts.total -= len(ts.idle)
+	ts.idle = nil
+	ts.notify()
+	ts.Unlock()
+
+	slog.Info("browser reconnected", slog.String("host", ts.Host))
+
+	// the tabs of the previous connection are closed with it, the
+	// checked out ones are dropped on checkin.
+	disconnect()
+
+	// the browser context may be lost with the browser.
+	ts.Lock()
+	isolated := ts.isolation != nil
+	ts.Unlock()
+	if isolated {
+		if err := ts.isolate(ctx); err != nil {
+			slog.Error("browser context", slog.String("host", ts.Host), slog.Any("err", err))
+		}
+	}
+}
+
+// resolve returns the websocket url of the browser.
+func (ts *targetStore) resolve(ctx context.Context) string {
+	ws, err := ts.endpoints.Resolve(ctx, ts.Host)
+	if err != nil {
+		// the host may not expose /json/version, keep the given url.
+		slog.Debug("resolve cdp", slog.String("host", ts.Host), slog.Any("err", err))
+		return ts.ws
+	}
+
+	return ws
+}
+
+// Delay before trying again to open tabs on a browser which failed.
+const storeRetryDelay = 30 * time.Second
+
+// healthy returns false if the browser failed recently to open a tab.
+// The store must be locked.
+func (ts *targetStore) healthy(now time.Time) bool {
+	return ts.failedAt.IsZero() || now.Sub(ts.failedAt) > storeRetryDelay
+}
+
+// load returns the number of tabs used.
+// The store must be locked.
+func (ts *targetStore) load() int {
+	return len(ts.busy)
+}
+
+// full returns true if no more tab can be created.
+// The store must be locked.
+func (ts *targetStore) full() bool {
+	return ts.MaxTabs > 0 && ts.total >= ts.MaxTabs
+}
+
+// notify wakes up the waiting checkouts.
+// The store must be locked.
+func (ts *targetStore) notify() {
+	close(ts.released)
+	ts.released = make(chan struct{})
+}
+
+// forget removes the tab t from the store, the caller must cancel it.
+// The store must be locked.
+func (ts *targetStore) forget(t *tab) {
+	tracked := false
+	if _, ok := ts.busy[t.id]; ok {
+		delete(ts.busy, t.id)
+		tracked = true
+	}
+	if t.pin != "" && ts.pinned[t.pin] == t {
+		delete(ts.pinned, t.pin)
+		tracked = true
+	}
+
+	// the tab may have been already removed by a clear.
+	if tracked {
+		ts.total--
+		ts.notify()
+	}
+}
+
+// checkoutStats counts the checkouts not served by an idle tab.
+type checkoutStats struct {
+	checkouts int
+	// the idle tabs were missing, a new tab was opened.
+	created int
+	// the pool was full, the checkout waited for a free tab.
+	waits     int
+	waitTotal time.Duration
+	waitMax   time.Duration
+	// the waits timed out.
+	timeouts int
+	// the pool was full with the fail policy.
+	rejected int
+	// maximum number of tabs checked out at the same time.
+	peak int
+}
+
+// checkedOut records a checkout started at start. The checkouts served after
+// a wait or by a new tab are logged with the pool occupancy.
+func (ts *targetStore) checkedOut(start time.Time, waited, created bool) {
+	d := time.Since(start)
+
+	ts.Lock()
+	ts.waits.checkouts++
+	ts.waits.peak = max(ts.waits.peak, len(ts.busy))
+	if created {
+		ts.waits.created++
+	}
+	if waited {
+		ts.waits.waits++
+		ts.waits.waitTotal += d
+		ts.waits.waitMax = max(ts.waits.waitMax, d)
+	}
+	idle, busy, total := len(ts.idle), len(ts.busy), ts.total
+	ts.Unlock()
+
+	if waited || created {
+		slog.Debug("tab checkout",
+			slog.String("host", ts.Host),
+			slog.Duration("wait", d),
+			slog.Bool("waited", waited),
+			slog.Bool("created", created),
+			slog.Int("idle", idle),
+			slog.Int("busy", busy),
+			slog.Int("total", total),
+			slog.Int("max", ts.MaxTabs),
+		)
+	}
+}
+
+// Checkout returns an idle tab or creates a new one if none is available.
+// The idle tabs are checked before being returned, dead tabs are dropped.
+// When the maximum number of tabs is reached, the pool policy applies: block
+// waits for a checkin until WaitTimeout, grow creates a surplus tab and fail
+// returns ErrPoolFull. Without maximum, a new tab is always created.
+func (ts *targetStore) Checkout() (*tab, error) {
+	var timeout <-chan time.Time
+	start := time.Now()
+	waited := false
+
+	for {
+		ts.Lock()
+		if ts.draining {
+			ts.Unlock()
+			return nil, ErrDraining
+		}
+
+		if t := ts.popIdle(); t != nil {
+			ts.Unlock()
+
+			if t.alive() {
+				ts.checkedOut(start, waited, false)
+				return t, nil
+			}
+
+			slog.Debug("drop stale tab", slog.String("id", t.id.String()))
+			ts.drop(t)
+			continue
+		}
+
+		if !ts.full() || ts.Policy == PoolGrow {
+			ts.total++
+			ts.Unlock()
+
+			t, err := ts.create()
+			if err != nil {
+				return nil, err
+			}
+			ts.checkedOut(start, waited, true)
+
+			return t, nil
+		}
+
+		if ts.Policy == PoolFail {
+			ts.waits.rejected++
+			ts.Unlock()
+			slog.Debug("tab checkout: pool full", slog.String("host", ts.Host), slog.Int("max", ts.MaxTabs))
+			return nil, fmt.Errorf("%w, %d tabs max with the %s pool policy", ErrPoolFull, ts.MaxTabs, PoolFail)
+		}
+
+		// wait for a tab to be released.
+		released := ts.released
+		ts.Unlock()
+
+		if timeout == nil {
+			timer := time.NewTimer(ts.WaitTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		waited = true
+
+		select {
+		case <-released:
+		case <-timeout:
+			ts.Lock()
+			ts.waits.timeouts++
+			ts.Unlock()
+			slog.Debug("tab checkout: timeout", slog.String("host", ts.Host), slog.Duration("wait", time.Since(start)), slog.Int("max", ts.MaxTabs))
+			return nil, fmt.Errorf("%w after %s, %d tabs max with the %s pool policy", ErrPoolTimeout, ts.WaitTimeout, ts.MaxTabs, PoolBlock)
+		}
+	}
+}
+
+// create opens a new tab. The total of tabs must be already incremented.
+func (ts *targetStore) create() (*tab, error) {
+	ts.Lock()
+	cdpctx, gen, opts := ts.cdpctx, ts.gen, ts.contextOptions()
+	ts.Unlock()
+
+	ctx, cancel := chromedp.NewContext(cdpctx, opts...)
+
+	var actions []chromedp.Action
+	if len(ts.Headers) > 0 {
+		h := make(network.Headers, len(ts.Headers))
+		for k, v := range ts.Headers {
+			h[k] = v
+		}
+		actions = append(actions, network.SetExtraHTTPHeaders(h))
+	}
+
+	// ensure the tab is created
+	if err := chromedp.Run(ctx, actions...); err != nil {
+		cancel()
+
+		ts.Lock()
+		ts.total--
+		ts.failedAt = time.Now()
+		ts.notify()
+		ts.Unlock()
+
+		// the cached url may be stale.
+		ts.endpoints.Forget(ts.Host)
+
+		return nil, &CDPConnectError{Host: ts.Host, Err: fmt.Errorf("new tab: %w", err)}
+	}
+
+	t := &tab{
+		id:     chromedp.FromContext(ctx).Target.TargetID,
+		ctx:    ctx,
+		cancel: cancel,
+		store:  ts,
+		gen:    gen,
+	}
+	t.listen()
+	if ts.BlockPrivate {
+		t.blockPrivate()
+	}
+
+	ts.Lock()
+	ts.busy[t.id] = t
+	ts.failedAt = time.Time{}
+	ts.Unlock()
+
+	slog.Debug("new tab", slog.String("id", t.id.String()))
+
+	return t, nil
+}
+
+// popIdle returns the last idle tab and marks it busy, or nil if there is no
+// idle tab.
+// The store must be locked.
+func (ts *targetStore) popIdle() *tab {
+	if len(ts.idle) == 0 {
+		return nil
+	}
+
+	t := ts.idle[len(ts.idle)-1]
+	ts.idle = ts.idle[:len(ts.idle)-1]
+	ts.busy[t.id] = t
+
+	return t
+}
+
+// anyTab returns a tab of the current browser connection, idle, busy or
+// pinned, without checking it out, or nil if the store has no tab.
+func (ts *targetStore) anyTab() *tab {
+	ts.Lock()
+	defer ts.Unlock()
+
+	if len(ts.idle) > 0 {
+		return ts.idle[len(ts.idle)-1]
+	}
+	for _, t := range ts.busy {
+		if t.gen == ts.gen {
+			return t
+		}
+	}
+	for _, t := range ts.pinned {
+		if t.gen == ts.gen {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// drop removes the tab t from the store and closes it.
+func (ts *targetStore) drop(t *tab) {
+	ts.Lock()
+	ts.forget(t)
+	ts.Unlock()
+
+	t.cancel()
+}
+
+const tabCheckTimeout = 5 * time.Second
+
+// ping sends a command on the websocket of the tab's browser connection.
+func (t *tab) ping() error {
+	ctx, cancel := context.WithTimeout(t.ctx, tabCheckTimeout)
+	defer cancel()
+
+	if _, err := chromedp.Targets(ctx); err != nil {
+		return fmt.Errorf("get targets: %w", err)
+	}
+
+	return nil
+}
+
+// alive returns true if the tab's target still exists in the browser.
+func (t *tab) alive() bool {
+	if t.ctx.Err() != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(t.ctx, tabCheckTimeout)
+	defer cancel()
+
+	infos, err := chromedp.Targets(ctx)
+	if err != nil {
+		slog.Debug("get targets", slog.String("id", t.id.String()), slog.Any("err", err))
+		return false
+	}
+
+	for _, info := range infos {
+		if info.TargetID == t.id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Prune checks all the idle and unused pinned tabs and drops the dead ones.
+// It returns the number of dropped tabs.
+func (ts *targetStore) Prune() int {
+	// take the idle tabs out of the pool during the check.
+	ts.Lock()
+	idle := ts.idle
+	ts.idle = nil
+	for _, t := range idle {
+		ts.busy[t.id] = t
+	}
+	pinned := make([]*tab, 0, len(ts.pinned))
+	for _, t := range ts.pinned {
+		if _, ok := ts.busy[t.id]; !ok {
+			pinned = append(pinned, t)
+		}
+	}
+	ts.Unlock()
+
+	n := 0
+	for _, t := range idle {
+		if !t.alive() {
+			ts.drop(t)
+			n++
+			continue
+		}
+		// the idle tabs are already reset.
+		ts.checkin(t, false)
+	}
+	for _, t := range pinned {
+		if !t.alive() {
+			ts.drop(t)
+			n++
+		}
+	}
+
+	if n > 0 {
+		slog.Debug("tabs pruned", slog.Int("count", n))
+	}
+
+	return n
+}
+
+// Checkin gives back a tab to the store. Dead tabs and tabs exceeding the
+// maximum number of tabs are closed. The state of the tabs returning to the
+// pool is reset if ResetState is set.
+func (ts *targetStore) Checkin(t *tab) {
+	ts.checkin(t, ts.ResetState)
+}
+
+func (ts *targetStore) checkin(t *tab, reset bool) {
+	ts.Lock()
+
+	// the tab has been removed from the store.
+	if _, ok := ts.busy[t.id]; !ok {
+		ts.Unlock()
+		t.cancel()
+		return
+	}
+
+	if t.ctx.Err() != nil || (ts.MaxTabs > 0 && ts.total > ts.MaxTabs && t.pin == "") || (ts.draining && t.pin == "") {
+		ts.forget(t)
+		ts.Unlock()
+		t.cancel()
+		return
+	}
+
+	// pinned tabs keep their state.
+	if reset && t.pin == "" {
+		ts.Unlock()
+
+		if err := t.reset(); err != nil {
+			slog.Debug("drop tab on reset", slog.String("id", t.id.String()), slog.Any("err", err))
+			ts.drop(t)
+			return
+		}
+
+		ts.Lock()
+		// the tab may have been removed during the reset.
+		if _, ok := ts.busy[t.id]; !ok {
+			ts.Unlock()
+			t.cancel()
+			return
+		}
+	}
+
+	delete(ts.busy, t.id)
+
+	// pinned tabs stay out of the idle pool.
+	if t.pin == "" {
+		ts.idle = append(ts.idle, t)
+	}
+
+	ts.notify()
+	ts.Unlock()
+}
+
+const tabResetTimeout = 5 * time.Second
+
+// reset deletes the cookies of the current page, clears its session storage
+// and navigates to a blank page.
+// Cookies are deleted one by one because clearing all the browser cookies
+// would affect the other tabs.
+func (t *tab) reset() error {
+	ctx, cancel := context.WithTimeout(t.ctx, tabResetTimeout)
+	defer cancel()
+
+	t.mu.Lock()
+	emulated := t.emulated
+	t.emulated = false
+	t.mu.Unlock()
+	if emulated {
+		if err := chromedp.Run(ctx, resetEmulation()); err != nil {
+			return fmt.Errorf("reset emulation: %w", err)
+		}
+		if err := t.unstealth(ctx); err != nil {
+			return fmt.Errorf("reset stealth: %w", err)
+		}
+	}
+
+	err := chromedp.Run(ctx,
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			cookies, err := network.GetCookies().Do(ctx)
+			if err != nil {
+				return fmt.Errorf("get cookies: %w", err)
+			}
+			for _, c := range cookies {
+				err := network.DeleteCookies(c.Name).WithDomain(c.Domain).WithPath(c.Path).Do(ctx)
+				if err != nil {
+					return fmt.Errorf("delete cookie %s: %w", c.Name, err)
+				}
+			}
+			return nil
+		}),
+		chromedp.Evaluate(`try { sessionStorage.clear() } catch (e) {}`, nil),
+		chromedp.Navigate("about:blank"),
+	)
+	if err != nil {
+		return err
+	}
+
+	t.takeDialogs()
+	t.takeOpened()
+
+	return nil
+}
+
+var (
+	ErrNoPinnedTab = errors.New("no pinned tab")
+	ErrPinLimit    = errors.New("pinned tabs limit reached")
+	ErrPinExists   = errors.New("pin name already used")
+	ErrTabBusy     = errors.New("tab used by another connection")
+	ErrPinIsolated = errors.New("the tabs of an isolated session can't be pinned")
+)
+
+// Pin exempts the checked out tab t from recycling under the given name.
+// No more than limit tabs can be pinned.
+func (ts *targetStore) Pin(name string, t *tab, limit int) error {
+	ts.Lock()
+	defer ts.Unlock()
+
+	if t.pin == name {
+		return nil
+	}
+	if _, ok := ts.pinned[name]; ok {
+		return ErrPinExists
+	}
+	if t.pin == "" && len(ts.pinned) >= limit {
+		return ErrPinLimit
+	}
+
+	if t.pin != "" {
+		delete(ts.pinned, t.pin)
+	}
+	t.pin = name
+	ts.pinned[name] = t
+
+	return nil
+}
+
+// attach attaches to the existing target id of the browser.
+func (ts *targetStore) attach(id target.ID) (*tab, error) {
+	ts.Lock()
+	cdpctx, gen := ts.cdpctx, ts.gen
+	ts.Unlock()
+
+	ctx, cancel := chromedp.NewContext(cdpctx, chromedp.WithTargetID(id))
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("attach tab %s: %w", id, err)
+	}
+
+	t := &tab{
+		id:     id,
+		ctx:    ctx,
+		cancel: cancel,
+		store:  ts,
+		gen:    gen,
+	}
+	t.listen()
+	if ts.BlockPrivate {
+		t.blockPrivate()
+	}
+
+	return t, nil
+}
+
+// Attach attaches to the existing target id of the browser, e.g. a page
+// opened by a checked out tab, and checks it out.
+func (ts *targetStore) Attach(id target.ID) (*tab, error) {
+	t, err := ts.attach(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ts.Lock()
+	defer ts.Unlock()
+
+	if _, ok := ts.busy[t.id]; ok {
+		t.cancel()
+		return nil, ErrTabBusy
+	}
+	ts.busy[t.id] = t
+	ts.total++
+
+	slog.Debug("tab attached", slog.String("id", id.String()))
+
+	return t, nil
+}
+
+// Adopt attaches to the existing target id of the browser and pins it under
+// name. It takes over a tab pinned by another daemon.
+func (ts *targetStore) Adopt(name string, id target.ID) (*tab, error) {
+	ts.Lock()
+	if _, ok := ts.pinned[name]; ok {
+		ts.Unlock()
+		return nil, ErrPinExists
+	}
+	ts.Unlock()
+
+	t, err := ts.attach(id)
+	if err != nil {
+		return nil, err
+	}
+	t.pin = name
+
+	ts.Lock()
+	defer ts.Unlock()
+
+	if _, ok := ts.pinned[name]; ok {
+		t.cancel()
+		return nil, ErrPinExists
+	}
+	ts.pinned[name] = t
+	ts.busy[t.id] = t
+	ts.total++
+
+	slog.Info("pinned tab adopted", slog.String("name", name), slog.String("id", id.String()))
+
+	return t, nil
+}
+
+// Unpin gives back the tab pinned under name to the regular pool.
+func (ts *targetStore) Unpin(name string) error {
+	ts.Lock()
+
+	t, ok := ts.pinned[name]
+	if !ok {
+		ts.Unlock()
+		return ErrNoPinnedTab
+	}
+
+	delete(ts.pinned, name)
+	t.pin = ""
+
+	// the tab is used, it will be checked in by its connection.
+	if _, ok := ts.busy[t.id]; ok {
+		ts.Unlock()
+		return nil
+	}
+
+	// the tab is checked in again to apply the checkin rules.
+	ts.busy[t.id] = t
+	ts.Unlock()
+
+	ts.Checkin(t)
+
+	return nil
+}
+
+// CheckoutPinned returns the tab pinned under name. The current tab of the
+// caller, if any, is given with cur to allow checking out it again.
+func (ts *targetStore) CheckoutPinned(name string, cur *tab) (*tab, error) {
+	ts.Lock()
+
+	t, ok := ts.pinned[name]
+	if !ok {
+		ts.Unlock()
+		return nil, ErrNoPinnedTab
+	}
+	if t == cur {
+		ts.Unlock()
+		return t, nil
+	}
+	if _, ok := ts.busy[t.id]; ok {
+		ts.Unlock()
+		return nil, ErrTabBusy
+	}
+	if ts.draining {
+		ts.Unlock()
+		return nil, ErrDraining
+	}
+
+	if t.ctx.Err() != nil {
+		ts.forget(t)
+		ts.Unlock()
+		t.cancel()
+		return nil, ErrNoPinnedTab
+	}
+
+	ts.busy[t.id] = t
+	ts.Unlock()
+
+	return t, nil
+}
+
+// IsPinned returns true if the tab is pinned.
+func (ts *targetStore) IsPinned(t *tab) bool {
+	ts.Lock()
+	defer ts.Unlock()
+
+	return t.pin != ""
+}
+
+// TabInfo describes a tab of the store.
+type TabInfo struct {
+	Id    string `json:"id"`
+	Host  string `json:"host"`
+	State string `json:"state"`
+	Pin   string `json:"pin,omitempty"`
+}
+
+// List returns the info of all the tabs.
+func (ts *targetStore) List() []TabInfo {
+	ts.Lock()
+	defer ts.Unlock()
+
+	infos := make([]TabInfo, 0, ts.total)
+	for _, t := range ts.idle {
+		infos = append(infos, TabInfo{Id: t.id.String(), Host: ts.Host, State: "idle"})
+	}
+	for _, t := range ts.busy {
+		infos = append(infos, TabInfo{Id: t.id.String(), Host: ts.Host, State: "checked-out", Pin: t.pin})
+	}
+	for name, t := range ts.pinned {
+		if _, ok := ts.busy[t.id]; ok {
+			continue
+		}
+		infos = append(infos, TabInfo{Id: t.id.String(), Host: ts.Host, State: "pinned", Pin: name})
+	}
+
+	return infos
+}
+
+// TabsStats counts the tabs of one CDP host.
+type TabsStats struct {
+	Idle       int `json:"idle"`
+	CheckedOut int `json:"checkedOut"`
+	Pinned     int `json:"pinned"`
+	Total      int `json:"total"`
+	Max        int `json:"max"`
+	// Behavior of the checkouts when Max is reached.
+	Policy   PoolPolicy `json:"policy"`
+	Healthy  bool       `json:"healthy"`
+	Draining bool       `json:"draining,omitempty"`
+	// Checkouts since the start, the ones served by a new tab and the ones
+	// waiting for a free tab.
+	Checkouts int           `json:"checkouts"`
+	Created   int           `json:"created"`
+	Waits     int           `json:"waits"`
+	WaitTotal time.Duration `json:"waitTotal"`
+	WaitMax   time.Duration `json:"waitMax"`
+	// Checkouts failed because all the tabs were in use.
+	Timeouts int `json:"timeouts"`
+	Rejected int `json:"rejected"`
+	// Maximum number of tabs checked out at the same time.
+	Peak int `json:"peak"`
+	// Reconnections of the browser after a lost connection.
+	Reconnects int `json:"reconnects"`
+}
+
+// Hints returns the pool size tunings suggested by the checkouts.
+func (st TabsStats) Hints() []string {
+	var hints []string
+	if n := st.Timeouts + st.Rejected; n > 0 {
+		hints = append(hints, fmt.Sprintf("%d checkouts failed with all the tabs in use, raise --max-tabs or use --pool-policy grow", n))
+	}
+	if st.Checkouts > 0 && st.Waits*10 > st.Checkouts {
+		hints = append(hints, fmt.Sprintf("%d%% of the checkouts waited for a free tab, %s on average, raise --max-tabs",
+			st.Waits*100/st.Checkouts, (st.WaitTotal/time.Duration(st.Waits)).Round(time.Millisecond)))
+	}
+	if st.Max > 0 && st.Checkouts >= 100 && st.Waits == 0 && st.Peak*2 <= st.Max {
+		hints = append(hints, fmt.Sprintf("at most %d of the %d tabs were used at the same time, --max-tabs can be lowered", st.Peak, st.Max))
+	}
+
+	return hints
+}
+
+// Stats returns the tabs counts per CDP host.
+func (ts *targetStore) Stats() map[string]TabsStats {
+	ts.Lock()
+	defer ts.Unlock()
+
+	return map[string]TabsStats{
+		ts.Host: {
+			Idle:       len(ts.idle),
+			CheckedOut: len(ts.busy),
+			Pinned:     len(ts.pinned),
+			Total:      ts.total,
+			Max:        ts.MaxTabs,
+			Policy:     ts.Policy,
+			Healthy:    ts.healthy(time.Now()),
+			Draining:   ts.draining,
+			Checkouts:  ts.waits.checkouts,
+			Created:    ts.waits.created,
+			Waits:      ts.waits.waits,
+			WaitTotal:  ts.waits.waitTotal,
+			WaitMax:    ts.waits.waitMax,
+			Timeouts:   ts.waits.timeouts,
+			Rejected:   ts.waits.rejected,
+			Peak:       ts.waits.peak,
+			Reconnects: ts.gen,
+		},
+	}
+}
+
+var ErrNoTab = errors.New("no tab found")
+
+// CloseTarget closes the browser target id. The target isn't required to be
+// managed by the store.
+func (ts *targetStore) CloseTarget(id target.ID) error {
+	ts.Lock()
+	var found *tab
+	for _, t := range ts.idle {
+		if t.id == id {
+			found = t
+		}
+	}
+	if t, ok := ts.busy[id]; ok {
+		found = t
+	}
+	for _, t := range ts.pinned {
+		if t.id == id {
+			found = t
+		}
+	}
+	if found != nil {
+		ts.idle = slices.DeleteFunc(ts.idle, func(t *tab) bool { return t == found })
+		// idle tabs are not tracked by forget.
+		ts.busy[found.id] = found
+		ts.forget(found)
+	}
+	ts.Unlock()
+
+	if found != nil {
+		found.cancel()
+		return nil
+	}
+
+	// use a tab to reach the browser.
+	t, err := ts.Checkout()
+	if err != nil {
+		return fmt.Errorf("checkout: %w", err)
+	}
+	defer ts.Checkin(t)
+
+	ctx, cancel := context.WithTimeout(t.ctx, tabCheckTimeout)
+	defer cancel()
+
+	err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		c := chromedp.FromContext(ctx)
+		return target.CloseTarget(id).Do(cdp.WithExecutor(ctx, c.Browser))
+	}))
+	if err != nil {
+		return fmt.Errorf("close target %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// Clear closes all the tabs. The tabs checked out are closed too, their
+// connections will get a new tab on the next navigation.
+func (ts *targetStore) Clear() int {
+	ts.Lock()
+	tabs := make([]*tab, 0, ts.total)
+	tabs = append(tabs, ts.idle...)
+	for _, t := range ts.busy {
+		tabs = append(tabs, t)
+	}
+	for _, t := range ts.pinned {
+		if _, ok := ts.busy[t.id]; !ok {
+			tabs = append(tabs, t)
+		}
+	}
+
+	ts.idle = nil
+	ts.busy = make(map[target.ID]*tab)
+	ts.pinned = make(map[string]*tab)
+	ts.total = 0
+	ts.notify()
+	ts.Unlock()
+
+	for _, t := range tabs {
+		t.cancel()
+	}
+
+	return len(tabs)
+}
+
+// Drain stops giving tabs, closes the idle ones and waits for the checked out
+// tabs to be released before closing the store. The pinned tabs are closed
+// last.
+func (ts *targetStore) Drain(ctx context.Context) error {
+	ts.Lock()
+	ts.draining = true
+	idle := ts.idle
+	ts.idle = nil
+	ts.total -= len(idle)
+	ts.notify()
+	ts.Unlock()
+
+	for _, t := range idle {
+		t.cancel()
+	}
+
+	for {
+		ts.Lock()
+		inuse := len(ts.busy)
+		released := ts.released
+		ts.Unlock()
+
+		if inuse == 0 {
+			break
+		}
+
+		slog.Debug("drain: wait tabs", slog.String("host", ts.Host), slog.Int("busy", inuse))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+
+	ts.Close()
+
+	return nil
+}
+
+// Close closes all the tabs, the browser context of an isolated store is
+// disposed.
+func (ts *targetStore) Close() {
+	ts.Lock()
+
+	for _, t := range ts.idle {
+		t.cancel()
+	}
+	for _, t := range ts.busy {
+		t.cancel()
+	}
+	for _, t := range ts.pinned {
+		t.cancel()
+	}
+
+	ts.idle = nil
+	ts.busy = make(map[target.ID]*tab)
+	ts.pinned = make(map[string]*tab)
+	ts.total = 0
+	ts.notify()
+
+	ts.disconnect()
+
+	isolation := ts.isolation
+	ts.isolation = nil
+	ts.Unlock()
+
+	isolation.dispose()
+}
@@ -0,0 +1,118 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSOptions are the certificates of the HTTP server.
+type TLSOptions struct {
+	// Certificate and key files of the server, in PEM. TLS is disabled
+	// without them.
+	Cert string
+	Key  string
+	// CA file of the client certificates, in PEM. The clients must give a
+	// certificate signed by one of its CAs.
+	ClientCA string
+}
+
+// Enabled returns true if the server uses TLS.
+func (o TLSOptions) Enabled() bool {
+	return o.Cert != "" || o.Key != ""
+}
+
+// Config returns the TLS configuration of the server, nil if TLS is disabled.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	if !o.Enabled() {
+		if o.ClientCA != "" {
+			return nil, errors.New("tls: client CA given without certificate")
+		}
+		return nil, nil
+	}
+	if o.Cert == "" || o.Key == "" {
+		return nil, errors.New("tls: both certificate and key are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(o.Cert, o.Key)
+	if err != nil {
+		return nil, fmt.Errorf("tls: load certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if o.ClientCA != "" {
+		b, err := os.ReadFile(o.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("tls: read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("tls: no certificate in client CA %s", o.ClientCA)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// TLSClientOptions are the certificates of the clients of the HTTP server,
+// e.g. the admin commands.
+type TLSClientOptions struct {
+	// CA file of the server certificate, in PEM, the system CAs by default.
+	CA string
+	// Certificate and key files of the client, in PEM, for a server
+	// requiring the client certificates.
+	Cert string
+	Key  string
+}
+
+// Config returns the TLS configuration of the client.
+func (o TLSClientOptions) Config() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if o.CA != "" {
+		b, err := os.ReadFile(o.CA)
+		if err != nil {
+			return nil, fmt.Errorf("tls: read CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("tls: no certificate in CA %s", o.CA)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if o.Cert != "" || o.Key != "" {
+		if o.Cert == "" || o.Key == "" {
+			return nil, errors.New("tls: both client certificate and key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(o.Cert, o.Key)
+		if err != nil {
+			return nil, fmt.Errorf("tls: load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
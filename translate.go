@@ -0,0 +1,108 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+const translateTimeout = time.Minute
+
+// Translator translates the extracted contents with a LibreTranslate
+// compatible endpoint.
+type Translator struct {
+	// URL of the translate endpoint, e.g. https://libretranslate.com/translate.
+	Endpoint string
+	// Language of the translations, e.g. en.
+	Target string
+	// Optional key of the endpoint.
+	APIKey string
+}
+
+type translateRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+type translateResponse struct {
+	TranslatedText string `json:"translatedText"`
+	Error          string `json:"error"`
+}
+
+// Translate returns the text translated in the target language. lang is the
+// language of the text, detected by the endpoint if empty. The text already
+// in the target language and the nil translator return the text unchanged.
+func (t *Translator) Translate(ctx context.Context, text, lang string) (string, error) {
+	if t == nil || text == "" {
+		return text, nil
+	}
+	source := fetch.PrimaryLanguage(lang)
+	if source == fetch.PrimaryLanguage(t.Target) {
+		return text, nil
+	}
+	if source == "" {
+		source = "auto"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, translateTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(translateRequest{
+		Q:      text,
+		Source: source,
+		Target: t.Target,
+		Format: "text",
+		APIKey: t.APIKey,
+	})
+	if err != nil {
+		return "", fmt.Errorf("translate: encode: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("translate: create http req: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("translate: do req: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tr translateResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(&tr); err != nil {
+		return "", fmt.Errorf("translate: bad status code %d: decode: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || tr.Error != "" {
+		return "", fmt.Errorf("translate: bad status code %d: %s", resp.StatusCode, tr.Error)
+	}
+
+	slog.Debug("translated", slog.String("source", source), slog.String("target", t.Target), slog.Int("len", len(text)))
+
+	return tr.TranslatedText, nil
+}
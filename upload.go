@@ -0,0 +1,118 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+var (
+	ErrUploadDisabled = errors.New("file uploads disabled, start the server with --upload-dir")
+	ErrNoUploadFile   = errors.New("no file")
+)
+
+// uploadPaths resolves the files, relative to the upload directory, into
+// absolute paths. The files outside the directory are refused, the agents
+// must not send arbitrary local files to the pages.
+func (s *MCPServer) uploadPaths(files []string) ([]string, error) {
+	if s.UploadDir == "" {
+		return nil, ErrUploadDisabled
+	}
+	if len(files) == 0 {
+		return nil, ErrNoUploadFile
+	}
+
+	dir, err := filepath.Abs(s.UploadDir)
+	if err == nil {
+		dir, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("upload dir: %w", err)
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		if !filepath.IsLocal(f) {
+			return nil, fmt.Errorf("upload %s: not a relative path inside the upload directory", f)
+		}
+		// the symlinks must not escape the directory either.
+		p, err := filepath.EvalSymlinks(filepath.Join(dir, f))
+		if err != nil {
+			return nil, fmt.Errorf("upload %s: %w", f, err)
+		}
+		if !strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			return nil, fmt.Errorf("upload %s: not a relative path inside the upload directory", f)
+		}
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("upload %s: %w", f, err)
+		}
+		if !fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("upload %s: not a regular file", f)
+		}
+		paths = append(paths, p)
+	}
+
+	return paths, nil
+}
+
+// setFileInput selects the files in the file input matching sel.
+func (s *MCPServer) setFileInput(ctx context.Context, sel string, files []string) error {
+	paths, err := s.uploadPaths(files)
+	if err != nil {
+		return err
+	}
+
+	expr, by := fetch.Query(sel)
+	if err := chromedp.Run(ctx, chromedp.SetUploadFiles(expr, paths, by)); err != nil {
+		return fmt.Errorf("set file input %s: %w", sel, err)
+	}
+
+	return nil
+}
+
+// SetFileInput selects the files, relative to the upload directory, in the
+// file input matching the selector.
+func (c *MCPConn) SetFileInput(ctx context.Context, sel string, files []string) (string, error) {
+	if c.cdpctx == nil {
+		return "", errors.New("no browser connection, try to use goto first")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, interactTimeout)
+	defer cancel()
+
+	tctx, cancel := c.tabctx(ctx)
+	defer cancel()
+
+	if err := c.srv.setFileInput(tctx, sel, files); err != nil {
+		return "", err
+	}
+	if err := chromedp.Run(tctx, chromedp.Sleep(interactSettle)); err != nil {
+		return "", fmt.Errorf("set file input %s: %w", sel, err)
+	}
+
+	c.screenshot(ctx)
+
+	return fmt.Sprintf("The files %s have been selected in '%s'.", strings.Join(files, ", "), sel), nil
+}
@@ -0,0 +1,386 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	vaultFile = "vault.json"
+
+	// PBKDF2 iterations deriving the key of the vault from the passphrase.
+	vaultIterations = 600_000
+	// bounds of the iterations of the opened vaults: too few weaken the
+	// key, too many hang the derivation.
+	vaultMinIterations = 100_000
+	vaultMaxIterations = 10_000_000
+)
+
+var (
+	ErrVaultPassphrase = errors.New("wrong vault passphrase or corrupted vault")
+	ErrNoCredential    = errors.New("no credential for the site")
+)
+
+func vaultfile() string {
+	dir, err := configdir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, vaultFile)
+}
+
+// LoginStep is a step of a scripted login. The values reference the
+// credential as ${username} and ${password}.
+type LoginStep struct {
+	// goto, click, type, press or wait.
+	Action   string `json:"action" yaml:"action"`
+	Selector string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	// url of goto, text of type or key of press.
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// Credential is the login of a site.
+type Credential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Page of the login form, the current page by default.
+	LoginURL string `json:"loginUrl,omitempty"`
+	// Steps of a scripted login, the login form is detected without them.
+	Steps []LoginStep `json:"steps,omitempty"`
+}
+
+// expand replaces the references to the credential in the step value.
+func (c Credential) expand(v string) string {
+	return strings.NewReplacer("${username}", c.Username, "${password}", c.Password).Replace(v)
+}
+
+// sealedVault is the vault file, the credentials are encrypted with AES-GCM
+// with a key derived from the passphrase.
+type sealedVault struct {
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// Vault stores the credentials of the sites by host, encrypted with a
+// passphrase.
+type Vault struct {
+	Path       string
+	Passphrase string
+}
+
+func vaultCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Load returns the credentials by site, none if the vault doesn't exist.
+func (v Vault) Load() (map[string]Credential, error) {
+	creds := map[string]Credential{}
+
+	b, err := os.ReadFile(v.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return creds, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read vault: %w", err)
+	}
+
+	var sealed sealedVault
+	if err := json.Unmarshal(b, &sealed); err != nil {
+		return nil, fmt.Errorf("decode vault: %w", err)
+	}
+	if sealed.Iterations < vaultMinIterations || sealed.Iterations > vaultMaxIterations {
+		return nil, fmt.Errorf("decode vault: invalid iterations %d", sealed.Iterations)
+	}
+	aead, err := vaultCipher(v.Passphrase, sealed.Salt, sealed.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed.Nonce) != aead.NonceSize() {
+		return nil, ErrVaultPassphrase
+	}
+	data, err := aead.Open(nil, sealed.Nonce, sealed.Data, nil)
+	if err != nil {
+		return nil, ErrVaultPassphrase
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("decode vault: %w", err)
+	}
+
+	return creds, nil
+}
+
+// Save encrypts the credentials and replaces the vault.
+func (v Vault) Save(creds map[string]Credential) error {
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("encode vault: %w", err)
+	}
+
+	sealed := sealedVault{
+		Iterations: vaultIterations,
+		Salt:       make([]byte, 16),
+	}
+	if _, err := rand.Read(sealed.Salt); err != nil {
+		return fmt.Errorf("salt: %w", err)
+	}
+	aead, err := vaultCipher(v.Passphrase, sealed.Salt, sealed.Iterations)
+	if err != nil {
+		return err
+	}
+	sealed.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(sealed.Nonce); err != nil {
+		return fmt.Errorf("nonce: %w", err)
+	}
+	sealed.Data = aead.Seal(nil, sealed.Nonce, data, nil)
+
+	b, err := json.Marshal(sealed)
+	if err != nil {
+		return fmt.Errorf("encode vault: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(v.Path), 0775); err != nil {
+		return fmt.Errorf("create dir: %w", err)
+	}
+	// the rename doesn't leave a partial vault.
+	if err := os.WriteFile(v.Path+".tmp", b, 0600); err != nil {
+		return fmt.Errorf("write vault: %w", err)
+	}
+	if err := os.Rename(v.Path+".tmp", v.Path); err != nil {
+		return fmt.Errorf("write vault: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the credential of the site or of its closest parent domain.
+func (v Vault) Get(site string) (string, Credential, error) {
+	creds, err := v.Load()
+	if err != nil {
+		return "", Credential{}, err
+	}
+
+	host := siteHost(site)
+	for host != "" {
+		if c, ok := creds[host]; ok {
+			return host, c, nil
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			break
+		}
+		host = parent
+	}
+
+	return "", Credential{}, fmt.Errorf("%w %s", ErrNoCredential, site)
+}
+
+// siteHost returns the host of the site, given as a host or an url.
+func siteHost(site string) string {
+	site = strings.ToLower(strings.TrimSpace(site))
+	if strings.Contains(site, "://") {
+		if u, err := url.Parse(site); err == nil {
+			return u.Hostname()
+		}
+	}
+	host, _, _ := strings.Cut(site, "/")
+
+	return strings.TrimSuffix(host, ".")
+}
+
+// runauth executes the auth command managing the vault. The passphrase and
+// the password are read from in when they aren't given by the env, without
+// echo from a terminal.
+func runauth(path string, args []string, in io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing auth command: add <site>|list|remove <site>")
+	}
+
+	lines := bufio.NewScanner(in)
+	read := func(prompt string) (string, error) {
+		fmt.Fprintf(stderr, "%s: ", prompt)
+		if !lines.Scan() {
+			if err := lines.Err(); err != nil {
+				return "", fmt.Errorf("read %s: %w", prompt, err)
+			}
+			return "", fmt.Errorf("read %s: %w", prompt, io.ErrUnexpectedEOF)
+		}
+		return strings.TrimRight(lines.Text(), "\r"), nil
+	}
+	secret := func(prompt string) (string, error) {
+		f, ok := in.(*os.File)
+		if !ok || !term.IsTerminal(int(f.Fd())) {
+			return read(prompt)
+		}
+		fmt.Fprintf(stderr, "%s: ", prompt)
+		b, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(stderr)
+		if err != nil {
+			return "", fmt.Errorf("read %s: %w", prompt, err)
+		}
+		return string(b), nil
+	}
+
+	v := Vault{Path: path, Passphrase: os.Getenv("MCP_VAULT_PASSPHRASE")}
+	if v.Passphrase == "" {
+		var err error
+		if v.Passphrase, err = secret("Vault passphrase"); err != nil {
+			return err
+		}
+		if v.Passphrase == "" {
+			return errors.New("empty vault passphrase")
+		}
+	}
+
+	switch args[0] {
+	case "add":
+		flags := flag.NewFlagSet("auth add", flag.ContinueOnError)
+		flags.SetOutput(stderr)
+
+		var (
+			user     = flags.String("username", "", "login of the site, read from the input by default")
+			loginurl = flags.String("login-url", "", "page of the login form, the current page by default")
+			steps    = flags.String("steps", "", "YAML file of the steps of a scripted login, the login form is detected without it")
+		)
+		flags.Usage = func() {
+			fmt.Fprintf(stderr, "usage: auth add [options] <site>\n")
+			flags.PrintDefaults()
+		}
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			flags.Usage()
+			return errors.New("bad arguments")
+		}
+		site := siteHost(flags.Arg(0))
+		if site == "" {
+			return errors.New("no site")
+		}
+
+		c := Credential{Username: *user, LoginURL: *loginurl}
+		if *steps != "" {
+			b, err := os.ReadFile(*steps)
+			if err != nil {
+				return fmt.Errorf("read steps: %w", err)
+			}
+			if err := yaml.Unmarshal(b, &c.Steps); err != nil {
+				return fmt.Errorf("decode steps: %w", err)
+			}
+			for _, st := range c.Steps {
+				if !slices.Contains(loginActions, st.Action) {
+					return fmt.Errorf("invalid login step %q, want %s", st.Action, strings.Join(loginActions, ", "))
+				}
+			}
+		}
+
+		creds, err := v.Load()
+		if err != nil {
+			return err
+		}
+		if c.Username == "" {
+			if c.Username, err = read("Username"); err != nil {
+				return err
+			}
+		}
+		if c.Password, err = secret("Password"); err != nil {
+			return err
+		}
+		creds[site] = c
+
+		if err := v.Save(creds); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "credential of %s saved in %s\n", site, path)
+
+		return nil
+	case "list":
+		if len(args) != 1 {
+			return errors.New("usage: auth list")
+		}
+
+		creds, err := v.Load()
+		if err != nil {
+			return err
+		}
+		sites := make([]string, 0, len(creds))
+		for site := range creds {
+			sites = append(sites, site)
+		}
+		slices.Sort(sites)
+		for _, site := range sites {
+			c := creds[site]
+			fmt.Fprintf(stdout, "%s\t%s", site, c.Username)
+			if c.LoginURL != "" {
+				fmt.Fprintf(stdout, "\t%s", c.LoginURL)
+			}
+			if len(c.Steps) > 0 {
+				fmt.Fprintf(stdout, "\t%d steps", len(c.Steps))
+			}
+			fmt.Fprintln(stdout)
+		}
+
+		return nil
+	case "remove":
+		if len(args) != 2 {
+			return errors.New("usage: auth remove <site>")
+		}
+
+		creds, err := v.Load()
+		if err != nil {
+			return err
+		}
+		site := siteHost(args[1])
+		if _, ok := creds[site]; !ok {
+			return fmt.Errorf("%w %s", ErrNoCredential, site)
+		}
+		delete(creds, site)
+
+		return v.Save(creds)
+	}
+
+	return fmt.Errorf("invalid auth command: %s", args[0])
+}
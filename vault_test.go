@@ -0,0 +1,170 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVaultSealOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), vaultFile)
+	creds := map[string]Credential{
+		"example.com": {Username: "alice", Password: "p4ss", LoginURL: "https://example.com/login"},
+	}
+	if err := (Vault{Path: path, Passphrase: "secret"}).Save(creds); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sealed sealedVault
+	if err := json.Unmarshal(b, &sealed); err != nil {
+		t.Fatal(err)
+	}
+
+	// reseal writes the vault sealed with its fields changed by fn.
+	reseal := func(t *testing.T, fn func(*sealedVault)) string {
+		s := sealed
+		s.Data = append([]byte(nil), sealed.Data...)
+		fn(&s)
+		b, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := filepath.Join(t.TempDir(), vaultFile)
+		if err := os.WriteFile(p, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	for _, tc := range []struct {
+		name       string
+		path       func(t *testing.T) string
+		passphrase string
+		want       map[string]Credential
+		// any error is expected if fails, err if set.
+		fails bool
+		err   error
+	}{
+		{
+			name:       "open",
+			path:       func(*testing.T) string { return path },
+			passphrase: "secret",
+			want:       creds,
+		},
+		{
+			name:       "missing",
+			path:       func(t *testing.T) string { return filepath.Join(t.TempDir(), vaultFile) },
+			passphrase: "secret",
+			want:       map[string]Credential{},
+		},
+		{
+			name:       "wrong passphrase",
+			path:       func(*testing.T) string { return path },
+			passphrase: "wrong",
+			fails:      true,
+			err:        ErrVaultPassphrase,
+		},
+		{
+			name:       "tampered",
+			path:       func(t *testing.T) string { return reseal(t, func(s *sealedVault) { s.Data[0] ^= 1 }) },
+			passphrase: "secret",
+			fails:      true,
+			err:        ErrVaultPassphrase,
+		},
+		{
+			name:       "short nonce",
+			path:       func(t *testing.T) string { return reseal(t, func(s *sealedVault) { s.Nonce = s.Nonce[:4] }) },
+			passphrase: "secret",
+			fails:      true,
+			err:        ErrVaultPassphrase,
+		},
+		{
+			name:       "few iterations",
+			path:       func(t *testing.T) string { return reseal(t, func(s *sealedVault) { s.Iterations = 1 }) },
+			passphrase: "secret",
+			fails:      true,
+		},
+		{
+			name:       "many iterations",
+			path:       func(t *testing.T) string { return reseal(t, func(s *sealedVault) { s.Iterations = 1 << 40 }) },
+			passphrase: "secret",
+			fails:      true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Vault{Path: tc.path(t), Passphrase: tc.passphrase}.Load()
+			switch {
+			case tc.fails:
+				if err == nil || (tc.err != nil && !errors.Is(err, tc.err)) {
+					t.Fatalf("Load() = %v, want an error %v", err, tc.err)
+				}
+			case err != nil:
+				t.Fatalf("Load() = %v", err)
+			case len(got) != len(tc.want):
+				t.Fatalf("Load() = %v, want %v", got, tc.want)
+			default:
+				for site, c := range tc.want {
+					if g := got[site]; g.Username != c.Username || g.Password != c.Password || g.LoginURL != c.LoginURL {
+						t.Errorf("Load()[%s] = %+v, want %+v", site, g, c)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestVaultGet(t *testing.T) {
+	v := Vault{Path: filepath.Join(t.TempDir(), vaultFile), Passphrase: "secret"}
+	if err := v.Save(map[string]Credential{
+		"example.com":       {Username: "root"},
+		"login.example.com": {Username: "sub"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		site string
+		host string
+		err  bool
+	}{
+		{site: "example.com", host: "example.com"},
+		{site: "https://www.example.com/account", host: "example.com"},
+		{site: "login.example.com", host: "login.example.com"},
+		{site: "EXAMPLE.COM", host: "example.com"},
+		{site: "example.org", err: true},
+		{site: "notexample.com", err: true},
+	} {
+		t.Run(tc.site, func(t *testing.T) {
+			host, _, err := v.Get(tc.site)
+			if tc.err {
+				if !errors.Is(err, ErrNoCredential) {
+					t.Errorf("Get(%s) = %s, %v, want ErrNoCredential", tc.site, host, err)
+				}
+				return
+			}
+			if err != nil || host != tc.host {
+				t.Errorf("Get(%s) = %s, %v, want %s", tc.site, host, err, tc.host)
+			}
+		})
+	}
+}
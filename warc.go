@@ -0,0 +1,44 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lightpanda-io/gomcp/pkg/warc"
+)
+
+// warcSoftware is the software named in the warcinfo records.
+const warcSoftware = "lightpanda gomcp"
+
+// createWARC creates the WARC file at path, compressed if it ends with .gz,
+// and writes its warcinfo record. The caller closes the file.
+func createWARC(path string) (*warc.Writer, io.Closer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("warc: %w", err)
+	}
+
+	w := warc.NewWriter(f, strings.HasSuffix(path, ".gz"))
+	if err := w.WriteInfo(warcSoftware); err != nil {
+		f.Close() // nolint:errcheck
+		return nil, nil, fmt.Errorf("warc: %w", err)
+	}
+
+	return w, f, nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2025 Lightpanda (Selecy SAS)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lightpanda-io/gomcp/pkg/fetch"
+)
+
+const (
+	WatchDefaultInterval = 10 * time.Minute
+	// Timeout of the fetch of a watched page.
+	WatchPageTimeout = 30 * time.Second
+
+	snapshotsDir = "snapshots"
+)
+
+var ErrNoSnapshots = errors.New("no snapshots directory")
+
+// snapshotsdir returns the default directory of the pages snapshots.
+func snapshotsdir() string {
+	dir, err := configdir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, snapshotsDir)
+}
+
+// Snapshot is the last content seen of a watched page.
+type Snapshot struct {
+	URL       string    `json:"url"`
+	Hash      string    `json:"hash"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Markdown  string    `json:"markdown"`
+}
+
+// PageChange is the change of a page since its last snapshot.
+type PageChange struct {
+	URL string `json:"url"`
+	// Time of the previous snapshot, zero for the first one.
+	Since   time.Time `json:"since,omitzero"`
+	Changed bool      `json:"changed"`
+	// Unified diff of the markdown, empty without change.
+	Diff string `json:"diff,omitempty"`
+}
+
+// Snapshots stores the last snapshot of each page in a directory, one JSON
+// file per url.
+type Snapshots struct {
+	Dir string
+}
+
+func (s Snapshots) path(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// Load returns the snapshot of the url, fs.ErrNotExist if there is none.
+func (s Snapshots) Load(u string) (Snapshot, error) {
+	var snap Snapshot
+
+	b, err := os.ReadFile(s.path(u))
+	if err != nil {
+		return snap, fmt.Errorf("read snapshot: %w", err)
+	}
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return snap, fmt.Errorf("decode snapshot: %w", err)
+	}
+
+	return snap, nil
+}
+
+// Save replaces the snapshot of its url.
+func (s Snapshots) Save(snap Snapshot) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("snapshots dir: %w", err)
+	}
+
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	// the rename doesn't leave a partial snapshot.
+	path := s.path(snap.URL)
+	if err := os.WriteFile(path+".tmp", b, 0644); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// Compare returns the change of the markdown of the url since its last
+// snapshot, the markdown fetched at is saved as the new snapshot.
+func (s Snapshots) Compare(u, md string, at time.Time) (PageChange, error) {
+	sum := sha256.Sum256([]byte(md))
+	next := Snapshot{URL: u, Hash: hex.EncodeToString(sum[:]), FetchedAt: at, Markdown: md}
+
+	prev, err := s.Load(u)
+	if errors.Is(err, fs.ErrNotExist) {
+		return PageChange{URL: u}, s.Save(next)
+	}
+	if err != nil {
+		return PageChange{}, err
+	}
+
+	change := PageChange{URL: u, Since: prev.FetchedAt}
+	if prev.Hash == next.Hash {
+		return change, nil
+	}
+
+	change.Changed = true
+	change.Diff = fetch.Diff(
+		u+"\t"+prev.FetchedAt.Format(time.RFC3339),
+		u+"\t"+at.Format(time.RFC3339),
+		prev.Markdown, md,
+	)
+
+	return change, s.Save(next)
+}
+
+// DiffPage fetches the page of the url and returns its change since its last
+// snapshot.
+func (s *MCPServer) DiffPage(ctx context.Context, u string) (PageChange, error) {
+	if s.Snapshots.Dir == "" {
+		return PageChange{}, ErrNoSnapshots
+	}
+
+	at := time.Now()
+	// the whole content is compared, without truncation.
+	res, _, err := s.FetchPage(ctx, PageJob{
+		Op:  "diff_page",
+		URL: u,
+		Options: fetch.Options{
+			Timeout:           WatchPageTimeout,
+			FailOnErrorStatus: true,
+			Clicks:            s.Clicks,
+			ClickWait:         s.ClickWait,
+			DismissConsent:    s.DismissConsent,
+			Sites:             s.SiteExtractors,
+			Sanitize:          s.Sanitize,
+			Boilerplate:       s.Boilerplate,
+		},
+	})
+	if err != nil {
+		return PageChange{}, err
+	}
+
+	return s.Snapshots.Compare(u, res.Markdown, at)
+}
+
+// FormatPageChange returns the change of the page in text.
+func FormatPageChange(c PageChange) string {
+	switch {
+	case c.Since.IsZero():
+		return fmt.Sprintf("First snapshot of %s saved.", c.URL)
+	case !c.Changed:
+		return fmt.Sprintf("No change of %s since %s.", c.URL, c.Since.Format(time.RFC3339))
+	}
+
+	return fmt.Sprintf("%s changed since %s:\n\n%s", c.URL, c.Since.Format(time.RFC3339), c.Diff)
+}
+
+// runwatch executes the watch command.
+func runwatch(ctx context.Context, mcpsrv *MCPServer, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var (
+		interval = flags.Duration("interval", WatchDefaultInterval, "interval of the checks of the page")
+		once     = flags.Bool("once", false, "check the page once and exit")
+	)
+
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: watch [options] <url>\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("bad arguments")
+	}
+	if *interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	u := flags.Arg(0)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		c, err := mcpsrv.DiffPage(ctx, u)
+		switch {
+		case err != nil && *once:
+			return err
+		case err != nil:
+			// a failing check doesn't stop the watch.
+			slog.Error("watch", slog.String("url", u), slog.Any("err", err))
+		case c.Changed || c.Since.IsZero():
+			if _, err := fmt.Fprintf(stdout, "%s\n", FormatPageChange(c)); err != nil {
+				return err
+			}
+		default:
+			slog.Debug("watch: no change", slog.String("url", u))
+		}
+
+		if *once {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}